@@ -13,13 +13,17 @@ import (
 	"github.com/eko/gocache/lib/v4/store"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 // CachedCollection wraps Collection[T] and adds a transparent read-through
-// cache for Find, FindOne, CountDocuments, and CachedAggregate calls.
-// Write operations (InsertOne, UpdateOne, etc.) pass straight through to
-// the underlying collection — cache invalidation is either TTL-based or
-// driven by a ChangeStreamInvalidator.
+// cache for Find, FindOne, CountDocuments, CachedDistinctOf, and CachedAggregate
+// calls.
+// Every other method is the embedded Collection's, so write operations
+// (InsertOne, UpdateOne, etc.) pass straight through to the underlying
+// collection — cache invalidation is either TTL-based or driven by a
+// ChangeStreamInvalidator. Cached reads run inside the collection's
+// interceptors, which see cache hits as well as misses.
 //
 // Example (in-memory, TTL-only):
 //
@@ -27,7 +31,7 @@ import (
 //	cacheMgr := cache.New[[]byte](store)
 //	coll     := gmqb.WrapWithCache[User](db.Collection("users"), cacheMgr, 5*time.Minute)
 type CachedCollection[T any] struct {
-	inner   *Collection[T]
+	*Collection[T]
 	cache   cache.CacheInterface[[]byte]
 	ttl     time.Duration
 	metricP metrics.MetricsInterface // nil when metrics are not enabled
@@ -42,11 +46,12 @@ func WrapWithCache[T any](
 	coll *mongo.Collection,
 	cacheManager cache.CacheInterface[[]byte],
 	ttl time.Duration,
+	opts ...CollectionOpt,
 ) *CachedCollection[T] {
 	return &CachedCollection[T]{
-		inner: Wrap[T](coll),
-		cache: cacheManager,
-		ttl:   ttl,
+		Collection: Wrap[T](coll, opts...),
+		cache:      cacheManager,
+		ttl:        ttl,
 	}
 }
 
@@ -62,13 +67,14 @@ func WrapWithCacheAndMetrics[T any](
 	cacheManager cache.CacheInterface[[]byte],
 	ttl time.Duration,
 	metricsProvider metrics.MetricsInterface,
+	opts ...CollectionOpt,
 ) *CachedCollection[T] {
 	metricCache := cache.NewMetric[[]byte](metricsProvider, cacheManager)
 	return &CachedCollection[T]{
-		inner:   Wrap[T](coll),
-		cache:   metricCache,
-		ttl:     ttl,
-		metricP: metricsProvider,
+		Collection: Wrap[T](coll, opts...),
+		cache:      metricCache,
+		ttl:        ttl,
+		metricP:    metricsProvider,
 	}
 }
 
@@ -80,13 +86,13 @@ func (c *CachedCollection[T]) CacheMetrics() metrics.MetricsInterface {
 
 // Unwrap returns the underlying typed Collection[T].
 func (c *CachedCollection[T]) Unwrap() *Collection[T] {
-	return c.inner
+	return c.Collection
 }
 
 // collectionTag returns the gocache tag used for all entries belonging to this
 // collection so InvalidateByTags can flush them all at once.
 func (c *CachedCollection[T]) collectionTag() string {
-	col := c.inner.Unwrap()
+	col := c.coll
 	return col.Database().Name() + "." + col.Name()
 }
 
@@ -106,7 +112,12 @@ func cacheKey(prefix string, parts ...any) (string, error) {
 }
 
 // filterKey serialises a Filter to a stable string via Extended JSON.
+// Filters bound from a FilterTemplate use the template identity and parameter
+// values instead.
 func filterKey(f Filter) (string, error) {
+	if f.bound != nil && f.bound.key != "" {
+		return f.bound.key, nil
+	}
 	raw, err := bson.MarshalExtJSON(f.BsonD(), false, false)
 	if err != nil {
 		return "", err
@@ -115,7 +126,12 @@ func filterKey(f Filter) (string, error) {
 }
 
 // pipelineKey serialises a Pipeline into a stable Extended JSON string.
+// Pipelines bound from a PipelineTemplate use the template identity and
+// parameter values instead.
 func pipelineKey(p Pipeline) (string, error) {
+	if p.bound != nil && p.bound.key != "" {
+		return p.bound.key, nil
+	}
 	// Wrap the pipeline in a root document because bson.MarshalExtJSON
 	// cannot directly marshal an array at the top level.
 	doc := bson.D{{Key: "pipeline", Value: p.BsonD()}}
@@ -126,121 +142,246 @@ func pipelineKey(p Pipeline) (string, error) {
 	return string(b), nil
 }
 
-// findOptsKey serialises FindOpts to a string for inclusion in the cache key.
-func findOptsKey(opts []FindOpt) (string, error) {
-	fo := buildFindOpts(opts)
-	b, err := json.Marshal(fo)
+// driverOptsKey serialises the driver options of type O a call sends, those
+// of the CallOpts of ctx overridden by the settings of cfg, to a string for
+// inclusion in the cache key. Calls that differ only in e.g. CallOpts.Let
+// thus get separate entries.
+func driverOptsKey[O any](ctx context.Context, cfg opConfig) (string, error) {
+	var o O
+	for _, l := range []options.Lister[O]{callOptions[O](ctx), driverOptions[O](cfg)} {
+		for _, fn := range l.List() {
+			_ = fn(&o)
+		}
+	}
+	return optsKey(o)
+}
+
+// optsKey marshals resolved driver options to JSON. The builders themselves
+// hold setter funcs and cannot be marshalled.
+func optsKey(o any) (string, error) {
+	b, err := json.Marshal(o)
 	if err != nil {
 		return "", err
 	}
 	return string(b), nil
 }
 
+// lookup reads key from the cache. Inside a transaction the cache is bypassed
+// so reads observe the transaction's own writes.
+func (c *CachedCollection[T]) lookup(ctx context.Context, key string) ([]byte, error) {
+	if InTransaction(ctx) {
+		return nil, store.NotFound{}
+	}
+	return c.cache.Get(ctx, key)
+}
+
+// store writes raw under key. Inside a transaction the write is deferred until
+// the transaction commits and dropped if it aborts.
+func (c *CachedCollection[T]) store(ctx context.Context, key string, raw []byte) {
+	afterCommit(ctx, func(ctx context.Context) {
+		_ = c.cache.Set(ctx, key, raw,
+			store.WithExpiration(c.ttl),
+			store.WithTags([]string{c.collectionTag()}),
+		)
+	})
+}
+
 // --- Read operations ---
 
 // Find returns all documents matching the filter, serving from cache on a hit.
-func (c *CachedCollection[T]) Find(ctx context.Context, filter Filter, opts ...FindOpt) ([]T, error) {
+func (c *CachedCollection[T]) Find(ctx context.Context, filter Filter, opts ...Opt) ([]T, error) {
+	return intercept(c.Collection, ctx, &OpInfo{Operation: OpFind, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) ([]T, error) {
+		return c.find(ctx, op)
+	})
+}
+
+// find serves a find from the cache, querying the collection on a miss.
+func (c *CachedCollection[T]) find(ctx context.Context, op *OpInfo) ([]T, error) {
+	filter := op.Filter
+	opts, err := buildOpts(op.Operation, optsOf[Opt](op))
+	if err != nil {
+		return nil, err
+	}
 	fk, err := filterKey(filter)
 	if err != nil {
-		return c.inner.Find(ctx, filter, opts...)
+		return c.Collection.find(ctx, filter, opts)
 	}
-	ok, err := findOptsKey(opts)
+	ok, err := driverOptsKey[options.FindOptions](ctx, opts)
 	if err != nil {
-		return c.inner.Find(ctx, filter, opts...)
+		return c.Collection.find(ctx, filter, opts)
 	}
 	key, err := cacheKey("find:"+c.collectionTag(), fk, ok)
 	if err != nil {
-		return c.inner.Find(ctx, filter, opts...)
+		return c.Collection.find(ctx, filter, opts)
 	}
 
-	if raw, cErr := c.cache.Get(ctx, key); cErr == nil {
+	if raw, cErr := c.lookup(ctx, key); cErr == nil {
 		var results []T
 		if json.Unmarshal(raw, &results) == nil {
+			op.Cache = CacheHit
 			return results, nil
 		}
 	}
 
-	results, err := c.inner.Find(ctx, filter, opts...)
+	op.Cache = CacheMiss
+	results, err := c.Collection.find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	if raw, mErr := json.Marshal(results); mErr == nil {
-		_ = c.cache.Set(ctx, key, raw,
-			store.WithExpiration(c.ttl),
-			store.WithTags([]string{c.collectionTag()}),
-		)
+		c.store(ctx, key, raw)
 	}
 	return results, nil
 }
 
 // FindOne returns a single document matching the filter, serving from cache on a hit.
 // Returns mongo.ErrNoDocuments if no document matches.
-func (c *CachedCollection[T]) FindOne(ctx context.Context, filter Filter, opts ...FindOpt) (*T, error) {
+func (c *CachedCollection[T]) FindOne(ctx context.Context, filter Filter, opts ...Opt) (*T, error) {
+	return intercept(c.Collection, ctx, &OpInfo{Operation: OpFindOne, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) (*T, error) {
+		return c.findOne(ctx, op)
+	})
+}
+
+// findOne serves a findOne from the cache, querying the collection on a miss.
+func (c *CachedCollection[T]) findOne(ctx context.Context, op *OpInfo) (*T, error) {
+	filter := op.Filter
+	opts, err := buildOpts(op.Operation, optsOf[Opt](op))
+	if err != nil {
+		return nil, err
+	}
 	fk, err := filterKey(filter)
 	if err != nil {
-		return c.inner.FindOne(ctx, filter, opts...)
+		return c.Collection.findOne(ctx, filter, opts)
 	}
-	ok, err2 := findOptsKey(opts)
+	ok, err2 := driverOptsKey[options.FindOneOptions](ctx, opts)
 	if err2 != nil {
-		return c.inner.FindOne(ctx, filter, opts...)
+		return c.Collection.findOne(ctx, filter, opts)
 	}
 	key, err3 := cacheKey("findone:"+c.collectionTag(), fk, string(ok))
 	if err3 != nil {
-		return c.inner.FindOne(ctx, filter, opts...)
+		return c.Collection.findOne(ctx, filter, opts)
 	}
 
-	if raw, cErr := c.cache.Get(ctx, key); cErr == nil {
+	if raw, cErr := c.lookup(ctx, key); cErr == nil {
 		// stored as a 1-element slice to distinguish "not found" from cache miss
 		var results []*T
 		if json.Unmarshal(raw, &results) == nil && len(results) == 1 {
+			op.Cache = CacheHit
 			return results[0], nil
 		}
 	}
 
-	result, err := c.inner.FindOne(ctx, filter, opts...)
+	op.Cache = CacheMiss
+	result, err := c.Collection.findOne(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	if raw, mErr := json.Marshal([]*T{result}); mErr == nil {
-		_ = c.cache.Set(ctx, key, raw,
-			store.WithExpiration(c.ttl),
-			store.WithTags([]string{c.collectionTag()}),
-		)
+		c.store(ctx, key, raw)
 	}
 	return result, nil
 }
 
 // CountDocuments returns the number of documents matching the filter, serving from cache on a hit.
-func (c *CachedCollection[T]) CountDocuments(ctx context.Context, filter Filter) (int64, error) {
+func (c *CachedCollection[T]) CountDocuments(ctx context.Context, filter Filter, opts ...Opt) (int64, error) {
+	return intercept(c.Collection, ctx, &OpInfo{Operation: OpCountDocuments, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) (int64, error) {
+		return c.countDocuments(ctx, op)
+	})
+}
+
+// countDocuments serves a count from the cache, querying the collection on a miss.
+func (c *CachedCollection[T]) countDocuments(ctx context.Context, op *OpInfo) (int64, error) {
+	filter := op.Filter
+	opts, err := buildOpts(op.Operation, optsOf[Opt](op))
+	if err != nil {
+		return 0, err
+	}
 	fk, err := filterKey(filter)
 	if err != nil {
-		return c.inner.CountDocuments(ctx, filter)
+		return c.Collection.countDocuments(ctx, filter, opts)
+	}
+	ok, err := driverOptsKey[options.CountOptions](ctx, opts)
+	if err != nil {
+		return c.Collection.countDocuments(ctx, filter, opts)
 	}
-	key, err2 := cacheKey("count:"+c.collectionTag(), fk)
+	key, err2 := cacheKey("count:"+c.collectionTag(), fk, ok)
 	if err2 != nil {
-		return c.inner.CountDocuments(ctx, filter)
+		return c.Collection.countDocuments(ctx, filter, opts)
 	}
 
-	if raw, cErr := c.cache.Get(ctx, key); cErr == nil && len(raw) == 8 {
+	if raw, cErr := c.lookup(ctx, key); cErr == nil && len(raw) == 8 {
+		op.Cache = CacheHit
 		return int64(binary.BigEndian.Uint64(raw)), nil
 	}
 
-	count, err := c.inner.CountDocuments(ctx, filter)
+	op.Cache = CacheMiss
+	count, err := c.Collection.countDocuments(ctx, filter, opts)
 	if err != nil {
 		return 0, err
 	}
 
 	buf := make([]byte, 8)
 	binary.BigEndian.PutUint64(buf, uint64(count))
-	_ = c.cache.Set(ctx, key, buf,
-		store.WithExpiration(c.ttl),
-		store.WithTags([]string{c.collectionTag()}),
-	)
+	c.store(ctx, key, buf)
 	return count, nil
 }
 
+// CachedDistinctOf returns the distinct values of field with caching. It
+// mirrors the package-level DistinctOf[V, T] function, keying entries on the
+// field, filter and options and tagging them like Find so InvalidateCache and
+// a ChangeStreamInvalidator flush them too.
+//
+// Example:
+//
+//	countries, err := gmqb.CachedDistinctOf[string](ctx, coll, "country", gmqb.Gte("age", 18))
+func CachedDistinctOf[V any, T any](ctx context.Context, c *CachedCollection[T], field string, filter Filter, opts ...Opt) ([]V, error) {
+	return intercept(c.Collection, ctx, &OpInfo{Operation: OpDistinct, Field: field, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) ([]V, error) {
+		return cachedDistinctOf[V](c, ctx, op)
+	})
+}
+
+// cachedDistinctOf serves a distinct from the cache, querying the collection on a miss.
+func cachedDistinctOf[V any, T any](c *CachedCollection[T], ctx context.Context, op *OpInfo) ([]V, error) {
+	field, filter := op.Field, op.Filter
+	opts, err := buildOpts(op.Operation, optsOf[Opt](op))
+	if err != nil {
+		return nil, err
+	}
+	fk, err := filterKey(filter)
+	if err != nil {
+		return distinctOf[V](c.Collection, ctx, field, filter, opts)
+	}
+	ok, err := driverOptsKey[options.DistinctOptions](ctx, opts)
+	if err != nil {
+		return distinctOf[V](c.Collection, ctx, field, filter, opts)
+	}
+	key, err := cacheKey("distinct:"+c.collectionTag(), field, fk, ok)
+	if err != nil {
+		return distinctOf[V](c.Collection, ctx, field, filter, opts)
+	}
+
+	if raw, cErr := c.lookup(ctx, key); cErr == nil {
+		var values []V
+		if json.Unmarshal(raw, &values) == nil && values != nil {
+			op.Cache = CacheHit
+			return values, nil
+		}
+	}
+
+	op.Cache = CacheMiss
+	values, err := distinctOf[V](c.Collection, ctx, field, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if raw, mErr := json.Marshal(values); mErr == nil {
+		c.store(ctx, key, raw)
+	}
+	return values, nil
+}
+
 // CachedAggregate runs an aggregation pipeline with caching.
 // It mirrors the package-level Aggregate[R, T] function.
 //
@@ -249,96 +390,64 @@ func (c *CachedCollection[T]) CountDocuments(ctx context.Context, filter Filter)
 //	stats, err := gmqb.CachedAggregate[Stats](coll, ctx,
 //	    gmqb.NewPipeline().Group(gmqb.GroupSpec("$country", gmqb.GroupAcc("count", gmqb.AccSum(1)))),
 //	)
-func CachedAggregate[R any, T any](c *CachedCollection[T], ctx context.Context, pipeline Pipeline) ([]R, error) {
+func CachedAggregate[R any, T any](c *CachedCollection[T], ctx context.Context, pipeline Pipeline, opts ...Opt) ([]R, error) {
+	if _, err := validateAggregate(pipeline, opts); err != nil {
+		return nil, err
+	}
+	return intercept(c.Collection, ctx, &OpInfo{Operation: OpAggregate, Pipeline: pipeline, Options: opts}, func(ctx context.Context, op *OpInfo) ([]R, error) {
+		return cachedAggregate[R](c, ctx, op)
+	})
+}
+
+// cachedAggregate serves an aggregation from the cache, running it on a miss.
+func cachedAggregate[R any, T any](c *CachedCollection[T], ctx context.Context, op *OpInfo) ([]R, error) {
+	pipeline := op.Pipeline
+	opts, err := buildOpts(op.Operation, optsOf[Opt](op))
+	if err != nil {
+		return nil, err
+	}
 	pk, err := pipelineKey(pipeline)
 	if err != nil {
-		return Aggregate[R](c.inner, ctx, pipeline)
+		return aggregate[R](c.Collection, ctx, pipeline, opts)
+	}
+	ok, err := driverOptsKey[options.AggregateOptions](ctx, opts)
+	if err != nil {
+		return aggregate[R](c.Collection, ctx, pipeline, opts)
 	}
-	key, err2 := cacheKey("aggregate:"+c.collectionTag(), pk)
+	key, err2 := cacheKey("aggregate:"+c.collectionTag(), pk, ok)
 	if err2 != nil {
-		return Aggregate[R](c.inner, ctx, pipeline)
+		return aggregate[R](c.Collection, ctx, pipeline, opts)
 	}
 
-	if raw, cErr := c.cache.Get(ctx, key); cErr == nil {
+	if raw, cErr := c.lookup(ctx, key); cErr == nil {
 		var results []R
 		if json.Unmarshal(raw, &results) == nil {
+			op.Cache = CacheHit
 			return results, nil
 		}
 	}
 
-	results, err := Aggregate[R](c.inner, ctx, pipeline)
+	op.Cache = CacheMiss
+	results, err := aggregate[R](c.Collection, ctx, pipeline, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	if raw, mErr := json.Marshal(results); mErr == nil {
-		_ = c.cache.Set(ctx, key, raw,
-			store.WithExpiration(c.ttl),
-			store.WithTags([]string{c.collectionTag()}),
-		)
+		c.store(ctx, key, raw)
 	}
 	return results, nil
 }
 
-// --- Write passthrough ---
-
-// InsertOne inserts a single document (no caching).
-func (c *CachedCollection[T]) InsertOne(ctx context.Context, doc *T) (*mongo.InsertOneResult, error) {
-	return c.inner.InsertOne(ctx, doc)
-}
-
-// InsertMany inserts multiple documents (no caching).
-func (c *CachedCollection[T]) InsertMany(ctx context.Context, docs []T) (*mongo.InsertManyResult, error) {
-	return c.inner.InsertMany(ctx, docs)
-}
-
-// UpdateOne updates a single matching document (no caching).
-func (c *CachedCollection[T]) UpdateOne(ctx context.Context, filter Filter, update Updater, opts ...UpdateOpt) (*mongo.UpdateResult, error) {
-	return c.inner.UpdateOne(ctx, filter, update, opts...)
-}
-
-// UpdateMany updates all matching documents (no caching).
-func (c *CachedCollection[T]) UpdateMany(ctx context.Context, filter Filter, update Updater, opts ...UpdateManyOpt) (*mongo.UpdateResult, error) {
-	return c.inner.UpdateMany(ctx, filter, update, opts...)
-}
-
-// DeleteOne deletes a single matching document (no caching).
-func (c *CachedCollection[T]) DeleteOne(ctx context.Context, filter Filter) (*mongo.DeleteResult, error) {
-	return c.inner.DeleteOne(ctx, filter)
-}
-
-// DeleteMany deletes all matching documents (no caching).
-func (c *CachedCollection[T]) DeleteMany(ctx context.Context, filter Filter) (*mongo.DeleteResult, error) {
-	return c.inner.DeleteMany(ctx, filter)
-}
-
-// FindOneAndDelete deletes a single matching document and returns it (no caching).
-func (c *CachedCollection[T]) FindOneAndDelete(ctx context.Context, filter Filter, opts ...FindOneAndDeleteOpt) (*T, error) {
-	return c.inner.FindOneAndDelete(ctx, filter, opts...)
-}
-
-// FindOneAndUpdate updates a single matching document and returns it (no caching).
-func (c *CachedCollection[T]) FindOneAndUpdate(ctx context.Context, filter Filter, update Updater, opts ...FindOneAndUpdateOpt) (*T, error) {
-	return c.inner.FindOneAndUpdate(ctx, filter, update, opts...)
-}
-
-// FindOneAndReplace replaces a single matching document and returns it (no caching).
-func (c *CachedCollection[T]) FindOneAndReplace(ctx context.Context, filter Filter, replacement *T, opts ...FindOneAndReplaceOpt) (*T, error) {
-	return c.inner.FindOneAndReplace(ctx, filter, replacement, opts...)
-}
-
-// BulkWrite performs multiple write operations (no caching).
-func (c *CachedCollection[T]) BulkWrite(ctx context.Context, models []WriteModel[T], opts ...BulkWriteOpt) (*mongo.BulkWriteResult, error) {
-	return c.inner.BulkWrite(ctx, models, opts...)
-}
-
-// Distinct returns distinct values for a field (no caching).
-func (c *CachedCollection[T]) Distinct(ctx context.Context, field string, filter Filter) *mongo.DistinctResult {
-	return c.inner.Distinct(ctx, field, filter)
-}
-
 // InvalidateCache flushes all cached entries for this collection.
 // Useful for manual invalidation in tests or after bulk operations.
+// Inside a transaction the flush is deferred until the transaction commits.
 func (c *CachedCollection[T]) InvalidateCache(ctx context.Context) error {
+	if InTransaction(ctx) {
+		afterCommit(ctx, func(ctx context.Context) {
+			_ = c.cache.Invalidate(ctx, store.WithInvalidateTags([]string{c.collectionTag()}))
+		})
+		return nil
+	}
 	return c.cache.Invalidate(ctx, store.WithInvalidateTags([]string{c.collectionTag()}))
 }
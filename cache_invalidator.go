@@ -2,7 +2,6 @@ package gmqb
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
@@ -32,20 +31,20 @@ import (
 //	    }
 //	}()
 type ChangeStreamInvalidator struct {
-	coll           *mongo.Collection
-	cache          cache.CacheInterface[[]byte]
-	tag            string // e.g. "mydb.users"
-	resumeTokenKey string // cache key for persisting the resume token
+	coll        *mongo.Collection
+	cache       cache.CacheInterface[[]byte]
+	tag         string // e.g. "mydb.users"
+	checkpoints *CacheCheckpointStore
 }
 
 // NewChangeStreamInvalidator creates a ChangeStreamInvalidator for coll.
 func NewChangeStreamInvalidator(coll *mongo.Collection, cacheManager cache.CacheInterface[[]byte]) *ChangeStreamInvalidator {
 	tag := coll.Database().Name() + "." + coll.Name()
 	return &ChangeStreamInvalidator{
-		coll:           coll,
-		cache:          cacheManager,
-		tag:            tag,
-		resumeTokenKey: "gmqb::resumetoken::" + tag,
+		coll:        coll,
+		cache:       cacheManager,
+		tag:         tag,
+		checkpoints: NewCacheCheckpointStore(cacheManager),
 	}
 }
 
@@ -125,25 +124,11 @@ func (inv *ChangeStreamInvalidator) watch(ctx context.Context) error {
 
 // saveResumeToken persists the resume token to the cache with no expiry.
 func (inv *ChangeStreamInvalidator) saveResumeToken(ctx context.Context, token bson.Raw) {
-	if token == nil {
-		return
-	}
-	b, err := json.Marshal(token)
-	if err != nil {
-		return
-	}
-	_ = inv.cache.Set(ctx, inv.resumeTokenKey, b, store.WithExpiration(0))
+	_ = inv.checkpoints.SaveCheckpoint(ctx, inv.tag, token)
 }
 
 // loadResumeToken retrieves a previously persisted resume token, or nil.
 func (inv *ChangeStreamInvalidator) loadResumeToken(ctx context.Context) bson.Raw {
-	raw, err := inv.cache.Get(ctx, inv.resumeTokenKey)
-	if err != nil || len(raw) == 0 {
-		return nil
-	}
-	var token bson.Raw
-	if err := json.Unmarshal(raw, &token); err != nil {
-		return nil
-	}
+	token, _ := inv.checkpoints.LoadCheckpoint(ctx, inv.tag)
 	return token
 }
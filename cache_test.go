@@ -157,6 +157,42 @@ func TestCache_CountDocuments_CacheMiss_ThenHit(t *testing.T) {
 	assert.Equal(t, int64(2), count2, "cache hit expected")
 }
 
+// --- CachedDistinctOf ---
+
+func TestCache_CachedDistinctOf_CacheMiss_ThenHit(t *testing.T) {
+	coll := cachedUsers(t, time.Minute)
+	ctx := context.Background()
+
+	_, err := coll.InsertMany(ctx, []User{
+		{Name: "Alice", Country: "US"},
+		{Name: "Bob", Country: "UK"},
+	})
+	require.NoError(t, err)
+
+	countries, err := gmqb.CachedDistinctOf[string](ctx, coll, "country", gmqb.NewFilter())
+	require.NoError(t, err)
+	assert.Len(t, countries, 2)
+
+	_, err = coll.InsertOne(ctx, &User{Name: "Carol", Country: "DE"})
+	require.NoError(t, err)
+
+	// Same field and filter — served from cache.
+	countries, err = gmqb.CachedDistinctOf[string](ctx, coll, "country", gmqb.NewFilter())
+	require.NoError(t, err)
+	assert.Len(t, countries, 2, "cache hit: stale result expected")
+
+	// A different field is a different key.
+	names, err := gmqb.CachedDistinctOf[string](ctx, coll, "name", gmqb.NewFilter())
+	require.NoError(t, err)
+	assert.Len(t, names, 3)
+
+	// Entries share the collection tag with Find.
+	require.NoError(t, coll.InvalidateCache(ctx))
+	countries, err = gmqb.CachedDistinctOf[string](ctx, coll, "country", gmqb.NewFilter())
+	require.NoError(t, err)
+	assert.Len(t, countries, 3)
+}
+
 // --- CachedAggregate ---
 
 func TestCache_CachedAggregate_CacheMiss_ThenHit(t *testing.T) {
@@ -308,3 +344,26 @@ func (s *stubMetrics) RecordFromCodec(_ codec.CodecInterface) {
 		s.onHit()
 	}
 }
+
+func TestCache_InterceptorSeesCacheResult(t *testing.T) {
+	ctx := context.Background()
+	coll := testDB.Collection(t.Name())
+	_ = coll.Drop(ctx)
+
+	var results []gmqb.CacheResult
+	record := func(ctx context.Context, op *gmqb.OpInfo, next gmqb.Invoker) error {
+		err := next(ctx, op)
+		results = append(results, op.Cache)
+		return err
+	}
+	cached := gmqb.WrapWithCache[User](coll, newInMemCache(), time.Minute, gmqb.WithInterceptors(record))
+
+	_, err := cached.CountDocuments(ctx, gmqb.Eq("active", true))
+	require.NoError(t, err)
+	_, err = cached.CountDocuments(ctx, gmqb.Eq("active", true))
+	require.NoError(t, err)
+	_, err = cached.InsertOne(ctx, &User{Name: "Dan"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []gmqb.CacheResult{gmqb.CacheMiss, gmqb.CacheHit, ""}, results)
+}
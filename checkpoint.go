@@ -0,0 +1,114 @@
+package gmqb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/eko/gocache/lib/v4/cache"
+	"github.com/eko/gocache/lib/v4/store"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// CheckpointStore persists change stream resume tokens under a key so a
+// stream can continue where it left off after a restart.
+type CheckpointStore interface {
+	// LoadCheckpoint returns the token saved under key, or nil if there is none.
+	LoadCheckpoint(ctx context.Context, key string) (bson.Raw, error)
+	// SaveCheckpoint stores token under key, replacing any previous token.
+	SaveCheckpoint(ctx context.Context, key string, token bson.Raw) error
+}
+
+// MongoCheckpointStore keeps resume tokens in a MongoDB collection, one
+// document per key: { _id: key, token: <resume token>, t: <saved at> }.
+type MongoCheckpointStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoCheckpointStore creates a CheckpointStore backed by coll.
+//
+// Example:
+//
+//	store := gmqb.NewMongoCheckpointStore(db.Collection("gmqb_checkpoints"))
+func NewMongoCheckpointStore(coll *mongo.Collection) *MongoCheckpointStore {
+	return &MongoCheckpointStore{coll: coll}
+}
+
+type checkpointDoc struct {
+	Key   string    `bson:"_id"`
+	Token bson.Raw  `bson:"token"`
+	At    time.Time `bson:"t"`
+}
+
+// LoadCheckpoint implements CheckpointStore.
+func (s *MongoCheckpointStore) LoadCheckpoint(ctx context.Context, key string) (bson.Raw, error) {
+	var doc checkpointDoc
+	err := s.coll.FindOne(ctx, bson.D{{Key: "_id", Value: key}}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.Token, nil
+}
+
+// SaveCheckpoint implements CheckpointStore.
+func (s *MongoCheckpointStore) SaveCheckpoint(ctx context.Context, key string, token bson.Raw) error {
+	_, err := s.coll.ReplaceOne(ctx,
+		bson.D{{Key: "_id", Value: key}},
+		checkpointDoc{Key: key, Token: token, At: time.Now()},
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// CacheCheckpointStore keeps resume tokens in a gocache backend with no
+// expiry. It is what ChangeStreamInvalidator uses, so a stream can resume
+// without an extra state store.
+type CacheCheckpointStore struct {
+	cache cache.CacheInterface[[]byte]
+}
+
+// NewCacheCheckpointStore creates a CheckpointStore backed by cacheManager.
+// Keys are stored with a "gmqb::resumetoken::" prefix.
+//
+// Example:
+//
+//	store := gmqb.NewCacheCheckpointStore(cacheMgr)
+func NewCacheCheckpointStore(cacheManager cache.CacheInterface[[]byte]) *CacheCheckpointStore {
+	return &CacheCheckpointStore{cache: cacheManager}
+}
+
+func (s *CacheCheckpointStore) cacheKey(key string) string {
+	return "gmqb::resumetoken::" + key
+}
+
+// LoadCheckpoint implements CheckpointStore. A missing or unreadable entry is
+// reported as no checkpoint.
+func (s *CacheCheckpointStore) LoadCheckpoint(ctx context.Context, key string) (bson.Raw, error) {
+	raw, err := s.cache.Get(ctx, s.cacheKey(key))
+	if err != nil || len(raw) == 0 {
+		return nil, nil
+	}
+	var token bson.Raw
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return nil, nil
+	}
+	return token, nil
+}
+
+// SaveCheckpoint implements CheckpointStore.
+func (s *CacheCheckpointStore) SaveCheckpoint(ctx context.Context, key string, token bson.Raw) error {
+	if token == nil {
+		return nil
+	}
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return s.cache.Set(ctx, s.cacheKey(key), b, store.WithExpiration(0))
+}
@@ -0,0 +1,518 @@
+package gmqb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Server limits on a single write command.
+const (
+	// maxBatchCount is the server's maxWriteBatchSize.
+	maxBatchCount = 100_000
+	// maxBatchBytes is the server's maxMessageSizeBytes.
+	maxBatchBytes = 48_000_000
+	// writeOverhead allows for the encoding of a write around its documents
+	// when sizing batches.
+	writeOverhead = 64
+)
+
+// labelRetryableWrite marks write errors that are safe to retry.
+const labelRetryableWrite = "RetryableWriteError"
+
+// ChunkOpts configures InsertManyChunked and BulkWriteChunked.
+type ChunkOpts struct {
+	// MaxBatchCount bounds the number of writes in one batch. Default and
+	// maximum is 100,000, the server's maxWriteBatchSize.
+	MaxBatchCount int
+	// MaxBatchBytes bounds the encoded size of one batch. Default and maximum
+	// is 48MB, the server's maxMessageSizeBytes.
+	MaxBatchBytes int
+	// Concurrency is the number of batches written at once. Default is 1.
+	// Inside a transaction batches are always written one at a time.
+	Concurrency int
+	// MaxRetries bounds how many times a batch is resent after a transient
+	// failure, such as a network error or a RetryableWriteError. Default is
+	// 3; a negative value disables retries. Batches are not retried inside a
+	// transaction, where WithTransaction retries the whole transaction.
+	MaxRetries int
+	// MinBackoff is the delay before the first retry of a batch. Each further
+	// retry doubles it, with jitter, up to MaxBackoff. Default is 100ms.
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Default is 5s.
+	MaxBackoff time.Duration
+}
+
+// DefaultChunkOpts provides sensible defaults for InsertManyChunked and
+// BulkWriteChunked.
+var DefaultChunkOpts = ChunkOpts{
+	MaxBatchCount: maxBatchCount,
+	MaxBatchBytes: maxBatchBytes,
+	Concurrency:   1,
+	MaxRetries:    3,
+	MinBackoff:    100 * time.Millisecond,
+	MaxBackoff:    5 * time.Second,
+}
+
+func (o ChunkOpts) withDefaults() ChunkOpts {
+	if o.MaxBatchCount <= 0 || o.MaxBatchCount > maxBatchCount {
+		o.MaxBatchCount = maxBatchCount
+	}
+	if o.MaxBatchBytes <= 0 || o.MaxBatchBytes > maxBatchBytes {
+		o.MaxBatchBytes = maxBatchBytes
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultChunkOpts.Concurrency
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = DefaultChunkOpts.MaxRetries
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = DefaultChunkOpts.MinBackoff
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = max(DefaultChunkOpts.MaxBackoff, o.MinBackoff)
+	}
+	return o
+}
+
+// ChunkedResult aggregates the results of the batches of a chunked write.
+// Indexes refer to positions in the slice passed to the method.
+type ChunkedResult struct {
+	// InsertedCount, MatchedCount, ModifiedCount, DeletedCount and
+	// UpsertedCount sum the counts reported for every batch.
+	InsertedCount int64
+	MatchedCount  int64
+	ModifiedCount int64
+	DeletedCount  int64
+	UpsertedCount int64
+	// InsertedIDs maps the index of each document written by
+	// InsertManyChunked to its _id.
+	InsertedIDs map[int]any
+	// UpsertedIDs maps the index of each upsert that inserted a document to
+	// the new document's _id.
+	UpsertedIDs map[int]any
+	// Batches is the number of batches sent, not counting retries.
+	Batches int
+	// Failed maps the index of each input that was not written to its error:
+	// a mongo.WriteError when the server rejected the write, e.g. for a
+	// duplicate key or a failed validation, or the error that failed its
+	// whole batch. The writes of a batch whose write concern was not
+	// satisfied are reported with the mongo.WriteConcernError even though the
+	// server may have applied them.
+	Failed map[int]error
+}
+
+// err reports the failed writes of r out of total, or nil.
+func (r *ChunkedResult) err(total int) error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d writes failed", ErrPartialWrite, len(r.Failed), total)
+}
+
+// InsertManyChunked inserts docs in batches bounded by opts.MaxBatchCount
+// writes and opts.MaxBatchBytes encoded bytes, so inputs of any size can be
+// inserted in one call. Batches are written unordered, up to
+// opts.Concurrency at a time, and each runs through the interceptors as an
+// OpInsertMany of its documents. A batch that fails transiently is resent up
+// to opts.MaxRetries times.
+//
+// Documents without an _id are assigned one before they are sent, so a
+// resent batch does not insert them twice: a duplicate key on a generated
+// _id after a retry counts as inserted.
+//
+// The result maps every input that was not inserted to its error. When any
+// input failed, the returned error wraps ErrPartialWrite and the result is
+// still returned.
+//
+// See: https://www.mongodb.com/docs/manual/reference/limits/#mongodb-limit-Write-Command-Batch-Limit-Size
+//
+// Example:
+//
+//	res, err := coll.InsertManyChunked(ctx, users, gmqb.ChunkOpts{Concurrency: 4})
+//	if errors.Is(err, gmqb.ErrPartialWrite) {
+//	    for i, werr := range res.Failed {
+//	        log.Printf("user %d: %v", i, werr)
+//	    }
+//	}
+func (c *Collection[T]) InsertManyChunked(ctx context.Context, docs []T, opts ChunkOpts) (*ChunkedResult, error) {
+	opts = opts.withDefaults()
+	res := newChunkedResult()
+	if len(docs) == 0 {
+		return res, nil
+	}
+	ts, err := timestampsOf[T]()
+	if err != nil {
+		return nil, err
+	}
+	now := timestampNow()
+	raws := make([]bson.Raw, len(docs))
+	ids := make([]any, len(docs))
+	generated := make([]bool, len(docs))
+	sizes := make([]int, len(docs))
+	for i := range docs {
+		ts.stampInsert(reflect.ValueOf(&docs[i]), now)
+		raw, id, gen, err := encodeWithID(docs[i])
+		if err != nil {
+			res.Failed[i] = err
+			continue
+		}
+		raws[i], ids[i], generated[i], sizes[i] = raw, id, gen, len(raw)+writeOverhead
+	}
+
+	write := func(ctx context.Context, batch []int) (*mongo.BulkWriteResult, int, error) {
+		batchDocs := make([]T, len(batch))
+		for j, i := range batch {
+			batchDocs[j] = docs[i]
+		}
+		attempts := 0
+		bres, err := intercept(c, ctx, &OpInfo{Operation: OpInsertMany, Document: batchDocs, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.BulkWriteResult, error) {
+			models, err := insertModels(op.Document, batchDocs, batch, raws)
+			if err != nil {
+				return nil, err
+			}
+			return c.writeChunk(ctx, models, opts, &attempts)
+		})
+		return bres, attempts, err
+	}
+	record := func(batch []int, bres *mongo.BulkWriteResult, attempts int, err error) {
+		failed := res.merge(batch, bres, attempts, err, func(i int, we mongo.WriteError) bool {
+			return attempts > 1 && generated[i] && isDuplicateID(we)
+		})
+		for _, i := range batch {
+			if !failed[i] {
+				res.InsertedIDs[i] = ids[i]
+			}
+		}
+	}
+	c.runChunks(ctx, splitChunks(sizes, res.Failed, opts), opts, write, record)
+	return res, res.err(len(docs))
+}
+
+// BulkWriteChunked performs models in batches bounded by opts.MaxBatchCount
+// writes and opts.MaxBatchBytes encoded bytes. Batches are written
+// unordered, up to opts.Concurrency at a time, and each runs through the
+// interceptors as an OpBulkWrite of its models. A batch that fails
+// transiently is resent whole up to opts.MaxRetries times, so its models
+// should be idempotent, e.g. upserts with $set rather than $inc.
+//
+// The result maps every model that was not applied to its error. When any
+// model failed, the returned error wraps ErrPartialWrite and the result is
+// still returned.
+//
+// Example:
+//
+//	res, err := coll.BulkWriteChunked(ctx, models, gmqb.DefaultChunkOpts)
+//	fmt.Println(res.MatchedCount, res.UpsertedCount, len(res.Failed))
+func (c *Collection[T]) BulkWriteChunked(ctx context.Context, models []WriteModel[T], opts ChunkOpts) (*ChunkedResult, error) {
+	opts = opts.withDefaults()
+	res := newChunkedResult()
+	if len(models) == 0 {
+		return res, nil
+	}
+	ts, err := timestampsOf[T]()
+	if err != nil {
+		return nil, err
+	}
+	now := timestampNow()
+	mongoModels := make([]mongo.WriteModel, len(models))
+	sizes := make([]int, len(models))
+	for i, m := range models {
+		mm, err := mongoWriteModel[T](m, ts, now)
+		if err == nil {
+			sizes[i], err = writeModelSize(mm)
+		}
+		if err != nil {
+			res.Failed[i] = err
+			continue
+		}
+		mongoModels[i] = mm
+	}
+
+	write := func(ctx context.Context, batch []int) (*mongo.BulkWriteResult, int, error) {
+		batchModels := make([]WriteModel[T], len(batch))
+		for j, i := range batch {
+			batchModels[j] = models[i]
+		}
+		attempts := 0
+		bres, err := intercept(c, ctx, &OpInfo{Operation: OpBulkWrite, Document: batchModels, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.BulkWriteResult, error) {
+			ms, _ := op.Document.([]WriteModel[T])
+			out := make([]mongo.WriteModel, len(ms))
+			if sameSlice(ms, batchModels) {
+				for j, i := range batch {
+					out[j] = mongoModels[i]
+				}
+			} else {
+				// An interceptor replaced the models.
+				for j, m := range ms {
+					var err error
+					if out[j], err = mongoWriteModel[T](m, ts, now); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return c.writeChunk(ctx, out, opts, &attempts)
+		})
+		return bres, attempts, err
+	}
+	record := func(batch []int, bres *mongo.BulkWriteResult, attempts int, err error) {
+		res.merge(batch, bres, attempts, err, nil)
+	}
+	c.runChunks(ctx, splitChunks(sizes, res.Failed, opts), opts, write, record)
+	return res, res.err(len(models))
+}
+
+func newChunkedResult() *ChunkedResult {
+	return &ChunkedResult{
+		InsertedIDs: map[int]any{},
+		UpsertedIDs: map[int]any{},
+		Failed:      map[int]error{},
+	}
+}
+
+// splitChunks groups the indexes of sizes, skipping those in failed, into
+// batches within the count and byte bounds of opts. An input larger than
+// MaxBatchBytes gets a batch of its own.
+func splitChunks(sizes []int, failed map[int]error, opts ChunkOpts) [][]int {
+	var chunks [][]int
+	var cur []int
+	curBytes := 0
+	for i, size := range sizes {
+		if _, ok := failed[i]; ok {
+			continue
+		}
+		if len(cur) > 0 && (len(cur) >= opts.MaxBatchCount || curBytes+size > opts.MaxBatchBytes) {
+			chunks = append(chunks, cur)
+			cur, curBytes = nil, 0
+		}
+		cur = append(cur, i)
+		curBytes += size
+	}
+	if len(cur) > 0 {
+		chunks = append(chunks, cur)
+	}
+	return chunks
+}
+
+// runChunks writes chunks up to opts.Concurrency at a time, passing the
+// outcome of each to record. Chunks not started before ctx is done are
+// recorded with the context's error. record is never called concurrently.
+func (c *Collection[T]) runChunks(
+	ctx context.Context,
+	chunks [][]int,
+	opts ChunkOpts,
+	write func(ctx context.Context, batch []int) (*mongo.BulkWriteResult, int, error),
+	record func(batch []int, res *mongo.BulkWriteResult, attempts int, err error),
+) {
+	workers := opts.Concurrency
+	if InTransaction(ctx) {
+		// A session must not be used concurrently.
+		workers = 1
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, workers)
+	)
+	for _, batch := range chunks {
+		acquired := false
+		select {
+		case sem <- struct{}{}:
+			acquired = true
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			if acquired {
+				<-sem
+			}
+			mu.Lock()
+			record(batch, nil, 0, err)
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			res, attempts, err := write(ctx, batch)
+			mu.Lock()
+			record(batch, res, attempts, err)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
+
+// writeChunk sends models as one unordered bulk write, resending it after a
+// transient failure. attempts counts the sends.
+func (c *Collection[T]) writeChunk(ctx context.Context, models []mongo.WriteModel, opts ChunkOpts, attempts *int) (*mongo.BulkWriteResult, error) {
+	backoff := TxOpts{MinBackoff: opts.MinBackoff, MaxBackoff: opts.MaxBackoff}
+	for attempt := 0; ; attempt++ {
+		*attempts++
+		res, err := c.collFor(ctx).BulkWrite(ctx, models, callOptions[options.BulkWriteOptions](ctx), options.BulkWrite().SetOrdered(false))
+		if err == nil || !isTransientWriteError(err) || attempt >= opts.MaxRetries || InTransaction(ctx) {
+			return res, err
+		}
+		if werr := backoff.wait(ctx, attempt); werr != nil {
+			return res, err
+		}
+	}
+}
+
+// merge adds the outcome of a batch sent attempts times to r and returns the
+// indexes of its inputs that failed. ignore, when set, reports write errors
+// to count as successful inserts instead.
+func (r *ChunkedResult) merge(batch []int, res *mongo.BulkWriteResult, attempts int, err error, ignore func(i int, we mongo.WriteError) bool) map[int]bool {
+	if attempts > 0 {
+		r.Batches++
+	}
+	if res != nil {
+		r.InsertedCount += res.InsertedCount
+		r.MatchedCount += res.MatchedCount
+		r.ModifiedCount += res.ModifiedCount
+		r.DeletedCount += res.DeletedCount
+		r.UpsertedCount += res.UpsertedCount
+		for j, id := range res.UpsertedIDs {
+			if int(j) < len(batch) {
+				r.UpsertedIDs[batch[j]] = id
+			}
+		}
+	}
+	failed := map[int]bool{}
+	if err == nil {
+		return failed
+	}
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) || (len(bwe.WriteErrors) == 0 && bwe.WriteConcernError == nil) {
+		// The batch failed as a whole.
+		for _, i := range batch {
+			r.Failed[i] = err
+			failed[i] = true
+		}
+		return failed
+	}
+	for _, we := range bwe.WriteErrors {
+		if we.Index < 0 || we.Index >= len(batch) {
+			continue
+		}
+		i := batch[we.Index]
+		if ignore != nil && ignore(i, we.WriteError) {
+			r.InsertedCount++
+			continue
+		}
+		r.Failed[i] = we.WriteError
+		failed[i] = true
+	}
+	if wce := bwe.WriteConcernError; wce != nil {
+		for _, i := range batch {
+			if !failed[i] {
+				r.Failed[i] = *wce
+				failed[i] = true
+			}
+		}
+	}
+	return failed
+}
+
+// insertModels returns the insert models for the documents of a batch. While
+// docs is still the batch handed to the interceptors, the documents encoded
+// up front are used; if an interceptor replaced them they are encoded again.
+func insertModels[T any](docs any, batchDocs []T, batch []int, raws []bson.Raw) ([]mongo.WriteModel, error) {
+	ds, _ := docs.([]T)
+	models := make([]mongo.WriteModel, len(ds))
+	if sameSlice(ds, batchDocs) {
+		for j, i := range batch {
+			models[j] = mongo.NewInsertOneModel().SetDocument(raws[i])
+		}
+		return models, nil
+	}
+	for j := range ds {
+		raw, _, _, err := encodeWithID(ds[j])
+		if err != nil {
+			return nil, err
+		}
+		models[j] = mongo.NewInsertOneModel().SetDocument(raw)
+	}
+	return models, nil
+}
+
+// sameSlice reports whether a and b are the same slice.
+func sameSlice[E any](a, b []E) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// encodeWithID marshals doc and returns it with its _id. A document without
+// an _id is given a new ObjectID, and generated reports so.
+func encodeWithID(doc any) (raw bson.Raw, id any, generated bool, err error) {
+	raw, err = bson.Marshal(doc)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("gmqb: encode document: %w", err)
+	}
+	if v, err := raw.LookupErr("_id"); err == nil {
+		return raw, v, false, nil
+	}
+	oid := bson.NewObjectID()
+	head, err := bson.Marshal(bson.D{{Key: "_id", Value: oid}})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	// Splice the _id element in front of the document's elements.
+	out := make([]byte, 0, len(head)+len(raw)-5)
+	out = append(out, 0, 0, 0, 0)
+	out = append(out, head[4:len(head)-1]...)
+	out = append(out, raw[4:]...)
+	binary.LittleEndian.PutUint32(out, uint32(len(out)))
+	return out, oid, true, nil
+}
+
+// writeModelSize estimates the encoded size of m in a write command.
+func writeModelSize(m mongo.WriteModel) (int, error) {
+	var parts bson.D
+	switch m := m.(type) {
+	case *mongo.InsertOneModel:
+		parts = bson.D{{Key: "d", Value: m.Document}}
+	case *mongo.ReplaceOneModel:
+		parts = bson.D{{Key: "q", Value: m.Filter}, {Key: "u", Value: m.Replacement}}
+	case *mongo.UpdateOneModel:
+		parts = bson.D{{Key: "q", Value: m.Filter}, {Key: "u", Value: m.Update}, {Key: "a", Value: m.ArrayFilters}}
+	case *mongo.UpdateManyModel:
+		parts = bson.D{{Key: "q", Value: m.Filter}, {Key: "u", Value: m.Update}, {Key: "a", Value: m.ArrayFilters}}
+	case *mongo.DeleteOneModel:
+		parts = bson.D{{Key: "q", Value: m.Filter}}
+	case *mongo.DeleteManyModel:
+		parts = bson.D{{Key: "q", Value: m.Filter}}
+	default:
+		return 0, fmt.Errorf("gmqb: unsupported write model %T", m)
+	}
+	raw, err := bson.Marshal(parts)
+	if err != nil {
+		return 0, fmt.Errorf("gmqb: encode write model: %w", err)
+	}
+	return len(raw) + writeOverhead, nil
+}
+
+// isTransientWriteError reports whether a failed write may succeed if resent.
+func isTransientWriteError(err error) bool {
+	return mongo.IsNetworkError(err) ||
+		hasErrorLabel(err, labelRetryableWrite) ||
+		hasErrorLabel(err, labelTransientTransaction)
+}
+
+// isDuplicateID reports whether we is a duplicate key error on the _id index.
+func isDuplicateID(we mongo.WriteError) bool {
+	if !mongo.IsDuplicateKeyError(we) {
+		return false
+	}
+	if _, err := we.Raw.LookupErr("keyPattern", "_id"); err == nil {
+		return true
+	}
+	return we.HasErrorMessage(" index: _id_ ")
+}
@@ -0,0 +1,81 @@
+package gmqb_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/squall-chua/gmqb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestIntegration_InsertManyChunked(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+	_, err := coll.CreateIndex(ctx, gmqb.NewIndex(gmqb.Asc("email")).Unique())
+	require.NoError(t, err)
+
+	users := make([]User, 2500)
+	for i := range users {
+		users[i] = User{Name: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("u%d@example.com", i)}
+	}
+	// Every 1000th user repeats the first user's email.
+	for i := 1000; i < len(users); i += 1000 {
+		users[i].Email = users[0].Email
+	}
+
+	res, err := coll.InsertManyChunked(ctx, users, gmqb.ChunkOpts{MaxBatchCount: 300, Concurrency: 4})
+	assert.ErrorIs(t, err, gmqb.ErrPartialWrite)
+	assert.Equal(t, 9, res.Batches)
+	assert.Equal(t, int64(2498), res.InsertedCount)
+	assert.Len(t, res.InsertedIDs, 2498)
+	require.Len(t, res.Failed, 2)
+	assert.True(t, mongo.IsDuplicateKeyError(res.Failed[1000]))
+	assert.True(t, mongo.IsDuplicateKeyError(res.Failed[2000]))
+
+	n, err := coll.CountDocuments(ctx, gmqb.NewFilter())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2498), n)
+
+	// The reported _id is the stored document's.
+	got, err := coll.FindOne(ctx, gmqb.Eq("email", users[1234].Email))
+	require.NoError(t, err)
+	assert.Equal(t, res.InsertedIDs[1234], got.ID)
+}
+
+func TestIntegration_BulkWriteChunked(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+	seedUsers(t, coll)
+
+	var models []gmqb.WriteModel[User]
+	for i := range 10 {
+		models = append(models, gmqb.NewUpdateOneModel[User]().
+			SetFilter(gmqb.Eq("email", fmt.Sprintf("new%d@example.com", i))).
+			SetUpdate(gmqb.NewUpdate().Set("name", fmt.Sprintf("new%d", i))).
+			SetUpsert(true))
+	}
+	models = append(models,
+		gmqb.NewUpdateOneModel[User]().SetFilter(gmqb.Eq("name", "Alice")).SetUpdate(gmqb.NewUpdate().Set("age", 31)),
+		// $inc on a string field is rejected by the server.
+		gmqb.NewUpdateOneModel[User]().SetFilter(gmqb.Eq("name", "Bob")).SetUpdate(gmqb.NewUpdate().Inc("name", 1)),
+		gmqb.NewDeleteOneModel[User]().SetFilter(gmqb.Eq("name", "Eve")),
+	)
+
+	res, err := coll.BulkWriteChunked(ctx, models, gmqb.ChunkOpts{MaxBatchCount: 4, Concurrency: 2})
+	assert.ErrorIs(t, err, gmqb.ErrPartialWrite)
+	assert.Equal(t, 4, res.Batches)
+	assert.Equal(t, int64(10), res.UpsertedCount)
+	assert.Len(t, res.UpsertedIDs, 10)
+	assert.Contains(t, res.UpsertedIDs, 9)
+	assert.Equal(t, int64(1), res.ModifiedCount)
+	assert.Equal(t, int64(1), res.DeletedCount)
+	require.Len(t, res.Failed, 1)
+	assert.Contains(t, res.Failed, 11)
+
+	n, err := coll.CountDocuments(ctx, gmqb.NewFilter())
+	require.NoError(t, err)
+	assert.Equal(t, int64(14), n)
+}
@@ -0,0 +1,183 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestSplitChunks(t *testing.T) {
+	opts := ChunkOpts{MaxBatchCount: 3, MaxBatchBytes: 100}.withDefaults()
+	sizes := []int{10, 10, 10, 10, 60, 50, 200, 10}
+	failed := map[int]error{3: errors.New("bad")}
+	assert.Equal(t, [][]int{{0, 1, 2}, {4}, {5}, {6}, {7}}, splitChunks(sizes, failed, opts))
+	assert.Nil(t, splitChunks(nil, nil, opts))
+}
+
+func TestChunkOpts_Defaults(t *testing.T) {
+	o := ChunkOpts{MaxBatchCount: 1 << 30, MaxRetries: -1}.withDefaults()
+	assert.Equal(t, maxBatchCount, o.MaxBatchCount)
+	assert.Equal(t, maxBatchBytes, o.MaxBatchBytes)
+	assert.Equal(t, 1, o.Concurrency)
+	assert.Equal(t, -1, o.MaxRetries)
+	assert.Equal(t, DefaultChunkOpts.MinBackoff, o.MinBackoff)
+}
+
+func TestEncodeWithID(t *testing.T) {
+	type doc struct {
+		ID   bson.ObjectID `bson:"_id,omitempty"`
+		Name string        `bson:"name"`
+	}
+	raw, id, generated, err := encodeWithID(doc{Name: "a"})
+	require.NoError(t, err)
+	assert.True(t, generated)
+	var got doc
+	require.NoError(t, bson.Unmarshal(raw, &got))
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, "a", got.Name)
+	assert.Equal(t, "_id", raw.Index(0).Key())
+
+	own := bson.NewObjectID()
+	_, id, generated, err = encodeWithID(doc{ID: own})
+	require.NoError(t, err)
+	assert.False(t, generated)
+	assert.Equal(t, own, id.(bson.RawValue).ObjectID())
+
+	_, _, _, err = encodeWithID(make(chan int))
+	assert.Error(t, err)
+}
+
+func TestWriteModelSize(t *testing.T) {
+	small, err := writeModelSize(mongo.NewDeleteOneModel().SetFilter(bson.D{{Key: "a", Value: 1}}))
+	require.NoError(t, err)
+	big, err := writeModelSize(mongo.NewUpdateOneModel().
+		SetFilter(bson.D{{Key: "a", Value: 1}}).
+		SetUpdate(bson.D{{Key: "$set", Value: bson.D{{Key: "b", Value: string(make([]byte, 1000))}}}}))
+	require.NoError(t, err)
+	assert.Greater(t, small, writeOverhead)
+	assert.Greater(t, big, small+1000)
+
+	_, err = writeModelSize(mongo.NewInsertOneModel().SetDocument(make(chan int)))
+	assert.Error(t, err)
+}
+
+func TestChunkedResult_Merge(t *testing.T) {
+	r := newChunkedResult()
+	batch := []int{10, 11, 12, 13}
+	dup := mongo.WriteError{Index: 1, Code: 11000, Message: "E11000 duplicate key error collection: shop.orders index: _id_ dup key"}
+	invalid := mongo.WriteError{Index: 3, Code: 121, Message: "Document failed validation"}
+	failed := r.merge(batch, &mongo.BulkWriteResult{InsertedCount: 2, UpsertedIDs: map[int64]any{0: "x"}}, 1,
+		mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: dup}, {WriteError: invalid}}}, nil)
+	assert.Equal(t, map[int]bool{11: true, 13: true}, failed)
+	assert.Equal(t, dup, r.Failed[11])
+	assert.Equal(t, "x", r.UpsertedIDs[10])
+	assert.Equal(t, int64(2), r.InsertedCount)
+	assert.Equal(t, 1, r.Batches)
+	assert.True(t, mongo.IsDuplicateKeyError(r.Failed[11]))
+
+	// A duplicate _id after a retry counts as inserted.
+	r = newChunkedResult()
+	r.merge(batch, &mongo.BulkWriteResult{InsertedCount: 2}, 2,
+		mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: dup}, {WriteError: invalid}}},
+		func(_ int, we mongo.WriteError) bool { return isDuplicateID(we) })
+	assert.Equal(t, int64(3), r.InsertedCount)
+	assert.Len(t, r.Failed, 1)
+
+	// A write concern error fails the rest of the batch.
+	r = newChunkedResult()
+	wce := &mongo.WriteConcernError{Code: 64, Message: "waiting for replication timed out"}
+	r.merge(batch, nil, 1, mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: invalid}}, WriteConcernError: wce}, nil)
+	assert.Len(t, r.Failed, 4)
+	assert.Equal(t, invalid, r.Failed[13])
+	assert.Equal(t, *wce, r.Failed[10])
+
+	// Any other error fails the whole batch.
+	r = newChunkedResult()
+	boom := errors.New("boom")
+	r.merge(batch, nil, 0, boom, nil)
+	assert.Len(t, r.Failed, 4)
+	assert.Same(t, boom, r.Failed[12])
+	assert.Zero(t, r.Batches)
+}
+
+func TestIsTransientWriteError(t *testing.T) {
+	assert.True(t, isTransientWriteError(mongo.CommandError{Labels: []string{labelRetryableWrite}}))
+	assert.True(t, isTransientWriteError(mongo.CommandError{Labels: []string{"NetworkError"}}))
+	assert.False(t, isTransientWriteError(mongo.CommandError{Code: 11000}))
+	assert.False(t, isTransientWriteError(errors.New("boom")))
+}
+
+func TestInsertManyChunked_Batches(t *testing.T) {
+	ctx := context.Background()
+	var sizes []int
+	reject := func(_ context.Context, op *OpInfo, _ Invoker) error {
+		docs := op.Document.([]bson.M)
+		sizes = append(sizes, len(docs))
+		if docs[0]["n"] == 5 {
+			return mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 0, Code: 11000}}}}
+		}
+		return nil
+	}
+	coll := Wrap[bson.M](offlineCollection(t), WithInterceptors(reject))
+
+	docs := make([]bson.M, 7)
+	for i := range docs {
+		docs[i] = bson.M{"n": i}
+	}
+	docs[2]["bad"] = make(chan int)
+	res, err := coll.InsertManyChunked(ctx, docs, ChunkOpts{MaxBatchCount: 2})
+	assert.ErrorIs(t, err, ErrPartialWrite)
+	assert.Equal(t, []int{2, 2, 2}, sizes)
+	assert.Len(t, res.Failed, 2)
+	assert.Contains(t, res.Failed[2].Error(), "encode document")
+	assert.True(t, mongo.IsDuplicateKeyError(res.Failed[5]))
+	assert.Len(t, res.InsertedIDs, 5)
+	assert.NotContains(t, res.InsertedIDs, 5)
+
+	// A cancelled context fails every batch not yet started.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	res, err = coll.InsertManyChunked(cctx, []bson.M{{"n": 0}}, DefaultChunkOpts)
+	assert.ErrorIs(t, err, ErrPartialWrite)
+	assert.ErrorIs(t, res.Failed[0], context.Canceled)
+
+	res, err = coll.InsertManyChunked(ctx, nil, DefaultChunkOpts)
+	require.NoError(t, err)
+	assert.Empty(t, res.Failed)
+}
+
+func TestBulkWriteChunked_Batches(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu   sync.Mutex
+		seen []OpInfo
+	)
+	record := func(_ context.Context, op *OpInfo, _ Invoker) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, *op)
+		return nil
+	}
+	coll := Wrap[bson.M](offlineCollection(t), WithInterceptors(record))
+
+	models := make([]WriteModel[bson.M], 5)
+	for i := range models {
+		models[i] = NewDeleteOneModel[bson.M]().SetFilter(Eq("n", i))
+	}
+	res, err := coll.BulkWriteChunked(ctx, models, ChunkOpts{MaxBatchCount: 2, Concurrency: 3})
+	require.NoError(t, err)
+	assert.Empty(t, res.Failed)
+	require.Len(t, seen, 3)
+	total := 0
+	for _, op := range seen {
+		assert.Equal(t, OpBulkWrite, op.Operation)
+		total += len(op.Document.([]WriteModel[bson.M]))
+	}
+	assert.Equal(t, 5, total)
+}
@@ -3,6 +3,8 @@ package gmqb
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"slices"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -13,6 +15,9 @@ import (
 // gmqb builders (Filter, Updater, Pipeline) instead of raw bson.D.
 // The type parameter T specifies the document struct type.
 //
+// Fields of T tagged gmqb:"createdAt" or gmqb:"updatedAt" are maintained by
+// the insert, update and replace methods and by BulkWrite.
+//
 // See: https://www.mongodb.com/docs/drivers/go/current/fundamentals/crud/
 //
 // Example:
@@ -24,16 +29,23 @@ import (
 //	coll := gmqb.Wrap[User](db.Collection("users"))
 //	users, err := coll.Find(ctx, gmqb.Gte("age", 18))
 type Collection[T any] struct {
-	coll *mongo.Collection
+	coll         *mongo.Collection
+	interceptors []Interceptor
 }
 
 // Wrap creates a typed Collection wrapper around a mongo.Collection.
+// Options such as WithInterceptors and WithReadPreference customise every
+// operation.
 //
 // Example:
 //
 //	coll := gmqb.Wrap[User](db.Collection("users"))
-func Wrap[T any](coll *mongo.Collection) *Collection[T] {
-	return &Collection[T]{coll: coll}
+func Wrap[T any](coll *mongo.Collection, opts ...CollectionOpt) *Collection[T] {
+	var cfg collectionConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Collection[T]{coll: cfg.cloned(coll), interceptors: cfg.interceptors}
 }
 
 // Unwrap returns the underlying mongo.Collection for direct driver access.
@@ -53,9 +65,21 @@ func (c *Collection[T]) Unwrap() *mongo.Collection {
 //	    gmqb.WithLimit(10),
 //	    gmqb.WithSort(gmqb.Desc("createdAt")),
 //	)
-func (c *Collection[T]) Find(ctx context.Context, filter Filter, opts ...FindOpt) ([]T, error) {
-	findOpts := buildFindOpts(opts)
-	cursor, err := c.coll.Find(ctx, filter.BsonD(), findOpts)
+func (c *Collection[T]) Find(ctx context.Context, filter Filter, opts ...Opt) ([]T, error) {
+	return intercept(c, ctx, &OpInfo{Operation: OpFind, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) ([]T, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		return c.find(ctx, op.Filter, cfg)
+	})
+}
+
+// find runs a find without interceptors.
+func (c *Collection[T]) find(ctx context.Context, filter Filter, cfg opConfig) ([]T, error) {
+	ctx, cancel := withDeadline(ctx, cfg.deadline())
+	defer cancel()
+	cursor, err := c.collFor(ctx).Find(ctx, filter.BsonD(), callOptions[options.FindOptions](ctx), driverOptions[options.FindOptions](cfg))
 	if err != nil {
 		return nil, err
 	}
@@ -74,30 +98,22 @@ func (c *Collection[T]) Find(ctx context.Context, filter Filter, opts ...FindOpt
 // Example:
 //
 //	user, err := coll.FindOne(ctx, gmqb.Eq("email", "alice@example.com"))
-func (c *Collection[T]) FindOne(ctx context.Context, filter Filter, opts ...FindOpt) (*T, error) {
-	findOneOpts := options.FindOne()
-	for _, opt := range opts {
-		// Convert FindOpt to FindOneOptionsBuilder settings
-		builder := options.Find()
-		opt(builder)
-		// Extract values from the builder via List()
-		for _, fn := range builder.List() {
-			var fo options.FindOptions
-			_ = fn(&fo)
-			if fo.Sort != nil {
-				findOneOpts.SetSort(fo.Sort)
-			}
-			if fo.Projection != nil {
-				findOneOpts.SetProjection(fo.Projection)
-			}
-			if fo.Skip != nil {
-				findOneOpts.SetSkip(*fo.Skip)
-			}
+func (c *Collection[T]) FindOne(ctx context.Context, filter Filter, opts ...Opt) (*T, error) {
+	return intercept(c, ctx, &OpInfo{Operation: OpFindOne, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) (*T, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
 		}
-	}
+		return c.findOne(ctx, op.Filter, cfg)
+	})
+}
 
+// findOne runs a findOne without interceptors.
+func (c *Collection[T]) findOne(ctx context.Context, filter Filter, cfg opConfig) (*T, error) {
+	ctx, cancel := withDeadline(ctx, cfg.deadline())
+	defer cancel()
 	var result T
-	err := c.coll.FindOne(ctx, filter.BsonD(), findOneOpts).Decode(&result)
+	err := c.collFor(ctx).FindOne(ctx, filter.BsonD(), callOptions[options.FindOneOptions](ctx), driverOptions[options.FindOneOptions](cfg)).Decode(&result)
 	if err != nil {
 		return nil, err
 	}
@@ -111,8 +127,21 @@ func (c *Collection[T]) FindOne(ctx context.Context, filter Filter, opts ...Find
 // Example:
 //
 //	result, err := coll.InsertOne(ctx, &User{Name: "Alice", Age: 30})
-func (c *Collection[T]) InsertOne(ctx context.Context, doc *T) (*mongo.InsertOneResult, error) {
-	return c.coll.InsertOne(ctx, doc)
+func (c *Collection[T]) InsertOne(ctx context.Context, doc *T, opts ...Opt) (*mongo.InsertOneResult, error) {
+	return intercept(c, ctx, &OpInfo{Operation: OpInsertOne, Document: doc, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.InsertOneResult, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ts, err := timestampsOf[T]()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		ts.stampInsert(reflect.ValueOf(op.Document), timestampNow())
+		return c.collFor(ctx).InsertOne(ctx, op.Document, callOptions[options.InsertOneOptions](ctx), driverOptions[options.InsertOneOptions](cfg))
+	})
 }
 
 // InsertMany inserts multiple documents.
@@ -125,12 +154,27 @@ func (c *Collection[T]) InsertOne(ctx context.Context, doc *T) (*mongo.InsertOne
 //	    {Name: "Alice", Age: 30},
 //	    {Name: "Bob", Age: 25},
 //	})
-func (c *Collection[T]) InsertMany(ctx context.Context, docs []T) (*mongo.InsertManyResult, error) {
-	ifaces := make([]interface{}, len(docs))
-	for i := range docs {
-		ifaces[i] = docs[i]
-	}
-	return c.coll.InsertMany(ctx, ifaces)
+func (c *Collection[T]) InsertMany(ctx context.Context, docs []T, opts ...Opt) (*mongo.InsertManyResult, error) {
+	return intercept(c, ctx, &OpInfo{Operation: OpInsertMany, Document: docs, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.InsertManyResult, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ts, err := timestampsOf[T]()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		docs, _ := op.Document.([]T)
+		now := timestampNow()
+		ifaces := make([]interface{}, len(docs))
+		for i := range docs {
+			ts.stampInsert(reflect.ValueOf(&docs[i]), now)
+			ifaces[i] = docs[i]
+		}
+		return c.collFor(ctx).InsertMany(ctx, ifaces, callOptions[options.InsertManyOptions](ctx), driverOptions[options.InsertManyOptions](cfg))
+	})
 }
 
 // BulkWrite performs multiple write operations in a single batch.
@@ -144,16 +188,31 @@ func (c *Collection[T]) InsertMany(ctx context.Context, docs []T) (*mongo.Insert
 //	    gmqb.NewUpdateOneModel[User]().SetFilter(gmqb.Eq("name", "Bob")).SetUpdate(gmqb.NewUpdate().Set("age", 25)),
 //	}
 //	result, err := coll.BulkWrite(ctx, models)
-func (c *Collection[T]) BulkWrite(ctx context.Context, models []WriteModel[T], opts ...BulkWriteOpt) (*mongo.BulkWriteResult, error) {
+func (c *Collection[T]) BulkWrite(ctx context.Context, models []WriteModel[T], opts ...Opt) (*mongo.BulkWriteResult, error) {
 	if len(models) == 0 {
 		return nil, nil // Return empty if no models specified
 	}
-	mongoModels := make([]mongo.WriteModel, len(models))
-	for i, m := range models {
-		mongoModels[i] = m.MongoWriteModel()
-	}
-	bwOpts := buildBulkWriteOpts(opts)
-	return c.coll.BulkWrite(ctx, mongoModels, bwOpts)
+	return intercept(c, ctx, &OpInfo{Operation: OpBulkWrite, Document: models, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.BulkWriteResult, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ts, err := timestampsOf[T]()
+		if err != nil {
+			return nil, err
+		}
+		models, _ := op.Document.([]WriteModel[T])
+		now := timestampNow()
+		mongoModels := make([]mongo.WriteModel, len(models))
+		for i, m := range models {
+			if mongoModels[i], err = mongoWriteModel[T](m, ts, now); err != nil {
+				return nil, err
+			}
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		return c.collFor(ctx).BulkWrite(ctx, mongoModels, callOptions[options.BulkWriteOptions](ctx), driverOptions[options.BulkWriteOptions](cfg))
+	})
 }
 
 // UpdateOne updates a single document matching the filter.
@@ -166,15 +225,27 @@ func (c *Collection[T]) BulkWrite(ctx context.Context, models []WriteModel[T], o
 //	    gmqb.Eq("name", "Alice"),
 //	    gmqb.NewUpdate().Set("age", 31),
 //	)
-func (c *Collection[T]) UpdateOne(ctx context.Context, filter Filter, update UpdateDoc, opts ...UpdateOpt) (*mongo.UpdateResult, error) {
+func (c *Collection[T]) UpdateOne(ctx context.Context, filter Filter, update UpdateDoc, opts ...Opt) (*mongo.UpdateResult, error) {
 	if filter.IsEmpty() {
 		return nil, fmt.Errorf("%w: UpdateOne requires a non-empty filter", ErrEmptyFilter)
 	}
 	if update.IsEmpty() {
 		return nil, fmt.Errorf("%w: UpdateOne requires a non-empty update", ErrEmptyUpdate)
 	}
-	updateOpts := buildUpdateOneOpts(opts)
-	return c.coll.UpdateOne(ctx, filter.BsonD(), update.updatePayload(), updateOpts)
+	return intercept(c, ctx, &OpInfo{Operation: OpUpdateOne, Filter: filter, Update: update, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.UpdateResult, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ts, err := timestampsOf[T]()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		update := ts.stampUpdate(op.Update, isUpsert(cfg.upsert), timestampNow())
+		return c.collFor(ctx).UpdateOne(ctx, op.Filter.BsonD(), update.updatePayload(), callOptions[options.UpdateOneOptions](ctx), driverOptions[options.UpdateOneOptions](cfg))
+	})
 }
 
 // UpdateMany updates all documents matching the filter.
@@ -187,26 +258,39 @@ func (c *Collection[T]) UpdateOne(ctx context.Context, filter Filter, update Upd
 //	    gmqb.Lt("age", 18),
 //	    gmqb.NewUpdate().Set("status", "minor"),
 //	)
-func (c *Collection[T]) UpdateMany(ctx context.Context, filter Filter, update UpdateDoc, opts ...UpdateManyOpt) (*mongo.UpdateResult, error) {
+func (c *Collection[T]) UpdateMany(ctx context.Context, filter Filter, update UpdateDoc, opts ...Opt) (*mongo.UpdateResult, error) {
 	if filter.IsEmpty() {
 		return nil, fmt.Errorf("%w: UpdateMany requires a non-empty filter", ErrEmptyFilter)
 	}
 	if update.IsEmpty() {
 		return nil, fmt.Errorf("%w: UpdateMany requires a non-empty update", ErrEmptyUpdate)
 	}
-	updateOpts := buildUpdateManyOpts(opts)
-	return c.coll.UpdateMany(ctx, filter.BsonD(), update.updatePayload(), updateOpts)
+	return intercept(c, ctx, &OpInfo{Operation: OpUpdateMany, Filter: filter, Update: update, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.UpdateResult, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ts, err := timestampsOf[T]()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		update := ts.stampUpdate(op.Update, isUpsert(cfg.upsert), timestampNow())
+		return c.collFor(ctx).UpdateMany(ctx, op.Filter.BsonD(), update.updatePayload(), callOptions[options.UpdateManyOptions](ctx), driverOptions[options.UpdateManyOptions](cfg))
+	})
 }
 
 // UpsertOne updates a single document matching the filter, or inserts a new one
-// if no document matches. It is equivalent to UpdateOne with WithUpsert(true).
+// if no document matches. It is equivalent to UpdateOne with WithUpsert(true)
+// after opts.
 //
 // Example:
 //
 //	result, err := coll.UpsertOne(ctx, gmqb.Eq("email", "alice@example.com"),
 //	    gmqb.NewUpdate().Set("lastLogin", time.Now()))
-func (c *Collection[T]) UpsertOne(ctx context.Context, filter Filter, update UpdateDoc) (*mongo.UpdateResult, error) {
-	return c.UpdateOne(ctx, filter, update, WithUpsert(true))
+func (c *Collection[T]) UpsertOne(ctx context.Context, filter Filter, update UpdateDoc, opts ...Opt) (*mongo.UpdateResult, error) {
+	return c.UpdateOne(ctx, filter, update, append(slices.Clip(opts), WithUpsert(true))...)
 }
 
 // ReplaceOne replaces a single document matching the filter.
@@ -219,12 +303,30 @@ func (c *Collection[T]) UpsertOne(ctx context.Context, filter Filter, update Upd
 //	    gmqb.Eq("name", "Alice"),
 //	    &User{Name: "Alice", Age: 31},
 //	)
-func (c *Collection[T]) ReplaceOne(ctx context.Context, filter Filter, replacement *T, opts ...ReplaceOpt) (*mongo.UpdateResult, error) {
+func (c *Collection[T]) ReplaceOne(ctx context.Context, filter Filter, replacement *T, opts ...Opt) (*mongo.UpdateResult, error) {
 	if filter.IsEmpty() {
 		return nil, fmt.Errorf("%w: ReplaceOne requires a non-empty filter", ErrEmptyFilter)
 	}
-	replaceOpts := buildReplaceOpts(opts)
-	return c.coll.ReplaceOne(ctx, filter.BsonD(), replacement, replaceOpts)
+	return intercept(c, ctx, &OpInfo{Operation: OpReplaceOne, Filter: filter, Document: replacement, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.UpdateResult, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ts, err := timestampsOf[T]()
+		if err != nil {
+			return nil, err
+		}
+		replacement, asUpdate, err := ts.stampReplace(op.Document, timestampNow())
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		if asUpdate {
+			return c.collFor(ctx).UpdateOne(ctx, op.Filter.BsonD(), replacement, callOptions[options.UpdateOneOptions](ctx), driverOptions[options.UpdateOneOptions](cfg))
+		}
+		return c.collFor(ctx).ReplaceOne(ctx, op.Filter.BsonD(), replacement, callOptions[options.ReplaceOptions](ctx), driverOptions[options.ReplaceOptions](cfg))
+	})
 }
 
 // DeleteOne deletes a single document matching the filter.
@@ -234,11 +336,19 @@ func (c *Collection[T]) ReplaceOne(ctx context.Context, filter Filter, replaceme
 // Example:
 //
 //	result, err := coll.DeleteOne(ctx, gmqb.Eq("name", "Alice"))
-func (c *Collection[T]) DeleteOne(ctx context.Context, filter Filter) (*mongo.DeleteResult, error) {
+func (c *Collection[T]) DeleteOne(ctx context.Context, filter Filter, opts ...Opt) (*mongo.DeleteResult, error) {
 	if filter.IsEmpty() {
 		return nil, fmt.Errorf("%w: DeleteOne requires a non-empty filter", ErrEmptyFilter)
 	}
-	return c.coll.DeleteOne(ctx, filter.BsonD())
+	return intercept(c, ctx, &OpInfo{Operation: OpDeleteOne, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.DeleteResult, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		return c.collFor(ctx).DeleteOne(ctx, op.Filter.BsonD(), callOptions[options.DeleteOneOptions](ctx), driverOptions[options.DeleteOneOptions](cfg))
+	})
 }
 
 // DeleteMany deletes all documents matching the filter.
@@ -248,11 +358,19 @@ func (c *Collection[T]) DeleteOne(ctx context.Context, filter Filter) (*mongo.De
 // Example:
 //
 //	result, err := coll.DeleteMany(ctx, gmqb.Eq("status", "inactive"))
-func (c *Collection[T]) DeleteMany(ctx context.Context, filter Filter) (*mongo.DeleteResult, error) {
+func (c *Collection[T]) DeleteMany(ctx context.Context, filter Filter, opts ...Opt) (*mongo.DeleteResult, error) {
 	if filter.IsEmpty() {
 		return nil, fmt.Errorf("%w: DeleteMany requires a non-empty filter", ErrEmptyFilter)
 	}
-	return c.coll.DeleteMany(ctx, filter.BsonD())
+	return intercept(c, ctx, &OpInfo{Operation: OpDeleteMany, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.DeleteResult, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		return c.collFor(ctx).DeleteMany(ctx, op.Filter.BsonD(), callOptions[options.DeleteManyOptions](ctx), driverOptions[options.DeleteManyOptions](cfg))
+	})
 }
 
 // FindOneAndDelete deletes a single document matching the filter and returns it.
@@ -263,17 +381,24 @@ func (c *Collection[T]) DeleteMany(ctx context.Context, filter Filter) (*mongo.D
 // Example:
 //
 //	deletedUser, err := coll.FindOneAndDelete(ctx, gmqb.Eq("name", "Alice"))
-func (c *Collection[T]) FindOneAndDelete(ctx context.Context, filter Filter, opts ...FindOneAndDeleteOpt) (*T, error) {
+func (c *Collection[T]) FindOneAndDelete(ctx context.Context, filter Filter, opts ...Opt) (*T, error) {
 	if filter.IsEmpty() {
 		return nil, fmt.Errorf("%w: FindOneAndDelete requires a non-empty filter", ErrEmptyFilter)
 	}
-	deleteOpts := buildFindOneAndDeleteOpts(opts)
-	var result T
-	err := c.coll.FindOneAndDelete(ctx, filter.BsonD(), deleteOpts).Decode(&result)
-	if err != nil {
-		return nil, err
-	}
-	return &result, nil
+	return intercept(c, ctx, &OpInfo{Operation: OpFindOneAndDelete, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) (*T, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		var result T
+		err = c.collFor(ctx).FindOneAndDelete(ctx, op.Filter.BsonD(), callOptions[options.FindOneAndDeleteOptions](ctx), driverOptions[options.FindOneAndDeleteOptions](cfg)).Decode(&result)
+		if err != nil {
+			return nil, err
+		}
+		return &result, nil
+	})
 }
 
 // FindOneAndUpdate updates a single document matching the filter and returns it.
@@ -289,24 +414,36 @@ func (c *Collection[T]) FindOneAndDelete(ctx context.Context, filter Filter, opt
 //	    gmqb.NewUpdate().Set("age", 31),
 //	    gmqb.WithReturnDocument(options.After),
 //	)
-func (c *Collection[T]) FindOneAndUpdate(ctx context.Context, filter Filter, update UpdateDoc, opts ...FindOneAndUpdateOpt) (*T, error) {
+func (c *Collection[T]) FindOneAndUpdate(ctx context.Context, filter Filter, update UpdateDoc, opts ...Opt) (*T, error) {
 	if filter.IsEmpty() {
 		return nil, fmt.Errorf("%w: FindOneAndUpdate requires a non-empty filter", ErrEmptyFilter)
 	}
 	if update.IsEmpty() {
 		return nil, fmt.Errorf("%w: FindOneAndUpdate requires a non-empty update", ErrEmptyUpdate)
 	}
-	updateOpts := buildFindOneAndUpdateOpts(opts)
-	var result T
-	err := c.coll.FindOneAndUpdate(ctx, filter.BsonD(), update.updatePayload(), updateOpts).Decode(&result)
-	if err != nil {
-		return nil, err
-	}
-	return &result, nil
+	return intercept(c, ctx, &OpInfo{Operation: OpFindOneAndUpdate, Filter: filter, Update: update, Options: opts}, func(ctx context.Context, op *OpInfo) (*T, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ts, err := timestampsOf[T]()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		update := ts.stampUpdate(op.Update, isUpsert(cfg.upsert), timestampNow())
+		var result T
+		err = c.collFor(ctx).FindOneAndUpdate(ctx, op.Filter.BsonD(), update.updatePayload(), callOptions[options.FindOneAndUpdateOptions](ctx), driverOptions[options.FindOneAndUpdateOptions](cfg)).Decode(&result)
+		if err != nil {
+			return nil, err
+		}
+		return &result, nil
+	})
 }
 
 // FindOneAndReplace replaces a single document matching the filter and returns it.
-// By default, it returns the document as it was before the replacement. Use WithReturnDocumentReplace(options.After)
+// By default, it returns the document as it was before the replacement. Use WithReturnDocument(options.After)
 // to return the replaced document. Returns mongo.ErrNoDocuments if no document matches.
 //
 // See: https://www.mongodb.com/docs/drivers/go/current/crud/compound-operations/#find-and-replace
@@ -316,19 +453,40 @@ func (c *Collection[T]) FindOneAndUpdate(ctx context.Context, filter Filter, upd
 //	replacedUser, err := coll.FindOneAndReplace(ctx,
 //		gmqb.Eq("name", "Alice"),
 //		&User{Name: "Alice", Age: 31},
-//		gmqb.WithReturnDocumentReplace(options.After),
+//		gmqb.WithReturnDocument(options.After),
 //	)
-func (c *Collection[T]) FindOneAndReplace(ctx context.Context, filter Filter, replacement *T, opts ...FindOneAndReplaceOpt) (*T, error) {
+func (c *Collection[T]) FindOneAndReplace(ctx context.Context, filter Filter, replacement *T, opts ...Opt) (*T, error) {
 	if filter.IsEmpty() {
 		return nil, fmt.Errorf("%w: FindOneAndReplace requires a non-empty filter", ErrEmptyFilter)
 	}
-	replaceOpts := buildFindOneAndReplaceOpts(opts)
-	var result T
-	err := c.coll.FindOneAndReplace(ctx, filter.BsonD(), replacement, replaceOpts).Decode(&result)
-	if err != nil {
-		return nil, err
-	}
-	return &result, nil
+	return intercept(c, ctx, &OpInfo{Operation: OpFindOneAndReplace, Filter: filter, Document: replacement, Options: opts}, func(ctx context.Context, op *OpInfo) (*T, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ts, err := timestampsOf[T]()
+		if err != nil {
+			return nil, err
+		}
+		replacement, asUpdate, err := ts.stampReplace(op.Document, timestampNow())
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		var res *mongo.SingleResult
+		if asUpdate {
+			res = c.collFor(ctx).FindOneAndUpdate(ctx, op.Filter.BsonD(), replacement, callOptions[options.FindOneAndUpdateOptions](ctx), driverOptions[options.FindOneAndUpdateOptions](cfg))
+		} else {
+			res = c.collFor(ctx).FindOneAndReplace(ctx, op.Filter.BsonD(), replacement, callOptions[options.FindOneAndReplaceOptions](ctx), driverOptions[options.FindOneAndReplaceOptions](cfg))
+		}
+		var result T
+		err = res.Decode(&result)
+		if err != nil {
+			return nil, err
+		}
+		return &result, nil
+	})
 }
 
 // CountDocuments returns the number of documents matching the filter.
@@ -337,10 +495,22 @@ func (c *Collection[T]) FindOneAndReplace(ctx context.Context, filter Filter, re
 //
 // Example:
 //
-//	count, err := coll.CountDocuments(ctx, gmqb.Gte("age", 18), gmqb.WithLimitCount(100))
-func (c *Collection[T]) CountDocuments(ctx context.Context, filter Filter, opts ...CountOpt) (int64, error) {
-	countOpts := buildCountOpts(opts)
-	return c.coll.CountDocuments(ctx, filter.BsonD(), countOpts)
+//	count, err := coll.CountDocuments(ctx, gmqb.Gte("age", 18), gmqb.WithLimit(100))
+func (c *Collection[T]) CountDocuments(ctx context.Context, filter Filter, opts ...Opt) (int64, error) {
+	return intercept(c, ctx, &OpInfo{Operation: OpCountDocuments, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) (int64, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return 0, err
+		}
+		return c.countDocuments(ctx, op.Filter, cfg)
+	})
+}
+
+// countDocuments counts without interceptors.
+func (c *Collection[T]) countDocuments(ctx context.Context, filter Filter, cfg opConfig) (int64, error) {
+	ctx, cancel := withDeadline(ctx, cfg.deadline())
+	defer cancel()
+	return c.collFor(ctx).CountDocuments(ctx, filter.BsonD(), callOptions[options.CountOptions](ctx), driverOptions[options.CountOptions](cfg))
 }
 
 // Distinct returns the distinct values for a specified field.
@@ -350,13 +520,80 @@ func (c *Collection[T]) CountDocuments(ctx context.Context, filter Filter, opts
 // Example:
 //
 //	result := coll.Distinct(ctx, "country", gmqb.Exists("country", true))
-func (c *Collection[T]) Distinct(ctx context.Context, field string, filter Filter) *mongo.DistinctResult {
-	return c.coll.Distinct(ctx, field, filter.BsonD())
+//
+// When an interceptor fails the call without running it, the error is
+// reported by the result's Err, wrapped in a mongo.MarshalError.
+func (c *Collection[T]) Distinct(ctx context.Context, field string, filter Filter, opts ...Opt) *mongo.DistinctResult {
+	res, err := intercept(c, ctx, &OpInfo{Operation: OpDistinct, Field: field, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.DistinctResult, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := withDeadline(ctx, cfg.deadline())
+		defer cancel()
+		res := c.collFor(ctx).Distinct(ctx, op.Field, op.Filter.BsonD(), callOptions[options.DistinctOptions](ctx), driverOptions[options.DistinctOptions](cfg))
+		return res, res.Err()
+	})
+	if res == nil {
+		// An interceptor or an invalid option rejected the operation. The
+		// driver offers no way to build a failed DistinctResult directly, so
+		// let it fail while marshalling the filter.
+		if err == nil {
+			err = fmt.Errorf("gmqb: distinct: no result")
+		}
+		return c.coll.Distinct(ctx, field, failingDocument{err: err})
+	}
+	return res
 }
 
+// DistinctOf returns the distinct values of field among the documents matching
+// the filter, decoded into []V. Unlike Distinct it reports errors directly and
+// accepts collation and hint options.
+//
+// MongoDB equivalent:
+//
+//	db.collection.distinct(field, filter, { collation: ..., hint: ... })
+//
+// See: https://www.mongodb.com/docs/manual/reference/command/distinct/
+//
+// Example:
+//
+//	countries, err := gmqb.DistinctOf[string](ctx, coll, "country", gmqb.Gte("age", 18))
+func DistinctOf[V any, T any](ctx context.Context, coll *Collection[T], field string, filter Filter, opts ...Opt) ([]V, error) {
+	return intercept(coll, ctx, &OpInfo{Operation: OpDistinct, Field: field, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) ([]V, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		return distinctOf[V](coll, ctx, op.Field, op.Filter, cfg)
+	})
+}
+
+// distinctOf runs a distinct without interceptors. It returns an empty,
+// non-nil slice when no document matches.
+func distinctOf[V any, T any](c *Collection[T], ctx context.Context, field string, filter Filter, cfg opConfig) ([]V, error) {
+	ctx, cancel := withDeadline(ctx, cfg.deadline())
+	defer cancel()
+	res := c.collFor(ctx).Distinct(ctx, field, filter.BsonD(), callOptions[options.DistinctOptions](ctx), driverOptions[options.DistinctOptions](cfg))
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+	values := []V{}
+	if err := res.Decode(&values); err != nil {
+		return nil, fmt.Errorf("gmqb: distinct %q: %w", field, err)
+	}
+	return values, nil
+}
+
+// failingDocument is a BSON document whose marshalling always fails with err.
+type failingDocument struct{ err error }
+
+func (d failingDocument) MarshalBSON() ([]byte, error) { return nil, d.err }
+
 // Aggregate runs an aggregation pipeline on the collection and returns typed results.
 // The type parameter R can differ from the collection's T when the pipeline
-// reshapes documents.
+// reshapes documents. Pass WithValidation to check the pipeline with
+// Pipeline.Validate before it is sent to the server.
 //
 // See: https://www.mongodb.com/docs/drivers/go/current/fundamentals/crud/read-operations/aggregate/
 //
@@ -370,11 +607,85 @@ func (c *Collection[T]) Distinct(ctx context.Context, field string, filter Filte
 //	    gmqb.NewPipeline().
 //	        Group(gmqb.GroupSpec("$country", gmqb.GroupAcc("count", gmqb.AccSum(1)))),
 //	)
-func Aggregate[R any, T any](c *Collection[T], ctx context.Context, pipeline Pipeline) ([]R, error) {
+func Aggregate[R any, T any](c *Collection[T], ctx context.Context, pipeline Pipeline, opts ...Opt) ([]R, error) {
 	if pipeline.IsEmpty() {
 		return nil, fmt.Errorf("%w: Aggregate requires a non-empty pipeline", ErrEmptyPipeline)
 	}
-	cursor, err := c.coll.Aggregate(ctx, pipeline.BsonD())
+	if _, err := validateAggregate(pipeline, opts); err != nil {
+		return nil, err
+	}
+	return intercept(c, ctx, &OpInfo{Operation: OpAggregate, Pipeline: pipeline, Options: opts}, func(ctx context.Context, op *OpInfo) ([]R, error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		return aggregate[R](c, ctx, op.Pipeline, cfg)
+	})
+}
+
+// validateAggregate checks opts before an aggregation starts and, with
+// WithValidation, the pipeline too.
+func validateAggregate(pipeline Pipeline, opts []Opt) (opConfig, error) {
+	cfg, err := buildOpts(OpAggregate, opts)
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.validate {
+		return cfg, pipeline.Validate()
+	}
+	return cfg, nil
+}
+
+// aggregate runs an aggregation without interceptors.
+func aggregate[R any, T any](c *Collection[T], ctx context.Context, pipeline Pipeline, cfg opConfig) ([]R, error) {
+	ctx, cancel := withDeadline(ctx, cfg.deadline())
+	defer cancel()
+	cursor, err := c.collFor(ctx).Aggregate(ctx, pipeline.BsonD(), callOptions[options.AggregateOptions](ctx), driverOptions[options.AggregateOptions](cfg))
+	if err != nil {
+		return nil, err
+	}
+	var results []R
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// AggregateDatabase runs a database-level aggregation pipeline, such as one that
+// starts with $documents, $currentOp or $shardedDataDistribution, and returns
+// typed results.
+//
+// The MaxTime, Comment, Let and AllowDiskUse of the context's CallOpts apply
+// as they do to Aggregate. Its concerns do not, as a *mongo.Database cannot
+// be cloned with them; pass a database configured with them instead.
+//
+// MongoDB equivalent: db.aggregate(pipeline)
+//
+// See: https://www.mongodb.com/docs/manual/reference/method/db.aggregate/
+//
+// Example:
+//
+//	type Row struct {
+//	    X int `bson:"x"`
+//	}
+//	rows, err := gmqb.AggregateDatabase[Row](db, ctx,
+//	    gmqb.NewPipeline().
+//	        Documents(bson.D{{"x", 1}}, bson.D{{"x", 2}}).
+//	        Match(gmqb.Gt("x", 1)),
+//	)
+func AggregateDatabase[R any](db *mongo.Database, ctx context.Context, pipeline Pipeline, opts ...Opt) ([]R, error) {
+	if pipeline.IsEmpty() {
+		return nil, fmt.Errorf("%w: AggregateDatabase requires a non-empty pipeline", ErrEmptyPipeline)
+	}
+	cfg, err := validateAggregate(pipeline, opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancelMax := withMaxTime(ctx)
+	defer cancelMax()
+	ctx, cancel := withDeadline(ctx, cfg.deadline())
+	defer cancel()
+	cursor, err := db.Aggregate(ctx, pipeline.BsonD(), callOptions[options.AggregateOptions](ctx), driverOptions[options.AggregateOptions](cfg))
 	if err != nil {
 		return nil, err
 	}
@@ -390,35 +701,55 @@ func Aggregate[R any, T any](c *Collection[T], ctx context.Context, pipeline Pip
 // CreateIndex creates a single index on the collection.
 // Returns the name of the created index.
 func (c *Collection[T]) CreateIndex(ctx context.Context, model IndexModel) (string, error) {
-	return c.coll.Indexes().CreateOne(ctx, model.MongoIndexModel())
+	names, err := c.CreateIndexes(ctx, []IndexModel{model})
+	if err != nil {
+		return "", err
+	}
+	return names[0], nil
 }
 
 // CreateIndexes creates multiple indexes on the collection.
 // Returns the names of the created indexes.
 func (c *Collection[T]) CreateIndexes(ctx context.Context, models []IndexModel) ([]string, error) {
-	mongoModels := make([]mongo.IndexModel, len(models))
-	for i, m := range models {
-		mongoModels[i] = m.MongoIndexModel()
-	}
-	return c.coll.Indexes().CreateMany(ctx, mongoModels)
+	return intercept(c, ctx, &OpInfo{Operation: OpCreateIndexes, Document: models}, func(ctx context.Context, op *OpInfo) ([]string, error) {
+		models, _ := op.Document.([]IndexModel)
+		mongoModels := make([]mongo.IndexModel, len(models))
+		for i, m := range models {
+			mongoModels[i] = m.MongoIndexModel()
+		}
+		return c.collFor(ctx).Indexes().CreateMany(ctx, mongoModels)
+	})
 }
 
 // DropIndex drops an index by its name.
 func (c *Collection[T]) DropIndex(ctx context.Context, name string) error {
-	return c.coll.Indexes().DropOne(ctx, name)
+	_, err := intercept(c, ctx, &OpInfo{Operation: OpDropIndex, Document: name}, func(ctx context.Context, op *OpInfo) (struct{}, error) {
+		name, _ := op.Document.(string)
+		return struct{}{}, c.collFor(ctx).Indexes().DropOne(ctx, name)
+	})
+	return err
 }
 
-// ListIndexes returns a list of all indexes on the collection.
-func (c *Collection[T]) ListIndexes(ctx context.Context) ([]bson.Raw, error) {
-	cursor, err := c.coll.Indexes().List(ctx)
-	if err != nil {
-		return nil, err
-	}
-	var results []bson.Raw
-	if err := cursor.All(ctx, &results); err != nil {
-		return nil, err
-	}
-	return results, nil
+// ListIndexes returns a list of all indexes on the collection, including the
+// _id index.
+func (c *Collection[T]) ListIndexes(ctx context.Context) ([]IndexInfo, error) {
+	return intercept(c, ctx, &OpInfo{Operation: OpListIndexes}, func(ctx context.Context, op *OpInfo) ([]IndexInfo, error) {
+		cursor, err := c.collFor(ctx).Indexes().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var raws []bson.Raw
+		if err := cursor.All(ctx, &raws); err != nil {
+			return nil, err
+		}
+		results := make([]IndexInfo, len(raws))
+		for i, raw := range raws {
+			if results[i], err = parseIndexInfo(raw); err != nil {
+				return nil, fmt.Errorf("gmqb: decode index: %w", err)
+			}
+		}
+		return results, nil
+	})
 }
 
 // --- Sort Helpers ---
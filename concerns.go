@@ -0,0 +1,235 @@
+package gmqb
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+)
+
+// --- Collection concerns ---
+
+// WithReadPreference routes the reads of a Collection to the given members,
+// e.g. readpref.SecondaryPreferred() for analytics.
+//
+// Example:
+//
+//	analytics := coll.With(gmqb.WithReadPreference(readpref.SecondaryPreferred()))
+func WithReadPreference(rp *readpref.ReadPref) CollectionOpt {
+	return func(c *collectionConfig) {
+		c.clone = append(c.clone, options.Collection().SetReadPreference(rp))
+	}
+}
+
+// WithReadConcern sets the read concern of a Collection's reads.
+//
+// Example:
+//
+//	coll := gmqb.Wrap[User](raw, gmqb.WithReadConcern(readconcern.Majority()))
+func WithReadConcern(rc *readconcern.ReadConcern) CollectionOpt {
+	return func(c *collectionConfig) {
+		c.clone = append(c.clone, options.Collection().SetReadConcern(rc))
+	}
+}
+
+// WithWriteConcern sets the write concern of a Collection's writes.
+//
+// Example:
+//
+//	coll := gmqb.Wrap[User](raw, gmqb.WithWriteConcern(writeconcern.Majority()))
+func WithWriteConcern(wc *writeconcern.WriteConcern) CollectionOpt {
+	return func(c *collectionConfig) {
+		c.clone = append(c.clone, options.Collection().SetWriteConcern(wc))
+	}
+}
+
+// With returns a copy of the Collection with opts applied on top of its
+// settings. Concern options clone the underlying mongo.Collection, so the
+// copy shares its client and namespace but not its read preference, read
+// concern or write concern. Interceptors are added after the existing ones.
+//
+// Example:
+//
+//	reports := orders.With(gmqb.WithReadPreference(readpref.Secondary()))
+//	payments := orders.With(gmqb.WithWriteConcern(writeconcern.Majority()))
+func (c *Collection[T]) With(opts ...CollectionOpt) *Collection[T] {
+	cfg := collectionConfig{interceptors: c.interceptors}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Collection[T]{coll: cfg.cloned(c.coll), interceptors: cfg.interceptors}
+}
+
+// With returns a copy of the CachedCollection with opts applied, as
+// Collection.With does. The copy shares the cache and its entries.
+func (c *CachedCollection[T]) With(opts ...CollectionOpt) *CachedCollection[T] {
+	cp := *c
+	cp.Collection = c.Collection.With(opts...)
+	return &cp
+}
+
+// cloned returns coll, cloned with the concern options of c if it has any.
+func (c collectionConfig) cloned(coll *mongo.Collection) *mongo.Collection {
+	if len(c.clone) == 0 {
+		return coll
+	}
+	return coll.Clone(c.clone...)
+}
+
+// --- Per-call options ---
+
+// CallOpts are settings for every operation run with a context returned by
+// WithCallOpts. Zero fields are left unset. Options passed to a method
+// directly take precedence, and options an operation does not support are
+// ignored: Let applies to finds, updates, deletes and aggregations, and
+// AllowDiskUse to finds and aggregations.
+type CallOpts struct {
+	// ReadPreference, ReadConcern and WriteConcern override the collection's
+	// concerns for the call.
+	ReadPreference *readpref.ReadPref
+	ReadConcern    *readconcern.ReadConcern
+	WriteConcern   *writeconcern.WriteConcern
+	// MaxTime bounds each operation with a context deadline, which the
+	// driver also uses to limit the server's work. A cursor's later batches
+	// are bounded by the context passed to Next.
+	MaxTime time.Duration
+	// Comment is attached to each command for the profiler and logs.
+	Comment any
+	// Let defines variables usable as $$name in the operation's expressions.
+	Let any
+	// AllowDiskUse lets blocking sorts and groups write temporary files.
+	AllowDiskUse *bool
+}
+
+type callOptsKey struct{}
+
+// WithCallOpts returns a context whose operations use opts. Fields set in
+// opts replace those of any CallOpts already carried by ctx.
+//
+// Example:
+//
+//	ctx = gmqb.WithCallOpts(ctx, gmqb.CallOpts{
+//	    ReadPreference: readpref.SecondaryPreferred(),
+//	    MaxTime:        5 * time.Second,
+//	    Comment:        "nightly-report",
+//	})
+//	rows, err := gmqb.Aggregate[Row](orders, ctx, pipeline)
+func WithCallOpts(ctx context.Context, opts CallOpts) context.Context {
+	return context.WithValue(ctx, callOptsKey{}, CallOptsFrom(ctx).merge(opts))
+}
+
+// CallOptsFrom returns the CallOpts carried by ctx.
+func CallOptsFrom(ctx context.Context) CallOpts {
+	opts, _ := ctx.Value(callOptsKey{}).(CallOpts)
+	return opts
+}
+
+// merge returns o with the set fields of next applied.
+func (o CallOpts) merge(next CallOpts) CallOpts {
+	if next.ReadPreference != nil {
+		o.ReadPreference = next.ReadPreference
+	}
+	if next.ReadConcern != nil {
+		o.ReadConcern = next.ReadConcern
+	}
+	if next.WriteConcern != nil {
+		o.WriteConcern = next.WriteConcern
+	}
+	if next.MaxTime > 0 {
+		o.MaxTime = next.MaxTime
+	}
+	if next.Comment != nil {
+		o.Comment = next.Comment
+	}
+	if next.Let != nil {
+		o.Let = next.Let
+	}
+	if next.AllowDiskUse != nil {
+		o.AllowDiskUse = next.AllowDiskUse
+	}
+	return o
+}
+
+// collFor returns the driver collection for a call with ctx, cloned with
+// the concerns of its CallOpts.
+func (c *Collection[T]) collFor(ctx context.Context) *mongo.Collection {
+	co := CallOptsFrom(ctx).collectionOptions()
+	if co == nil {
+		return c.coll
+	}
+	return c.coll.Clone(co)
+}
+
+// collectionOptions returns the concerns of o as collection options, or nil
+// if o sets none.
+func (o CallOpts) collectionOptions() *options.CollectionOptionsBuilder {
+	if o.ReadPreference == nil && o.ReadConcern == nil && o.WriteConcern == nil {
+		return nil
+	}
+	co := options.Collection()
+	if o.ReadPreference != nil {
+		co.SetReadPreference(o.ReadPreference)
+	}
+	if o.ReadConcern != nil {
+		co.SetReadConcern(o.ReadConcern)
+	}
+	if o.WriteConcern != nil {
+		co.SetWriteConcern(o.WriteConcern)
+	}
+	return co
+}
+
+// withMaxTime bounds ctx by the MaxTime of its CallOpts.
+func withMaxTime(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d := CallOptsFrom(ctx).MaxTime; d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return ctx, func() {}
+}
+
+// callOptions returns the driver options of type O set by the CallOpts of
+// ctx. Pass it before the method's own options so they take precedence.
+func callOptions[O any](ctx context.Context) options.Lister[O] {
+	return callLister[O]{opts: CallOptsFrom(ctx)}
+}
+
+// callLister applies CallOpts to whichever driver options struct O is,
+// setting the Comment, Let and AllowDiskUse fields O has.
+type callLister[O any] struct{ opts CallOpts }
+
+func (l callLister[O]) List() []func(*O) error {
+	if l.opts.Comment == nil && l.opts.Let == nil && l.opts.AllowDiskUse == nil {
+		return nil
+	}
+	return []func(*O) error{func(o *O) error {
+		v := reflect.ValueOf(o).Elem()
+		setOptField(v, "Comment", l.opts.Comment)
+		setOptField(v, "Let", l.opts.Let)
+		setOptField(v, "AllowDiskUse", l.opts.AllowDiskUse)
+		return nil
+	}}
+}
+
+// setOptField sets the field name of the options struct v to value when v
+// has such a field and value is set. A nil pointer counts as unset.
+func setOptField(v reflect.Value, name string, value any) {
+	if value == nil {
+		return
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return
+	}
+	f := v.FieldByName(name)
+	if !f.IsValid() || !f.CanSet() {
+		return
+	}
+	if rv.Type().AssignableTo(f.Type()) {
+		f.Set(rv)
+	}
+}
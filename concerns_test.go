@@ -0,0 +1,135 @@
+package gmqb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+)
+
+// concernsOf resolves the concern options a CollectionOpt adds.
+func concernsOf(opts ...CollectionOpt) options.CollectionOptions {
+	var cfg collectionConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var o options.CollectionOptions
+	for _, l := range cfg.clone {
+		for _, fn := range l.List() {
+			_ = fn(&o)
+		}
+	}
+	return o
+}
+
+func TestCollection_With(t *testing.T) {
+	o := concernsOf(WithReadPreference(readpref.Secondary()), WithReadConcern(readconcern.Majority()), WithWriteConcern(writeconcern.Majority()))
+	assert.Equal(t, readpref.SecondaryMode, o.ReadPreference.Mode())
+	assert.Equal(t, "majority", o.ReadConcern.Level)
+	assert.Equal(t, "majority", o.WriteConcern.W)
+
+	var seen []OpInfo
+	base := Wrap[bson.M](offlineCollection(t), WithInterceptors(stub(&seen, int64(1))))
+	reports := base.With(WithReadPreference(readpref.Secondary()))
+	assert.NotSame(t, base.Unwrap(), reports.Unwrap())
+	assert.Equal(t, base.Unwrap().Name(), reports.Unwrap().Name())
+	assert.Same(t, base.Unwrap(), base.With().Unwrap())
+
+	// Interceptors carry over, and new ones are added after them.
+	var order []string
+	tag := func(ctx context.Context, op *OpInfo, next Invoker) error {
+		order = append(order, "added")
+		return next(ctx, op)
+	}
+	_, err := reports.With(WithInterceptors(tag)).CountDocuments(context.Background(), NewFilter())
+	require.NoError(t, err)
+	assert.Len(t, seen, 1)
+	assert.Empty(t, order, "the stub short-circuits before the added interceptor")
+	assert.Len(t, base.interceptors, 1)
+
+	cached := WrapWithCache[bson.M](offlineCollection(t), nil, time.Minute)
+	secondary := cached.With(WithReadPreference(readpref.Secondary()))
+	assert.NotSame(t, cached.Unwrap().Unwrap(), secondary.Unwrap().Unwrap())
+	assert.Equal(t, cached.collectionTag(), secondary.collectionTag())
+	assert.Equal(t, time.Minute, secondary.ttl)
+}
+
+func TestWithCallOpts(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, CallOpts{}, CallOptsFrom(ctx))
+
+	allow := true
+	ctx = WithCallOpts(ctx, CallOpts{Comment: "report", MaxTime: time.Second})
+	ctx = WithCallOpts(ctx, CallOpts{AllowDiskUse: &allow, MaxTime: 2 * time.Second})
+	o := CallOptsFrom(ctx)
+	assert.Equal(t, "report", o.Comment)
+	assert.Equal(t, 2*time.Second, o.MaxTime)
+	assert.True(t, *o.AllowDiskUse)
+}
+
+func TestCallOptions(t *testing.T) {
+	allow := true
+	ctx := WithCallOpts(context.Background(), CallOpts{
+		Comment:      "report",
+		Let:          bson.D{{Key: "x", Value: 1}},
+		AllowDiskUse: &allow,
+	})
+
+	agg := resolveOptions[options.AggregateOptions](callOptions[options.AggregateOptions](ctx))
+	assert.Equal(t, "report", agg.Comment)
+	assert.Equal(t, bson.D{{Key: "x", Value: 1}}, agg.Let)
+	assert.True(t, *agg.AllowDiskUse)
+
+	// Fields an operation lacks are ignored.
+	ins := resolveOptions[options.InsertOneOptions](callOptions[options.InsertOneOptions](ctx))
+	assert.Equal(t, "report", ins.Comment)
+
+	// Options passed to the method come later and win.
+	var fo options.FindOptions
+	for _, l := range []options.Lister[options.FindOptions]{callOptions[options.FindOptions](ctx), options.Find().SetAllowDiskUse(false)} {
+		for _, fn := range l.List() {
+			require.NoError(t, fn(&fo))
+		}
+	}
+	assert.Equal(t, "report", fo.Comment)
+	assert.False(t, *fo.AllowDiskUse)
+
+	assert.Empty(t, callOptions[options.FindOptions](context.Background()).List())
+}
+
+func TestCallOpts_Collection(t *testing.T) {
+	coll := Wrap[bson.M](offlineCollection(t))
+	ctx := context.Background()
+	assert.Same(t, coll.Unwrap(), coll.collFor(ctx))
+	assert.Nil(t, CallOpts{Comment: "x"}.collectionOptions())
+
+	ctx = WithCallOpts(ctx, CallOpts{ReadPreference: readpref.SecondaryPreferred(), WriteConcern: writeconcern.W1()})
+	assert.NotSame(t, coll.Unwrap(), coll.collFor(ctx))
+	o := resolveOptions[options.CollectionOptions](CallOptsFrom(ctx).collectionOptions())
+	assert.Equal(t, readpref.SecondaryPreferredMode, o.ReadPreference.Mode())
+	assert.Equal(t, 1, o.WriteConcern.W)
+	assert.Nil(t, o.ReadConcern)
+}
+
+func TestCallOpts_MaxTime(t *testing.T) {
+	coll := Wrap[bson.M](offlineCollection(t))
+	deadlineOf := func(ctx context.Context) time.Time {
+		var deadline time.Time
+		_, err := intercept(coll, ctx, &OpInfo{Operation: OpFind}, func(ctx context.Context, _ *OpInfo) (int, error) {
+			deadline, _ = ctx.Deadline()
+			return 0, nil
+		})
+		require.NoError(t, err)
+		return deadline
+	}
+	assert.True(t, deadlineOf(context.Background()).IsZero())
+	deadline := deadlineOf(WithCallOpts(context.Background(), CallOpts{MaxTime: time.Minute}))
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
@@ -0,0 +1,227 @@
+package gmqb
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Cursor is a typed wrapper around mongo.Cursor that decodes documents one at a
+// time instead of loading the whole result set into memory.
+//
+// Example:
+//
+//	cur, err := coll.FindCursor(ctx, gmqb.Eq("active", true), gmqb.WithBatchSize(1000))
+//	if err != nil {
+//	    return err
+//	}
+//	defer cur.Close(ctx)
+//	for cur.Next(ctx) {
+//	    user := cur.Current()
+//	    // ...
+//	}
+//	return cur.Err()
+type Cursor[T any] struct {
+	cur      *mongo.Cursor
+	deadline time.Time
+	current  T
+	err      error
+}
+
+// newCursor wraps a driver cursor. A zero deadline means no time limit.
+func newCursor[T any](cur *mongo.Cursor, deadline time.Time) *Cursor[T] {
+	return &Cursor[T]{cur: cur, deadline: deadline}
+}
+
+// Next advances the cursor and decodes the next document, fetching a new batch
+// from the server when the current one is exhausted. It returns false when the
+// cursor is exhausted or an error occurs; check Err to tell them apart.
+func (c *Cursor[T]) Next(ctx context.Context) bool {
+	if c.err != nil {
+		return false
+	}
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	if !c.cur.Next(ctx) {
+		c.err = c.cur.Err()
+		return false
+	}
+	var v T
+	if err := c.cur.Decode(&v); err != nil {
+		c.err = fmt.Errorf("gmqb cursor: decode: %w", err)
+		return false
+	}
+	c.current = v
+	return true
+}
+
+// Current returns the document decoded by the last successful call to Next.
+func (c *Cursor[T]) Current() T {
+	return c.current
+}
+
+// Err returns the error that stopped iteration, if any.
+func (c *Cursor[T]) Err() error {
+	return c.err
+}
+
+// RemainingBatchLength returns the number of documents left in the current batch.
+func (c *Cursor[T]) RemainingBatchLength() int {
+	return c.cur.RemainingBatchLength()
+}
+
+// Close closes the cursor, killing it on the server if it is not exhausted.
+func (c *Cursor[T]) Close(ctx context.Context) error {
+	return c.cur.Close(ctx)
+}
+
+// Unwrap returns the underlying mongo.Cursor for direct driver access.
+func (c *Cursor[T]) Unwrap() *mongo.Cursor {
+	return c.cur
+}
+
+// All returns an iterator over the remaining documents. The cursor is closed
+// when the iterator finishes, including when the loop body breaks early. An
+// error is yielded at most once, as the final element.
+//
+// Example:
+//
+//	for user, err := range cur.All(ctx) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    // ...
+//	}
+func (c *Cursor[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		// Close must still reach the server when ctx was cancelled mid-iteration.
+		defer c.Close(context.WithoutCancel(ctx))
+		for c.Next(ctx) {
+			if !yield(c.current, nil) {
+				return
+			}
+		}
+		if c.err != nil {
+			var zero T
+			yield(zero, c.err)
+		}
+	}
+}
+
+// --- Streaming reads ---
+
+// FindCursor runs a find and returns a typed cursor for streaming the results.
+// The caller must Close the cursor.
+//
+// MongoDB equivalent: db.collection.find(filter, opts)
+//
+// See: https://www.mongodb.com/docs/drivers/go/current/fundamentals/crud/read-operations/cursor/
+//
+// Example:
+//
+//	cur, err := coll.FindCursor(ctx, gmqb.Eq("active", true),
+//	    gmqb.WithSort(gmqb.Asc("_id")),
+//	    gmqb.WithBatchSize(500),
+//	)
+func (c *Collection[T]) FindCursor(ctx context.Context, filter Filter, opts ...Opt) (*Cursor[T], error) {
+	return intercept(c, ctx, &OpInfo{Operation: OpFind, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) (*Cursor[T], error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		deadline := cfg.deadline()
+		ctx, cancel := withDeadline(ctx, deadline)
+		defer cancel()
+		cur, err := c.collFor(ctx).Find(ctx, op.Filter.BsonD(), callOptions[options.FindOptions](ctx), driverOptions[options.FindOptions](cfg))
+		if err != nil {
+			return nil, err
+		}
+		return newCursor[T](cur, deadline), nil
+	})
+}
+
+// FindIter runs a find and returns an iterator that decodes documents one at a
+// time. The cursor is closed when iteration ends, including on early break.
+// Errors, including a failure to open the cursor, are yielded as the final element.
+//
+// Example:
+//
+//	for user, err := range coll.FindIter(ctx, gmqb.Eq("active", true), gmqb.WithBatchSize(1000)) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    export(user)
+//	}
+func (c *Collection[T]) FindIter(ctx context.Context, filter Filter, opts ...Opt) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		cur, err := c.FindCursor(ctx, filter, opts...)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		cur.All(ctx)(yield)
+	}
+}
+
+// AggregateCursor runs an aggregation pipeline and returns a typed cursor for
+// streaming the results. The caller must Close the cursor.
+//
+// MongoDB equivalent: db.collection.aggregate(pipeline, opts)
+//
+// Example:
+//
+//	cur, err := gmqb.AggregateCursor[Row](coll, ctx, pipeline, gmqb.WithAllowDiskUse(true))
+func AggregateCursor[R any, T any](c *Collection[T], ctx context.Context, pipeline Pipeline, opts ...Opt) (*Cursor[R], error) {
+	if pipeline.IsEmpty() {
+		return nil, fmt.Errorf("%w: AggregateCursor requires a non-empty pipeline", ErrEmptyPipeline)
+	}
+	if _, err := validateAggregate(pipeline, opts); err != nil {
+		return nil, err
+	}
+	return intercept(c, ctx, &OpInfo{Operation: OpAggregate, Pipeline: pipeline, Options: opts}, func(ctx context.Context, op *OpInfo) (*Cursor[R], error) {
+		cfg, err := buildOpts(op.Operation, optsOf[Opt](op))
+		if err != nil {
+			return nil, err
+		}
+		deadline := cfg.deadline()
+		ctx, cancel := withDeadline(ctx, deadline)
+		defer cancel()
+		cur, err := c.collFor(ctx).Aggregate(ctx, op.Pipeline.BsonD(), callOptions[options.AggregateOptions](ctx), driverOptions[options.AggregateOptions](cfg))
+		if err != nil {
+			return nil, err
+		}
+		return newCursor[R](cur, deadline), nil
+	})
+}
+
+// AggregateIter runs an aggregation pipeline and returns an iterator that
+// decodes results one at a time. The cursor is closed when iteration ends,
+// including on early break.
+//
+// Example:
+//
+//	for row, err := range gmqb.AggregateIter[Row](coll, ctx, pipeline, gmqb.WithBatchSize(500)) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    // ...
+//	}
+func AggregateIter[R any, T any](c *Collection[T], ctx context.Context, pipeline Pipeline, opts ...Opt) iter.Seq2[R, error] {
+	return func(yield func(R, error) bool) {
+		cur, err := AggregateCursor[R](c, ctx, pipeline, opts...)
+		if err != nil {
+			var zero R
+			yield(zero, err)
+			return
+		}
+		cur.All(ctx)(yield)
+	}
+}
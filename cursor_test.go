@@ -0,0 +1,119 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type cursorDoc struct {
+	N int `bson:"n"`
+}
+
+func docsCursor(t *testing.T, docs ...interface{}) *Cursor[cursorDoc] {
+	t.Helper()
+	cur, err := mongo.NewCursorFromDocuments(docs, nil, nil)
+	require.NoError(t, err)
+	return newCursor[cursorDoc](cur, time.Time{})
+}
+
+func TestCursor_Next(t *testing.T) {
+	ctx := context.Background()
+	cur := docsCursor(t, bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: 2}})
+
+	var got []int
+	for cur.Next(ctx) {
+		got = append(got, cur.Current().N)
+	}
+	assert.NoError(t, cur.Err())
+	assert.Equal(t, []int{1, 2}, got)
+	assert.NoError(t, cur.Close(ctx))
+}
+
+func TestCursor_All(t *testing.T) {
+	ctx := context.Background()
+	cur := docsCursor(t, bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: 2}}, bson.D{{Key: "n", Value: 3}})
+
+	var got []int
+	for doc, err := range cur.All(ctx) {
+		require.NoError(t, err)
+		got = append(got, doc.N)
+	}
+	assert.Equal(t, []int{1, 2, 3}, got)
+}
+
+func TestCursor_All_EarlyBreakCloses(t *testing.T) {
+	ctx := context.Background()
+	cur := docsCursor(t, bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: 2}})
+
+	for doc, err := range cur.All(ctx) {
+		require.NoError(t, err)
+		assert.Equal(t, 1, doc.N)
+		break
+	}
+	// The underlying cursor has been closed, so it yields nothing more.
+	assert.False(t, cur.Unwrap().Next(ctx))
+}
+
+func TestCursor_DecodeError(t *testing.T) {
+	ctx := context.Background()
+	cur := docsCursor(t, bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: "not a number"}}, bson.D{{Key: "n", Value: 3}})
+
+	var got []int
+	var errs []error
+	for doc, err := range cur.All(ctx) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		got = append(got, doc.N)
+	}
+	assert.Equal(t, []int{1}, got)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "gmqb cursor: decode")
+	assert.False(t, cur.Next(ctx), "cursor stays stopped after an error")
+}
+
+func TestCursorOptions(t *testing.T) {
+	cfg, err := buildOpts(OpFind, []Opt{
+		WithSort(Asc("n")),
+		WithLimit(5),
+		WithBatchSize(100),
+		WithAllowDiskUse(true),
+		WithMaxTime(time.Minute),
+	})
+	require.NoError(t, err)
+	assert.False(t, cfg.deadline().IsZero())
+
+	fo := resolveOptions[options.FindOptions](driverOptions[options.FindOptions](cfg))
+	assert.Equal(t, int32(100), *fo.BatchSize)
+	assert.True(t, *fo.AllowDiskUse)
+	assert.Equal(t, int64(5), *fo.Limit)
+	assert.NotNil(t, fo.Sort)
+
+	_, err = Wrap[bson.M](offlineCollection(t)).FindCursor(context.Background(), NewFilter(), WithValidation())
+	assert.ErrorIs(t, err, ErrInvalidOption)
+
+	assert.True(t, opConfig{}.deadline().IsZero())
+}
+
+func TestAggregateIter_ValidationError(t *testing.T) {
+	// Validation fails before the collection is touched, so a nil mongo.Collection is safe.
+	coll := Wrap[bson.M](nil)
+	var errs []error
+	for _, err := range AggregateIter[bson.M](coll, context.Background(), NewPipeline().Out("a").Limit(1), WithValidation()) {
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	assert.True(t, errors.Is(errs[0], ErrInvalidPipeline))
+
+	_, err := AggregateCursor[bson.M](coll, context.Background(), NewPipeline())
+	assert.ErrorIs(t, err, ErrEmptyPipeline)
+}
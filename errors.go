@@ -15,4 +15,41 @@ var (
 
 	// ErrEmptyPipeline is returned when an empty pipeline is passed.
 	ErrEmptyPipeline = errors.New("gmqb: empty pipeline")
+
+	// ErrInvalidPipeline is returned by Pipeline.Validate when a pipeline contains
+	// a structural mistake, such as a misplaced $out stage.
+	ErrInvalidPipeline = errors.New("gmqb: invalid pipeline")
+
+	// ErrUnboundParam is returned when a template is bound without a value for
+	// one of its parameters, or when an unbound Param is marshalled.
+	ErrUnboundParam = errors.New("gmqb: unbound parameter")
+
+	// ErrUnsupportedOperator is returned by RunPipeline when a pipeline uses a
+	// stage, query operator or expression the in-memory engine does not implement.
+	ErrUnsupportedOperator = errors.New("gmqb: unsupported operator")
+
+	// ErrInvalidPageToken is returned by Paginate when a page token is
+	// malformed, has been tampered with, or was issued for a different sort.
+	ErrInvalidPageToken = errors.New("gmqb: invalid page token")
+
+	// ErrInvalidIndexTag is returned by IndexesFor and EnsureIndexes when a
+	// gmqb struct tag declares an index that cannot be built.
+	ErrInvalidIndexTag = errors.New("gmqb: invalid index tag")
+
+	// ErrInvalidMigration is returned by NewMigrator and Migrator.Down when a
+	// migration is missing a version or a function it needs.
+	ErrInvalidMigration = errors.New("gmqb: invalid migration")
+
+	// ErrMigrationLocked is returned by Migrator.Up and Migrator.Down when
+	// another instance holds the migration lock for longer than LockWait.
+	ErrMigrationLocked = errors.New("gmqb: migrations are locked by another instance")
+
+	// ErrPartialWrite is returned by InsertManyChunked and BulkWriteChunked
+	// when some of the writes failed. The result maps each failed input to
+	// its error.
+	ErrPartialWrite = errors.New("gmqb: partial write")
+
+	// ErrInvalidOption is returned when an Opt is passed to a method it does
+	// not apply to, such as WithLimit to FindOne.
+	ErrInvalidOption = errors.New("gmqb: invalid option")
 )
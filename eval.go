@@ -0,0 +1,568 @@
+package gmqb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// RunPipeline evaluates an aggregation pipeline in memory against docs and
+// returns the resulting documents. It is intended for unit-testing pipelines
+// without a MongoDB server.
+//
+// Supported stages: $match, $project, $addFields / $set, $unset, $group (with
+// the Acc* accumulators), $sort, $limit, $skip, $unwind, $count, $facet,
+// $replaceRoot / $replaceWith and $sortByCount. Expressions support the
+// arithmetic, comparison, boolean, conditional, string, array, set, date,
+// type conversion and object operators built by the Expr* helpers. Anything
+// else fails with ErrUnsupportedOperator.
+//
+// Input documents are normalized through BSON first, so Go values such as int,
+// time.Time and []string appear in the output as int32/int64, bson.DateTime
+// and bson.A respectively — exactly as they would when read back from MongoDB.
+// The input slice is not modified.
+//
+// Results follow server semantics for the supported subset, including BSON
+// comparison order, numeric type promotion and null/missing handling. Text
+// search, geospatial queries, collation and $meta are not supported.
+//
+// Example:
+//
+//	docs := []bson.D{
+//	    {{"status", "A"}, {"amount", 10}},
+//	    {{"status", "A"}, {"amount", 5}},
+//	    {{"status", "B"}, {"amount", 7}},
+//	}
+//	p := gmqb.NewPipeline().
+//	    Group(gmqb.GroupSpec("$status", gmqb.GroupAcc("total", gmqb.AccSum("$amount")))).
+//	    Sort(gmqb.Asc("_id"))
+//	out, err := gmqb.RunPipeline(docs, p)
+//	// out: [{_id: "A", total: 15}, {_id: "B", total: 7}]
+func RunPipeline(docs []bson.D, p Pipeline) ([]bson.D, error) {
+	stages, err := normalizeDocs(p.stages)
+	if err != nil {
+		return nil, fmt.Errorf("gmqb eval: invalid pipeline: %w", err)
+	}
+	input, err := normalizeDocs(docs)
+	if err != nil {
+		return nil, err
+	}
+	return runStages(newEvaluator(time.Now()), input, stages)
+}
+
+// runStages applies normalized stages in order.
+func runStages(ev *evaluator, docs []bson.D, stages []bson.D) ([]bson.D, error) {
+	for i, stage := range stages {
+		if len(stage) != 1 {
+			return nil, fmt.Errorf("gmqb eval: stage %d: a pipeline stage must have exactly one field", i)
+		}
+		out, err := runStage(ev, docs, stage[0])
+		if err != nil {
+			return nil, fmt.Errorf("gmqb eval: stage %d (%s): %w", i, stage[0].Key, err)
+		}
+		docs = out
+	}
+	return docs, nil
+}
+
+// runStage applies a single stage.
+func runStage(ev *evaluator, docs []bson.D, stage bson.E) ([]bson.D, error) {
+	spec := stage.Value
+	switch StageName(stage.Key) {
+	case StageMatch:
+		return filterDocs(docs, func(d bson.D) (bool, error) {
+			return matchQuery(ev, d, asDoc(spec))
+		})
+	case StageProject:
+		return runProject(ev, docs, asDoc(spec))
+	case StageAddFields, StageSet:
+		return runAddFields(ev, docs, asDoc(spec))
+	case StageUnset:
+		fields := asArray(spec)
+		if fields == nil {
+			fields = bson.A{spec}
+		}
+		return mapDocs(docs, func(d bson.D) (bson.D, error) {
+			for _, f := range fields {
+				name, ok := f.(string)
+				if !ok || name == "" {
+					return nil, fmt.Errorf("fields must be non-empty strings")
+				}
+				d = removePath(d, splitPath(name))
+			}
+			return d, nil
+		})
+	case StageGroup:
+		return runGroup(ev, docs, asDoc(spec))
+	case StageSort:
+		keys, err := parseSortSpec(asDoc(spec))
+		if err != nil {
+			return nil, err
+		}
+		out := append([]bson.D{}, docs...)
+		sort.SliceStable(out, func(i, j int) bool { return keys.compare(out[i], out[j]) < 0 })
+		return out, nil
+	case StageLimit:
+		n, err := intArg(spec, "limit")
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("the limit must be positive")
+		}
+		return docs[:min(n, int64(len(docs)))], nil
+	case StageSkip:
+		n, err := intArg(spec, "skip")
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("skip must be a non-negative integer")
+		}
+		return docs[min(n, int64(len(docs))):], nil
+	case StageUnwind:
+		return runUnwind(docs, spec)
+	case StageCount:
+		name, ok := spec.(string)
+		if !ok || name == "" || strings.HasPrefix(name, "$") || strings.Contains(name, ".") {
+			return nil, fmt.Errorf("the count field must be a non-empty string without '$' or '.'")
+		}
+		if len(docs) == 0 {
+			return []bson.D{}, nil
+		}
+		return []bson.D{{{Key: name, Value: intNumber(int64(len(docs))).value()}}}, nil
+	case StageFacet:
+		out := bson.D{}
+		for _, f := range asDoc(spec) {
+			var sub []bson.D
+			for _, s := range asArray(f.Value) {
+				sub = append(sub, asDoc(s))
+			}
+			res, err := runStages(ev, docs, sub)
+			if err != nil {
+				return nil, fmt.Errorf("facet %q: %w", f.Key, err)
+			}
+			arr := make(bson.A, len(res))
+			for i, d := range res {
+				arr[i] = d
+			}
+			out = append(out, bson.E{Key: f.Key, Value: arr})
+		}
+		return []bson.D{out}, nil
+	case StageReplaceRoot:
+		d, err := namedArgs(spec, []string{"newRoot"})
+		if err != nil {
+			return nil, err
+		}
+		return runReplaceRoot(ev, docs, getField(d, "newRoot"))
+	case StageReplaceWith:
+		return runReplaceRoot(ev, docs, spec)
+	case StageSortByCount:
+		grouped, err := runGroup(ev, docs, bson.D{
+			{Key: "_id", Value: spec},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: int32(1)}}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(grouped, func(i, j int) bool {
+			return compareValues(getField(grouped[i], "count"), getField(grouped[j], "count")) > 0
+		})
+		return grouped, nil
+	}
+	return nil, fmt.Errorf("%w stage %s", ErrUnsupportedOperator, stage.Key)
+}
+
+// filterDocs returns the documents for which keep reports true.
+func filterDocs(docs []bson.D, keep func(bson.D) (bool, error)) ([]bson.D, error) {
+	out := make([]bson.D, 0, len(docs))
+	for _, d := range docs {
+		ok, err := keep(d)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// mapDocs applies fn to each document.
+func mapDocs(docs []bson.D, fn func(bson.D) (bson.D, error)) ([]bson.D, error) {
+	out := make([]bson.D, len(docs))
+	for i, d := range docs {
+		r, err := fn(d)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+// --- $project / $addFields ---
+
+// projField is a flattened $project or $addFields entry.
+type projField struct {
+	path []string
+	mode int // projInclude, projExclude or projCompute
+	expr interface{}
+}
+
+const (
+	projInclude = iota
+	projExclude
+	projCompute
+)
+
+// flattenProjection expands nested specifications such as {a: {b: 1}} into
+// dotted paths. Operator documents and, for $addFields, empty documents are
+// kept as expressions.
+func flattenProjection(spec bson.D, prefix []string, addFields bool) []projField {
+	var out []projField
+	for _, e := range spec {
+		path := append(append([]string{}, prefix...), splitPath(e.Key)...)
+		if d, ok := e.Value.(bson.D); ok && len(d) > 0 && !strings.HasPrefix(d[0].Key, "$") {
+			out = append(out, flattenProjection(d, path, addFields)...)
+			continue
+		}
+		f := projField{path: path, mode: projCompute, expr: e.Value}
+		if !addFields {
+			switch v := e.Value.(type) {
+			case bool:
+				f.mode = map[bool]int{true: projInclude, false: projExclude}[v]
+			case int32, int64, float64:
+				n, _ := asNumber(v)
+				f.mode = map[bool]int{true: projInclude, false: projExclude}[n.float() != 0]
+			}
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// runAddFields implements $addFields and $set.
+func runAddFields(ev *evaluator, docs []bson.D, spec bson.D) ([]bson.D, error) {
+	fields := flattenProjection(spec, nil, true)
+	return mapDocs(docs, func(d bson.D) (bson.D, error) {
+		dev := ev.withRoot(d)
+		vals := make([]interface{}, len(fields))
+		for i, f := range fields {
+			v, err := dev.eval(f.expr)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		for i, f := range fields {
+			d = setPath(d, f.path, vals[i])
+		}
+		return d, nil
+	})
+}
+
+// projNode is a level of an inclusion projection tree.
+type projNode struct {
+	include  bool
+	compute  bool
+	expr     interface{}
+	children map[string]*projNode
+	order    []string
+}
+
+func (n *projNode) child(name string) *projNode {
+	if n.children == nil {
+		n.children = map[string]*projNode{}
+	}
+	c, ok := n.children[name]
+	if !ok {
+		c = &projNode{}
+		n.children[name] = c
+		n.order = append(n.order, name)
+	}
+	return c
+}
+
+// runProject implements $project in inclusion or exclusion mode.
+func runProject(ev *evaluator, docs []bson.D, spec bson.D) ([]bson.D, error) {
+	fields := flattenProjection(spec, nil, false)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("projection specification must have at least one field")
+	}
+	exclusion, inclusion := false, false
+	excludeID := false
+	for _, f := range fields {
+		isID := len(f.path) == 1 && f.path[0] == "_id"
+		switch {
+		case f.mode == projExclude && isID:
+			excludeID = true
+		case f.mode == projExclude:
+			exclusion = true
+		default:
+			inclusion = true
+		}
+	}
+	if exclusion && inclusion {
+		return nil, fmt.Errorf("cannot mix inclusion and exclusion in a projection")
+	}
+	if !inclusion {
+		return mapDocs(docs, func(d bson.D) (bson.D, error) {
+			for _, f := range fields {
+				d = removePath(d, f.path)
+			}
+			return d, nil
+		})
+	}
+	root := &projNode{}
+	if !excludeID {
+		root.child("_id").include = true
+	}
+	for _, f := range fields {
+		if f.mode == projExclude {
+			continue
+		}
+		n := root
+		for _, p := range f.path {
+			n = n.child(p)
+		}
+		n.include = f.mode == projInclude
+		n.compute = f.mode == projCompute
+		n.expr = f.expr
+	}
+	if excludeID {
+		delete(root.children, "_id")
+	}
+	return mapDocs(docs, func(d bson.D) (bson.D, error) {
+		out, err := projectDoc(ev.withRoot(d), d, root)
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	})
+}
+
+// projectDoc applies an inclusion projection tree to a document. Included
+// fields keep their document order; computed fields follow in spec order.
+func projectDoc(ev *evaluator, d bson.D, node *projNode) (bson.D, error) {
+	out := bson.D{}
+	for _, e := range d {
+		c, ok := node.children[e.Key]
+		if !ok || c.compute {
+			continue
+		}
+		if c.include {
+			out = append(out, e)
+			continue
+		}
+		v, err := projectValue(ev, e.Value, c)
+		if err != nil {
+			return nil, err
+		}
+		if !isMissing(v) {
+			out = append(out, bson.E{Key: e.Key, Value: v})
+		}
+	}
+	for _, name := range node.order {
+		c := node.children[name]
+		switch {
+		case c.compute:
+			v, err := ev.eval(c.expr)
+			if err != nil {
+				return nil, err
+			}
+			out = setPath(out, []string{name}, v)
+		case !c.include && hasCompute(c) && isMissing(getField(d, name)):
+			v, err := projectDoc(ev, bson.D{}, c)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, bson.E{Key: name, Value: v})
+		}
+	}
+	return out, nil
+}
+
+// projectValue applies a nested projection to a field value: documents are
+// projected, arrays element-wise, and scalars are dropped.
+func projectValue(ev *evaluator, v interface{}, node *projNode) (interface{}, error) {
+	switch x := v.(type) {
+	case bson.D:
+		return projectDoc(ev, x, node)
+	case bson.A:
+		out := bson.A{}
+		for _, item := range x {
+			r, err := projectValue(ev, item, node)
+			if err != nil {
+				return nil, err
+			}
+			if !isMissing(r) {
+				out = append(out, r)
+			}
+		}
+		return out, nil
+	}
+	if hasCompute(node) {
+		return projectDoc(ev, bson.D{}, node)
+	}
+	return missing, nil
+}
+
+// hasCompute reports whether a projection subtree contains computed fields.
+func hasCompute(n *projNode) bool {
+	if n.compute {
+		return true
+	}
+	for _, c := range n.children {
+		if hasCompute(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- $sort ---
+
+// sortKey is a single field of a sort specification.
+type sortKey struct {
+	path []string
+	desc bool
+}
+
+// sortKeys is a parsed $sort specification.
+type sortKeys []sortKey
+
+// parseSortSpec parses {field: 1 | -1, ...}.
+func parseSortSpec(spec bson.D) (sortKeys, error) {
+	if len(spec) == 0 {
+		return nil, fmt.Errorf("sort specification must have at least one field")
+	}
+	keys := make(sortKeys, 0, len(spec))
+	for _, e := range spec {
+		dir, err := intArg(e.Value, "sort direction")
+		if err != nil || (dir != 1 && dir != -1) {
+			return nil, fmt.Errorf("%w: sort direction for %q must be 1 or -1", ErrUnsupportedOperator, e.Key)
+		}
+		keys = append(keys, sortKey{path: splitPath(e.Key), desc: dir == -1})
+	}
+	return keys, nil
+}
+
+// compare orders two documents by the sort keys.
+func (k sortKeys) compare(a, b bson.D) int {
+	for _, key := range k {
+		c := compareValues(sortValue(a, key), sortValue(b, key))
+		if key.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// sortValue returns the value a document sorts by for a key. Arrays sort by
+// their smallest element ascending and largest descending; empty arrays sort
+// before null, and missing sorts as null.
+func sortValue(d bson.D, key sortKey) interface{} {
+	v := resolvePath(d, key.path)
+	if isMissing(v) {
+		return nil
+	}
+	arr := asArray(v)
+	if arr == nil {
+		return v
+	}
+	if len(arr) == 0 {
+		return bson.Undefined{}
+	}
+	best := arr[0]
+	for _, item := range arr[1:] {
+		c := compareValues(item, best)
+		if (key.desc && c > 0) || (!key.desc && c < 0) {
+			best = item
+		}
+	}
+	if isMissing(best) {
+		return nil
+	}
+	return best
+}
+
+// --- $unwind / $replaceRoot ---
+
+// runUnwind implements $unwind in both its string and document forms.
+func runUnwind(docs []bson.D, spec interface{}) ([]bson.D, error) {
+	path, _ := spec.(string)
+	var indexField string
+	var preserve bool
+	if d := asDoc(spec); d != nil {
+		nd, err := namedArgs(d, []string{"path"}, "includeArrayIndex", "preserveNullAndEmptyArrays")
+		if err != nil {
+			return nil, err
+		}
+		path, _ = getField(nd, "path").(string)
+		indexField, _ = getField(nd, "includeArrayIndex").(string)
+		preserve = isTruthy(getField(nd, "preserveNullAndEmptyArrays"))
+	}
+	if !strings.HasPrefix(path, "$") || len(path) < 2 {
+		return nil, fmt.Errorf("path must be a field path prefixed with '$'")
+	}
+	fieldPath := splitPath(path[1:])
+	var out []bson.D
+	for _, d := range docs {
+		v := docPath(d, fieldPath)
+		arr := asArray(v)
+		switch {
+		case arr != nil && len(arr) > 0:
+			for i, item := range arr {
+				nd := setPath(d, fieldPath, item)
+				if indexField != "" {
+					nd = setPath(nd, splitPath(indexField), int64(i))
+				}
+				out = append(out, nd)
+			}
+		case arr == nil && !isNullish(v):
+			// A non-array operand is treated as a single-element array.
+			nd := d
+			if indexField != "" {
+				nd = setPath(nd, splitPath(indexField), nil)
+			}
+			out = append(out, nd)
+		case preserve:
+			nd := d
+			if arr != nil {
+				nd = removePath(nd, fieldPath)
+			}
+			if indexField != "" {
+				nd = setPath(nd, splitPath(indexField), nil)
+			}
+			out = append(out, nd)
+		}
+	}
+	return out, nil
+}
+
+// docPath follows a dotted path through embedded documents only.
+func docPath(d bson.D, path []string) interface{} {
+	v := getField(d, path[0])
+	if len(path) == 1 {
+		return v
+	}
+	if sub := asDoc(v); sub != nil {
+		return docPath(sub, path[1:])
+	}
+	return missing
+}
+
+// runReplaceRoot implements $replaceRoot and $replaceWith.
+func runReplaceRoot(ev *evaluator, docs []bson.D, expr interface{}) ([]bson.D, error) {
+	return mapDocs(docs, func(d bson.D) (bson.D, error) {
+		v, err := ev.withRoot(d).eval(expr)
+		if err != nil {
+			return nil, err
+		}
+		if !isDoc(v) {
+			return nil, fmt.Errorf("'newRoot' expression must evaluate to an object, but resulting value was of type %s", bsonTypeName(v))
+		}
+		return asDoc(v), nil
+	})
+}
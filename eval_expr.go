@@ -0,0 +1,916 @@
+package gmqb
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// evaluator evaluates aggregation expressions against the current document
+// and the variables in scope ($$ROOT, $$CURRENT, $$NOW, $let variables, ...).
+type evaluator struct {
+	vars map[string]interface{}
+}
+
+// exprOp implements an expression operator. arg is the operator's raw,
+// unevaluated argument.
+type exprOp func(ev *evaluator, arg interface{}) (interface{}, error)
+
+// exprOps holds every supported expression operator, keyed by name.
+var exprOps = map[string]exprOp{}
+
+func init() {
+	for _, table := range []map[string]exprOp{
+		coreExprOps(), arrayExprOps(), stringExprOps(), dateExprOps(),
+	} {
+		for name, op := range table {
+			exprOps[name] = op
+		}
+	}
+}
+
+// newEvaluator creates an evaluator with $$NOW fixed to now.
+func newEvaluator(now time.Time) *evaluator {
+	return &evaluator{vars: map[string]interface{}{
+		"NOW": bson.NewDateTimeFromTime(now),
+	}}
+}
+
+// with returns a copy of the evaluator with an extra variable in scope.
+func (ev *evaluator) with(name string, v interface{}) *evaluator {
+	vars := make(map[string]interface{}, len(ev.vars)+1)
+	for k, val := range ev.vars {
+		vars[k] = val
+	}
+	vars[name] = v
+	return &evaluator{vars: vars}
+}
+
+// withRoot returns a copy of the evaluator with $$ROOT and $$CURRENT set to doc.
+func (ev *evaluator) withRoot(doc bson.D) *evaluator {
+	out := ev.with("ROOT", doc)
+	out.vars["CURRENT"] = doc
+	return out
+}
+
+// eval evaluates an aggregation expression.
+func (ev *evaluator) eval(expr interface{}) (interface{}, error) {
+	switch x := expr.(type) {
+	case string:
+		if strings.HasPrefix(x, "$$") {
+			return ev.variable(x[2:])
+		}
+		if strings.HasPrefix(x, "$") {
+			return resolvePath(ev.vars["CURRENT"], splitPath(x[1:])), nil
+		}
+		return x, nil
+	case bson.D:
+		if len(x) > 0 && strings.HasPrefix(x[0].Key, "$") {
+			if len(x) != 1 {
+				return nil, fmt.Errorf("expression %s must be the only field in its object", x[0].Key)
+			}
+			op, ok := exprOps[x[0].Key]
+			if !ok {
+				return nil, fmt.Errorf("%w %s", ErrUnsupportedOperator, x[0].Key)
+			}
+			v, err := op(ev, x[0].Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", x[0].Key, err)
+			}
+			return v, nil
+		}
+		out := make(bson.D, 0, len(x))
+		for _, e := range x {
+			v, err := ev.eval(e.Value)
+			if err != nil {
+				return nil, err
+			}
+			if !isMissing(v) {
+				out = append(out, bson.E{Key: e.Key, Value: v})
+			}
+		}
+		return out, nil
+	case bson.M:
+		d := documentElems(x)
+		sort.Slice(d, func(i, j int) bool { return d[i].Key < d[j].Key })
+		return ev.eval(d)
+	case bson.A, []interface{}:
+		arr := asArray(x)
+		out := make(bson.A, len(arr))
+		for i, item := range arr {
+			v, err := ev.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			if isMissing(v) {
+				v = nil
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+	return expr, nil
+}
+
+// variable resolves "$$name" or "$$name.path".
+func (ev *evaluator) variable(ref string) (interface{}, error) {
+	name, rest, _ := strings.Cut(ref, ".")
+	if name == "REMOVE" {
+		return missing, nil
+	}
+	v, ok := ev.vars[name]
+	if !ok {
+		return nil, fmt.Errorf("use of undefined variable: %s", name)
+	}
+	if rest == "" {
+		return v, nil
+	}
+	return resolvePath(v, splitPath(rest)), nil
+}
+
+// args evaluates an operator argument given either as an array of expressions
+// or a single expression, and checks the argument count.
+func (ev *evaluator) args(arg interface{}, minN, maxN int) ([]interface{}, error) {
+	var raw []interface{}
+	if arr := asArray(arg); arr != nil {
+		raw = arr
+	} else {
+		raw = []interface{}{arg}
+	}
+	if len(raw) < minN || (maxN >= 0 && len(raw) > maxN) {
+		if minN == maxN {
+			return nil, fmt.Errorf("expected %d argument(s), got %d", minN, len(raw))
+		}
+		return nil, fmt.Errorf("expected %d to %d arguments, got %d", minN, maxN, len(raw))
+	}
+	out := make([]interface{}, len(raw))
+	for i, r := range raw {
+		v, err := ev.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// namedArgs returns the fields of a document-form operator argument, checking
+// that all required fields are present and no unknown fields are given.
+func namedArgs(arg interface{}, required []string, optional ...string) (bson.D, error) {
+	d, ok := arg.(bson.D)
+	if !ok {
+		if m, isM := arg.(bson.M); isM {
+			d = documentElems(m)
+		} else {
+			return nil, fmt.Errorf("argument must be an object")
+		}
+	}
+	known := make(map[string]bool, len(required)+len(optional))
+	for _, k := range required {
+		known[k] = true
+		if isMissing(getField(d, k)) {
+			return nil, fmt.Errorf("missing required argument %q", k)
+		}
+	}
+	for _, k := range optional {
+		known[k] = true
+	}
+	for _, e := range d {
+		if !known[e.Key] {
+			return nil, fmt.Errorf("unknown argument %q", e.Key)
+		}
+	}
+	return d, nil
+}
+
+// evalField evaluates the named field of a document-form argument; absent
+// fields evaluate to missing.
+func (ev *evaluator) evalField(d bson.D, key string) (interface{}, error) {
+	v := getField(d, key)
+	if isMissing(v) {
+		return missing, nil
+	}
+	return ev.eval(v)
+}
+
+// anyNullish reports whether any value is null, undefined or missing.
+func anyNullish(vals []interface{}) bool {
+	for _, v := range vals {
+		if isNullish(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// numbersOf converts all values to numbers or fails naming the operator.
+func numbersOf(vals []interface{}) ([]number, error) {
+	out := make([]number, len(vals))
+	for i, v := range vals {
+		n, ok := asNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("only supports numeric types, not %s", bsonTypeName(v))
+		}
+		out[i] = n
+	}
+	return out, nil
+}
+
+// unaryNumeric builds an operator applying fn to a single numeric argument.
+// Integer arguments are passed through intFn when it is non-nil.
+func unaryNumeric(fn func(float64) float64, intFn func(number) number) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		vals, err := ev.args(arg, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(vals[0]) {
+			return nil, nil
+		}
+		n, ok := asNumber(vals[0])
+		if !ok {
+			return nil, fmt.Errorf("only supports numeric types, not %s", bsonTypeName(vals[0]))
+		}
+		if n.kind != numDouble && intFn != nil {
+			return intFn(n).value(), nil
+		}
+		return fn(n.float()), nil
+	}
+}
+
+// comparison builds a comparison operator from a predicate on compareValues.
+func comparison(pred func(int) bool) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		vals, err := ev.args(arg, 2, 2)
+		if err != nil {
+			return nil, err
+		}
+		return pred(compareValues(vals[0], vals[1])), nil
+	}
+}
+
+// roundPlace rounds f to place decimal places using fn (math.RoundToEven or math.Trunc).
+func roundPlace(fn func(float64) float64, f float64, place int64) float64 {
+	if place == 0 {
+		return fn(f)
+	}
+	p := math.Pow(10, float64(place))
+	return fn(f*p) / p
+}
+
+// roundOp builds $round and $trunc.
+func roundOp(fn func(float64) float64) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		vals, err := ev.args(arg, 1, 2)
+		if err != nil {
+			return nil, err
+		}
+		if anyNullish(vals) {
+			return nil, nil
+		}
+		n, ok := asNumber(vals[0])
+		if !ok {
+			return nil, fmt.Errorf("only supports numeric types, not %s", bsonTypeName(vals[0]))
+		}
+		var place int64
+		if len(vals) == 2 {
+			if place, ok = toInt64(vals[1]); !ok || place < -20 || place > 100 {
+				return nil, fmt.Errorf("place must be an integer between -20 and 100")
+			}
+		}
+		if n.kind != numDouble {
+			if place >= 0 {
+				return n.value(), nil
+			}
+			return widen(n.kind, int64(roundPlace(fn, float64(n.i), place))).value(), nil
+		}
+		return roundPlace(fn, n.f, place), nil
+	}
+}
+
+func coreExprOps() map[string]exprOp {
+	return map[string]exprOp{
+		// --- Arithmetic ---
+		"$add": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 0, -1)
+			if err != nil {
+				return nil, err
+			}
+			if anyNullish(vals) {
+				return nil, nil
+			}
+			sum := intNumber(0)
+			var date *time.Time
+			for _, v := range vals {
+				if t, ok := v.(bson.DateTime); ok {
+					if date != nil {
+						return nil, fmt.Errorf("only one date allowed")
+					}
+					tt := t.Time()
+					date = &tt
+					continue
+				}
+				n, ok := asNumber(v)
+				if !ok {
+					return nil, fmt.Errorf("only supports numeric or date types, not %s", bsonTypeName(v))
+				}
+				sum = addNumbers(sum, n)
+			}
+			if date != nil {
+				return bson.NewDateTimeFromTime(date.Add(time.Duration(math.Round(sum.float())) * time.Millisecond)), nil
+			}
+			return sum.value(), nil
+		},
+		"$subtract": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			if anyNullish(vals) {
+				return nil, nil
+			}
+			da, aDate := vals[0].(bson.DateTime)
+			db, bDate := vals[1].(bson.DateTime)
+			switch {
+			case aDate && bDate:
+				return int64(da) - int64(db), nil
+			case aDate:
+				n, ok := asNumber(vals[1])
+				if !ok {
+					return nil, fmt.Errorf("cannot subtract %s from a date", bsonTypeName(vals[1]))
+				}
+				return bson.DateTime(int64(da) - int64(math.Round(n.float()))), nil
+			case bDate:
+				return nil, fmt.Errorf("cannot subtract a date from %s", bsonTypeName(vals[0]))
+			}
+			ns, err := numbersOf(vals)
+			if err != nil {
+				return nil, err
+			}
+			return subNumbers(ns[0], ns[1]).value(), nil
+		},
+		"$multiply": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 0, -1)
+			if err != nil {
+				return nil, err
+			}
+			if anyNullish(vals) {
+				return nil, nil
+			}
+			ns, err := numbersOf(vals)
+			if err != nil {
+				return nil, err
+			}
+			prod := intNumber(1)
+			for _, n := range ns {
+				prod = mulNumbers(prod, n)
+			}
+			return prod.value(), nil
+		},
+		"$divide": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			if anyNullish(vals) {
+				return nil, nil
+			}
+			ns, err := numbersOf(vals)
+			if err != nil {
+				return nil, err
+			}
+			if ns[1].float() == 0 {
+				return nil, fmt.Errorf("can't divide by zero")
+			}
+			return ns[0].float() / ns[1].float(), nil
+		},
+		"$mod": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			if anyNullish(vals) {
+				return nil, nil
+			}
+			ns, err := numbersOf(vals)
+			if err != nil {
+				return nil, err
+			}
+			if ns[1].float() == 0 {
+				return nil, fmt.Errorf("can't $mod by zero")
+			}
+			if ns[0].kind == numDouble || ns[1].kind == numDouble {
+				return math.Mod(ns[0].float(), ns[1].float()), nil
+			}
+			return widen(max(ns[0].kind, ns[1].kind), ns[0].i%ns[1].i).value(), nil
+		},
+		"$abs": unaryNumeric(math.Abs, func(n number) number {
+			if n.i < 0 {
+				return mulNumbers(n, intNumber(-1))
+			}
+			return n
+		}),
+		"$ceil":  unaryNumeric(math.Ceil, func(n number) number { return n }),
+		"$floor": unaryNumeric(math.Floor, func(n number) number { return n }),
+		"$sqrt": unaryNumeric(func(f float64) float64 {
+			return math.Sqrt(f)
+		}, nil),
+		"$ln":    unaryNumeric(math.Log, nil),
+		"$log10": unaryNumeric(math.Log10, nil),
+		"$exp":   unaryNumeric(math.Exp, nil),
+		"$round": roundOp(math.RoundToEven),
+		"$trunc": roundOp(math.Trunc),
+		"$log": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			if anyNullish(vals) {
+				return nil, nil
+			}
+			ns, err := numbersOf(vals)
+			if err != nil {
+				return nil, err
+			}
+			return math.Log(ns[0].float()) / math.Log(ns[1].float()), nil
+		},
+		"$pow": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			if anyNullish(vals) {
+				return nil, nil
+			}
+			ns, err := numbersOf(vals)
+			if err != nil {
+				return nil, err
+			}
+			base, exp := ns[0], ns[1]
+			if base.kind != numDouble && exp.kind != numDouble && exp.i >= 0 {
+				res := intNumber(1)
+				res.kind = max(base.kind, exp.kind)
+				for i := int64(0); i < exp.i; i++ {
+					res = mulNumbers(res, base)
+					if res.kind == numDouble {
+						return math.Pow(base.float(), exp.float()), nil
+					}
+				}
+				return res.value(), nil
+			}
+			return math.Pow(base.float(), exp.float()), nil
+		},
+
+		// --- Comparison ---
+		"$cmp": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			return int32(compareValues(vals[0], vals[1])), nil
+		},
+		"$eq":  comparison(func(c int) bool { return c == 0 }),
+		"$ne":  comparison(func(c int) bool { return c != 0 }),
+		"$gt":  comparison(func(c int) bool { return c > 0 }),
+		"$gte": comparison(func(c int) bool { return c >= 0 }),
+		"$lt":  comparison(func(c int) bool { return c < 0 }),
+		"$lte": comparison(func(c int) bool { return c <= 0 }),
+
+		// --- Boolean ---
+		"$and": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			for _, item := range argList(arg) {
+				v, err := ev.eval(item)
+				if err != nil {
+					return nil, err
+				}
+				if !isTruthy(v) {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+		"$or": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			for _, item := range argList(arg) {
+				v, err := ev.eval(item)
+				if err != nil {
+					return nil, err
+				}
+				if isTruthy(v) {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		"$not": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 1, 1)
+			if err != nil {
+				return nil, err
+			}
+			return !isTruthy(vals[0]), nil
+		},
+
+		// --- Conditional ---
+		"$cond": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			var ifE, thenE, elseE interface{}
+			if arr := asArray(arg); arr != nil {
+				if len(arr) != 3 {
+					return nil, fmt.Errorf("expected 3 arguments, got %d", len(arr))
+				}
+				ifE, thenE, elseE = arr[0], arr[1], arr[2]
+			} else {
+				d, err := namedArgs(arg, []string{"if", "then", "else"})
+				if err != nil {
+					return nil, err
+				}
+				ifE, thenE, elseE = getField(d, "if"), getField(d, "then"), getField(d, "else")
+			}
+			c, err := ev.eval(ifE)
+			if err != nil {
+				return nil, err
+			}
+			if isTruthy(c) {
+				return ev.eval(thenE)
+			}
+			return ev.eval(elseE)
+		},
+		"$ifNull": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			list := argList(arg)
+			if len(list) < 2 {
+				return nil, fmt.Errorf("needs at least 2 arguments")
+			}
+			for i, item := range list {
+				v, err := ev.eval(item)
+				if err != nil {
+					return nil, err
+				}
+				if !isNullish(v) || i == len(list)-1 {
+					return v, nil
+				}
+			}
+			return nil, nil
+		},
+		"$switch": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"branches"}, "default")
+			if err != nil {
+				return nil, err
+			}
+			for _, b := range asArray(getField(d, "branches")) {
+				bd, err := namedArgs(b, []string{"case", "then"})
+				if err != nil {
+					return nil, err
+				}
+				c, err := ev.eval(getField(bd, "case"))
+				if err != nil {
+					return nil, err
+				}
+				if isTruthy(c) {
+					return ev.eval(getField(bd, "then"))
+				}
+			}
+			if def := getField(d, "default"); !isMissing(def) {
+				return ev.eval(def)
+			}
+			return nil, fmt.Errorf("could not find a matching branch for an input, and no default was specified")
+		},
+
+		// --- Objects and variables ---
+		"$literal": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			return arg, nil
+		},
+		"$let": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"vars", "in"})
+			if err != nil {
+				return nil, err
+			}
+			scope := ev
+			for _, v := range asDoc(getField(d, "vars")) {
+				val, err := ev.eval(v.Value)
+				if err != nil {
+					return nil, err
+				}
+				scope = scope.with(v.Key, val)
+			}
+			return scope.eval(getField(d, "in"))
+		},
+		"$mergeObjects": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 0, -1)
+			if err != nil {
+				return nil, err
+			}
+			out := bson.D{}
+			for _, v := range vals {
+				if isNullish(v) {
+					continue
+				}
+				if !isDoc(v) {
+					return nil, fmt.Errorf("requires object inputs, but input is of type %s", bsonTypeName(v))
+				}
+				for _, e := range asDoc(v) {
+					out = setPath(out, []string{e.Key}, e.Value)
+				}
+			}
+			return out, nil
+		},
+		"$getField": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			field, input := arg, interface{}("$$CURRENT")
+			if d, ok := arg.(bson.D); ok && !isMissing(getField(d, "field")) {
+				nd, err := namedArgs(d, []string{"field"}, "input")
+				if err != nil {
+					return nil, err
+				}
+				field = getField(nd, "field")
+				if in := getField(nd, "input"); !isMissing(in) {
+					input = in
+				}
+			}
+			f, err := ev.eval(field)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := f.(string)
+			if !ok {
+				return nil, fmt.Errorf("field must be a string")
+			}
+			in, err := ev.eval(input)
+			if err != nil {
+				return nil, err
+			}
+			if isNullish(in) {
+				return nil, nil
+			}
+			if !isDoc(in) {
+				return nil, fmt.Errorf("input must be an object")
+			}
+			return getField(asDoc(in), name), nil
+		},
+		"$setField": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			return setFieldOp(ev, arg, true)
+		},
+		"$unsetField": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			return setFieldOp(ev, arg, false)
+		},
+
+		// --- Types ---
+		"$type": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 1, 1)
+			if err != nil {
+				return nil, err
+			}
+			return bsonTypeName(vals[0]), nil
+		},
+		"$isNumber": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 1, 1)
+			if err != nil {
+				return nil, err
+			}
+			return isNumber(vals[0]), nil
+		},
+		"$convert": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"input", "to"}, "onError", "onNull")
+			if err != nil {
+				return nil, err
+			}
+			in, err := ev.eval(getField(d, "input"))
+			if err != nil {
+				return nil, err
+			}
+			to, err := ev.eval(getField(d, "to"))
+			if err != nil {
+				return nil, err
+			}
+			target, ok := to.(string)
+			if !ok {
+				n, isNum := asNumber(to)
+				if !isNum {
+					return nil, fmt.Errorf("'to' must be a type name or code")
+				}
+				target = bsonTypeCodes[int64(n.float())]
+			}
+			if isNullish(in) {
+				if v := getField(d, "onNull"); !isMissing(v) {
+					return ev.eval(v)
+				}
+				return nil, nil
+			}
+			out, cErr := convertValue(in, target)
+			if cErr != nil {
+				if v := getField(d, "onError"); !isMissing(v) {
+					return ev.eval(v)
+				}
+				return nil, cErr
+			}
+			return out, nil
+		},
+		"$toBool":     convertOp("bool"),
+		"$toInt":      convertOp("int"),
+		"$toLong":     convertOp("long"),
+		"$toDouble":   convertOp("double"),
+		"$toDecimal":  convertOp("decimal"),
+		"$toString":   convertOp("string"),
+		"$toDate":     convertOp("date"),
+		"$toObjectId": convertOp("objectId"),
+	}
+}
+
+// argList returns the raw elements of an array argument, or the argument itself.
+func argList(arg interface{}) []interface{} {
+	if arr := asArray(arg); arr != nil {
+		return arr
+	}
+	return []interface{}{arg}
+}
+
+// setFieldOp implements $setField and $unsetField.
+func setFieldOp(ev *evaluator, arg interface{}, set bool) (interface{}, error) {
+	required := []string{"field", "input"}
+	if set {
+		required = append(required, "value")
+	}
+	d, err := namedArgs(arg, required)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ev.eval(getField(d, "field"))
+	if err != nil {
+		return nil, err
+	}
+	name, ok := f.(string)
+	if !ok {
+		return nil, fmt.Errorf("field must be a string")
+	}
+	in, err := ev.eval(getField(d, "input"))
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(in) {
+		return nil, nil
+	}
+	if !isDoc(in) {
+		return nil, fmt.Errorf("input must be an object")
+	}
+	val := interface{}(missing)
+	if set {
+		if val, err = ev.eval(getField(d, "value")); err != nil {
+			return nil, err
+		}
+	}
+	// Field names are literal here, so dots do not denote paths.
+	return setPath(asDoc(in), []string{name}, val), nil
+}
+
+// convertOp builds the $toX shorthand for $convert.
+func convertOp(target string) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		vals, err := ev.args(arg, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(vals[0]) {
+			return nil, nil
+		}
+		return convertValue(vals[0], target)
+	}
+}
+
+// convertValue implements the $convert conversion matrix for common types.
+func convertValue(v interface{}, target string) (interface{}, error) {
+	fail := func() (interface{}, error) {
+		return nil, fmt.Errorf("unsupported conversion from %s to %s", bsonTypeName(v), target)
+	}
+	n, isNum := asNumber(v)
+	switch target {
+	case "bool":
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case string, bson.ObjectID, bson.DateTime:
+			return true, nil
+		}
+		if isNum {
+			return n.float() != 0, nil
+		}
+	case "int", "long":
+		var i int64
+		switch x := v.(type) {
+		case bool:
+			if x {
+				i = 1
+			}
+		case string:
+			p, err := strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse number %q", x)
+			}
+			i = p
+		case bson.DateTime:
+			if target == "int" {
+				return fail()
+			}
+			i = int64(x)
+		default:
+			if !isNum {
+				return fail()
+			}
+			if n.kind == numDouble {
+				if math.IsNaN(n.f) || math.IsInf(n.f, 0) || math.Abs(n.f) >= 1<<63 {
+					return nil, fmt.Errorf("conversion would overflow target type")
+				}
+				i = int64(n.f)
+			} else {
+				i = n.i
+			}
+		}
+		if target == "long" {
+			return i, nil
+		}
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return nil, fmt.Errorf("conversion would overflow target type")
+		}
+		return int32(i), nil
+	case "double":
+		switch x := v.(type) {
+		case bool:
+			if x {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse number %q", x)
+			}
+			return f, nil
+		case bson.DateTime:
+			return float64(x), nil
+		}
+		if isNum {
+			return n.float(), nil
+		}
+	case "decimal":
+		s, err := convertValue(v, "string")
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := v.(bool); ok {
+			s = map[bool]string{true: "1", false: "0"}[b]
+		}
+		d, err := bson.ParseDecimal128(s.(string))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse number %q", s)
+		}
+		return d, nil
+	case "string":
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case bool:
+			return strconv.FormatBool(x), nil
+		case bson.ObjectID:
+			return x.Hex(), nil
+		case bson.DateTime:
+			return x.Time().UTC().Format("2006-01-02T15:04:05.000Z"), nil
+		case bson.Decimal128:
+			return x.String(), nil
+		}
+		if isNum {
+			if n.kind != numDouble {
+				return strconv.FormatInt(n.i, 10), nil
+			}
+			return strconv.FormatFloat(n.f, 'g', -1, 64), nil
+		}
+	case "date":
+		switch x := v.(type) {
+		case bson.DateTime:
+			return x, nil
+		case string:
+			t, err := parseDateString(x, "", time.UTC)
+			if err != nil {
+				return nil, err
+			}
+			return bson.NewDateTimeFromTime(t), nil
+		case bson.ObjectID, bson.Timestamp:
+			t, _ := asTime(x)
+			return bson.NewDateTimeFromTime(t), nil
+		}
+		if isNum && n.kind != numInt32 {
+			return bson.DateTime(int64(n.float())), nil
+		}
+	case "objectId":
+		switch x := v.(type) {
+		case bson.ObjectID:
+			return x, nil
+		case string:
+			id, err := bson.ObjectIDFromHex(x)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse objectId %q", x)
+			}
+			return id, nil
+		}
+	}
+	return fail()
+}
@@ -0,0 +1,666 @@
+package gmqb
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// arrayArg evaluates a single argument that must be an array. ok is false
+// when the argument is null or missing.
+func (ev *evaluator) arrayArg(arg interface{}) (arr bson.A, ok bool, err error) {
+	vals, err := ev.args(arg, 1, 1)
+	if err != nil {
+		return nil, false, err
+	}
+	if isNullish(vals[0]) {
+		return nil, false, nil
+	}
+	if !isArray(vals[0]) {
+		return nil, false, fmt.Errorf("argument must be an array, not %s", bsonTypeName(vals[0]))
+	}
+	return asArray(vals[0]), true, nil
+}
+
+// intArg converts an evaluated argument to an integer.
+func intArg(v interface{}, what string) (int64, error) {
+	n, ok := asNumber(v)
+	if !ok || (n.kind == numDouble && n.f != math.Trunc(n.f)) {
+		return 0, fmt.Errorf("%s must be an integer, not %s", what, bsonTypeName(v))
+	}
+	if n.kind == numDouble {
+		return int64(n.f), nil
+	}
+	return n.i, nil
+}
+
+// setOf de-duplicates values, keeping the first occurrence of each.
+func setOf(vals bson.A) bson.A {
+	seen := make(map[string]bool, len(vals))
+	out := bson.A{}
+	for _, v := range vals {
+		k := groupKey(v)
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// setContains reports whether set contains v.
+func setContains(set bson.A, v interface{}) bool {
+	for _, item := range set {
+		if valuesEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// setArgs evaluates the arguments of a set operator, which must all be arrays.
+// ok is false when any argument is null or missing.
+func (ev *evaluator) setArgs(arg interface{}, minN, maxN int) (sets []bson.A, ok bool, err error) {
+	vals, err := ev.args(arg, minN, maxN)
+	if err != nil {
+		return nil, false, err
+	}
+	if anyNullish(vals) {
+		return nil, false, nil
+	}
+	for _, v := range vals {
+		if !isArray(v) {
+			return nil, false, fmt.Errorf("arguments must be arrays, not %s", bsonTypeName(v))
+		}
+		sets = append(sets, asArray(v))
+	}
+	return sets, true, nil
+}
+
+// iterate evaluates the input of $filter / $map and returns it with the
+// evaluator scope to bind each element under the "as" name.
+func (ev *evaluator) iterate(d bson.D) (bson.A, string, bool, error) {
+	in, err := ev.eval(getField(d, "input"))
+	if err != nil {
+		return nil, "", false, err
+	}
+	as := "this"
+	if v := getField(d, "as"); !isMissing(v) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, "", false, fmt.Errorf("'as' must be a variable name")
+		}
+		if s != "" {
+			as = s
+		}
+	}
+	if isNullish(in) {
+		return nil, as, false, nil
+	}
+	if !isArray(in) {
+		return nil, as, false, fmt.Errorf("input must be an array, not %s", bsonTypeName(in))
+	}
+	return asArray(in), as, true, nil
+}
+
+// accumulatorExpr builds the expression form of an accumulator: given a single
+// array argument it accumulates over the array's elements, otherwise over the
+// argument list.
+func accumulatorExpr(name string) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		vals, err := ev.args(arg, 0, -1)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) == 1 && isArray(vals[0]) {
+			vals = asArray(vals[0])
+		}
+		acc, err := newAccumulator(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vals {
+			if err := acc.add(v); err != nil {
+				return nil, err
+			}
+		}
+		return acc.result(), nil
+	}
+}
+
+func arrayExprOps() map[string]exprOp {
+	return map[string]exprOp{
+		"$arrayElemAt": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			if anyNullish(vals) {
+				return nil, nil
+			}
+			if !isArray(vals[0]) {
+				return nil, fmt.Errorf("first argument must be an array, not %s", bsonTypeName(vals[0]))
+			}
+			idx, err := intArg(vals[1], "index")
+			if err != nil {
+				return nil, err
+			}
+			return elemAt(asArray(vals[0]), idx), nil
+		},
+		"$first": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			arr, ok, err := ev.arrayArg(arg)
+			if err != nil || !ok {
+				return nil, err
+			}
+			return elemAt(arr, 0), nil
+		},
+		"$last": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			arr, ok, err := ev.arrayArg(arg)
+			if err != nil || !ok {
+				return nil, err
+			}
+			return elemAt(arr, -1), nil
+		},
+		"$concatArrays": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			sets, ok, err := ev.setArgs(arg, 0, -1)
+			if err != nil || !ok {
+				return nil, err
+			}
+			out := bson.A{}
+			for _, s := range sets {
+				out = append(out, s...)
+			}
+			return out, nil
+		},
+		"$filter": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"input", "cond"}, "as", "limit")
+			if err != nil {
+				return nil, err
+			}
+			arr, as, ok, err := ev.iterate(d)
+			if err != nil || !ok {
+				return nil, err
+			}
+			limit := int64(-1)
+			if l, err := ev.evalField(d, "limit"); err != nil {
+				return nil, err
+			} else if !isNullish(l) {
+				if limit, err = intArg(l, "limit"); err != nil || limit < 1 {
+					return nil, fmt.Errorf("limit must be a positive integer")
+				}
+			}
+			out := bson.A{}
+			for _, item := range arr {
+				if limit >= 0 && int64(len(out)) >= limit {
+					break
+				}
+				c, err := ev.with(as, item).eval(getField(d, "cond"))
+				if err != nil {
+					return nil, err
+				}
+				if isTruthy(c) {
+					out = append(out, item)
+				}
+			}
+			return out, nil
+		},
+		"$map": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"input", "in"}, "as")
+			if err != nil {
+				return nil, err
+			}
+			arr, as, ok, err := ev.iterate(d)
+			if err != nil || !ok {
+				return nil, err
+			}
+			out := make(bson.A, len(arr))
+			for i, item := range arr {
+				v, err := ev.with(as, item).eval(getField(d, "in"))
+				if err != nil {
+					return nil, err
+				}
+				if isMissing(v) {
+					v = nil
+				}
+				out[i] = v
+			}
+			return out, nil
+		},
+		"$reduce": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"input", "initialValue", "in"})
+			if err != nil {
+				return nil, err
+			}
+			in, err := ev.eval(getField(d, "input"))
+			if err != nil {
+				return nil, err
+			}
+			if isNullish(in) {
+				return nil, nil
+			}
+			if !isArray(in) {
+				return nil, fmt.Errorf("input must be an array, not %s", bsonTypeName(in))
+			}
+			acc, err := ev.eval(getField(d, "initialValue"))
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range asArray(in) {
+				if acc, err = ev.with("this", item).with("value", acc).eval(getField(d, "in")); err != nil {
+					return nil, err
+				}
+			}
+			return acc, nil
+		},
+		"$isArray": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 1, 1)
+			if err != nil {
+				return nil, err
+			}
+			return isArray(vals[0]), nil
+		},
+		"$size": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 1, 1)
+			if err != nil {
+				return nil, err
+			}
+			if !isArray(vals[0]) {
+				return nil, fmt.Errorf("argument must be an array, not %s", bsonTypeName(vals[0]))
+			}
+			return int32(len(asArray(vals[0]))), nil
+		},
+		"$slice": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 3)
+			if err != nil {
+				return nil, err
+			}
+			if anyNullish(vals) {
+				return nil, nil
+			}
+			if !isArray(vals[0]) {
+				return nil, fmt.Errorf("first argument must be an array, not %s", bsonTypeName(vals[0]))
+			}
+			arr := asArray(vals[0])
+			a, err := intArg(vals[1], "position")
+			if err != nil {
+				return nil, err
+			}
+			n := int64(len(arr))
+			var start, end int64
+			if len(vals) == 2 {
+				if a >= 0 {
+					start, end = 0, min(a, n)
+				} else {
+					start, end = max(n+a, 0), n
+				}
+			} else {
+				cnt, err := intArg(vals[2], "n")
+				if err != nil {
+					return nil, err
+				}
+				if cnt <= 0 {
+					return nil, fmt.Errorf("n must be positive")
+				}
+				start = a
+				if a < 0 {
+					start = max(n+a, 0)
+				}
+				start = min(start, n)
+				end = min(start+cnt, n)
+			}
+			return append(bson.A{}, arr[start:end]...), nil
+		},
+		"$in": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			if !isArray(vals[1]) {
+				return nil, fmt.Errorf("second argument must be an array, not %s", bsonTypeName(vals[1]))
+			}
+			return setContains(asArray(vals[1]), vals[0]), nil
+		},
+		"$indexOfArray": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 4)
+			if err != nil {
+				return nil, err
+			}
+			if isNullish(vals[0]) {
+				return nil, nil
+			}
+			if !isArray(vals[0]) {
+				return nil, fmt.Errorf("first argument must be an array, not %s", bsonTypeName(vals[0]))
+			}
+			arr := asArray(vals[0])
+			start, end, err := indexBounds(vals[2:], int64(len(arr)))
+			if err != nil {
+				return nil, err
+			}
+			for i := start; i < end; i++ {
+				if valuesEqual(arr[i], vals[1]) {
+					return int32(i), nil
+				}
+			}
+			return int32(-1), nil
+		},
+		"$reverseArray": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			arr, ok, err := ev.arrayArg(arg)
+			if err != nil || !ok {
+				return nil, err
+			}
+			out := make(bson.A, len(arr))
+			for i, v := range arr {
+				out[len(arr)-1-i] = v
+			}
+			return out, nil
+		},
+		"$sortArray": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"input", "sortBy"})
+			if err != nil {
+				return nil, err
+			}
+			in, err := ev.eval(getField(d, "input"))
+			if err != nil {
+				return nil, err
+			}
+			if isNullish(in) {
+				return nil, nil
+			}
+			if !isArray(in) {
+				return nil, fmt.Errorf("input must be an array, not %s", bsonTypeName(in))
+			}
+			out := append(bson.A{}, asArray(in)...)
+			by := getField(d, "sortBy")
+			if spec := asDoc(by); spec != nil {
+				keys, err := parseSortSpec(spec)
+				if err != nil {
+					return nil, err
+				}
+				sort.SliceStable(out, func(i, j int) bool {
+					return keys.compare(asDoc(out[i]), asDoc(out[j])) < 0
+				})
+				return out, nil
+			}
+			dir, err := intArg(by, "sortBy")
+			if err != nil || (dir != 1 && dir != -1) {
+				return nil, fmt.Errorf("sortBy must be 1, -1 or a sort document")
+			}
+			sortValues(out, dir == -1)
+			return out, nil
+		},
+		"$zip": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"inputs"}, "useLongestLength", "defaults")
+			if err != nil {
+				return nil, err
+			}
+			inputs, err := ev.eval(getField(d, "inputs"))
+			if err != nil {
+				return nil, err
+			}
+			if !isArray(inputs) {
+				return nil, fmt.Errorf("inputs must be an array")
+			}
+			var arrays []bson.A
+			for _, in := range asArray(inputs) {
+				if isNullish(in) {
+					return nil, nil
+				}
+				if !isArray(in) {
+					return nil, fmt.Errorf("inputs must be arrays, not %s", bsonTypeName(in))
+				}
+				arrays = append(arrays, asArray(in))
+			}
+			longest := isTruthy(getField(d, "useLongestLength"))
+			var defaults bson.A
+			if dv := getField(d, "defaults"); !isMissing(dv) {
+				if !longest {
+					return nil, fmt.Errorf("defaults requires useLongestLength")
+				}
+				v, err := ev.eval(dv)
+				if err != nil {
+					return nil, err
+				}
+				if defaults = asArray(v); len(defaults) != len(arrays) {
+					return nil, fmt.Errorf("defaults must have the same length as inputs")
+				}
+			}
+			n := -1
+			for _, a := range arrays {
+				if n < 0 || (longest && len(a) > n) || (!longest && len(a) < n) {
+					n = len(a)
+				}
+			}
+			out := bson.A{}
+			for i := 0; i < n; i++ {
+				row := make(bson.A, len(arrays))
+				for j, a := range arrays {
+					switch {
+					case i < len(a):
+						row[j] = a[i]
+					case defaults != nil:
+						row[j] = defaults[j]
+					}
+				}
+				out = append(out, row)
+			}
+			return out, nil
+		},
+		"$objectToArray": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 1, 1)
+			if err != nil {
+				return nil, err
+			}
+			if isNullish(vals[0]) {
+				return nil, nil
+			}
+			if !isDoc(vals[0]) {
+				return nil, fmt.Errorf("argument must be an object, not %s", bsonTypeName(vals[0]))
+			}
+			out := bson.A{}
+			for _, e := range asDoc(vals[0]) {
+				out = append(out, bson.D{{Key: "k", Value: e.Key}, {Key: "v", Value: e.Value}})
+			}
+			return out, nil
+		},
+		"$arrayToObject": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			arr, ok, err := ev.arrayArg(arg)
+			if err != nil || !ok {
+				return nil, err
+			}
+			out := bson.D{}
+			for _, item := range arr {
+				var k, v interface{}
+				switch {
+				case isArray(item) && len(asArray(item)) == 2:
+					k, v = asArray(item)[0], asArray(item)[1]
+				case isDoc(item) && len(asDoc(item)) == 2:
+					k, v = getField(asDoc(item), "k"), getField(asDoc(item), "v")
+				default:
+					return nil, fmt.Errorf("elements must be [k, v] pairs or {k, v} documents")
+				}
+				key, isStr := k.(string)
+				if !isStr || isMissing(v) {
+					return nil, fmt.Errorf("elements must be [k, v] pairs or {k, v} documents")
+				}
+				out = setPath(out, []string{key}, v)
+			}
+			return out, nil
+		},
+		"$range": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 3)
+			if err != nil {
+				return nil, err
+			}
+			bounds := make([]int64, 3)
+			bounds[2] = 1
+			for i, v := range vals {
+				if bounds[i], err = intArg(v, "argument"); err != nil {
+					return nil, err
+				}
+			}
+			start, end, step := bounds[0], bounds[1], bounds[2]
+			if step == 0 {
+				return nil, fmt.Errorf("step must not be zero")
+			}
+			out := bson.A{}
+			for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+				out = append(out, int32(i))
+			}
+			return out, nil
+		},
+
+		// --- Sets ---
+		"$setEquals": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			sets, _, err := ev.setArgs(arg, 2, -1)
+			if err != nil {
+				return nil, err
+			}
+			if sets == nil {
+				return nil, fmt.Errorf("arguments must be arrays")
+			}
+			for _, s := range sets[1:] {
+				if !isSubset(sets[0], s) || !isSubset(s, sets[0]) {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+		"$setIntersection": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			sets, ok, err := ev.setArgs(arg, 0, -1)
+			if err != nil || !ok {
+				return nil, err
+			}
+			if len(sets) == 0 {
+				return bson.A{}, nil
+			}
+			out := bson.A{}
+			for _, v := range setOf(sets[0]) {
+				in := true
+				for _, s := range sets[1:] {
+					in = in && setContains(s, v)
+				}
+				if in {
+					out = append(out, v)
+				}
+			}
+			return out, nil
+		},
+		"$setUnion": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			sets, ok, err := ev.setArgs(arg, 0, -1)
+			if err != nil || !ok {
+				return nil, err
+			}
+			var all bson.A
+			for _, s := range sets {
+				all = append(all, s...)
+			}
+			return setOf(all), nil
+		},
+		"$setDifference": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			sets, ok, err := ev.setArgs(arg, 2, 2)
+			if err != nil || !ok {
+				return nil, err
+			}
+			out := bson.A{}
+			for _, v := range setOf(sets[0]) {
+				if !setContains(sets[1], v) {
+					out = append(out, v)
+				}
+			}
+			return out, nil
+		},
+		"$setIsSubset": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			sets, ok, err := ev.setArgs(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("arguments must be arrays")
+			}
+			return isSubset(sets[0], sets[1]), nil
+		},
+		"$anyElementTrue": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			arr, ok, err := ev.arrayArg(arg)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("argument must be an array")
+			}
+			for _, v := range arr {
+				if isTruthy(v) {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		"$allElementsTrue": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			arr, ok, err := ev.arrayArg(arg)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("argument must be an array")
+			}
+			for _, v := range arr {
+				if !isTruthy(v) {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+
+		// --- Accumulators in expression form ---
+		"$sum":        accumulatorExpr("$sum"),
+		"$avg":        accumulatorExpr("$avg"),
+		"$min":        accumulatorExpr("$min"),
+		"$max":        accumulatorExpr("$max"),
+		"$stdDevPop":  accumulatorExpr("$stdDevPop"),
+		"$stdDevSamp": accumulatorExpr("$stdDevSamp"),
+	}
+}
+
+// elemAt returns arr[idx], counting from the end for negative indices, or
+// missing when out of range.
+func elemAt(arr bson.A, idx int64) interface{} {
+	if idx < 0 {
+		idx += int64(len(arr))
+	}
+	if idx < 0 || idx >= int64(len(arr)) {
+		return missing
+	}
+	return arr[idx]
+}
+
+// indexBounds parses the optional start and end arguments of $indexOfArray,
+// $indexOfBytes and $indexOfCP, clamped to n.
+func indexBounds(vals []interface{}, n int64) (int64, int64, error) {
+	start, end := int64(0), n
+	for i, v := range vals {
+		x, err := intArg(v, "index")
+		if err != nil {
+			return 0, 0, err
+		}
+		if x < 0 {
+			return 0, 0, fmt.Errorf("index must be non-negative")
+		}
+		if i == 0 {
+			start = x
+		} else {
+			end = min(x, n)
+		}
+	}
+	return start, end, nil
+}
+
+// isSubset reports whether every element of a is in b.
+func isSubset(a, b bson.A) bool {
+	for _, v := range a {
+		if !setContains(b, v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,698 @@
+package gmqb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// defaultDateFormat is the $dateToString format used when none is given.
+const defaultDateFormat = "%Y-%m-%dT%H:%M:%S.%LZ"
+
+// parseTimezone parses an Olson timezone identifier or a UTC offset such as
+// "+03:00", "-0530" or "+03". Null and missing mean UTC.
+func parseTimezone(v interface{}) (*time.Location, error) {
+	if isNullish(v) {
+		return time.UTC, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("timezone must be a string, not %s", bsonTypeName(v))
+	}
+	if s == "" || s == "UTC" || s == "GMT" || s == "Z" {
+		return time.UTC, nil
+	}
+	if s[0] == '+' || s[0] == '-' {
+		digits := strings.ReplaceAll(s[1:], ":", "")
+		var h, m int
+		var err error
+		switch len(digits) {
+		case 2:
+			h, err = strconv.Atoi(digits)
+		case 4:
+			if h, err = strconv.Atoi(digits[:2]); err == nil {
+				m, err = strconv.Atoi(digits[2:])
+			}
+		default:
+			err = fmt.Errorf("bad length")
+		}
+		if err != nil || h > 23 || m > 59 {
+			return nil, fmt.Errorf("unrecognized time zone identifier: %q", s)
+		}
+		off := h*3600 + m*60
+		if s[0] == '-' {
+			off = -off
+		}
+		return time.FixedZone(s, off), nil
+	}
+	loc, err := time.LoadLocation(s)
+	if err != nil {
+		return nil, fmt.Errorf("unrecognized time zone identifier: %q", s)
+	}
+	return loc, nil
+}
+
+// dateArg evaluates the argument of a date part operator, given either as a
+// date expression or as {date, timezone}. ok is false when the date is null.
+func (ev *evaluator) dateArg(arg interface{}) (t time.Time, ok bool, err error) {
+	dateExpr, tzExpr := arg, interface{}(missing)
+	if d, isD := arg.(bson.D); isD && !isMissing(getField(d, "date")) {
+		nd, err := namedArgs(d, []string{"date"}, "timezone")
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		dateExpr, tzExpr = getField(nd, "date"), getField(nd, "timezone")
+	} else if arr := asArray(arg); arr != nil {
+		if len(arr) != 1 {
+			return time.Time{}, false, fmt.Errorf("expected 1 argument, got %d", len(arr))
+		}
+		dateExpr = arr[0]
+	}
+	v, err := ev.eval(dateExpr)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	tz, err := ev.eval(tzExpr)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if isNullish(v) {
+		return time.Time{}, false, nil
+	}
+	t, ok = asTime(v)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("can't convert from BSON type %s to Date", bsonTypeName(v))
+	}
+	loc, err := parseTimezone(tz)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t.In(loc), true, nil
+}
+
+// datePartOp builds an operator that extracts an integer part of a date.
+func datePartOp(part func(time.Time) int) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		t, ok, err := ev.dateArg(arg)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return int32(part(t)), nil
+	}
+}
+
+// sundayWeek returns the week of the year (0-53) with weeks starting on Sunday.
+func sundayWeek(t time.Time) int {
+	return (t.YearDay() - 1 + 7 - int(t.Weekday())) / 7
+}
+
+// isoDayOfWeek returns the ISO 8601 weekday, Monday=1 through Sunday=7.
+func isoDayOfWeek(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+// formatDate formats t using MongoDB's $dateToString format specifiers.
+func formatDate(t time.Time, format string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		if i+1 >= len(format) {
+			return "", fmt.Errorf("format must not end with a single '%%'")
+		}
+		i++
+		isoYear, isoWeek := t.ISOWeek()
+		switch format[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'G':
+			fmt.Fprintf(&b, "%04d", isoYear)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'L':
+			fmt.Fprintf(&b, "%03d", t.Nanosecond()/int(time.Millisecond))
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'w':
+			fmt.Fprintf(&b, "%d", int(t.Weekday())+1)
+		case 'u':
+			fmt.Fprintf(&b, "%d", isoDayOfWeek(t))
+		case 'U':
+			fmt.Fprintf(&b, "%02d", sundayWeek(t))
+		case 'V':
+			fmt.Fprintf(&b, "%02d", isoWeek)
+		case 'b':
+			b.WriteString(t.Month().String()[:3])
+		case 'B':
+			b.WriteString(t.Month().String())
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'Z':
+			_, off := t.Zone()
+			fmt.Fprintf(&b, "%+d", off/60)
+		case '%':
+			b.WriteByte('%')
+		default:
+			return "", fmt.Errorf("invalid format character '%%%c'", format[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// dateLayouts are the layouts $dateFromString accepts when no format is given.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"Jan 2, 2006",
+	"January 2, 2006",
+}
+
+// goDateLayout translates a MongoDB date format to a Go time layout.
+func goDateLayout(format string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		if i+1 >= len(format) {
+			return "", fmt.Errorf("format must not end with a single '%%'")
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			b.WriteString("2006")
+		case 'm':
+			b.WriteString("01")
+		case 'd':
+			b.WriteString("02")
+		case 'H':
+			b.WriteString("15")
+		case 'M':
+			b.WriteString("04")
+		case 'S':
+			b.WriteString("05")
+		case 'L':
+			b.WriteString("000")
+		case 'j':
+			b.WriteString("002")
+		case 'b':
+			b.WriteString("Jan")
+		case 'B':
+			b.WriteString("January")
+		case 'z':
+			b.WriteString("-0700")
+		case '%':
+			b.WriteString("%")
+		default:
+			return "", fmt.Errorf("format character '%%%c' is not supported by $dateFromString", format[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// parseDateString parses s with a MongoDB format (or the default layouts when
+// format is empty). Strings without an explicit offset are read in loc.
+func parseDateString(s, format string, loc *time.Location) (time.Time, error) {
+	layouts := dateLayouts
+	if format != "" {
+		layout, err := goDateLayout(format)
+		if err != nil {
+			return time.Time{}, err
+		}
+		layouts = []string{layout}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("error parsing date string %q", s)
+}
+
+// dateUnits maps fixed-length $dateAdd / $dateDiff units to their duration.
+var dateUnits = map[string]time.Duration{
+	"week":        7 * 24 * time.Hour,
+	"day":         24 * time.Hour,
+	"hour":        time.Hour,
+	"minute":      time.Minute,
+	"second":      time.Second,
+	"millisecond": time.Millisecond,
+}
+
+// monthsPerUnit maps calendar units to a number of months.
+var monthsPerUnit = map[string]int{"year": 12, "quarter": 3, "month": 1}
+
+// addMonths adds n months to t, clamping the day to the end of the target month.
+func addMonths(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(d, last)-1)
+}
+
+// addDateUnits adds amount units to t in t's location. Days and weeks follow
+// the local calendar; shorter units are exact durations.
+func addDateUnits(t time.Time, unit string, amount int64) (time.Time, error) {
+	if months, ok := monthsPerUnit[unit]; ok {
+		return addMonths(t, months*int(amount)), nil
+	}
+	switch unit {
+	case "week":
+		return t.AddDate(0, 0, 7*int(amount)), nil
+	case "day":
+		return t.AddDate(0, 0, int(amount)), nil
+	}
+	d, ok := dateUnits[unit]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown time unit %q", unit)
+	}
+	return t.Add(time.Duration(amount) * d), nil
+}
+
+// weekdays maps startOfWeek names (full or abbreviated) to weekdays.
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+	"wednesday": time.Wednesday, "thursday": time.Thursday, "friday": time.Friday,
+	"saturday": time.Saturday, "sun": time.Sunday, "mon": time.Monday,
+	"tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday,
+	"fri": time.Friday, "sat": time.Saturday,
+}
+
+// startOfWeekArg parses the optional startOfWeek argument (default Sunday).
+func startOfWeekArg(v interface{}) (time.Weekday, error) {
+	if isNullish(v) {
+		return time.Sunday, nil
+	}
+	s, _ := v.(string)
+	wd, ok := weekdays[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown startOfWeek %v", v)
+	}
+	return wd, nil
+}
+
+// truncateDate truncates t (in its location) to the start of the unit.
+func truncateDate(t time.Time, unit string, startOfWeek time.Weekday) (time.Time, error) {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch unit {
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc), nil
+	case "quarter":
+		return time.Date(y, (m-1)/3*3+1, 1, 0, 0, 0, 0, loc), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc), nil
+	case "week":
+		back := (int(t.Weekday()) - int(startOfWeek) + 7) % 7
+		return time.Date(y, m, d-back, 0, 0, 0, 0, loc), nil
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, loc), nil
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc), nil
+	case "minute":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc), nil
+	case "second":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, loc), nil
+	case "millisecond":
+		return t.Truncate(time.Millisecond), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown time unit %q", unit)
+}
+
+// dateDiff counts the unit boundaries crossed between a and b, which are both
+// in the evaluation timezone.
+func dateDiff(a, b time.Time, unit string, startOfWeek time.Weekday) (int64, error) {
+	if months, ok := monthsPerUnit[unit]; ok {
+		ma := int64(a.Year())*12 + int64(a.Month()) - 1
+		mb := int64(b.Year())*12 + int64(b.Month()) - 1
+		return floorDiv(mb, int64(months)) - floorDiv(ma, int64(months)), nil
+	}
+	if unit == "millisecond" {
+		return b.UnixMilli() - a.UnixMilli(), nil
+	}
+	ta, err := truncateDate(a, unit, startOfWeek)
+	if err != nil {
+		return 0, err
+	}
+	tb, _ := truncateDate(b, unit, startOfWeek)
+	if unit == "day" || unit == "week" {
+		// Compare calendar dates so DST transitions do not skew the count.
+		ca := time.Date(ta.Year(), ta.Month(), ta.Day(), 0, 0, 0, 0, time.UTC)
+		cb := time.Date(tb.Year(), tb.Month(), tb.Day(), 0, 0, 0, 0, time.UTC)
+		days := int64(cb.Sub(ca) / (24 * time.Hour))
+		if unit == "week" {
+			return days / 7, nil
+		}
+		return days, nil
+	}
+	return int64(tb.Sub(ta) / dateUnits[unit]), nil
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// binDate truncates t to a bin of binSize units counted from the reference
+// date 2000-01-01 in t's location.
+func binDate(t time.Time, unit string, binSize int64, startOfWeek time.Weekday) (time.Time, error) {
+	start, err := truncateDate(t, unit, startOfWeek)
+	if err != nil || binSize == 1 {
+		return start, err
+	}
+	ref := time.Date(2000, 1, 1, 0, 0, 0, 0, t.Location())
+	if unit == "week" {
+		ref = ref.AddDate(0, 0, (int(startOfWeek)-int(ref.Weekday())+7)%7)
+	}
+	n, err := dateDiff(ref, start, unit, startOfWeek)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return addDateUnits(ref, unit, floorDiv(n, binSize)*binSize)
+}
+
+// dateFields evaluates the named fields of a document-form date operator.
+func (ev *evaluator) dateFields(d bson.D, keys ...string) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		v, err := ev.evalField(d, k)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// dateArithOp builds $dateAdd (sign 1) and $dateSubtract (sign -1).
+func dateArithOp(sign int64) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		d, err := namedArgs(arg, []string{"startDate", "unit", "amount"}, "timezone")
+		if err != nil {
+			return nil, err
+		}
+		f, err := ev.dateFields(d, "startDate", "unit", "amount", "timezone")
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(f["startDate"]) || isNullish(f["unit"]) || isNullish(f["amount"]) {
+			return nil, nil
+		}
+		start, ok := asTime(f["startDate"])
+		if !ok {
+			return nil, fmt.Errorf("startDate must be a date, not %s", bsonTypeName(f["startDate"]))
+		}
+		unit, err := stringArg(f["unit"], "unit")
+		if err != nil {
+			return nil, err
+		}
+		amount, err := intArg(f["amount"], "amount")
+		if err != nil {
+			return nil, err
+		}
+		loc, err := parseTimezone(f["timezone"])
+		if err != nil {
+			return nil, err
+		}
+		out, err := addDateUnits(start.In(loc), unit, sign*amount)
+		if err != nil {
+			return nil, err
+		}
+		return bson.NewDateTimeFromTime(out), nil
+	}
+}
+
+func dateExprOps() map[string]exprOp {
+	return map[string]exprOp{
+		"$year":        datePartOp(func(t time.Time) int { return t.Year() }),
+		"$month":       datePartOp(func(t time.Time) int { return int(t.Month()) }),
+		"$dayOfMonth":  datePartOp(func(t time.Time) int { return t.Day() }),
+		"$hour":        datePartOp(func(t time.Time) int { return t.Hour() }),
+		"$minute":      datePartOp(func(t time.Time) int { return t.Minute() }),
+		"$second":      datePartOp(func(t time.Time) int { return t.Second() }),
+		"$millisecond": datePartOp(func(t time.Time) int { return t.Nanosecond() / int(time.Millisecond) }),
+		"$dayOfWeek":   datePartOp(func(t time.Time) int { return int(t.Weekday()) + 1 }),
+		"$dayOfYear":   datePartOp(func(t time.Time) int { return t.YearDay() }),
+		"$week":        datePartOp(sundayWeek),
+		"$isoWeek": datePartOp(func(t time.Time) int {
+			_, w := t.ISOWeek()
+			return w
+		}),
+		"$isoWeekYear": datePartOp(func(t time.Time) int {
+			y, _ := t.ISOWeek()
+			return y
+		}),
+		"$isoDayOfWeek": datePartOp(isoDayOfWeek),
+		"$dateToString": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"date"}, "format", "timezone", "onNull")
+			if err != nil {
+				return nil, err
+			}
+			f, err := ev.dateFields(d, "date", "format", "timezone")
+			if err != nil {
+				return nil, err
+			}
+			if isNullish(f["date"]) {
+				if v := getField(d, "onNull"); !isMissing(v) {
+					return ev.eval(v)
+				}
+				return nil, nil
+			}
+			t, ok := asTime(f["date"])
+			if !ok {
+				return nil, fmt.Errorf("date must be a date, not %s", bsonTypeName(f["date"]))
+			}
+			loc, err := parseTimezone(f["timezone"])
+			if err != nil {
+				return nil, err
+			}
+			format := defaultDateFormat
+			if !isNullish(f["format"]) {
+				if format, err = stringArg(f["format"], "format"); err != nil {
+					return nil, err
+				}
+			} else if loc != time.UTC {
+				format = "%Y-%m-%dT%H:%M:%S.%L"
+			}
+			return formatDate(t.In(loc), format)
+		},
+		"$dateFromString": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"dateString"}, "format", "timezone", "onError", "onNull")
+			if err != nil {
+				return nil, err
+			}
+			f, err := ev.dateFields(d, "dateString", "format", "timezone")
+			if err != nil {
+				return nil, err
+			}
+			if isNullish(f["dateString"]) {
+				if v := getField(d, "onNull"); !isMissing(v) {
+					return ev.eval(v)
+				}
+				return nil, nil
+			}
+			onError := func(err error) (interface{}, error) {
+				if v := getField(d, "onError"); !isMissing(v) {
+					return ev.eval(v)
+				}
+				return nil, err
+			}
+			s, err := stringArg(f["dateString"], "dateString")
+			if err != nil {
+				return onError(err)
+			}
+			loc, err := parseTimezone(f["timezone"])
+			if err != nil {
+				return nil, err
+			}
+			var format string
+			if !isNullish(f["format"]) {
+				if format, err = stringArg(f["format"], "format"); err != nil {
+					return nil, err
+				}
+			}
+			t, err := parseDateString(s, format, loc)
+			if err != nil {
+				return onError(err)
+			}
+			return bson.NewDateTimeFromTime(t), nil
+		},
+		"$dateFromParts": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			keys := []string{"year", "month", "day", "hour", "minute", "second", "millisecond"}
+			d, err := namedArgs(arg, []string{"year"}, append(keys[1:], "timezone")...)
+			if err != nil {
+				return nil, err
+			}
+			f, err := ev.dateFields(d, append(keys, "timezone")...)
+			if err != nil {
+				return nil, err
+			}
+			parts := []int{0, 1, 1, 0, 0, 0, 0}
+			for i, k := range keys {
+				if isMissing(f[k]) {
+					continue
+				}
+				if f[k] == nil {
+					return nil, nil
+				}
+				n, err := intArg(f[k], k)
+				if err != nil {
+					return nil, err
+				}
+				parts[i] = int(n)
+			}
+			loc, err := parseTimezone(f["timezone"])
+			if err != nil {
+				return nil, err
+			}
+			t := time.Date(parts[0], time.Month(parts[1]), parts[2], parts[3], parts[4], parts[5], parts[6]*int(time.Millisecond), loc)
+			return bson.NewDateTimeFromTime(t), nil
+		},
+		"$dateToParts": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"date"}, "timezone", "iso8601")
+			if err != nil {
+				return nil, err
+			}
+			t, ok, err := ev.dateArg(bson.D{{Key: "date", Value: getField(d, "date")}, {Key: "timezone", Value: nullIfMissing(getField(d, "timezone"))}})
+			if err != nil || !ok {
+				return nil, err
+			}
+			iso, err := ev.evalField(d, "iso8601")
+			if err != nil {
+				return nil, err
+			}
+			out := bson.D{}
+			if isTruthy(iso) {
+				y, w := t.ISOWeek()
+				out = append(out,
+					bson.E{Key: "isoWeekYear", Value: int32(y)},
+					bson.E{Key: "isoWeek", Value: int32(w)},
+					bson.E{Key: "isoDayOfWeek", Value: int32(isoDayOfWeek(t))})
+			} else {
+				out = append(out,
+					bson.E{Key: "year", Value: int32(t.Year())},
+					bson.E{Key: "month", Value: int32(t.Month())},
+					bson.E{Key: "day", Value: int32(t.Day())})
+			}
+			return append(out,
+				bson.E{Key: "hour", Value: int32(t.Hour())},
+				bson.E{Key: "minute", Value: int32(t.Minute())},
+				bson.E{Key: "second", Value: int32(t.Second())},
+				bson.E{Key: "millisecond", Value: int32(t.Nanosecond() / int(time.Millisecond))}), nil
+		},
+		"$dateAdd":      dateArithOp(1),
+		"$dateSubtract": dateArithOp(-1),
+		"$dateDiff": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"startDate", "endDate", "unit"}, "timezone", "startOfWeek")
+			if err != nil {
+				return nil, err
+			}
+			f, err := ev.dateFields(d, "startDate", "endDate", "unit", "timezone", "startOfWeek")
+			if err != nil {
+				return nil, err
+			}
+			if isNullish(f["startDate"]) || isNullish(f["endDate"]) || isNullish(f["unit"]) {
+				return nil, nil
+			}
+			a, okA := asTime(f["startDate"])
+			b, okB := asTime(f["endDate"])
+			if !okA || !okB {
+				return nil, fmt.Errorf("startDate and endDate must be dates")
+			}
+			unit, err := stringArg(f["unit"], "unit")
+			if err != nil {
+				return nil, err
+			}
+			loc, err := parseTimezone(f["timezone"])
+			if err != nil {
+				return nil, err
+			}
+			sow, err := startOfWeekArg(f["startOfWeek"])
+			if err != nil {
+				return nil, err
+			}
+			n, err := dateDiff(a.In(loc), b.In(loc), unit, sow)
+			if err != nil {
+				return nil, err
+			}
+			return n, nil
+		},
+		"$dateTrunc": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			d, err := namedArgs(arg, []string{"date", "unit"}, "binSize", "timezone", "startOfWeek")
+			if err != nil {
+				return nil, err
+			}
+			f, err := ev.dateFields(d, "date", "unit", "binSize", "timezone", "startOfWeek")
+			if err != nil {
+				return nil, err
+			}
+			if isNullish(f["date"]) || isNullish(f["unit"]) || f["binSize"] == nil {
+				return nil, nil
+			}
+			t, ok := asTime(f["date"])
+			if !ok {
+				return nil, fmt.Errorf("date must be a date, not %s", bsonTypeName(f["date"]))
+			}
+			unit, err := stringArg(f["unit"], "unit")
+			if err != nil {
+				return nil, err
+			}
+			binSize := int64(1)
+			if !isMissing(f["binSize"]) {
+				if binSize, err = intArg(f["binSize"], "binSize"); err != nil || binSize < 1 {
+					return nil, fmt.Errorf("binSize must be a positive integer")
+				}
+			}
+			loc, err := parseTimezone(f["timezone"])
+			if err != nil {
+				return nil, err
+			}
+			sow, err := startOfWeekArg(f["startOfWeek"])
+			if err != nil {
+				return nil, err
+			}
+			out, err := binDate(t.In(loc), unit, binSize, sow)
+			if err != nil {
+				return nil, err
+			}
+			return bson.NewDateTimeFromTime(out), nil
+		},
+	}
+}
+
+// nullIfMissing maps missing to null so optional arguments can be forwarded.
+func nullIfMissing(v interface{}) interface{} {
+	if isMissing(v) {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,401 @@
+package gmqb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// stringArg converts an evaluated argument to a string.
+func stringArg(v interface{}, what string) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case bson.Symbol:
+		return string(s), nil
+	}
+	return "", fmt.Errorf("%s must be a string, not %s", what, bsonTypeName(v))
+}
+
+// caseOp builds $toLower and $toUpper, which treat null as the empty string
+// and stringify numbers and dates.
+func caseOp(fn func(string) string) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		vals, err := ev.args(arg, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(vals[0]) {
+			return "", nil
+		}
+		s, err := convertValue(vals[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		return fn(s.(string)), nil
+	}
+}
+
+// trimOp builds $trim, $ltrim and $rtrim.
+func trimOp(left, right bool) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		d, err := namedArgs(arg, []string{"input"}, "chars")
+		if err != nil {
+			return nil, err
+		}
+		in, err := ev.eval(getField(d, "input"))
+		if err != nil {
+			return nil, err
+		}
+		chars, err := ev.evalField(d, "chars")
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(in) || chars == nil {
+			return nil, nil
+		}
+		s, err := stringArg(in, "input")
+		if err != nil {
+			return nil, err
+		}
+		cut := func(r rune) bool { return r == 0 || unicode.IsSpace(r) }
+		if !isMissing(chars) {
+			set, err := stringArg(chars, "chars")
+			if err != nil {
+				return nil, err
+			}
+			cut = func(r rune) bool { return strings.ContainsRune(set, r) }
+		}
+		if left {
+			s = strings.TrimLeftFunc(s, cut)
+		}
+		if right {
+			s = strings.TrimRightFunc(s, cut)
+		}
+		return s, nil
+	}
+}
+
+// regexArgs evaluates the arguments shared by $regexMatch, $regexFind and
+// $regexFindAll. ok is false when the input is null or missing.
+func (ev *evaluator) regexArgs(arg interface{}) (input string, re *regexpMatcher, ok bool, err error) {
+	d, err := namedArgs(arg, []string{"input", "regex"}, "options")
+	if err != nil {
+		return "", nil, false, err
+	}
+	in, err := ev.eval(getField(d, "input"))
+	if err != nil {
+		return "", nil, false, err
+	}
+	rx, err := ev.eval(getField(d, "regex"))
+	if err != nil {
+		return "", nil, false, err
+	}
+	opts, err := ev.evalField(d, "options")
+	if err != nil {
+		return "", nil, false, err
+	}
+	if isNullish(in) {
+		return "", nil, false, nil
+	}
+	if input, err = stringArg(in, "input"); err != nil {
+		return "", nil, false, err
+	}
+	var options string
+	if !isNullish(opts) {
+		if options, err = stringArg(opts, "options"); err != nil {
+			return "", nil, false, err
+		}
+	}
+	if r, isRe := rx.(bson.Regex); isRe && options != "" && r.Options != "" {
+		return "", nil, false, fmt.Errorf("options cannot be given both in regex and options")
+	}
+	compiled, err := compileQueryRegex(rx, options)
+	if err != nil {
+		return "", nil, false, err
+	}
+	return input, &regexpMatcher{re: compiled}, true, nil
+}
+
+// regexpMatcher converts Go regexp matches to $regexFind results.
+type regexpMatcher struct {
+	re *regexp.Regexp
+}
+
+// matches returns up to n $regexFind result documents for s (n < 0 for all).
+func (m *regexpMatcher) matches(s string, n int) bson.A {
+	out := bson.A{}
+	for _, loc := range m.re.FindAllStringSubmatchIndex(s, n) {
+		captures := bson.A{}
+		for g := 1; g < len(loc)/2; g++ {
+			if loc[2*g] < 0 {
+				captures = append(captures, nil)
+			} else {
+				captures = append(captures, s[loc[2*g]:loc[2*g+1]])
+			}
+		}
+		out = append(out, bson.D{
+			{Key: "match", Value: s[loc[0]:loc[1]]},
+			{Key: "idx", Value: int32(utf8.RuneCountInString(s[:loc[0]]))},
+			{Key: "captures", Value: captures},
+		})
+	}
+	return out
+}
+
+// replaceOp builds $replaceOne and $replaceAll.
+func replaceOp(n int) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		d, err := namedArgs(arg, []string{"input", "find", "replacement"})
+		if err != nil {
+			return nil, err
+		}
+		vals := make([]interface{}, 3)
+		for i, k := range []string{"input", "find", "replacement"} {
+			if vals[i], err = ev.eval(getField(d, k)); err != nil {
+				return nil, err
+			}
+		}
+		if anyNullish(vals) {
+			return nil, nil
+		}
+		strs := make([]string, 3)
+		for i, v := range vals {
+			if strs[i], err = stringArg(v, "argument"); err != nil {
+				return nil, err
+			}
+		}
+		return strings.Replace(strs[0], strs[1], strs[2], n), nil
+	}
+}
+
+// substrOp builds $substrBytes and $substrCP.
+func substrOp(codePoints bool) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		vals, err := ev.args(arg, 3, 3)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(vals[0]) {
+			return "", nil
+		}
+		s, err := convertValue(vals[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		start, err := intArg(vals[1], "start")
+		if err != nil {
+			return nil, err
+		}
+		length, err := intArg(vals[2], "length")
+		if err != nil {
+			return nil, err
+		}
+		var units []string
+		if codePoints {
+			for _, r := range s.(string) {
+				units = append(units, string(r))
+			}
+		} else {
+			str := s.(string)
+			if start < 0 {
+				return nil, fmt.Errorf("start must be non-negative")
+			}
+			if start > int64(len(str)) {
+				return "", nil
+			}
+			end := int64(len(str))
+			if length >= 0 && start+length < end {
+				end = start + length
+			}
+			if (start < end && !utf8.RuneStart(str[start])) || (end < int64(len(str)) && !utf8.RuneStart(str[end])) {
+				return nil, fmt.Errorf("invalid range, starting or ending in the middle of a UTF-8 character")
+			}
+			return str[start:end], nil
+		}
+		n := int64(len(units))
+		if start < 0 || length < 0 {
+			return nil, fmt.Errorf("start and length must be non-negative")
+		}
+		if start >= n {
+			return "", nil
+		}
+		return strings.Join(units[start:min(start+length, n)], ""), nil
+	}
+}
+
+// indexOfOp builds $indexOfBytes and $indexOfCP.
+func indexOfOp(codePoints bool) exprOp {
+	return func(ev *evaluator, arg interface{}) (interface{}, error) {
+		vals, err := ev.args(arg, 2, 4)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(vals[0]) {
+			return nil, nil
+		}
+		s, err := stringArg(vals[0], "first argument")
+		if err != nil {
+			return nil, err
+		}
+		sub, err := stringArg(vals[1], "second argument")
+		if err != nil {
+			return nil, err
+		}
+		hay, needle := []rune(s), []rune(sub)
+		if !codePoints {
+			hay, needle = bytesAsRunes(s), bytesAsRunes(sub)
+		}
+		start, end, err := indexBounds(vals[2:], int64(len(hay)))
+		if err != nil {
+			return nil, err
+		}
+		for i := start; i+int64(len(needle)) <= end; i++ {
+			if string(hay[i:i+int64(len(needle))]) == string(needle) {
+				return int32(i), nil
+			}
+		}
+		return int32(-1), nil
+	}
+}
+
+// bytesAsRunes widens each byte of s to a rune so byte and code point
+// searches can share an implementation.
+func bytesAsRunes(s string) []rune {
+	out := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		out[i] = rune(s[i])
+	}
+	return out
+}
+
+func stringExprOps() map[string]exprOp {
+	return map[string]exprOp{
+		"$concat": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 0, -1)
+			if err != nil {
+				return nil, err
+			}
+			if anyNullish(vals) {
+				return nil, nil
+			}
+			var b strings.Builder
+			for _, v := range vals {
+				s, err := stringArg(v, "argument")
+				if err != nil {
+					return nil, err
+				}
+				b.WriteString(s)
+			}
+			return b.String(), nil
+		},
+		"$substr":      substrOp(false),
+		"$substrBytes": substrOp(false),
+		"$substrCP":    substrOp(true),
+		"$toLower":     caseOp(strings.ToLower),
+		"$toUpper":     caseOp(strings.ToUpper),
+		"$trim":        trimOp(true, true),
+		"$ltrim":       trimOp(true, false),
+		"$rtrim":       trimOp(false, true),
+		"$strLenCP": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 1, 1)
+			if err != nil {
+				return nil, err
+			}
+			s, err := stringArg(vals[0], "argument")
+			if err != nil {
+				return nil, err
+			}
+			return int32(utf8.RuneCountInString(s)), nil
+		},
+		"$strLenBytes": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 1, 1)
+			if err != nil {
+				return nil, err
+			}
+			s, err := stringArg(vals[0], "argument")
+			if err != nil {
+				return nil, err
+			}
+			return int32(len(s)), nil
+		},
+		"$strcasecmp": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			strs := make([]string, 2)
+			for i, v := range vals {
+				if isNullish(v) {
+					continue
+				}
+				s, err := convertValue(v, "string")
+				if err != nil {
+					return nil, err
+				}
+				strs[i] = strings.ToUpper(s.(string))
+			}
+			return int32(strings.Compare(strs[0], strs[1])), nil
+		},
+		"$split": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			vals, err := ev.args(arg, 2, 2)
+			if err != nil {
+				return nil, err
+			}
+			if isNullish(vals[0]) {
+				return nil, nil
+			}
+			s, err := stringArg(vals[0], "first argument")
+			if err != nil {
+				return nil, err
+			}
+			sep, err := stringArg(vals[1], "delimiter")
+			if err != nil {
+				return nil, err
+			}
+			if sep == "" {
+				return nil, fmt.Errorf("delimiter must not be empty")
+			}
+			out := bson.A{}
+			for _, part := range strings.Split(s, sep) {
+				out = append(out, part)
+			}
+			return out, nil
+		},
+		"$indexOfBytes": indexOfOp(false),
+		"$indexOfCP":    indexOfOp(true),
+		"$replaceOne":   replaceOp(1),
+		"$replaceAll":   replaceOp(-1),
+		"$regexMatch": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			s, m, ok, err := ev.regexArgs(arg)
+			if err != nil || !ok {
+				return false, err
+			}
+			return m.re.MatchString(s), nil
+		},
+		"$regexFind": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			s, m, ok, err := ev.regexArgs(arg)
+			if err != nil || !ok {
+				return nil, err
+			}
+			if found := m.matches(s, 1); len(found) > 0 {
+				return found[0], nil
+			}
+			return nil, nil
+		},
+		"$regexFindAll": func(ev *evaluator, arg interface{}) (interface{}, error) {
+			s, m, ok, err := ev.regexArgs(arg)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return bson.A{}, nil
+			}
+			return m.matches(s, -1), nil
+		},
+	}
+}
@@ -0,0 +1,526 @@
+package gmqb
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// accumulator folds the values of a $group field (or the elements of an array
+// passed to an accumulator expression) into a single result.
+type accumulator interface {
+	add(v interface{}) error
+	result() interface{}
+}
+
+// accParams are the per-group parameters of accumulators that take more than
+// an input expression: the n of $firstN/$topN, the p of $percentile and the
+// sortBy of $top/$bottom.
+type accParams struct {
+	n      int64
+	p      []float64
+	sortBy sortKeys
+}
+
+// topEntry is the value fed to $top/$bottom accumulators: the document used
+// for sort comparison and the evaluated output.
+type topEntry struct {
+	doc bson.D
+	out interface{}
+}
+
+// newAccumulator creates an accumulator by operator name. params may be nil
+// for accumulators that take a single input expression.
+func newAccumulator(op string, params *accParams) (accumulator, error) {
+	if params == nil {
+		params = &accParams{}
+	}
+	switch op {
+	case "$sum":
+		return &sumAcc{sum: intNumber(0)}, nil
+	case "$avg":
+		return &avgAcc{sum: intNumber(0)}, nil
+	case "$min":
+		return &extremeAcc{sign: -1}, nil
+	case "$max":
+		return &extremeAcc{sign: 1}, nil
+	case "$first":
+		return &firstLastAcc{}, nil
+	case "$last":
+		return &firstLastAcc{last: true}, nil
+	case "$push":
+		return &pushAcc{}, nil
+	case "$addToSet":
+		return &pushAcc{set: true}, nil
+	case "$mergeObjects":
+		return &mergeAcc{out: bson.D{}}, nil
+	case "$stdDevPop":
+		return &stdDevAcc{}, nil
+	case "$stdDevSamp":
+		return &stdDevAcc{sample: true}, nil
+	case "$count":
+		return &countAcc{}, nil
+	case "$firstN", "$lastN":
+		return &firstLastAcc{last: op == "$lastN", n: params.n}, nil
+	case "$maxN":
+		return &extremeNAcc{sign: 1, n: params.n}, nil
+	case "$minN":
+		return &extremeNAcc{sign: -1, n: params.n}, nil
+	case "$top", "$bottom", "$topN", "$bottomN":
+		n := params.n
+		if op == "$top" || op == "$bottom" {
+			n = 0
+		}
+		return &topAcc{bottom: op == "$bottom" || op == "$bottomN", n: n, sortBy: params.sortBy}, nil
+	case "$median":
+		return &percentileAcc{p: []float64{0.5}, scalar: true}, nil
+	case "$percentile":
+		return &percentileAcc{p: params.p}, nil
+	}
+	return nil, fmt.Errorf("%w %s", ErrUnsupportedOperator, op)
+}
+
+type sumAcc struct{ sum number }
+
+func (a *sumAcc) add(v interface{}) error {
+	if n, ok := asNumber(v); ok {
+		a.sum = addNumbers(a.sum, n)
+	}
+	return nil
+}
+
+func (a *sumAcc) result() interface{} { return a.sum.value() }
+
+type avgAcc struct {
+	sum   number
+	count int64
+}
+
+func (a *avgAcc) add(v interface{}) error {
+	if n, ok := asNumber(v); ok {
+		a.sum = addNumbers(a.sum, n)
+		a.count++
+	}
+	return nil
+}
+
+func (a *avgAcc) result() interface{} {
+	if a.count == 0 {
+		return nil
+	}
+	return a.sum.float() / float64(a.count)
+}
+
+// extremeAcc implements $min (sign -1) and $max (sign 1), ignoring nulls.
+type extremeAcc struct {
+	sign int
+	best interface{}
+	seen bool
+}
+
+func (a *extremeAcc) add(v interface{}) error {
+	if isNullish(v) {
+		return nil
+	}
+	if !a.seen || compareValues(v, a.best)*a.sign > 0 {
+		a.best, a.seen = v, true
+	}
+	return nil
+}
+
+func (a *extremeAcc) result() interface{} {
+	if !a.seen {
+		return nil
+	}
+	return a.best
+}
+
+// firstLastAcc implements $first/$last and, with n > 0, $firstN/$lastN.
+type firstLastAcc struct {
+	last bool
+	n    int64
+	vals bson.A
+}
+
+func (a *firstLastAcc) add(v interface{}) error {
+	if isMissing(v) {
+		v = nil
+	}
+	switch {
+	case a.last:
+		a.vals = append(a.vals, v)
+		if keep := max(a.n, 1); int64(len(a.vals)) > keep {
+			a.vals = a.vals[int64(len(a.vals))-keep:]
+		}
+	case int64(len(a.vals)) < max(a.n, 1):
+		a.vals = append(a.vals, v)
+	}
+	return nil
+}
+
+func (a *firstLastAcc) result() interface{} {
+	if a.n > 0 {
+		return append(bson.A{}, a.vals...)
+	}
+	if len(a.vals) == 0 {
+		return nil
+	}
+	return a.vals[0]
+}
+
+// pushAcc implements $push and $addToSet. Missing values are skipped.
+type pushAcc struct {
+	set  bool
+	vals bson.A
+	seen map[string]bool
+}
+
+func (a *pushAcc) add(v interface{}) error {
+	if isMissing(v) {
+		return nil
+	}
+	if a.set {
+		if a.seen == nil {
+			a.seen = map[string]bool{}
+		}
+		k := groupKey(v)
+		if a.seen[k] {
+			return nil
+		}
+		a.seen[k] = true
+	}
+	a.vals = append(a.vals, v)
+	return nil
+}
+
+func (a *pushAcc) result() interface{} { return append(bson.A{}, a.vals...) }
+
+type mergeAcc struct{ out bson.D }
+
+func (a *mergeAcc) add(v interface{}) error {
+	if isNullish(v) {
+		return nil
+	}
+	if !isDoc(v) {
+		return fmt.Errorf("$mergeObjects requires object inputs, but input is of type %s", bsonTypeName(v))
+	}
+	for _, e := range asDoc(v) {
+		a.out = setPath(a.out, []string{e.Key}, e.Value)
+	}
+	return nil
+}
+
+func (a *mergeAcc) result() interface{} { return a.out }
+
+// stdDevAcc implements $stdDevPop and $stdDevSamp using Welford's algorithm.
+type stdDevAcc struct {
+	sample bool
+	count  float64
+	mean   float64
+	m2     float64
+}
+
+func (a *stdDevAcc) add(v interface{}) error {
+	n, ok := asNumber(v)
+	if !ok {
+		return nil
+	}
+	x := n.float()
+	a.count++
+	delta := x - a.mean
+	a.mean += delta / a.count
+	a.m2 += delta * (x - a.mean)
+	return nil
+}
+
+func (a *stdDevAcc) result() interface{} {
+	switch {
+	case a.count == 0, a.sample && a.count < 2:
+		return nil
+	case a.sample:
+		return math.Sqrt(a.m2 / (a.count - 1))
+	}
+	return math.Sqrt(a.m2 / a.count)
+}
+
+type countAcc struct{ n int64 }
+
+func (a *countAcc) add(interface{}) error {
+	a.n++
+	return nil
+}
+
+func (a *countAcc) result() interface{} { return intNumber(a.n).value() }
+
+// extremeNAcc implements $maxN (sign 1) and $minN (sign -1), ignoring nulls.
+type extremeNAcc struct {
+	sign int
+	n    int64
+	vals bson.A
+}
+
+func (a *extremeNAcc) add(v interface{}) error {
+	if !isNullish(v) {
+		a.vals = append(a.vals, v)
+	}
+	return nil
+}
+
+func (a *extremeNAcc) result() interface{} {
+	out := append(bson.A{}, a.vals...)
+	sortValues(out, a.sign > 0)
+	if int64(len(out)) > a.n {
+		out = out[:a.n]
+	}
+	return out
+}
+
+// topAcc implements $top/$bottom (n == 0) and $topN/$bottomN.
+type topAcc struct {
+	bottom  bool
+	n       int64
+	sortBy  sortKeys
+	entries []topEntry
+}
+
+func (a *topAcc) add(v interface{}) error {
+	e, ok := v.(topEntry)
+	if !ok {
+		return fmt.Errorf("internal: unexpected %T fed to $top/$bottom", v)
+	}
+	if isMissing(e.out) {
+		e.out = nil
+	}
+	a.entries = append(a.entries, e)
+	return nil
+}
+
+func (a *topAcc) result() interface{} {
+	entries := append([]topEntry{}, a.entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return a.sortBy.compare(entries[i].doc, entries[j].doc) < 0
+	})
+	if a.bottom {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+	if a.n == 0 {
+		if len(entries) == 0 {
+			return nil
+		}
+		return entries[0].out
+	}
+	out := bson.A{}
+	for i := 0; i < len(entries) && int64(i) < a.n; i++ {
+		out = append(out, entries[i].out)
+	}
+	if a.bottom {
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+	return out
+}
+
+// percentileAcc implements $percentile and $median using the nearest-rank
+// method, which matches MongoDB's "approximate" method for small inputs.
+type percentileAcc struct {
+	p      []float64
+	scalar bool
+	vals   []float64
+}
+
+func (a *percentileAcc) add(v interface{}) error {
+	if n, ok := asNumber(v); ok {
+		a.vals = append(a.vals, n.float())
+	}
+	return nil
+}
+
+func (a *percentileAcc) result() interface{} {
+	if len(a.vals) == 0 {
+		if a.scalar {
+			return nil
+		}
+		out := make(bson.A, len(a.p))
+		return out
+	}
+	sorted := append([]float64{}, a.vals...)
+	sort.Float64s(sorted)
+	out := make(bson.A, len(a.p))
+	for i, p := range a.p {
+		idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+		out[i] = sorted[min(max(idx, 0), len(sorted)-1)]
+	}
+	if a.scalar {
+		return out[0]
+	}
+	return out
+}
+
+// groupField is a parsed accumulator field of a $group stage.
+type groupField struct {
+	name  string
+	op    string
+	input interface{} // expression whose value is added per document
+	n     interface{} // n expression of the N accumulators
+	p     interface{} // p expression of $percentile
+	sort  sortKeys    // sortBy of $top/$bottom
+}
+
+// parseGroupField parses `name: {$op: arg}` from a $group specification.
+func parseGroupField(e bson.E) (groupField, error) {
+	spec := asDoc(e.Value)
+	if len(spec) != 1 || len(spec[0].Key) == 0 || spec[0].Key[0] != '$' {
+		return groupField{}, fmt.Errorf("field %q must be an accumulator object", e.Key)
+	}
+	f := groupField{name: e.Key, op: spec[0].Key, input: spec[0].Value}
+	switch f.op {
+	case "$count":
+		f.input = int32(1)
+	case "$firstN", "$lastN", "$maxN", "$minN":
+		d, err := namedArgs(spec[0].Value, []string{"input", "n"})
+		if err != nil {
+			return groupField{}, fmt.Errorf("%s: %w", f.op, err)
+		}
+		f.input, f.n = getField(d, "input"), getField(d, "n")
+	case "$top", "$bottom", "$topN", "$bottomN":
+		required := []string{"sortBy", "output"}
+		if f.op == "$topN" || f.op == "$bottomN" {
+			required = append(required, "n")
+		}
+		d, err := namedArgs(spec[0].Value, required)
+		if err != nil {
+			return groupField{}, fmt.Errorf("%s: %w", f.op, err)
+		}
+		if f.sort, err = parseSortSpec(asDoc(getField(d, "sortBy"))); err != nil {
+			return groupField{}, fmt.Errorf("%s: %w", f.op, err)
+		}
+		f.input, f.n = getField(d, "output"), getField(d, "n")
+	case "$median", "$percentile":
+		required := []string{"input", "method"}
+		if f.op == "$percentile" {
+			required = append(required, "p")
+		}
+		d, err := namedArgs(spec[0].Value, required)
+		if err != nil {
+			return groupField{}, fmt.Errorf("%s: %w", f.op, err)
+		}
+		f.input, f.p = getField(d, "input"), getField(d, "p")
+	}
+	if _, err := newAccumulator(f.op, nil); err != nil {
+		return groupField{}, err
+	}
+	return f, nil
+}
+
+// newFor creates the field's accumulator for a new group, evaluating n and p
+// against the group's first document.
+func (f groupField) newFor(ev *evaluator) (accumulator, error) {
+	params := &accParams{sortBy: f.sort}
+	if !isMissing(f.n) && f.n != nil {
+		v, err := ev.eval(f.n)
+		if err != nil {
+			return nil, err
+		}
+		if params.n, err = intArg(v, "n"); err != nil || params.n < 1 {
+			return nil, fmt.Errorf("%s: n must be a positive integer", f.op)
+		}
+	}
+	if f.op == "$percentile" {
+		v, err := ev.eval(f.p)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range asArray(v) {
+			n, ok := asNumber(item)
+			if !ok || n.float() < 0 || n.float() > 1 {
+				return nil, fmt.Errorf("$percentile: p must be an array of numbers between 0 and 1")
+			}
+			params.p = append(params.p, n.float())
+		}
+		if len(params.p) == 0 {
+			return nil, fmt.Errorf("$percentile: p must be a non-empty array")
+		}
+	}
+	return newAccumulator(f.op, params)
+}
+
+// feed evaluates the field's input for the current document and adds it.
+func (f groupField) feed(ev *evaluator, doc bson.D, acc accumulator) error {
+	v, err := ev.eval(f.input)
+	if err != nil {
+		return err
+	}
+	if f.sort != nil {
+		v = topEntry{doc: doc, out: v}
+	}
+	return acc.add(v)
+}
+
+// runGroup implements the $group stage. Groups are emitted in order of first
+// appearance.
+func runGroup(ev *evaluator, docs []bson.D, spec bson.D) ([]bson.D, error) {
+	idExpr := getField(spec, "_id")
+	if isMissing(idExpr) {
+		return nil, fmt.Errorf("a group specification must include an _id")
+	}
+	var fields []groupField
+	for _, e := range spec {
+		if e.Key == "_id" {
+			continue
+		}
+		f, err := parseGroupField(e)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	type group struct {
+		id   interface{}
+		accs []accumulator
+	}
+	var order []*group
+	groups := map[string]*group{}
+	for _, doc := range docs {
+		dev := ev.withRoot(doc)
+		id, err := dev.eval(idExpr)
+		if err != nil {
+			return nil, err
+		}
+		if isMissing(id) {
+			id = nil
+		}
+		key := groupKey(id)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{id: id}
+			for _, f := range fields {
+				acc, err := f.newFor(dev)
+				if err != nil {
+					return nil, err
+				}
+				g.accs = append(g.accs, acc)
+			}
+			groups[key] = g
+			order = append(order, g)
+		}
+		for i, f := range fields {
+			if err := f.feed(dev, doc, g.accs[i]); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+	}
+	out := make([]bson.D, 0, len(order))
+	for _, g := range order {
+		d := bson.D{{Key: "_id", Value: g.id}}
+		for i, f := range fields {
+			d = append(d, bson.E{Key: f.name, Value: g.accs[i].result()})
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
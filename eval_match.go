@@ -0,0 +1,366 @@
+package gmqb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// matchQuery reports whether doc satisfies a $match / find query filter.
+func matchQuery(ev *evaluator, doc bson.D, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		ok, err := matchElem(ev, doc, e)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchElem evaluates a single top-level filter element.
+func matchElem(ev *evaluator, doc bson.D, e bson.E) (bool, error) {
+	switch e.Key {
+	case "$and", "$or", "$nor":
+		subs := asArray(e.Value)
+		if subs == nil {
+			return false, fmt.Errorf("%s must be an array", e.Key)
+		}
+		for _, sub := range subs {
+			ok, err := matchQuery(ev, doc, asDoc(sub))
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case e.Key == "$and" && !ok:
+				return false, nil
+			case e.Key == "$or" && ok:
+				return true, nil
+			case e.Key == "$nor" && ok:
+				return false, nil
+			}
+		}
+		return e.Key != "$or", nil
+	case "$expr":
+		v, err := ev.withRoot(doc).eval(e.Value)
+		if err != nil {
+			return false, err
+		}
+		return isTruthy(v), nil
+	case "$comment":
+		return true, nil
+	}
+	if strings.HasPrefix(e.Key, "$") {
+		return false, fmt.Errorf("%w %s", ErrUnsupportedOperator, e.Key)
+	}
+	return matchField(ev, doc, e.Key, e.Value)
+}
+
+// isOperatorDoc reports whether a filter value is an operator document such as
+// {"$gt": 5} rather than a literal document to compare against.
+func isOperatorDoc(v interface{}) (bson.D, bool) {
+	d, ok := v.(bson.D)
+	if !ok || len(d) == 0 {
+		return nil, false
+	}
+	return d, strings.HasPrefix(d[0].Key, "$")
+}
+
+// matchField evaluates a condition on a field path.
+func matchField(ev *evaluator, doc bson.D, path string, cond interface{}) (bool, error) {
+	cands := queryCandidates(doc, splitPath(path))
+	ops, ok := isOperatorDoc(cond)
+	if !ok {
+		return anyCandidate(cands, func(c interface{}) bool { return queryEq(c, cond) }), nil
+	}
+	for _, op := range ops {
+		ok, err := matchOp(ev, doc, path, cands, op, ops)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchOp evaluates one query operator against the candidate values of a field.
+func matchOp(ev *evaluator, doc bson.D, path string, cands []interface{}, op bson.E, siblings bson.D) (bool, error) {
+	val := op.Value
+	switch op.Key {
+	case "$eq":
+		return anyCandidate(cands, func(c interface{}) bool { return queryEq(c, val) }), nil
+	case "$ne":
+		return !anyCandidate(cands, func(c interface{}) bool { return queryEq(c, val) }), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		return anyCandidate(cands, func(c interface{}) bool { return queryCompare(op.Key, c, val) }), nil
+	case "$in", "$nin":
+		list := asArray(val)
+		if list == nil {
+			return false, fmt.Errorf("%s needs an array", op.Key)
+		}
+		in := anyCandidate(cands, func(c interface{}) bool {
+			for _, item := range list {
+				if queryEq(c, item) {
+					return true
+				}
+			}
+			return false
+		})
+		return in == (op.Key == "$in"), nil
+	case "$exists":
+		exists := anyCandidate(cands, func(c interface{}) bool { return !isMissing(c) })
+		return exists == isTruthy(val), nil
+	case "$type":
+		types := asArray(val)
+		if types == nil {
+			types = bson.A{val}
+		}
+		return anyCandidate(cands, func(c interface{}) bool {
+			for _, t := range types {
+				if matchesType(c, t) {
+					return true
+				}
+			}
+			return false
+		}), nil
+	case "$regex":
+		re, err := compileQueryRegex(val, stringOf(getField(siblings, "$options")))
+		if err != nil {
+			return false, err
+		}
+		return anyCandidate(cands, func(c interface{}) bool {
+			s, ok := c.(string)
+			return ok && re.MatchString(s)
+		}), nil
+	case "$options":
+		return true, nil
+	case "$not":
+		if _, ok := isOperatorDoc(val); !ok {
+			if _, isRe := val.(bson.Regex); !isRe {
+				return false, fmt.Errorf("$not needs an operator document or regex")
+			}
+		}
+		ok, err := matchField(ev, doc, path, val)
+		return !ok, err
+	case "$all":
+		list := asArray(val)
+		if list == nil {
+			return false, fmt.Errorf("$all needs an array")
+		}
+		if len(list) == 0 {
+			return false, nil
+		}
+		for _, item := range list {
+			if sub, ok := isOperatorDoc(item); ok && sub[0].Key == "$elemMatch" {
+				ok, err := matchOp(ev, doc, path, cands, sub[0], sub)
+				if err != nil || !ok {
+					return false, err
+				}
+				continue
+			}
+			if !anyCandidate(cands, func(c interface{}) bool { return queryEq(c, item) }) {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "$elemMatch":
+		cond := asDoc(val)
+		_, isOps := isOperatorDoc(cond)
+		for _, c := range cands {
+			for _, elem := range asArray(c) {
+				var ok bool
+				var err error
+				if isOps {
+					ok, err = matchField(ev, bson.D{{Key: "v", Value: elem}}, "v", cond)
+				} else if d, isD := elem.(bson.D); isD {
+					ok, err = matchQuery(ev, d, cond)
+				}
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	case "$size":
+		n, ok := toInt64(val)
+		if !ok {
+			return false, fmt.Errorf("$size needs an integer")
+		}
+		return anyCandidate(cands, func(c interface{}) bool {
+			return isArray(c) && int64(len(asArray(c))) == n
+		}), nil
+	case "$mod":
+		args := asArray(val)
+		if len(args) != 2 {
+			return false, fmt.Errorf("$mod needs [divisor, remainder]")
+		}
+		div, ok1 := asNumber(args[0])
+		rem, ok2 := asNumber(args[1])
+		if !ok1 || !ok2 || int64(div.float()) == 0 {
+			return false, fmt.Errorf("$mod needs a non-zero numeric divisor and numeric remainder")
+		}
+		return anyCandidate(cands, func(c interface{}) bool {
+			n, ok := asNumber(c)
+			return ok && int64(n.float())%int64(div.float()) == int64(rem.float())
+		}), nil
+	case "$comment":
+		return true, nil
+	}
+	return false, fmt.Errorf("%w %s", ErrUnsupportedOperator, op.Key)
+}
+
+// queryCandidates returns the values a query condition on path is tested
+// against. Arrays contribute both themselves and their elements, and paths
+// descend into arrays of documents, mirroring MongoDB's query semantics.
+func queryCandidates(v interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		if arr := asArray(v); arr != nil {
+			return append([]interface{}{v}, arr...)
+		}
+		return []interface{}{v}
+	}
+	switch x := v.(type) {
+	case bson.D, bson.M:
+		return queryCandidates(getField(asDoc(x), path[0]), path[1:])
+	case bson.A, []interface{}:
+		arr := asArray(x)
+		var out []interface{}
+		if idx, err := strconv.Atoi(path[0]); err == nil && idx >= 0 && idx < len(arr) {
+			out = append(out, queryCandidates(arr[idx], path[1:])...)
+		}
+		for _, item := range arr {
+			if isDoc(item) {
+				out = append(out, queryCandidates(item, path)...)
+			}
+		}
+		if len(out) == 0 {
+			return []interface{}{missing}
+		}
+		return out
+	}
+	return []interface{}{missing}
+}
+
+func anyCandidate(cands []interface{}, fn func(interface{}) bool) bool {
+	for _, c := range cands {
+		if fn(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryEq implements query equality: null matches missing fields and regexes
+// match strings.
+func queryEq(c, val interface{}) bool {
+	switch v := val.(type) {
+	case nil, bson.Null:
+		return c == nil || isMissing(c) || c == (bson.Null{})
+	case bson.Regex:
+		if s, ok := c.(string); ok {
+			re, err := compileQueryRegex(v, "")
+			return err == nil && re.MatchString(s)
+		}
+	}
+	if isMissing(c) {
+		return false
+	}
+	return valuesEqual(c, val)
+}
+
+// queryCompare implements $gt/$gte/$lt/$lte, which only compare values of the
+// same type bracket.
+func queryCompare(op string, c, val interface{}) bool {
+	if isNullish(val) {
+		return (op == "$gte" || op == "$lte") && isNullish(c)
+	}
+	if isMissing(c) || typeOrder(c) != typeOrder(val) {
+		return false
+	}
+	cmp := compareValues(c, val)
+	switch op {
+	case "$gt":
+		return cmp > 0
+	case "$gte":
+		return cmp >= 0
+	case "$lt":
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+// bsonTypeCodes maps numeric $type codes to type aliases.
+var bsonTypeCodes = map[int64]string{
+	1: "double", 2: "string", 3: "object", 4: "array", 5: "binData", 6: "undefined",
+	7: "objectId", 8: "bool", 9: "date", 10: "null", 11: "regex", 13: "javascript",
+	14: "symbol", 16: "int", 17: "timestamp", 18: "long", 19: "decimal", -1: "minKey", 127: "maxKey",
+}
+
+// matchesType reports whether c has the $type given as an alias or numeric code.
+func matchesType(c interface{}, t interface{}) bool {
+	if isMissing(c) {
+		return false
+	}
+	alias, ok := t.(string)
+	if !ok {
+		n, isNum := asNumber(t)
+		if !isNum {
+			return false
+		}
+		alias = bsonTypeCodes[int64(n.float())]
+	}
+	if alias == "number" {
+		return isNumber(c)
+	}
+	return bsonTypeName(c) == alias
+}
+
+// regexCache holds compiled query and expression regexes keyed by options and pattern.
+var regexCache sync.Map
+
+// compileRegex compiles a MongoDB regex with options (i, m, s, x) to a Go regexp.
+func compileRegex(pattern, options string) (*regexp.Regexp, error) {
+	key := options + "/" + pattern
+	if re, ok := regexCache.Load(key); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	var flags string
+	for _, o := range options {
+		switch o {
+		case 'i', 'm', 's':
+			flags += string(o)
+		case 'x', 'u':
+		default:
+			return nil, fmt.Errorf("invalid regex option %q", o)
+		}
+	}
+	expr := pattern
+	if flags != "" {
+		expr = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+	regexCache.Store(key, re)
+	return re, nil
+}
+
+// compileQueryRegex compiles a $regex value given as a string or bson.Regex.
+func compileQueryRegex(v interface{}, options string) (*regexp.Regexp, error) {
+	switch r := v.(type) {
+	case string:
+		return compileRegex(r, options)
+	case bson.Regex:
+		if options == "" {
+			options = r.Options
+		}
+		return compileRegex(r.Pattern, options)
+	}
+	return nil, fmt.Errorf("$regex needs a string or regular expression")
+}
@@ -0,0 +1,347 @@
+package gmqb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// evalOne evaluates expr against doc through a $project stage and returns the
+// result, or missing when the expression produced no value.
+func evalOne(t *testing.T, doc bson.D, expr interface{}) interface{} {
+	t.Helper()
+	out, err := RunPipeline([]bson.D{doc}, NewPipeline().Project(bson.D{{Key: "_id", Value: 0}, {Key: "v", Value: expr}}))
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+	return getField(out[0], "v")
+}
+
+var evalOrders = []bson.D{
+	{{Key: "_id", Value: 1}, {Key: "status", Value: "A"}, {Key: "amount", Value: 10}, {Key: "tags", Value: []string{"x", "y"}}},
+	{{Key: "_id", Value: 2}, {Key: "status", Value: "B"}, {Key: "amount", Value: 7.5}, {Key: "tags", Value: []string{}}},
+	{{Key: "_id", Value: 3}, {Key: "status", Value: "A"}, {Key: "amount", Value: 5}},
+	{{Key: "_id", Value: 4}, {Key: "status", Value: "C"}, {Key: "amount", Value: 1}, {Key: "tags", Value: []string{"y"}}},
+}
+
+func TestRunPipeline_MatchSortLimit(t *testing.T) {
+	p := NewPipeline().
+		Match(Or(Eq("status", "A"), Gt("amount", 5))).
+		Sort(Desc("amount")).
+		Skip(1).
+		Limit(2)
+	out, err := RunPipeline(evalOrders, p)
+	require.NoError(t, err)
+	assert.Equal(t, []bson.D{
+		{{Key: "_id", Value: int32(2)}, {Key: "status", Value: "B"}, {Key: "amount", Value: 7.5}, {Key: "tags", Value: bson.A{}}},
+		{{Key: "_id", Value: int32(3)}, {Key: "status", Value: "A"}, {Key: "amount", Value: int32(5)}},
+	}, out)
+}
+
+func TestRunPipeline_MatchOperators(t *testing.T) {
+	ids := func(f Filter) []interface{} {
+		out, err := RunPipeline(evalOrders, NewPipeline().Match(f))
+		require.NoError(t, err)
+		var got []interface{}
+		for _, d := range out {
+			got = append(got, getField(d, "_id"))
+		}
+		return got
+	}
+	assert.Equal(t, []interface{}{int32(1), int32(4)}, ids(Eq("tags", "y")))
+	assert.Equal(t, []interface{}{int32(1), int32(3)}, ids(In("status", "A")))
+	assert.Equal(t, []interface{}{int32(3)}, ids(Exists("tags", false)))
+	assert.Equal(t, []interface{}{int32(2)}, ids(Size("tags", 0)))
+	assert.Equal(t, []interface{}{int32(1), int32(2), int32(3)}, ids(Nor(Eq("status", "C"))))
+	assert.Equal(t, []interface{}{int32(2)}, ids(Type("amount", "double")))
+	assert.Equal(t, []interface{}{int32(1), int32(4)}, ids(ElemMatch("tags", Filter{d: bson.D{{Key: "$in", Value: bson.A{"y"}}}})))
+	assert.Equal(t, []interface{}{int32(1), int32(2)}, ids(Expr(ExprGt("$amount", 6))))
+	assert.Equal(t, []interface{}{int32(2)}, ids(Regex("status", "^b$", "i")))
+	// $ne matches documents where the field is missing.
+	assert.Len(t, ids(Ne("tags", "x")), 3)
+	// Range operators only compare within a type bracket.
+	assert.Empty(t, ids(Gt("status", 0)))
+}
+
+func TestRunPipeline_Group(t *testing.T) {
+	p := NewPipeline().
+		Group(GroupSpec("$status",
+			GroupAcc("total", AccSum("$amount")),
+			GroupAcc("avg", AccAvg("$amount")),
+			GroupAcc("n", AccCount()),
+			GroupAcc("ids", AccPush("$_id")),
+			GroupAcc("tags", AccAddToSet("$tags")),
+			GroupAcc("max", AccMax("$amount")),
+			GroupAcc("first", AccFirst("$_id")),
+			GroupAcc("top", AccTopN(Desc("amount"), "$_id", 1)),
+		)).
+		Sort(Asc("_id"))
+	out, err := RunPipeline(evalOrders, p)
+	require.NoError(t, err)
+	require.Len(t, out, 3)
+	assert.Equal(t, bson.D{
+		{Key: "_id", Value: "A"},
+		{Key: "total", Value: int32(15)},
+		{Key: "avg", Value: 7.5},
+		{Key: "n", Value: int32(2)},
+		{Key: "ids", Value: bson.A{int32(1), int32(3)}},
+		{Key: "tags", Value: bson.A{bson.A{"x", "y"}}},
+		{Key: "max", Value: int32(10)},
+		{Key: "first", Value: int32(1)},
+		{Key: "top", Value: bson.A{int32(1)}},
+	}, out[0])
+	assert.Equal(t, 7.5, getField(out[1], "total"))
+
+	// A null _id groups every document.
+	out, err = RunPipeline(evalOrders, NewPipeline().Group(GroupSpec(nil,
+		GroupAcc("sd", AccStdDevPop(ExprCond(ExprEq("$status", "A"), 1, 3))),
+		GroupAcc("median", AccMedian("$amount", "approximate")),
+		GroupAcc("minN", AccMinN("$amount", 2)),
+	)))
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "_id", Value: nil},
+		{Key: "sd", Value: 1.0},
+		{Key: "median", Value: 5.0},
+		{Key: "minN", Value: bson.A{int32(1), int32(5)}},
+	}, out[0])
+}
+
+func TestRunPipeline_Project(t *testing.T) {
+	docs := []bson.D{{
+		{Key: "_id", Value: 1},
+		{Key: "name", Value: "ann"},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Oslo"}, {Key: "zip", Value: "0150"}}},
+		{Key: "items", Value: bson.A{bson.D{{Key: "sku", Value: "a"}, {Key: "qty", Value: 1}}, bson.D{{Key: "sku", Value: "b"}, {Key: "qty", Value: 2}}}},
+	}}
+
+	out, err := RunPipeline(docs, NewPipeline().Project(bson.D{
+		{Key: "items.sku", Value: 1},
+		{Key: "name", Value: 1},
+		{Key: "upper", Value: ExprToUpper("$name")},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, []bson.D{{
+		{Key: "_id", Value: int32(1)},
+		{Key: "name", Value: "ann"},
+		{Key: "items", Value: bson.A{bson.D{{Key: "sku", Value: "a"}}, bson.D{{Key: "sku", Value: "b"}}}},
+		{Key: "upper", Value: "ANN"},
+	}}, out)
+
+	out, err = RunPipeline(docs, NewPipeline().Project(Exclude("address.zip", "items", "_id")))
+	require.NoError(t, err)
+	assert.Equal(t, []bson.D{{
+		{Key: "name", Value: "ann"},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Oslo"}}},
+	}}, out)
+
+	_, err = RunPipeline(docs, NewPipeline().Project(bson.D{{Key: "name", Value: 1}, {Key: "items", Value: 0}}))
+	assert.Error(t, err)
+}
+
+func TestRunPipeline_AddFieldsUnsetReplaceRoot(t *testing.T) {
+	docs := []bson.D{{{Key: "a", Value: 1}, {Key: "sub", Value: bson.D{{Key: "x", Value: 1}}}}}
+	p := NewPipeline().
+		AddFields(bson.D{
+			{Key: "a", Value: ExprAdd("$a", 1)},
+			{Key: "sub.y", Value: "$a"},
+			{Key: "gone", Value: "$$REMOVE"},
+		}).
+		Unset("sub.x").
+		ReplaceRoot(ExprMergeObjects("$sub", bson.D{{Key: "a", Value: "$a"}}))
+	out, err := RunPipeline(docs, p)
+	require.NoError(t, err)
+	assert.Equal(t, []bson.D{{{Key: "y", Value: int32(1)}, {Key: "a", Value: int32(2)}}}, out)
+
+	_, err = RunPipeline(docs, NewPipeline().ReplaceRoot("$a"))
+	assert.ErrorContains(t, err, "must evaluate to an object")
+}
+
+func TestRunPipeline_Unwind(t *testing.T) {
+	out, err := RunPipeline(evalOrders, NewPipeline().Unwind("$tags").Project(Include("tags")))
+	require.NoError(t, err)
+	assert.Equal(t, []bson.D{
+		{{Key: "_id", Value: int32(1)}, {Key: "tags", Value: "x"}},
+		{{Key: "_id", Value: int32(1)}, {Key: "tags", Value: "y"}},
+		{{Key: "_id", Value: int32(4)}, {Key: "tags", Value: "y"}},
+	}, out)
+
+	out, err = RunPipeline(evalOrders, NewPipeline().
+		UnwindWithOpts(UnwindOpts{Path: "$tags", IncludeArrayIndex: "i", PreserveNullAndEmptyArrays: true}).
+		Project(bson.D{{Key: "tags", Value: 1}, {Key: "i", Value: 1}}))
+	require.NoError(t, err)
+	require.Len(t, out, 5)
+	assert.Equal(t, bson.D{{Key: "_id", Value: int32(1)}, {Key: "tags", Value: "y"}, {Key: "i", Value: int64(1)}}, out[1])
+	assert.Equal(t, bson.D{{Key: "_id", Value: int32(2)}, {Key: "i", Value: nil}}, out[2])
+	assert.Equal(t, bson.D{{Key: "_id", Value: int32(3)}, {Key: "i", Value: nil}}, out[3])
+}
+
+func TestRunPipeline_CountFacetSortByCount(t *testing.T) {
+	p := NewPipeline().Facet(map[string]Pipeline{
+		"total":    NewPipeline().Count("n"),
+		"byStatus": NewPipeline().SortByCount("$status"),
+		"none":     NewPipeline().Match(Eq("status", "Z")).Count("n"),
+	})
+	out, err := RunPipeline(evalOrders, p)
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+	assert.Equal(t, bson.A{bson.D{{Key: "n", Value: int32(4)}}}, getField(out[0], "total"))
+	assert.Equal(t, bson.A{
+		bson.D{{Key: "_id", Value: "A"}, {Key: "count", Value: int32(2)}},
+		bson.D{{Key: "_id", Value: "B"}, {Key: "count", Value: int32(1)}},
+		bson.D{{Key: "_id", Value: "C"}, {Key: "count", Value: int32(1)}},
+	}, getField(out[0], "byStatus"))
+	assert.Equal(t, bson.A{}, getField(out[0], "none"))
+}
+
+func TestRunPipeline_SortSemantics(t *testing.T) {
+	docs := []bson.D{
+		{{Key: "_id", Value: 1}, {Key: "v", Value: bson.A{5, 1}}},
+		{{Key: "_id", Value: 2}, {Key: "v", Value: "str"}},
+		{{Key: "_id", Value: 3}},
+		{{Key: "_id", Value: 4}, {Key: "v", Value: 3}},
+		{{Key: "_id", Value: 5}, {Key: "v", Value: nil}},
+	}
+	ids := func(spec bson.D) []interface{} {
+		out, err := RunPipeline(docs, NewPipeline().Sort(spec))
+		require.NoError(t, err)
+		var got []interface{}
+		for _, d := range out {
+			got = append(got, getField(d, "_id"))
+		}
+		return got
+	}
+	// Missing and null sort together (stably), before numbers and strings;
+	// arrays sort by their minimum ascending and maximum descending.
+	assert.Equal(t, []interface{}{int32(3), int32(5), int32(1), int32(4), int32(2)}, ids(Asc("v")))
+	assert.Equal(t, []interface{}{int32(2), int32(1), int32(4), int32(3), int32(5)}, ids(Desc("v")))
+}
+
+func TestRunPipeline_Errors(t *testing.T) {
+	_, err := RunPipeline(evalOrders, NewPipeline().Lookup(LookupOpts{From: "x", LocalField: "a", ForeignField: "b", As: "c"}))
+	assert.True(t, errors.Is(err, ErrUnsupportedOperator))
+	assert.ErrorContains(t, err, "stage 0 ($lookup)")
+
+	_, err = RunPipeline(evalOrders, NewPipeline().Project(bson.D{{Key: "v", Value: bson.D{{Key: "$function", Value: "x"}}}}))
+	assert.True(t, errors.Is(err, ErrUnsupportedOperator))
+
+	_, err = RunPipeline(evalOrders, NewPipeline().Match(Where("true")))
+	assert.True(t, errors.Is(err, ErrUnsupportedOperator))
+
+	_, err = RunPipeline(evalOrders, NewPipeline().Match(Eq("a", Param("a"))))
+	assert.True(t, errors.Is(err, ErrUnboundParam))
+
+	_, err = RunPipeline(evalOrders, NewPipeline().Project(bson.D{{Key: "v", Value: ExprDivide(1, 0)}}))
+	assert.ErrorContains(t, err, "divide by zero")
+
+	out, err := RunPipeline(nil, NewPipeline().Count("n"))
+	require.NoError(t, err)
+	assert.Empty(t, out)
+}
+
+func TestEval_Arithmetic(t *testing.T) {
+	doc := bson.D{{Key: "a", Value: 7}, {Key: "b", Value: 2}, {Key: "big", Value: int64(1) << 40}, {Key: "f", Value: 2.5}}
+	assert.Equal(t, int32(9), evalOne(t, doc, ExprAdd("$a", "$b")))
+	assert.Equal(t, int64(1<<40+7), evalOne(t, doc, ExprAdd("$a", "$big")))
+	assert.Equal(t, 9.5, evalOne(t, doc, ExprAdd("$a", "$f")))
+	assert.Equal(t, nil, evalOne(t, doc, ExprAdd("$a", "$nope")))
+	assert.Equal(t, int32(5), evalOne(t, doc, ExprSubtract("$a", "$b")))
+	assert.Equal(t, int32(14), evalOne(t, doc, ExprMultiply("$a", "$b")))
+	assert.Equal(t, int64(4294967296), evalOne(t, doc, ExprMultiply(65536, 65536)))
+	assert.Equal(t, 3.5, evalOne(t, doc, ExprDivide("$a", "$b")))
+	assert.Equal(t, int32(1), evalOne(t, doc, ExprMod("$a", "$b")))
+	assert.Equal(t, int32(49), evalOne(t, doc, ExprPow("$a", 2)))
+	assert.Equal(t, 2.0, evalOne(t, doc, ExprRound("$f", 0)))
+	assert.Equal(t, 3.0, evalOne(t, doc, ExprCeil("$f")))
+	assert.Equal(t, int32(7), evalOne(t, doc, ExprAbs(ExprSubtract(0, "$a"))))
+	assert.Equal(t, 3.0, evalOne(t, doc, ExprLog(8, 2)))
+}
+
+func TestEval_Conditional(t *testing.T) {
+	doc := bson.D{{Key: "age", Value: 40}, {Key: "nick", Value: nil}}
+	assert.Equal(t, "high", evalOne(t, doc, ExprCond(ExprGte("$age", 18), "high", "low")))
+	assert.Equal(t, "n/a", evalOne(t, doc, ExprIfNull("$nick", "n/a")))
+	assert.Equal(t, "adult", evalOne(t, doc, ExprSwitch([]SwitchBranch{
+		{Case: ExprGte("$age", 65), Then: "senior"},
+		{Case: ExprGte("$age", 18), Then: "adult"},
+	}, "minor")))
+	assert.Equal(t, false, evalOne(t, doc, ExprBoolAnd(true, ExprBoolNot("$age"))))
+	assert.Equal(t, true, evalOne(t, doc, ExprBoolOr(false, ExprEq("$nick", nil))))
+	// Missing is less than null in expression comparisons.
+	assert.Equal(t, int32(-1), evalOne(t, doc, ExprCmp("$none", nil)))
+	assert.Equal(t, int32(42), evalOne(t, doc, ExprLet(bson.D{{Key: "x", Value: 2}}, ExprAdd("$age", "$$x"))))
+}
+
+func TestEval_String(t *testing.T) {
+	doc := bson.D{{Key: "s", Value: "  Hello, Wörld  "}, {Key: "csv", Value: "a,b,c"}}
+	assert.Equal(t, "Hello, Wörld", evalOne(t, doc, ExprTrim("$s", nil)))
+	assert.Equal(t, "  Hello, Wörld", evalOne(t, doc, ExprRTrim("$s", nil)))
+	assert.Equal(t, "ab", evalOne(t, doc, ExprConcat("a", "b")))
+	assert.Equal(t, nil, evalOne(t, doc, ExprConcat("a", "$none")))
+	assert.Equal(t, int32(16), evalOne(t, doc, ExprStrLenCP("$s")))
+	assert.Equal(t, "He", evalOne(t, doc, ExprSubstr(ExprTrim("$s", nil), 0, 2)))
+	assert.Equal(t, bson.A{"a", "b", "c"}, evalOne(t, doc, ExprSplit("$csv", ",")))
+	assert.Equal(t, "a;b;c", evalOne(t, doc, ExprReplaceAll("$csv", ",", ";")))
+	assert.Equal(t, "a;b,c", evalOne(t, doc, ExprReplaceOne("$csv", ",", ";")))
+	assert.Equal(t, true, evalOne(t, doc, ExprRegexMatch("$s", "wörld", "i")))
+	assert.Equal(t, bson.D{
+		{Key: "match", Value: "Wörld"},
+		{Key: "idx", Value: int32(9)},
+		{Key: "captures", Value: bson.A{"ö"}},
+	}, evalOne(t, doc, ExprRegexFind("$s", "W(.)rld", "")))
+	assert.Len(t, evalOne(t, doc, ExprRegexFindAll("$csv", "[a-z]", "")), 3)
+	assert.Equal(t, "5", evalOne(t, doc, ExprToString(5)))
+	assert.Equal(t, int32(12), evalOne(t, doc, ExprToInt("12")))
+	assert.Equal(t, "fallback", evalOne(t, doc, ExprConvert("x", "int", "fallback", nil)))
+}
+
+func TestEval_Array(t *testing.T) {
+	doc := bson.D{
+		{Key: "nums", Value: bson.A{3, 1, 2}},
+		{Key: "items", Value: bson.A{bson.D{{Key: "k", Value: "a"}, {Key: "v", Value: 1}}, bson.D{{Key: "k", Value: "b"}, {Key: "v", Value: 2}}}},
+	}
+	assert.Equal(t, int32(2), evalOne(t, doc, ExprArrayElemAt("$nums", -1)))
+	assert.Equal(t, missing, evalOne(t, doc, ExprArrayElemAt("$nums", 5)))
+	assert.Equal(t, bson.A{int32(3), int32(2)}, evalOne(t, doc, ExprFilter("$nums", "n", ExprGte("$$n", 2))))
+	assert.Equal(t, bson.A{int32(6), int32(2), int32(4)}, evalOne(t, doc, ExprMap("$nums", "", ExprMultiply("$$this", 2))))
+	assert.Equal(t, int32(6), evalOne(t, doc, ExprReduce("$nums", 0, ExprAdd("$$value", "$$this"))))
+	assert.Equal(t, bson.A{int32(1), int32(2), int32(3)}, evalOne(t, doc, ExprSortArray("$nums", 1)))
+	assert.Equal(t, bson.A{int32(1), int32(2)}, evalOne(t, doc, ExprSlice("$nums", 1, 2)))
+	assert.Equal(t, bson.A{int32(2), int32(1), int32(3)}, evalOne(t, doc, ExprReverseArray("$nums")))
+	assert.Equal(t, true, evalOne(t, doc, ExprIn(1, "$nums")))
+	assert.Equal(t, int32(1), evalOne(t, doc, ExprIndexOfArray("$nums", 1)))
+	assert.Equal(t, bson.A{"a", "b"}, evalOne(t, doc, "$items.k"))
+	assert.Equal(t, bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(2)}}, evalOne(t, doc, ExprArrayToObject("$items")))
+	assert.Equal(t, bson.A{bson.A{int32(3), "x"}, bson.A{int32(1), nil}},
+		evalOne(t, doc, ExprZip(bson.A{ExprSlice("$nums", 2), bson.A{"x"}}, true, nil)))
+	assert.Equal(t, int32(6), evalOne(t, doc, bson.D{{Key: "$sum", Value: "$nums"}}))
+	assert.Equal(t, int32(3), evalOne(t, doc, bson.D{{Key: "$max", Value: "$nums"}}))
+	assert.Equal(t, bson.A{int32(1), int32(2)}, evalOne(t, doc, ExprSetIntersection("$nums", bson.A{1, 2, 9})))
+	assert.Equal(t, true, evalOne(t, doc, ExprSetEquals("$nums", bson.A{1, 2, 3, 3})))
+	assert.Equal(t, int32(3), evalOne(t, doc, bson.D{{Key: "$size", Value: "$nums"}}))
+}
+
+func TestEval_Date(t *testing.T) {
+	ts := time.Date(2024, 1, 31, 22, 30, 15, 250*int(time.Millisecond), time.UTC)
+	doc := bson.D{{Key: "d", Value: ts}}
+	assert.Equal(t, int32(2024), evalOne(t, doc, ExprYear("$d")))
+	assert.Equal(t, int32(31), evalOne(t, doc, ExprDayOfMonth("$d")))
+	assert.Equal(t, int32(4), evalOne(t, doc, ExprDayOfWeek("$d")))
+	assert.Equal(t, int32(5), evalOne(t, doc, ExprISOWeek("$d")))
+	assert.Equal(t, int32(250), evalOne(t, doc, ExprMillisecond("$d")))
+	assert.Equal(t, int32(1), evalOne(t, doc, ExprDayOfMonth(bson.D{{Key: "date", Value: "$d"}, {Key: "timezone", Value: "+02:00"}})))
+	assert.Equal(t, "2024-01-31T22:30:15.250Z", evalOne(t, doc, ExprDateToString("$d", nil, nil)))
+	assert.Equal(t, "02/01/2024 00:30", evalOne(t, doc, ExprDateToString("$d", "%m/%d/%Y %H:%M", "+02:00")))
+	assert.Equal(t, bson.NewDateTimeFromTime(time.Date(2024, 2, 29, 22, 30, 15, 250*int(time.Millisecond), time.UTC)),
+		evalOne(t, doc, ExprDateAdd("$d", "month", 1)))
+	assert.Equal(t, bson.NewDateTimeFromTime(ts.Add(-36*time.Hour)), evalOne(t, doc, ExprDateSubtract("$d", "hour", 36)))
+	assert.Equal(t, int64(1), evalOne(t, doc, ExprDateDiff("$d", ExprDateAdd("$d", "hour", 2), "day")))
+	assert.Equal(t, int64(11), evalOne(t, doc, ExprDateDiff("$d", time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), "month")))
+	assert.Equal(t, bson.NewDateTimeFromTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)), evalOne(t, doc, ExprDateTrunc("$d", "month")))
+	assert.Equal(t, bson.NewDateTimeFromTime(time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)),
+		evalOne(t, doc, ExprDateFromString("05/03/2024 10:00", "%d/%m/%Y %H:%M", nil)))
+	assert.Equal(t, int64(ts.Add(time.Second).UnixMilli()-ts.UnixMilli()), evalOne(t, doc, ExprSubtract(ExprAdd("$d", 1000), "$d")))
+}
@@ -0,0 +1,646 @@
+package gmqb
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// missingValue marks a field that does not exist, as opposed to one holding null.
+type missingValue struct{}
+
+// missing is the value of an absent field during evaluation.
+var missing = missingValue{}
+
+// isMissing reports whether v is the missing marker.
+func isMissing(v interface{}) bool {
+	_, ok := v.(missingValue)
+	return ok
+}
+
+// isNullish reports whether v is null, undefined or missing.
+func isNullish(v interface{}) bool {
+	switch v.(type) {
+	case nil, missingValue, bson.Undefined:
+		return true
+	}
+	return false
+}
+
+// normalizeDocs round-trips documents through BSON so that Go values (int,
+// time.Time, []string, structs, ...) take their canonical BSON representation.
+func normalizeDocs(docs []bson.D) ([]bson.D, error) {
+	out := make([]bson.D, len(docs))
+	for i, d := range docs {
+		n, err := normalizeDoc(d)
+		if err != nil {
+			return nil, fmt.Errorf("gmqb eval: document %d: %w", i, err)
+		}
+		out[i] = n
+	}
+	return out, nil
+}
+
+// normalizeDoc round-trips a single document through BSON.
+func normalizeDoc(d bson.D) (bson.D, error) {
+	if d == nil {
+		d = bson.D{}
+	}
+	raw, err := bson.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var out bson.D
+	if err := bson.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	if out == nil {
+		out = bson.D{}
+	}
+	return out, nil
+}
+
+// --- Numbers ---
+
+// numKind orders numeric types by width, mirroring MongoDB's type promotion.
+type numKind int
+
+const (
+	numInt32 numKind = iota
+	numInt64
+	numDouble
+)
+
+// number is a numeric value decoded from BSON.
+type number struct {
+	kind numKind
+	i    int64
+	f    float64
+}
+
+// asNumber converts a BSON numeric value to a number.
+func asNumber(v interface{}) (number, bool) {
+	switch n := v.(type) {
+	case int32:
+		return number{kind: numInt32, i: int64(n)}, true
+	case int64:
+		return number{kind: numInt64, i: n}, true
+	case int:
+		return number{kind: numInt64, i: int64(n)}, true
+	case float64:
+		return number{kind: numDouble, f: n}, true
+	case float32:
+		return number{kind: numDouble, f: float64(n)}, true
+	case bson.Decimal128:
+		f, err := strconv.ParseFloat(n.String(), 64)
+		if err != nil {
+			return number{}, false
+		}
+		return number{kind: numDouble, f: f}, true
+	}
+	return number{}, false
+}
+
+// isNumber reports whether v is a BSON numeric value.
+func isNumber(v interface{}) bool {
+	_, ok := asNumber(v)
+	return ok
+}
+
+func intNumber(i int64) number {
+	if i >= math.MinInt32 && i <= math.MaxInt32 {
+		return number{kind: numInt32, i: i}
+	}
+	return number{kind: numInt64, i: i}
+}
+
+func doubleNumber(f float64) number {
+	return number{kind: numDouble, f: f}
+}
+
+// float returns the number as a float64.
+func (n number) float() float64 {
+	if n.kind == numDouble {
+		return n.f
+	}
+	return float64(n.i)
+}
+
+// value returns the number as its BSON Go type.
+func (n number) value() interface{} {
+	switch n.kind {
+	case numInt32:
+		return int32(n.i)
+	case numInt64:
+		return n.i
+	}
+	return n.f
+}
+
+// widen returns an integer result of kind at least k, promoting int32 results
+// that overflow to int64.
+func widen(k numKind, i int64) number {
+	if k == numInt32 && (i < math.MinInt32 || i > math.MaxInt32) {
+		k = numInt64
+	}
+	return number{kind: k, i: i}
+}
+
+// addNumbers adds two numbers with MongoDB's type promotion rules.
+func addNumbers(a, b number) number {
+	k := max(a.kind, b.kind)
+	if k == numDouble {
+		return doubleNumber(a.float() + b.float())
+	}
+	s := a.i + b.i
+	if (s > a.i) != (b.i > 0) {
+		return doubleNumber(a.float() + b.float())
+	}
+	return widen(k, s)
+}
+
+// subNumbers subtracts b from a with MongoDB's type promotion rules.
+func subNumbers(a, b number) number {
+	if b.kind != numDouble && b.i != math.MinInt64 {
+		return addNumbers(a, number{kind: b.kind, i: -b.i})
+	}
+	return doubleNumber(a.float() - b.float())
+}
+
+// mulNumbers multiplies two numbers with MongoDB's type promotion rules.
+func mulNumbers(a, b number) number {
+	k := max(a.kind, b.kind)
+	if k == numDouble {
+		return doubleNumber(a.float() * b.float())
+	}
+	p := a.i * b.i
+	if a.i != 0 && (p/a.i != b.i || (a.i == -1 && b.i == math.MinInt64)) {
+		return doubleNumber(a.float() * b.float())
+	}
+	return widen(k, p)
+}
+
+// --- Ordering ---
+
+// typeOrder returns the BSON comparison order class of a value.
+//
+// See: https://www.mongodb.com/docs/manual/reference/bson-type-comparison-order/
+func typeOrder(v interface{}) int {
+	switch v.(type) {
+	case bson.MinKey:
+		return 1
+	case missingValue, bson.Undefined:
+		return 2
+	case nil, bson.Null:
+		return 3
+	case int32, int64, int, float64, float32, bson.Decimal128:
+		return 4
+	case string, bson.Symbol:
+		return 5
+	case bson.D, bson.M:
+		return 6
+	case bson.A, []interface{}:
+		return 7
+	case bson.Binary, []byte:
+		return 8
+	case bson.ObjectID:
+		return 9
+	case bool:
+		return 10
+	case bson.DateTime, time.Time:
+		return 11
+	case bson.Timestamp:
+		return 12
+	case bson.Regex:
+		return 13
+	case bson.MaxKey:
+		return 15
+	}
+	return 14
+}
+
+// compareValues orders two BSON values following MongoDB's comparison order.
+func compareValues(a, b interface{}) int {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return cmpInt(int64(ta), int64(tb))
+	}
+	switch ta {
+	case 4:
+		na, _ := asNumber(a)
+		nb, _ := asNumber(b)
+		if na.kind != numDouble && nb.kind != numDouble {
+			return cmpInt(na.i, nb.i)
+		}
+		fa, fb := na.float(), nb.float()
+		switch {
+		case math.IsNaN(fa) && math.IsNaN(fb):
+			return 0
+		case math.IsNaN(fa):
+			return -1
+		case math.IsNaN(fb):
+			return 1
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case 5:
+		return strings.Compare(stringOf(a), stringOf(b))
+	case 6:
+		da, db := asDoc(a), asDoc(b)
+		for i := 0; i < len(da) && i < len(db); i++ {
+			if c := cmpInt(int64(typeOrder(da[i].Value)), int64(typeOrder(db[i].Value))); c != 0 {
+				return c
+			}
+			if c := strings.Compare(da[i].Key, db[i].Key); c != 0 {
+				return c
+			}
+			if c := compareValues(da[i].Value, db[i].Value); c != 0 {
+				return c
+			}
+		}
+		return cmpInt(int64(len(da)), int64(len(db)))
+	case 7:
+		aa, ab := asArray(a), asArray(b)
+		for i := 0; i < len(aa) && i < len(ab); i++ {
+			if c := compareValues(aa[i], ab[i]); c != 0 {
+				return c
+			}
+		}
+		return cmpInt(int64(len(aa)), int64(len(ab)))
+	case 8:
+		ba, bb := asBinary(a), asBinary(b)
+		if c := cmpInt(int64(len(ba.Data)), int64(len(bb.Data))); c != 0 {
+			return c
+		}
+		if c := cmpInt(int64(ba.Subtype), int64(bb.Subtype)); c != 0 {
+			return c
+		}
+		return bytes.Compare(ba.Data, bb.Data)
+	case 9:
+		oa, ob := a.(bson.ObjectID), b.(bson.ObjectID)
+		return bytes.Compare(oa[:], ob[:])
+	case 10:
+		ba, bb := a.(bool), b.(bool)
+		switch {
+		case ba == bb:
+			return 0
+		case !ba:
+			return -1
+		}
+		return 1
+	case 11:
+		ta, _ := asTime(a)
+		tb, _ := asTime(b)
+		return ta.Compare(tb)
+	case 12:
+		sa, sb := a.(bson.Timestamp), b.(bson.Timestamp)
+		if c := cmpInt(int64(sa.T), int64(sb.T)); c != 0 {
+			return c
+		}
+		return cmpInt(int64(sa.I), int64(sb.I))
+	case 13:
+		ra, rb := a.(bson.Regex), b.(bson.Regex)
+		if c := strings.Compare(ra.Pattern, rb.Pattern); c != 0 {
+			return c
+		}
+		return strings.Compare(ra.Options, rb.Options)
+	}
+	return 0
+}
+
+// valuesEqual reports whether two values compare equal.
+func valuesEqual(a, b interface{}) bool {
+	return compareValues(a, b) == 0
+}
+
+func cmpInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// isTruthy applies MongoDB's expression truthiness: false, null, missing,
+// undefined and zero are false; everything else is true.
+func isTruthy(v interface{}) bool {
+	if isNullish(v) {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	if n, ok := asNumber(v); ok {
+		return n.float() != 0
+	}
+	return true
+}
+
+// --- Type accessors ---
+
+func stringOf(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case bson.Symbol:
+		return string(s)
+	}
+	return ""
+}
+
+func asDoc(v interface{}) bson.D {
+	switch d := v.(type) {
+	case bson.D:
+		return d
+	case bson.M:
+		return documentElems(d)
+	}
+	return nil
+}
+
+func isDoc(v interface{}) bool {
+	switch v.(type) {
+	case bson.D, bson.M:
+		return true
+	}
+	return false
+}
+
+func asArray(v interface{}) bson.A {
+	switch a := v.(type) {
+	case bson.A:
+		return a
+	case []interface{}:
+		return a
+	}
+	return nil
+}
+
+func isArray(v interface{}) bool {
+	switch v.(type) {
+	case bson.A, []interface{}:
+		return true
+	}
+	return false
+}
+
+func asBinary(v interface{}) bson.Binary {
+	switch b := v.(type) {
+	case bson.Binary:
+		return b
+	case []byte:
+		return bson.Binary{Data: b}
+	}
+	return bson.Binary{}
+}
+
+// asTime converts dates, ObjectIDs and timestamps to a UTC time.
+func asTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case bson.DateTime:
+		return t.Time().UTC(), true
+	case time.Time:
+		return t.UTC(), true
+	case bson.ObjectID:
+		return t.Timestamp().UTC(), true
+	case bson.Timestamp:
+		return time.Unix(int64(t.T), 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// bsonTypeName returns the $type alias of a value.
+func bsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case missingValue:
+		return "missing"
+	case nil, bson.Null:
+		return "null"
+	case bson.Undefined:
+		return "undefined"
+	case float64, float32:
+		return "double"
+	case int32:
+		return "int"
+	case int64, int:
+		return "long"
+	case bson.Decimal128:
+		return "decimal"
+	case string:
+		return "string"
+	case bson.Symbol:
+		return "symbol"
+	case bson.D, bson.M:
+		return "object"
+	case bson.A, []interface{}:
+		return "array"
+	case bson.Binary, []byte:
+		return "binData"
+	case bson.ObjectID:
+		return "objectId"
+	case bool:
+		return "bool"
+	case bson.DateTime, time.Time:
+		return "date"
+	case bson.Timestamp:
+		return "timestamp"
+	case bson.Regex:
+		return "regex"
+	case bson.JavaScript:
+		return "javascript"
+	case bson.MinKey:
+		return "minKey"
+	case bson.MaxKey:
+		return "maxKey"
+	}
+	return "unknown"
+}
+
+// --- Paths ---
+
+// getField returns the value of a top-level field, or missing.
+func getField(d bson.D, key string) interface{} {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return missing
+}
+
+// resolvePath evaluates a dotted field path the way aggregation expressions do:
+// arrays are traversed element-wise and the results collected into an array.
+func resolvePath(v interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return v
+	}
+	switch x := v.(type) {
+	case bson.D, bson.M:
+		return resolvePath(getField(asDoc(x), path[0]), path[1:])
+	case bson.A, []interface{}:
+		out := bson.A{}
+		for _, item := range asArray(x) {
+			if isArray(item) {
+				out = append(out, resolvePath(item, path))
+				continue
+			}
+			if r := resolvePath(item, path); !isMissing(r) {
+				out = append(out, r)
+			}
+		}
+		return out
+	}
+	return missing
+}
+
+// setPath returns a copy of d with the dotted path set to val. Intermediate
+// non-documents are replaced by documents; arrays are updated element-wise.
+// Setting missing removes the field.
+func setPath(d bson.D, path []string, val interface{}) bson.D {
+	out := make(bson.D, 0, len(d)+1)
+	found := false
+	for _, e := range d {
+		if e.Key != path[0] {
+			out = append(out, e)
+			continue
+		}
+		found = true
+		if len(path) == 1 {
+			if !isMissing(val) {
+				out = append(out, bson.E{Key: e.Key, Value: val})
+			}
+			continue
+		}
+		out = append(out, bson.E{Key: e.Key, Value: setPathValue(e.Value, path[1:], val)})
+	}
+	if !found && !isMissing(val) {
+		if len(path) == 1 {
+			out = append(out, bson.E{Key: path[0], Value: val})
+		} else {
+			out = append(out, bson.E{Key: path[0], Value: setPath(bson.D{}, path[1:], val)})
+		}
+	}
+	return out
+}
+
+func setPathValue(cur interface{}, path []string, val interface{}) interface{} {
+	switch x := cur.(type) {
+	case bson.D, bson.M:
+		return setPath(asDoc(x), path, val)
+	case bson.A, []interface{}:
+		arr := asArray(x)
+		out := make(bson.A, len(arr))
+		for i, item := range arr {
+			out[i] = setPathValue(item, path, val)
+		}
+		return out
+	}
+	return setPath(bson.D{}, path, val)
+}
+
+// removePath returns a copy of d without the dotted path. Arrays of documents
+// are updated element-wise.
+func removePath(d bson.D, path []string) bson.D {
+	out := make(bson.D, 0, len(d))
+	for _, e := range d {
+		if e.Key != path[0] {
+			out = append(out, e)
+			continue
+		}
+		if len(path) == 1 {
+			continue
+		}
+		out = append(out, bson.E{Key: e.Key, Value: removePathValue(e.Value, path[1:])})
+	}
+	return out
+}
+
+func removePathValue(cur interface{}, path []string) interface{} {
+	switch x := cur.(type) {
+	case bson.D, bson.M:
+		return removePath(asDoc(x), path)
+	case bson.A, []interface{}:
+		arr := asArray(x)
+		out := make(bson.A, len(arr))
+		for i, item := range arr {
+			out[i] = removePathValue(item, path)
+		}
+		return out
+	}
+	return cur
+}
+
+// splitPath splits a dotted field path.
+func splitPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// --- Group keys ---
+
+// groupKey returns a string that is equal for values that compare equal, used
+// to bucket documents by $group _id.
+func groupKey(v interface{}) string {
+	var b strings.Builder
+	writeGroupKey(&b, v)
+	return b.String()
+}
+
+func writeGroupKey(b *strings.Builder, v interface{}) {
+	if isMissing(v) || v == nil {
+		b.WriteString("null")
+		return
+	}
+	switch typeOrder(v) {
+	case 4:
+		n, _ := asNumber(v)
+		f := n.float()
+		if n.kind != numDouble {
+			b.WriteString("n" + strconv.FormatInt(n.i, 10))
+		} else if f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+			b.WriteString("n" + strconv.FormatInt(int64(f), 10))
+		} else {
+			b.WriteString("n" + strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case 5:
+		b.WriteString(strconv.Quote(stringOf(v)))
+	case 6:
+		b.WriteString("{")
+		for _, e := range asDoc(v) {
+			b.WriteString(strconv.Quote(e.Key) + ":")
+			writeGroupKey(b, e.Value)
+			b.WriteString(",")
+		}
+		b.WriteString("}")
+	case 7:
+		b.WriteString("[")
+		for _, item := range asArray(v) {
+			writeGroupKey(b, item)
+			b.WriteString(",")
+		}
+		b.WriteString("]")
+	default:
+		fmt.Fprintf(b, "%s:%v", bsonTypeName(v), v)
+	}
+}
+
+// sortValues sorts values in place using BSON comparison order.
+func sortValues(vals []interface{}, desc bool) {
+	sort.SliceStable(vals, func(i, j int) bool {
+		c := compareValues(vals[i], vals[j])
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
@@ -67,7 +67,7 @@ func main() {
 	replacedCharlie, err := coll.FindOneAndReplace(ctx,
 		gmqb.Eq("name", "Charlie"),
 		&User{Name: "Charlie", Age: 36, Status: "super-active"},
-		gmqb.WithReturnDocumentReplace(options.After),
+		gmqb.WithReturnDocument(options.After),
 	)
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,443 @@
+package gmqb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ExplainVerbosity controls how much information the explain command returns.
+//
+// See: https://www.mongodb.com/docs/manual/reference/command/explain/#verbosity-modes
+type ExplainVerbosity string
+
+const (
+	// ExplainQueryPlanner returns the winning plan without executing it.
+	ExplainQueryPlanner ExplainVerbosity = "queryPlanner"
+	// ExplainExecutionStats executes the winning plan and returns its statistics.
+	ExplainExecutionStats ExplainVerbosity = "executionStats"
+	// ExplainAllPlansExecution also returns statistics for rejected candidate plans.
+	ExplainAllPlansExecution ExplainVerbosity = "allPlansExecution"
+)
+
+// PlanStage is a single node of a query plan tree, such as COLLSCAN, IXSCAN,
+// FETCH or SORT.
+type PlanStage struct {
+	// Stage is the plan stage name, e.g. "IXSCAN".
+	Stage string
+	// IndexName is set for index-backed stages (IXSCAN, COUNT_SCAN, ...).
+	IndexName string
+	// KeyPattern is the key pattern of the index used by the stage.
+	KeyPattern bson.D
+	// Direction is the scan direction ("forward" or "backward"), when reported.
+	Direction string
+	// Inputs are the child stages feeding this stage.
+	Inputs []*PlanStage
+}
+
+// walk visits the stage and all of its descendants depth-first.
+func (s *PlanStage) walk(fn func(*PlanStage)) {
+	if s == nil {
+		return
+	}
+	fn(s)
+	for _, in := range s.Inputs {
+		in.walk(fn)
+	}
+}
+
+// ExplainResult is a typed summary of the output of the explain command.
+// Execution statistics are only populated for ExplainExecutionStats and
+// ExplainAllPlansExecution verbosity.
+type ExplainResult struct {
+	// Namespace is the "db.collection" the plan applies to.
+	Namespace string
+	// WinningPlan is the root of the winning query plan tree.
+	WinningPlan *PlanStage
+	// PipelineStages lists the aggregation stages reported by an aggregate explain
+	// that could not be pushed down into the query layer (e.g. "$cursor", "$group").
+	PipelineStages []string
+	// NReturned is the number of documents returned by the winning plan.
+	NReturned int64
+	// KeysExamined is the total number of index keys scanned.
+	KeysExamined int64
+	// DocsExamined is the total number of documents scanned.
+	DocsExamined int64
+	// ExecutionTime is the total time spent executing the winning plan.
+	ExecutionTime time.Duration
+	// Raw is the unparsed explain output.
+	Raw bson.Raw
+}
+
+// StageNames returns the names of all winning plan stages, depth-first from the root.
+func (r *ExplainResult) StageNames() []string {
+	var names []string
+	r.WinningPlan.walk(func(s *PlanStage) {
+		names = append(names, s.Stage)
+	})
+	return names
+}
+
+// HasStage reports whether the winning plan contains a stage with the given name.
+func (r *ExplainResult) HasStage(stage string) bool {
+	found := false
+	r.WinningPlan.walk(func(s *PlanStage) {
+		if s.Stage == stage {
+			found = true
+		}
+	})
+	return found
+}
+
+// HasCollectionScan reports whether the winning plan performs a full collection scan.
+//
+// Example:
+//
+//	res, _ := coll.ExplainFind(ctx, gmqb.Eq("email", email), gmqb.ExplainQueryPlanner)
+//	if res.HasCollectionScan() {
+//	    t.Fatalf("query is not indexed:\n%s", res.Raw)
+//	}
+func (r *ExplainResult) HasCollectionScan() bool {
+	return r.HasStage("COLLSCAN")
+}
+
+// HasInMemorySort reports whether the winning plan sorts documents in memory
+// instead of reading them in index order.
+func (r *ExplainResult) HasInMemorySort() bool {
+	return r.HasStage("SORT")
+}
+
+// IndexNames returns the names of all indexes used by the winning plan.
+func (r *ExplainResult) IndexNames() []string {
+	var names []string
+	r.WinningPlan.walk(func(s *PlanStage) {
+		if s.IndexName != "" {
+			names = append(names, s.IndexName)
+		}
+	})
+	return names
+}
+
+// UsesIndex reports whether the winning plan uses the named index. An empty
+// name matches any index.
+func (r *ExplainResult) UsesIndex(name string) bool {
+	for _, n := range r.IndexNames() {
+		if name == "" || n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ExplainFind explains the find command for the filter and options.
+//
+// MongoDB equivalent: db.collection.find(filter).explain(verbosity)
+//
+// See: https://www.mongodb.com/docs/manual/reference/command/explain/
+//
+// Example:
+//
+//	res, err := coll.ExplainFind(ctx, gmqb.Eq("email", "alice@example.com"),
+//	    gmqb.ExplainExecutionStats, gmqb.WithSort(gmqb.Desc("createdAt")))
+//	fmt.Println(res.StageNames(), res.IndexNames(), res.DocsExamined)
+func (c *Collection[T]) ExplainFind(ctx context.Context, filter Filter, verbosity ExplainVerbosity, opts ...Opt) (*ExplainResult, error) {
+	cfg, err := buildOpts(OpFind, opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withDeadline(ctx, cfg.deadline())
+	defer cancel()
+	return c.explain(ctx, findCommand(c.coll.Name(), filter, cfg), verbosity)
+}
+
+// findCommand builds the find command the options of cfg describe.
+func findCommand(collection string, filter Filter, cfg opConfig) bson.D {
+	cmd := bson.D{
+		{Key: "find", Value: collection},
+		{Key: "filter", Value: nonNilDoc(filter.BsonD())},
+	}
+	if cfg.sort != nil {
+		cmd = append(cmd, bson.E{Key: "sort", Value: cfg.sort})
+	}
+	if cfg.projection != nil {
+		cmd = append(cmd, bson.E{Key: "projection", Value: cfg.projection})
+	}
+	if cfg.skip != nil {
+		cmd = append(cmd, bson.E{Key: "skip", Value: *cfg.skip})
+	}
+	if cfg.limit != nil {
+		cmd = append(cmd, bson.E{Key: "limit", Value: *cfg.limit})
+	}
+	if cfg.batchSize != nil {
+		cmd = append(cmd, bson.E{Key: "batchSize", Value: *cfg.batchSize})
+	}
+	if cfg.allowDiskUse != nil {
+		cmd = append(cmd, bson.E{Key: "allowDiskUse", Value: *cfg.allowDiskUse})
+	}
+	return appendQueryOpts(cmd, cfg)
+}
+
+// ExplainCount explains the count command for the filter and options.
+//
+// MongoDB equivalent: db.collection.explain(verbosity).count(filter)
+//
+// Example:
+//
+//	res, err := coll.ExplainCount(ctx, gmqb.Eq("status", "active"), gmqb.ExplainQueryPlanner)
+func (c *Collection[T]) ExplainCount(ctx context.Context, filter Filter, verbosity ExplainVerbosity, opts ...Opt) (*ExplainResult, error) {
+	cfg, err := buildOpts(OpCountDocuments, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := bson.D{
+		{Key: "count", Value: c.coll.Name()},
+		{Key: "query", Value: nonNilDoc(filter.BsonD())},
+	}
+	if cfg.skip != nil {
+		cmd = append(cmd, bson.E{Key: "skip", Value: *cfg.skip})
+	}
+	if cfg.limit != nil {
+		cmd = append(cmd, bson.E{Key: "limit", Value: *cfg.limit})
+	}
+	cmd = appendQueryOpts(cmd, cfg)
+	ctx, cancel := withDeadline(ctx, cfg.deadline())
+	defer cancel()
+	return c.explain(ctx, cmd, verbosity)
+}
+
+// appendQueryOpts appends the hint, collation and comment of cfg to cmd.
+func appendQueryOpts(cmd bson.D, cfg opConfig) bson.D {
+	if cfg.hint != nil {
+		cmd = append(cmd, bson.E{Key: "hint", Value: cfg.hint})
+	}
+	if cfg.collation != nil {
+		cmd = append(cmd, bson.E{Key: "collation", Value: collationDoc(cfg.collation)})
+	}
+	if cfg.comment != nil {
+		cmd = append(cmd, bson.E{Key: "comment", Value: cfg.comment})
+	}
+	return cmd
+}
+
+// ExplainAggregate explains an aggregation pipeline.
+//
+// MongoDB equivalent: db.collection.explain(verbosity).aggregate(pipeline)
+//
+// Example:
+//
+//	res, err := coll.ExplainAggregate(ctx,
+//	    gmqb.NewPipeline().Match(gmqb.Eq("status", "active")).Group(gmqb.GroupSpec("$country")),
+//	    gmqb.ExplainExecutionStats)
+//	fmt.Println(res.PipelineStages, res.IndexNames())
+func (c *Collection[T]) ExplainAggregate(ctx context.Context, pipeline Pipeline, verbosity ExplainVerbosity) (*ExplainResult, error) {
+	if pipeline.IsEmpty() {
+		return nil, fmt.Errorf("%w: ExplainAggregate requires a non-empty pipeline", ErrEmptyPipeline)
+	}
+	cmd := bson.D{
+		{Key: "aggregate", Value: c.coll.Name()},
+		{Key: "pipeline", Value: pipeline.BsonD()},
+		{Key: "cursor", Value: bson.D{}},
+	}
+	return c.explain(ctx, cmd, verbosity)
+}
+
+// explain runs the explain command wrapping cmd and parses the result.
+func (c *Collection[T]) explain(ctx context.Context, cmd bson.D, verbosity ExplainVerbosity) (*ExplainResult, error) {
+	if verbosity == "" {
+		verbosity = ExplainQueryPlanner
+	}
+	raw, err := c.coll.Database().RunCommand(ctx, bson.D{
+		{Key: "explain", Value: cmd},
+		{Key: "verbosity", Value: string(verbosity)},
+	}).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("gmqb explain: %w", err)
+	}
+	return ParseExplain(raw)
+}
+
+// ParseExplain parses raw explain command output into an ExplainResult. It
+// understands classic and slot-based (SBE) plans, aggregate explains with
+// $cursor stages, and sharded plans.
+func ParseExplain(raw bson.Raw) (*ExplainResult, error) {
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("gmqb explain: decode: %w", err)
+	}
+	res := &ExplainResult{Raw: raw}
+
+	// Aggregations that are not fully pushed down report a list of stages, the
+	// first of which wraps the query-layer explain in $cursor.
+	section := bson.D(doc)
+	if stages, ok := lookupKey(doc, "stages"); ok {
+		list, _ := subPipeline(stages)
+		for _, st := range list {
+			if len(st) == 0 {
+				continue
+			}
+			name := st[0].Key
+			res.PipelineStages = append(res.PipelineStages, name)
+			if name == "$cursor" {
+				section = documentElems(st[0].Value)
+			}
+			if n, ok := lookupNumber(st, "nReturned"); ok {
+				res.NReturned = n
+			}
+		}
+	}
+
+	if planner, ok := lookupKey(section, "queryPlanner"); ok {
+		if ns, ok := lookupKey(planner, "namespace"); ok {
+			res.Namespace, _ = ns.(string)
+		}
+		if wp, ok := lookupKey(planner, "winningPlan"); ok {
+			res.WinningPlan = parsePlanStage(wp)
+		}
+	}
+
+	if stats, ok := lookupKey(section, "executionStats"); ok {
+		if res.PipelineStages == nil {
+			res.NReturned, _ = lookupNumber(stats, "nReturned")
+		}
+		res.KeysExamined, _ = lookupNumber(stats, "totalKeysExamined")
+		res.DocsExamined, _ = lookupNumber(stats, "totalDocsExamined")
+		if ms, ok := lookupNumber(stats, "executionTimeMillis"); ok {
+			res.ExecutionTime = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return res, nil
+}
+
+// parsePlanStage converts a winning plan document into a PlanStage tree.
+func parsePlanStage(v interface{}) *PlanStage {
+	doc := documentElems(v)
+	if doc == nil {
+		return nil
+	}
+	// SBE plans nest the classic-shaped plan under "queryPlan".
+	if qp, ok := lookupKey(doc, "queryPlan"); ok {
+		return parsePlanStage(qp)
+	}
+
+	s := &PlanStage{}
+	for _, e := range doc {
+		switch e.Key {
+		case "stage":
+			s.Stage, _ = e.Value.(string)
+		case "indexName":
+			s.IndexName, _ = e.Value.(string)
+		case "keyPattern":
+			s.KeyPattern = documentElems(e.Value)
+		case "direction":
+			s.Direction, _ = e.Value.(string)
+		case "inputStage", "outerStage", "innerStage":
+			if in := parsePlanStage(e.Value); in != nil {
+				s.Inputs = append(s.Inputs, in)
+			}
+		case "inputStages":
+			if arr, ok := e.Value.(bson.A); ok {
+				for _, item := range arr {
+					if in := parsePlanStage(item); in != nil {
+						s.Inputs = append(s.Inputs, in)
+					}
+				}
+			}
+		case "shards":
+			// Sharded plans list one winning plan per shard.
+			if arr, ok := e.Value.(bson.A); ok {
+				for _, item := range arr {
+					if wp, ok := lookupKey(item, "winningPlan"); ok {
+						if in := parsePlanStage(wp); in != nil {
+							s.Inputs = append(s.Inputs, in)
+						}
+					}
+				}
+			}
+		}
+	}
+	if s.Stage == "" && len(s.Inputs) == 0 {
+		return nil
+	}
+	return s
+}
+
+// lookupNumber returns the numeric value stored under key as an int64.
+func lookupNumber(v interface{}, key string) (int64, bool) {
+	n, ok := lookupKey(v, key)
+	if !ok {
+		return 0, false
+	}
+	return toInt64(n)
+}
+
+// nonNilDoc returns d, or an empty document when d is nil, so that commands
+// always carry an object rather than null.
+func nonNilDoc(d bson.D) bson.D {
+	if d == nil {
+		return bson.D{}
+	}
+	return d
+}
+
+// collationDoc converts a driver Collation to the document shape expected by
+// server commands.
+func collationDoc(co *options.Collation) bson.D {
+	d := bson.D{}
+	if co.Locale != "" {
+		d = append(d, bson.E{Key: "locale", Value: co.Locale})
+	}
+	if co.CaseLevel {
+		d = append(d, bson.E{Key: "caseLevel", Value: true})
+	}
+	if co.CaseFirst != "" {
+		d = append(d, bson.E{Key: "caseFirst", Value: co.CaseFirst})
+	}
+	if co.Strength != 0 {
+		d = append(d, bson.E{Key: "strength", Value: int32(co.Strength)})
+	}
+	if co.NumericOrdering {
+		d = append(d, bson.E{Key: "numericOrdering", Value: true})
+	}
+	if co.Alternate != "" {
+		d = append(d, bson.E{Key: "alternate", Value: co.Alternate})
+	}
+	if co.MaxVariable != "" {
+		d = append(d, bson.E{Key: "maxVariable", Value: co.MaxVariable})
+	}
+	if co.Normalization {
+		d = append(d, bson.E{Key: "normalization", Value: true})
+	}
+	if co.Backwards {
+		d = append(d, bson.E{Key: "backwards", Value: true})
+	}
+	return d
+}
+
+// String renders the winning plan as an indented tree, e.g.
+//
+//	FETCH
+//	  IXSCAN (email_1)
+func (r *ExplainResult) String() string {
+	var b strings.Builder
+	var write func(s *PlanStage, depth int)
+	write = func(s *PlanStage, depth int) {
+		b.WriteString(strings.Repeat("  ", depth))
+		b.WriteString(s.Stage)
+		if s.IndexName != "" {
+			b.WriteString(" (" + s.IndexName + ")")
+		}
+		b.WriteString("\n")
+		for _, in := range s.Inputs {
+			write(in, depth+1)
+		}
+	}
+	if r.WinningPlan != nil {
+		write(r.WinningPlan, 0)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,185 @@
+package gmqb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func mustRaw(t *testing.T, doc bson.D) bson.Raw {
+	t.Helper()
+	raw, err := bson.Marshal(doc)
+	require.NoError(t, err)
+	return raw
+}
+
+func TestParseExplain_ClassicFind(t *testing.T) {
+	raw := mustRaw(t, bson.D{
+		{Key: "queryPlanner", Value: bson.D{
+			{Key: "namespace", Value: "app.users"},
+			{Key: "winningPlan", Value: bson.D{
+				{Key: "stage", Value: "FETCH"},
+				{Key: "inputStage", Value: bson.D{
+					{Key: "stage", Value: "IXSCAN"},
+					{Key: "indexName", Value: "email_1"},
+					{Key: "keyPattern", Value: bson.D{{Key: "email", Value: 1}}},
+					{Key: "direction", Value: "forward"},
+				}},
+			}},
+		}},
+		{Key: "executionStats", Value: bson.D{
+			{Key: "nReturned", Value: int32(1)},
+			{Key: "executionTimeMillis", Value: int32(3)},
+			{Key: "totalKeysExamined", Value: int32(1)},
+			{Key: "totalDocsExamined", Value: int32(1)},
+		}},
+	})
+
+	res, err := ParseExplain(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "app.users", res.Namespace)
+	assert.Equal(t, []string{"FETCH", "IXSCAN"}, res.StageNames())
+	assert.False(t, res.HasCollectionScan())
+	assert.False(t, res.HasInMemorySort())
+	assert.True(t, res.UsesIndex("email_1"))
+	assert.True(t, res.UsesIndex(""))
+	assert.False(t, res.UsesIndex("age_1"))
+	assert.Equal(t, "forward", res.WinningPlan.Inputs[0].Direction)
+	assert.Equal(t, bson.D{{Key: "email", Value: int32(1)}}, res.WinningPlan.Inputs[0].KeyPattern)
+	assert.Equal(t, int64(1), res.NReturned)
+	assert.Equal(t, int64(1), res.KeysExamined)
+	assert.Equal(t, int64(1), res.DocsExamined)
+	assert.Equal(t, 3*time.Millisecond, res.ExecutionTime)
+	assert.Equal(t, "FETCH\n  IXSCAN (email_1)", res.String())
+}
+
+func TestParseExplain_SBEPlan(t *testing.T) {
+	raw := mustRaw(t, bson.D{
+		{Key: "queryPlanner", Value: bson.D{
+			{Key: "winningPlan", Value: bson.D{
+				{Key: "queryPlan", Value: bson.D{
+					{Key: "stage", Value: "SORT"},
+					{Key: "inputStage", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}},
+				}},
+				{Key: "slotBasedPlan", Value: bson.D{{Key: "stages", Value: "..."}}},
+			}},
+		}},
+	})
+
+	res, err := ParseExplain(raw)
+	require.NoError(t, err)
+	assert.True(t, res.HasCollectionScan())
+	assert.True(t, res.HasInMemorySort())
+	assert.Empty(t, res.IndexNames())
+}
+
+func TestParseExplain_MultipleInputs(t *testing.T) {
+	raw := mustRaw(t, bson.D{
+		{Key: "queryPlanner", Value: bson.D{
+			{Key: "winningPlan", Value: bson.D{
+				{Key: "stage", Value: "SUBPLAN"},
+				{Key: "inputStage", Value: bson.D{
+					{Key: "stage", Value: "OR"},
+					{Key: "inputStages", Value: bson.A{
+						bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: "a_1"}},
+						bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: "b_1"}},
+					}},
+				}},
+			}},
+		}},
+	})
+
+	res, err := ParseExplain(raw)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a_1", "b_1"}, res.IndexNames())
+	assert.Equal(t, []string{"SUBPLAN", "OR", "IXSCAN", "IXSCAN"}, res.StageNames())
+}
+
+func TestParseExplain_Sharded(t *testing.T) {
+	raw := mustRaw(t, bson.D{
+		{Key: "queryPlanner", Value: bson.D{
+			{Key: "winningPlan", Value: bson.D{
+				{Key: "stage", Value: "SHARD_MERGE"},
+				{Key: "shards", Value: bson.A{
+					bson.D{{Key: "shardName", Value: "s0"}, {Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}}},
+					bson.D{{Key: "shardName", Value: "s1"}, {Key: "winningPlan", Value: bson.D{
+						{Key: "stage", Value: "FETCH"},
+						{Key: "inputStage", Value: bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: "x_1"}}},
+					}}},
+				}},
+			}},
+		}},
+	})
+
+	res, err := ParseExplain(raw)
+	require.NoError(t, err)
+	assert.True(t, res.HasCollectionScan())
+	assert.True(t, res.UsesIndex("x_1"))
+}
+
+func TestParseExplain_AggregateStages(t *testing.T) {
+	raw := mustRaw(t, bson.D{
+		{Key: "stages", Value: bson.A{
+			bson.D{{Key: "$cursor", Value: bson.D{
+				{Key: "queryPlanner", Value: bson.D{
+					{Key: "namespace", Value: "app.users"},
+					{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}},
+				}},
+				{Key: "executionStats", Value: bson.D{
+					{Key: "nReturned", Value: int32(100)},
+					{Key: "totalDocsExamined", Value: int32(100)},
+				}},
+			}}, {Key: "nReturned", Value: int64(100)}},
+			bson.D{{Key: "$group", Value: bson.D{}}, {Key: "nReturned", Value: int64(4)}},
+		}},
+	})
+
+	res, err := ParseExplain(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "app.users", res.Namespace)
+	assert.Equal(t, []string{"$cursor", "$group"}, res.PipelineStages)
+	assert.True(t, res.HasCollectionScan())
+	assert.Equal(t, int64(4), res.NReturned)
+	assert.Equal(t, int64(100), res.DocsExamined)
+}
+
+func TestParseExplain_Empty(t *testing.T) {
+	res, err := ParseExplain(mustRaw(t, bson.D{{Key: "ok", Value: 1.0}}))
+	require.NoError(t, err)
+	assert.Nil(t, res.WinningPlan)
+	assert.False(t, res.HasCollectionScan())
+	assert.Empty(t, res.String())
+}
+
+func TestCollationDoc(t *testing.T) {
+	d := collationDoc(&options.Collation{Locale: "en", Strength: 2, NumericOrdering: true})
+	assert.Equal(t, bson.D{
+		{Key: "locale", Value: "en"},
+		{Key: "strength", Value: int32(2)},
+		{Key: "numericOrdering", Value: true},
+	}, d)
+}
+
+func TestFindCommand(t *testing.T) {
+	cfg, err := buildOpts(OpFind, []Opt{
+		WithSort(Asc("age")),
+		WithLimit(5),
+		WithBatchSize(2),
+		WithAllowDiskUse(true),
+		WithComment("report"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "find", Value: "users"},
+		{Key: "filter", Value: bson.D{{Key: "active", Value: bson.D{{Key: "$eq", Value: true}}}}},
+		{Key: "sort", Value: Asc("age")},
+		{Key: "limit", Value: int64(5)},
+		{Key: "batchSize", Value: int32(2)},
+		{Key: "allowDiskUse", Value: true},
+		{Key: "comment", Value: "report"},
+	}, findCommand("users", Eq("active", true), cfg))
+}
@@ -22,7 +22,8 @@ import (
 //	    Exists("email", true)
 //	cursor, err := coll.Find(ctx, filter.BsonD())
 type Filter struct {
-	d bson.D
+	d     bson.D
+	bound *boundTemplate // set when bound from a FilterTemplate
 }
 
 // NewFilter creates an empty Filter ready for chaining.
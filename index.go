@@ -1,6 +1,8 @@
 package gmqb
 
 import (
+	"slices"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -8,13 +10,17 @@ import (
 
 // IndexModel represents a MongoDB index specification.
 // It wraps a set of keys and optional configuration.
+//
+// IndexModel is immutable: every method returns a new model and leaves the
+// receiver unchanged, so a base model can be shared and refined.
 type IndexModel struct {
 	keys    bson.D
 	options *options.IndexOptionsBuilder
 }
 
 // NewIndex creates a new IndexModel with the specified keys.
-// Keys can be created using gmqb.Asc, gmqb.Desc, or gmqb.SortSpec.
+// Keys can be created using gmqb.Asc, gmqb.Desc, gmqb.SortSpec or the
+// special index key helpers such as gmqb.TextKeys and gmqb.HashedKey.
 //
 // Example:
 //
@@ -26,28 +32,134 @@ func NewIndex(keys bson.D) IndexModel {
 	}
 }
 
+// with returns a copy of m with set applied to a copy of its options.
+func (m IndexModel) with(set func(*options.IndexOptionsBuilder)) IndexModel {
+	b := options.Index()
+	if m.options != nil {
+		b.Opts = slices.Clone(m.options.Opts)
+	}
+	set(b)
+	m.options = b
+	return m
+}
+
 // Unique sets the index to be unique.
 func (m IndexModel) Unique() IndexModel {
-	m.options.SetUnique(true)
-	return m
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetUnique(true) })
 }
 
 // Sparse sets the index to be sparse.
 func (m IndexModel) Sparse() IndexModel {
-	m.options.SetSparse(true)
-	return m
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetSparse(true) })
 }
 
 // TTL sets the time-to-live for documents in seconds.
 func (m IndexModel) TTL(seconds int32) IndexModel {
-	m.options.SetExpireAfterSeconds(seconds)
-	return m
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetExpireAfterSeconds(seconds) })
 }
 
 // Name sets a custom name for the index.
 func (m IndexModel) Name(name string) IndexModel {
-	m.options.SetName(name)
-	return m
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetName(name) })
+}
+
+// PartialFilter only indexes the documents that match filter.
+//
+// MongoDB equivalent:
+//
+//	{ partialFilterExpression: <filter> }
+//
+// See: https://www.mongodb.com/docs/manual/core/index-partial/
+//
+// Example:
+//
+//	gmqb.NewIndex(gmqb.Asc("email")).Unique().PartialFilter(gmqb.Eq("deleted", false))
+func (m IndexModel) PartialFilter(filter Filter) IndexModel {
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetPartialFilterExpression(filter.BsonD()) })
+}
+
+// Collation sets the collation used to compare strings in the index. Only
+// queries with the same collation can use the index.
+//
+// See: https://www.mongodb.com/docs/manual/reference/collation/
+//
+// Example:
+//
+//	gmqb.NewIndex(gmqb.Asc("name")).Collation(&options.Collation{Locale: "en", Strength: 2})
+func (m IndexModel) Collation(collation *options.Collation) IndexModel {
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetCollation(collation) })
+}
+
+// Hidden hides the index from the query planner while still maintaining
+// it, so the effect of dropping it can be evaluated.
+//
+// See: https://www.mongodb.com/docs/manual/core/index-hidden/
+func (m IndexModel) Hidden() IndexModel {
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetHidden(true) })
+}
+
+// WildcardProjection selects the fields a wildcard index on "$**" covers.
+// The projection is built with gmqb.Include or gmqb.Exclude.
+//
+// See: https://www.mongodb.com/docs/manual/core/indexes/index-types/index-wildcard/
+//
+// Example:
+//
+//	gmqb.NewIndex(gmqb.WildcardKey("")).WildcardProjection(gmqb.Include("attrs", "tags"))
+func (m IndexModel) WildcardProjection(projection bson.D) IndexModel {
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetWildcardProjection(projection) })
+}
+
+// SphereVersion sets the 2dsphereIndexVersion of a 2dsphere index.
+//
+// See: https://www.mongodb.com/docs/manual/core/indexes/index-types/geospatial/2dsphere/
+func (m IndexModel) SphereVersion(version int32) IndexModel {
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetSphereVersion(version) })
+}
+
+// GeoBits sets the geohash precision of a 2d index, from 1 to 32 bits.
+// The default is 26.
+//
+// See: https://www.mongodb.com/docs/manual/core/indexes/index-types/geospatial/2d/
+func (m IndexModel) GeoBits(bits int32) IndexModel {
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetBits(bits) })
+}
+
+// GeoBounds sets the coordinate range of a 2d index. The default is
+// [-180, 180).
+//
+// Example:
+//
+//	gmqb.NewIndex(gmqb.Geo2DKey("pos")).GeoBounds(0, 1024)
+func (m IndexModel) GeoBounds(min, max float64) IndexModel {
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetMin(min).SetMax(max) })
+}
+
+// TextWeights sets the relative weight of the fields of a text index.
+// Fields without a weight default to 1.
+//
+// See: https://www.mongodb.com/docs/manual/core/indexes/index-types/index-text/control-text-search-results/
+//
+// Example:
+//
+//	gmqb.NewIndex(gmqb.TextKeys("title", "body")).
+//	    TextWeights(bson.D{{Key: "title", Value: 10}}).
+//	    DefaultLanguage("english")
+func (m IndexModel) TextWeights(weights bson.D) IndexModel {
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetWeights(weights) })
+}
+
+// DefaultLanguage sets the language that determines the stop words and
+// stemming rules of a text index. The default is "english"; "none" disables
+// stemming.
+func (m IndexModel) DefaultLanguage(language string) IndexModel {
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetDefaultLanguage(language) })
+}
+
+// LanguageOverride names the document field that overrides the default
+// language of a text index. The default is "language".
+func (m IndexModel) LanguageOverride(field string) IndexModel {
+	return m.with(func(b *options.IndexOptionsBuilder) { b.SetLanguageOverride(field) })
 }
 
 // MongoIndexModel converts the gmqb.IndexModel to a mongo.IndexModel.
@@ -57,3 +169,165 @@ func (m IndexModel) MongoIndexModel() mongo.IndexModel {
 		Options: m.options,
 	}
 }
+
+// TextKeys returns the keys of a text index over fields.
+//
+// MongoDB equivalent:
+//
+//	{ <field1>: "text", <field2>: "text", ... }
+//
+// See: https://www.mongodb.com/docs/manual/core/indexes/index-types/index-text/
+//
+// Example:
+//
+//	gmqb.NewIndex(gmqb.TextKeys("title", "body"))
+func TextKeys(fields ...string) bson.D {
+	return indexKeys("text", fields)
+}
+
+// HashedKey returns the key of a hashed index on field. Append it to
+// ascending or descending keys for a compound hashed index.
+//
+// MongoDB equivalent:
+//
+//	{ <field>: "hashed" }
+//
+// See: https://www.mongodb.com/docs/manual/core/indexes/index-types/index-hashed/
+//
+// Example:
+//
+//	gmqb.NewIndex(append(gmqb.Asc("tenant"), gmqb.HashedKey("user")...))
+func HashedKey(field string) bson.D {
+	return bson.D{{Key: field, Value: "hashed"}}
+}
+
+// Geo2DSphereKeys returns the keys of a 2dsphere index over GeoJSON or
+// legacy coordinate pair fields.
+//
+// MongoDB equivalent:
+//
+//	{ <field>: "2dsphere" }
+//
+// See: https://www.mongodb.com/docs/manual/core/indexes/index-types/geospatial/2dsphere/
+//
+// Example:
+//
+//	gmqb.NewIndex(gmqb.Geo2DSphereKeys("location")).SphereVersion(3)
+func Geo2DSphereKeys(fields ...string) bson.D {
+	return indexKeys("2dsphere", fields)
+}
+
+// Geo2DKey returns the key of a 2d index on a legacy coordinate pair field.
+//
+// MongoDB equivalent:
+//
+//	{ <field>: "2d" }
+//
+// See: https://www.mongodb.com/docs/manual/core/indexes/index-types/geospatial/2d/
+func Geo2DKey(field string) bson.D {
+	return bson.D{{Key: field, Value: "2d"}}
+}
+
+// WildcardKey returns the key of a wildcard index on the fields under path,
+// or on all fields when path is empty.
+//
+// MongoDB equivalent:
+//
+//	{ "<path>.$**": 1 }
+//
+// See: https://www.mongodb.com/docs/manual/core/indexes/index-types/index-wildcard/
+//
+// Example:
+//
+//	gmqb.NewIndex(gmqb.WildcardKey("attrs"))
+func WildcardKey(path string) bson.D {
+	if path == "" {
+		return bson.D{{Key: "$**", Value: 1}}
+	}
+	return bson.D{{Key: path + ".$**", Value: 1}}
+}
+
+// indexKeys returns a key specification giving every field the same type.
+func indexKeys(typ string, fields []string) bson.D {
+	d := make(bson.D, len(fields))
+	for i, f := range fields {
+		d[i] = bson.E{Key: f, Value: typ}
+	}
+	return d
+}
+
+// ClusteredIndex returns the clusteredIndex option of a new clustered
+// collection, whose documents are stored ordered by _id. An empty name lets
+// the server choose one. Clustered indexes can only be defined when the
+// collection is created.
+//
+// MongoDB equivalent:
+//
+//	{ clusteredIndex: { key: { _id: 1 }, unique: true, name: <name> } }
+//
+// See: https://www.mongodb.com/docs/manual/core/clustered-collections/
+//
+// Example:
+//
+//	err := db.CreateCollection(ctx, "events",
+//	    options.CreateCollection().SetClusteredIndex(gmqb.ClusteredIndex("events_by_id")))
+func ClusteredIndex(name string) bson.D {
+	d := bson.D{
+		{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}},
+		{Key: "unique", Value: true},
+	}
+	if name != "" {
+		d = append(d, bson.E{Key: "name", Value: name})
+	}
+	return d
+}
+
+// IndexInfo describes an index as reported by listIndexes.
+//
+// See: https://www.mongodb.com/docs/manual/reference/command/listIndexes/
+type IndexInfo struct {
+	// Name is the index name, e.g. "email_1".
+	Name string `bson:"name"`
+	// Keys is the index key specification.
+	Keys bson.D `bson:"key"`
+	// Version is the index version.
+	Version int32 `bson:"v"`
+	// Unique and Sparse report the index options of the same name.
+	Unique bool `bson:"unique,omitempty"`
+	Sparse bool `bson:"sparse,omitempty"`
+	// ExpireAfterSeconds is set for TTL indexes.
+	ExpireAfterSeconds *int32 `bson:"expireAfterSeconds,omitempty"`
+	// PartialFilterExpression is set for partial indexes.
+	PartialFilterExpression bson.D `bson:"partialFilterExpression,omitempty"`
+	// Collation is the index collation with the server's defaults filled
+	// in, or nil for the simple binary collation.
+	Collation bson.D `bson:"collation,omitempty"`
+	// Hidden reports whether the index is hidden from the query planner.
+	Hidden bool `bson:"hidden,omitempty"`
+	// WildcardProjection is set for wildcard indexes on "$**".
+	WildcardProjection bson.D `bson:"wildcardProjection,omitempty"`
+	// Weights, DefaultLanguage and LanguageOverride are set for text
+	// indexes, whose Keys hold "_fts" and "_ftsx" in place of the indexed
+	// fields.
+	Weights          bson.D `bson:"weights,omitempty"`
+	DefaultLanguage  string `bson:"default_language,omitempty"`
+	LanguageOverride string `bson:"language_override,omitempty"`
+	// SphereVersion is the 2dsphereIndexVersion of a 2dsphere index.
+	SphereVersion *int32 `bson:"2dsphereIndexVersion,omitempty"`
+	// Bits, Min and Max are the precision and bounds of a 2d index.
+	Bits *int32   `bson:"bits,omitempty"`
+	Min  *float64 `bson:"min,omitempty"`
+	Max  *float64 `bson:"max,omitempty"`
+	// Raw is the full listIndexes entry.
+	Raw bson.Raw `bson:"-"`
+}
+
+// parseIndexInfo decodes a listIndexes entry.
+func parseIndexInfo(raw bson.Raw) (IndexInfo, error) {
+	var info IndexInfo
+	if err := bson.Unmarshal(raw, &info); err != nil {
+		return IndexInfo{}, err
+	}
+	info.Raw = raw
+	return info, nil
+}
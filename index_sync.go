@@ -0,0 +1,487 @@
+package gmqb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// idIndexName is the name of the index MongoDB maintains on _id.
+const idIndexName = "_id_"
+
+// SyncOpts configures SyncIndexes.
+type SyncOpts struct {
+	// DryRun computes the plan without changing any index.
+	DryRun bool
+	// DropUnknown drops existing indexes that are not in the desired set.
+	// The _id index is never dropped.
+	DropUnknown bool
+}
+
+// IndexAction is the kind of change SyncIndexes makes to an index.
+type IndexAction string
+
+const (
+	// IndexCreate creates a missing index.
+	IndexCreate IndexAction = "create"
+	// IndexDrop drops an index. An index whose specification changed in a
+	// way collMod cannot apply is dropped and created again.
+	IndexDrop IndexAction = "drop"
+	// IndexModify changes an index in place with collMod.
+	IndexModify IndexAction = "modify"
+)
+
+// IndexChange is one step of an IndexPlan.
+type IndexChange struct {
+	Action IndexAction
+	// Name is the name of the index.
+	Name string
+	// Model is the desired index, for create and modify actions.
+	Model IndexModel
+	// Existing is the index found on the server, for drop and modify
+	// actions.
+	Existing *IndexInfo
+	// Reason describes the difference that requires the change, e.g.
+	// "expireAfterSeconds: 60 -> 3600". It is empty for a missing or
+	// unknown index.
+	Reason string
+}
+
+// String renders the change as a single line, e.g.
+// "modify expires_1: expireAfterSeconds: 60 -> 3600".
+func (ch IndexChange) String() string {
+	s := string(ch.Action) + " " + ch.Name
+	if ch.Action == IndexCreate {
+		s += " " + toCompactJSON(ch.Model.keys)
+	}
+	if ch.Reason != "" {
+		s += ": " + ch.Reason
+	}
+	return s
+}
+
+// IndexPlan lists the changes SyncIndexes makes, in the order it applies
+// them: drops, then modifications, then creations.
+type IndexPlan struct {
+	Changes []IndexChange
+}
+
+// IsEmpty reports whether the indexes already match the desired set.
+func (p IndexPlan) IsEmpty() bool {
+	return len(p.Changes) == 0
+}
+
+// String renders the plan with one change per line.
+func (p IndexPlan) String() string {
+	lines := make([]string, len(p.Changes))
+	for i, ch := range p.Changes {
+		lines[i] = ch.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SyncIndexes reconciles the collection's indexes with desired and returns
+// the plan it applied. Indexes are matched by name, or by keys when the
+// name differs. A missing index is created and one whose keys, uniqueness,
+// sparseness, partial filter, collation or other options differ is dropped
+// and created again. TTL and hidden changes are applied in place with
+// collMod. Indexes that are not desired are kept unless opts.DropUnknown is
+// set.
+//
+// With opts.DryRun the plan is returned without being applied. If applying
+// fails, the error is returned with the full plan, part of which may have
+// been applied.
+//
+// Example:
+//
+//	plan, err := coll.SyncIndexes(ctx, []gmqb.IndexModel{
+//	    gmqb.NewIndex(gmqb.Asc("email")).Unique(),
+//	    gmqb.NewIndex(gmqb.Asc("expiresAt")).TTL(3600),
+//	}, gmqb.SyncOpts{DropUnknown: true})
+//	fmt.Println(plan)
+//	// drop legacy_1
+//	// modify expiresAt_1: expireAfterSeconds: 60 -> 3600
+//	// create email_1 {"email":1}
+func (c *Collection[T]) SyncIndexes(ctx context.Context, desired []IndexModel, opts SyncOpts) (IndexPlan, error) {
+	existing, err := c.ListIndexes(ctx)
+	if err != nil {
+		return IndexPlan{}, err
+	}
+	plan, err := planIndexSync(existing, desired, opts.DropUnknown)
+	if err != nil || opts.DryRun {
+		return plan, err
+	}
+	return plan, c.applyIndexPlan(ctx, plan)
+}
+
+// planIndexSync diffs the existing indexes against the desired ones.
+func planIndexSync(existing []IndexInfo, desired []IndexModel, dropUnknown bool) (IndexPlan, error) {
+	var drops, modifies, creates []IndexChange
+	matched := make([]bool, len(existing))
+
+	for _, m := range desired {
+		want, err := m.info()
+		if err != nil {
+			return IndexPlan{}, err
+		}
+		if want.Name == idIndexName {
+			continue
+		}
+		i := matchIndex(existing, matched, want)
+		if i < 0 {
+			creates = append(creates, IndexChange{Action: IndexCreate, Name: want.Name, Model: m})
+			continue
+		}
+		matched[i] = true
+		have := &existing[i]
+		diffs, inPlace := diffIndex(*have, want)
+		switch {
+		case len(diffs) == 0:
+		case inPlace:
+			modifies = append(modifies, IndexChange{Action: IndexModify, Name: have.Name, Model: m, Existing: have, Reason: strings.Join(diffs, ", ")})
+		default:
+			reason := strings.Join(diffs, ", ")
+			drops = append(drops, IndexChange{Action: IndexDrop, Name: have.Name, Existing: have, Reason: reason})
+			creates = append(creates, IndexChange{Action: IndexCreate, Name: want.Name, Model: m, Reason: reason})
+		}
+	}
+
+	if dropUnknown {
+		for i := range existing {
+			if !matched[i] && existing[i].Name != idIndexName {
+				drops = append(drops, IndexChange{Action: IndexDrop, Name: existing[i].Name, Existing: &existing[i]})
+			}
+		}
+	}
+
+	changes := append(append(drops, modifies...), creates...)
+	return IndexPlan{Changes: changes}, nil
+}
+
+// matchIndex returns the position of the unmatched existing index with
+// want's name, or failing that with want's keys, or -1.
+func matchIndex(existing []IndexInfo, matched []bool, want IndexInfo) int {
+	for i, have := range existing {
+		if !matched[i] && have.Name == want.Name {
+			return i
+		}
+	}
+	for i, have := range existing {
+		if !matched[i] && have.Name != idIndexName && indexKeysEqual(have.Keys, want.Keys) {
+			return i
+		}
+	}
+	return -1
+}
+
+// diffIndex describes how have differs from want. inPlace reports whether
+// every difference can be applied with collMod.
+func diffIndex(have, want IndexInfo) (diffs []string, inPlace bool) {
+	rebuild := false
+	add := func(field string, from, to any, canModify bool) {
+		diffs = append(diffs, fmt.Sprintf("%s: %v -> %v", field, from, to))
+		rebuild = rebuild || !canModify
+	}
+	if have.Name != want.Name {
+		add("name", have.Name, want.Name, false)
+	}
+	if !indexKeysEqual(have.Keys, want.Keys) {
+		add("keys", toCompactJSON(have.Keys), toCompactJSON(want.Keys), false)
+	}
+	if have.Unique != want.Unique {
+		add("unique", have.Unique, want.Unique, false)
+	}
+	if have.Sparse != want.Sparse {
+		add("sparse", have.Sparse, want.Sparse, false)
+	}
+	if from, to := optString(have.ExpireAfterSeconds), optString(want.ExpireAfterSeconds); from != to {
+		// collMod can change the expiry of a TTL index but not add or
+		// remove one.
+		add("expireAfterSeconds", from, to, have.ExpireAfterSeconds != nil && want.ExpireAfterSeconds != nil)
+	}
+	if from, to := docString(have.PartialFilterExpression), docString(want.PartialFilterExpression); from != to {
+		add("partialFilterExpression", from, to, false)
+	}
+	if !collationMatches(have.Collation, want.Collation) {
+		add("collation", docString(have.Collation), docString(want.Collation), false)
+	}
+	if have.Hidden != want.Hidden {
+		add("hidden", have.Hidden, want.Hidden, true)
+	}
+	if from, to := docString(have.WildcardProjection), docString(want.WildcardProjection); from != to {
+		add("wildcardProjection", from, to, false)
+	}
+	if !sameFields(have.Weights, want.Weights) {
+		add("weights", docString(have.Weights), docString(want.Weights), false)
+	}
+	if have.DefaultLanguage != want.DefaultLanguage {
+		add("default_language", have.DefaultLanguage, want.DefaultLanguage, false)
+	}
+	if have.LanguageOverride != want.LanguageOverride {
+		add("language_override", have.LanguageOverride, want.LanguageOverride, false)
+	}
+	// The server fills in defaults for these, so they are only compared
+	// when the desired index sets them.
+	for _, opt := range []struct {
+		name       string
+		have, want any
+	}{
+		{"2dsphereIndexVersion", have.SphereVersion, want.SphereVersion},
+		{"bits", have.Bits, want.Bits},
+		{"min", have.Min, want.Min},
+		{"max", have.Max, want.Max},
+	} {
+		if from, to := optString(opt.have), optString(opt.want); to != "none" && from != to {
+			add(opt.name, from, to, false)
+		}
+	}
+	return diffs, !rebuild
+}
+
+// sameFields reports whether two documents hold the same fields and values
+// regardless of order, as the server may reorder text index weights.
+func sameFields(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, e := range a {
+		v, ok := lookupKey(b, e.Key)
+		if !ok || fmt.Sprint(v) != fmt.Sprint(e.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// collModIndex returns the collMod index options that turn have into want.
+func collModIndex(have IndexInfo, want IndexInfo) bson.D {
+	d := bson.D{{Key: "name", Value: have.Name}}
+	if want.ExpireAfterSeconds != nil && optString(have.ExpireAfterSeconds) != optString(want.ExpireAfterSeconds) {
+		d = append(d, bson.E{Key: "expireAfterSeconds", Value: *want.ExpireAfterSeconds})
+	}
+	if have.Hidden != want.Hidden {
+		d = append(d, bson.E{Key: "hidden", Value: want.Hidden})
+	}
+	return d
+}
+
+// applyIndexPlan carries out the changes of plan in order.
+func (c *Collection[T]) applyIndexPlan(ctx context.Context, plan IndexPlan) error {
+	var creates []IndexModel
+	for _, ch := range plan.Changes {
+		switch ch.Action {
+		case IndexDrop:
+			if err := c.DropIndex(ctx, ch.Name); err != nil {
+				return fmt.Errorf("gmqb: drop index %s: %w", ch.Name, err)
+			}
+		case IndexModify:
+			want, err := ch.Model.info()
+			if err != nil {
+				return err
+			}
+			if err := c.collMod(ctx, bson.D{{Key: "index", Value: collModIndex(*ch.Existing, want)}}); err != nil {
+				return fmt.Errorf("gmqb: modify index %s: %w", ch.Name, err)
+			}
+		case IndexCreate:
+			creates = append(creates, ch.Model)
+		}
+	}
+	if len(creates) == 0 {
+		return nil
+	}
+	_, err := c.CreateIndexes(ctx, creates)
+	return err
+}
+
+// collMod runs the collMod command on the collection with the given
+// options.
+//
+// See: https://www.mongodb.com/docs/manual/reference/command/collMod/
+func (c *Collection[T]) collMod(ctx context.Context, opts bson.D) error {
+	_, err := intercept(c, ctx, &OpInfo{Operation: OpCollMod, Document: opts}, func(ctx context.Context, op *OpInfo) (struct{}, error) {
+		opts, _ := op.Document.(bson.D)
+		cmd := append(bson.D{{Key: "collMod", Value: c.coll.Name()}}, opts...)
+		return struct{}{}, c.coll.Database().RunCommand(ctx, cmd).Err()
+	})
+	return err
+}
+
+// info returns the IndexInfo the server would report for m.
+func (m IndexModel) info() (IndexInfo, error) {
+	var o options.IndexOptions
+	if m.options != nil {
+		o = resolveOptions[options.IndexOptions](m.options)
+	}
+	info := IndexInfo{Keys: m.keys}
+	if o.Name != nil {
+		info.Name = *o.Name
+	} else {
+		info.Name = defaultIndexName(m.keys)
+	}
+	info.Unique = o.Unique != nil && *o.Unique
+	info.Sparse = o.Sparse != nil && *o.Sparse
+	info.Hidden = o.Hidden != nil && *o.Hidden
+	info.ExpireAfterSeconds = o.ExpireAfterSeconds
+	info.SphereVersion = o.SphereVersion
+	info.Bits, info.Min, info.Max = o.Bits, o.Min, o.Max
+	for _, opt := range []struct {
+		name string
+		v    any
+		dst  *bson.D
+	}{
+		{"partial filter", o.PartialFilterExpression, &info.PartialFilterExpression},
+		{"wildcard projection", o.WildcardProjection, &info.WildcardProjection},
+		{"weights", o.Weights, &info.Weights},
+	} {
+		if opt.v == nil {
+			continue
+		}
+		d, err := toBsonD(opt.v)
+		if err != nil {
+			return IndexInfo{}, fmt.Errorf("gmqb: index %s: %s: %w", info.Name, opt.name, err)
+		}
+		*opt.dst = d
+	}
+	if o.Collation != nil {
+		info.Collation = collationDoc(o.Collation)
+	}
+	if text := textFields(m.keys); len(text) > 0 {
+		info.Keys = textIndexKeys(m.keys)
+		info.Weights = textWeights(text, info.Weights)
+		info.DefaultLanguage = "english"
+		if o.DefaultLanguage != nil {
+			info.DefaultLanguage = *o.DefaultLanguage
+		}
+		info.LanguageOverride = "language"
+		if o.LanguageOverride != nil {
+			info.LanguageOverride = *o.LanguageOverride
+		}
+	}
+	return info, nil
+}
+
+// textFields returns the fields of keys indexed as text.
+func textFields(keys bson.D) []string {
+	var fields []string
+	for _, k := range keys {
+		if k.Value == "text" {
+			fields = append(fields, k.Key)
+		}
+	}
+	return fields
+}
+
+// textIndexKeys returns keys as the server lists them for a text index:
+// the text fields are replaced by "_fts" and "_ftsx" at the position of the
+// first one.
+func textIndexKeys(keys bson.D) bson.D {
+	out := make(bson.D, 0, len(keys)+1)
+	seen := false
+	for _, k := range keys {
+		if k.Value != "text" {
+			out = append(out, k)
+			continue
+		}
+		if !seen {
+			out = append(out, bson.E{Key: "_fts", Value: "text"}, bson.E{Key: "_ftsx", Value: 1})
+			seen = true
+		}
+	}
+	return out
+}
+
+// textWeights returns the weights the server records for a text index on
+// fields: 1 for each field, overridden by the explicit weights.
+func textWeights(fields []string, explicit bson.D) bson.D {
+	weights := make(bson.D, 0, len(fields)+len(explicit))
+	for _, f := range fields {
+		if _, ok := lookupKey(explicit, f); !ok {
+			weights = append(weights, bson.E{Key: f, Value: 1})
+		}
+	}
+	return append(weights, explicit...)
+}
+
+// defaultIndexName returns the name MongoDB generates for an index on keys,
+// e.g. "tenant_1_created_-1".
+func defaultIndexName(keys bson.D) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s_%v", k.Key, k.Value)
+	}
+	return strings.Join(parts, "_")
+}
+
+// indexKeysEqual compares key specifications, treating numerically equal
+// directions of different types as equal.
+func indexKeysEqual(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || fmt.Sprint(a[i].Value) != fmt.Sprint(b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// collationMatches reports whether an index collation as listed by the
+// server satisfies the desired one. The server fills in defaults, so only
+// the desired fields are compared.
+func collationMatches(have, want bson.D) bool {
+	if want == nil {
+		locale, _ := lookupKey(have, "locale")
+		return have == nil || locale == "simple"
+	}
+	for _, e := range want {
+		v, ok := lookupKey(have, e.Key)
+		if !ok || fmt.Sprint(v) != fmt.Sprint(e.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// toBsonD round-trips a document value through BSON.
+func toBsonD(v any) (bson.D, error) {
+	if s, ok := v.(Serializable); ok {
+		v = s.BsonD()
+	}
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	if err := bson.Unmarshal(raw, &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// optString renders an optional *int32 or *float64 option for an
+// IndexChange reason.
+func optString(v any) string {
+	switch p := v.(type) {
+	case *int32:
+		if p != nil {
+			return fmt.Sprint(*p)
+		}
+	case *float64:
+		if p != nil {
+			return fmt.Sprint(*p)
+		}
+	}
+	return "none"
+}
+
+// docString renders an optional document for an IndexChange reason.
+func docString(d bson.D) string {
+	if d == nil {
+		return "none"
+	}
+	return toCompactJSON(d)
+}
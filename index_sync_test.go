@@ -0,0 +1,103 @@
+package gmqb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func int32Ptr(v int32) *int32 { return &v }
+
+func TestPlanIndexSync(t *testing.T) {
+	existing := []IndexInfo{
+		{Name: "_id_", Keys: bson.D{{Key: "_id", Value: int32(1)}}},
+		{Name: "email_1", Keys: bson.D{{Key: "email", Value: int32(1)}}},
+		{Name: "expires_1", Keys: bson.D{{Key: "expires", Value: int32(1)}}, ExpireAfterSeconds: int32Ptr(60)},
+		{Name: "old_name", Keys: bson.D{{Key: "tenant", Value: int32(1)}}},
+		{Name: "legacy_1", Keys: bson.D{{Key: "legacy", Value: 1.0}}},
+		{Name: "status_1", Keys: bson.D{{Key: "status", Value: int32(1)}}},
+	}
+	desired := []IndexModel{
+		NewIndex(Asc("email")).Unique(),
+		NewIndex(Asc("expires")).TTL(3600),
+		NewIndex(Asc("tenant")).Name("by_tenant"),
+		NewIndex(Asc("status")),
+		NewIndex(Desc("age")),
+	}
+
+	plan, err := planIndexSync(existing, desired, false)
+	require.NoError(t, err)
+	assert.Equal(t, `drop email_1: unique: false -> true
+drop old_name: name: old_name -> by_tenant
+modify expires_1: expireAfterSeconds: 60 -> 3600
+create email_1 {"email":1}: unique: false -> true
+create by_tenant {"tenant":1}: name: old_name -> by_tenant
+create age_-1 {"age":-1}`, plan.String())
+	assert.Same(t, &existing[2], plan.Changes[2].Existing)
+
+	plan, err = planIndexSync(existing, desired, true)
+	require.NoError(t, err)
+	assert.Equal(t, IndexDrop, plan.Changes[2].Action)
+	assert.Equal(t, "legacy_1", plan.Changes[2].Name)
+
+	plan, err = planIndexSync(existing[:2], []IndexModel{NewIndex(Asc("email"))}, true)
+	require.NoError(t, err)
+	assert.True(t, plan.IsEmpty())
+}
+
+func TestDiffIndex(t *testing.T) {
+	ttl := IndexInfo{Name: "a_1", Keys: bson.D{{Key: "a", Value: int32(1)}}, ExpireAfterSeconds: int32Ptr(60)}
+	plain := IndexInfo{Name: "a_1", Keys: bson.D{{Key: "a", Value: int32(1)}}}
+
+	diffs, inPlace := diffIndex(plain, ttl)
+	assert.Equal(t, []string{"expireAfterSeconds: none -> 60"}, diffs)
+	assert.False(t, inPlace, "collMod cannot turn a regular index into a TTL index")
+
+	withFilter := plain
+	withFilter.PartialFilterExpression = bson.D{{Key: "a", Value: bson.D{{Key: "$gt", Value: int32(5)}}}}
+	diffs, _ = diffIndex(plain, withFilter)
+	assert.Equal(t, []string{`partialFilterExpression: none -> {"a":{"$gt":5}}`}, diffs)
+
+	// The server fills in collation defaults, which the desired spec omits.
+	listed := plain
+	listed.Collation = bson.D{{Key: "locale", Value: "en"}, {Key: "caseLevel", Value: false}, {Key: "strength", Value: int32(2)}}
+	desired := plain
+	desired.Collation = collationDoc(&options.Collation{Locale: "en", Strength: 2})
+	diffs, _ = diffIndex(listed, desired)
+	assert.Empty(t, diffs)
+
+	desired.Collation = collationDoc(&options.Collation{Locale: "fr"})
+	diffs, _ = diffIndex(listed, desired)
+	assert.Len(t, diffs, 1)
+
+	diffs, _ = diffIndex(listed, plain)
+	assert.Len(t, diffs, 1)
+	listed.Collation = bson.D{{Key: "locale", Value: "simple"}}
+	diffs, _ = diffIndex(listed, plain)
+	assert.Empty(t, diffs)
+}
+
+func TestIndexModel_Info(t *testing.T) {
+	info, err := NewIndex(SortSpec(SortRule("tenant", 1), SortRule("created", -1))).Sparse().info()
+	require.NoError(t, err)
+	assert.Equal(t, "tenant_1_created_-1", info.Name)
+	assert.True(t, info.Sparse)
+	assert.Nil(t, info.ExpireAfterSeconds)
+
+	raw, err := bson.Marshal(bson.D{
+		{Key: "v", Value: int32(2)},
+		{Key: "key", Value: bson.D{{Key: "expires", Value: int32(1)}}},
+		{Key: "name", Value: "expires_1"},
+		{Key: "expireAfterSeconds", Value: 3600.0},
+	})
+	require.NoError(t, err)
+	listed, err := parseIndexInfo(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "expires_1", listed.Name)
+	assert.Equal(t, int32(2), listed.Version)
+	assert.Equal(t, int32(3600), *listed.ExpireAfterSeconds)
+	assert.Equal(t, bson.Raw(raw), listed.Raw)
+}
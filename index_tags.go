@@ -0,0 +1,254 @@
+package gmqb
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// taggedIndexField is one field of an index declared in a gmqb tag.
+type taggedIndexField struct {
+	path  string
+	desc  bool
+	order int
+	seq   int // declaration position, the tiebreaker for equal orders
+}
+
+// taggedIndex collects the fields and options of one declared index.
+type taggedIndex struct {
+	name   string
+	fields []taggedIndexField
+	unique bool
+	sparse bool
+	ttl    *int32
+}
+
+// IndexesFor returns the indexes declared by the gmqb struct tags of T.
+//
+// A field's gmqb tag is a comma-separated list of directives:
+//
+//   - index: a single-field ascending index on the field.
+//   - unique, sparse: make the index unique or sparse, declaring a
+//     single-field index if the tag has none yet.
+//   - desc: sort the field in descending order.
+//   - ttl=SECONDS: expire documents SECONDS after the time in the field.
+//   - index=NAME: add the field to the compound index NAME. Fields are
+//     ordered by order=N, then by declaration order.
+//   - order=N: the field's position in the preceding named index.
+//
+// Modifiers apply to the most recent index directive, so a field can take
+// part in several indexes. Nested struct fields, including those of slice
+// and array elements, are indexed by their dotted BSON path and fields of
+// structs with bson:",inline" by their own name. The createdAt and
+// updatedAt directives of the timestamp fields and the directives of
+// JSONSchemaFor are allowed alongside; any other directive is an error, so
+// a misspelt one is not silently dropped.
+//
+// Example:
+//
+//	type Order struct {
+//	    Tenant    string    `bson:"tenant" gmqb:"index=tenant_created,order=1"`
+//	    CreatedAt time.Time `bson:"created" gmqb:"index=tenant_created,order=2,desc"`
+//	    Email     string    `bson:"email" gmqb:"unique"`
+//	    ExpiresAt time.Time `bson:"expires" gmqb:"ttl=86400"`
+//	}
+//	models, err := gmqb.IndexesFor[Order]()
+func IndexesFor[T any]() ([]IndexModel, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var (
+		single []*taggedIndex
+		named  []*taggedIndex
+		seq    int
+	)
+	byName := map[string]*taggedIndex{}
+	err := walkIndexTags(t, "", map[reflect.Type]bool{}, func(path string, directives []string) error {
+		var (
+			cur *taggedIndex
+			pos int // position of this field in cur.fields
+		)
+		// ensure returns the index the next modifier applies to, declaring a
+		// single-field index when the tag has not declared one yet.
+		ensure := func() *taggedIndex {
+			if cur == nil {
+				cur = &taggedIndex{fields: []taggedIndexField{{path: path}}}
+				pos = 0
+				single = append(single, cur)
+			}
+			return cur
+		}
+		for _, d := range directives {
+			key, value, hasValue := strings.Cut(d, "=")
+			switch {
+			case key == "index" && !hasValue:
+				cur = nil
+				ensure()
+			case key == "index":
+				if value == "" {
+					return fmt.Errorf("%w: %s: empty index name", ErrInvalidIndexTag, path)
+				}
+				idx, ok := byName[value]
+				if !ok {
+					idx = &taggedIndex{name: value}
+					byName[value] = idx
+					named = append(named, idx)
+				}
+				seq++
+				idx.fields = append(idx.fields, taggedIndexField{path: path, seq: seq})
+				cur, pos = idx, len(idx.fields)-1
+			case key == "unique":
+				ensure().unique = true
+			case key == "sparse":
+				ensure().sparse = true
+			case key == "desc":
+				ensure().fields[pos].desc = true
+			case key == "order":
+				n, err := strconv.Atoi(value)
+				if err != nil || cur == nil || cur.name == "" {
+					return fmt.Errorf("%w: %s: order=%s must follow index=NAME and be an integer", ErrInvalidIndexTag, path, value)
+				}
+				cur.fields[pos].order = n
+			case key == "ttl":
+				n, err := strconv.ParseInt(value, 10, 32)
+				if err != nil || n < 0 {
+					return fmt.Errorf("%w: %s: ttl=%s is not a number of seconds", ErrInvalidIndexTag, path, value)
+				}
+				secs := int32(n)
+				ensure().ttl = &secs
+			case key == tagCreatedAt || key == tagUpdatedAt,
+				key == "required" || key == "optional" || key == "enum":
+				// Timestamp and JSON schema directives share the gmqb tag.
+			default:
+				return fmt.Errorf("%w: %s: unknown directive %q", ErrInvalidIndexTag, path, d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]IndexModel, 0, len(single)+len(named))
+	for _, idx := range append(single, named...) {
+		if idx.ttl != nil && len(idx.fields) > 1 {
+			return nil, fmt.Errorf("%w: TTL index %q must have a single field", ErrInvalidIndexTag, idx.name)
+		}
+		models = append(models, idx.model())
+	}
+	return models, nil
+}
+
+// model converts a declared index to an IndexModel.
+func (idx *taggedIndex) model() IndexModel {
+	slices.SortStableFunc(idx.fields, func(a, b taggedIndexField) int {
+		return cmp.Or(cmp.Compare(a.order, b.order), cmp.Compare(a.seq, b.seq))
+	})
+	rules := make([]SortField, len(idx.fields))
+	for i, f := range idx.fields {
+		dir := 1
+		if f.desc {
+			dir = -1
+		}
+		rules[i] = SortRule(f.path, dir)
+	}
+	m := NewIndex(SortSpec(rules...))
+	if idx.name != "" {
+		m = m.Name(idx.name)
+	}
+	if idx.unique {
+		m = m.Unique()
+	}
+	if idx.sparse {
+		m = m.Sparse()
+	}
+	if idx.ttl != nil {
+		m = m.TTL(*idx.ttl)
+	}
+	return m
+}
+
+// walkIndexTags calls fn with the BSON path and gmqb directives of every
+// tagged field of t, descending into nested and inlined structs and the
+// elements of struct slices and arrays. visiting holds the structs being
+// walked, so a recursive type is walked only at its outermost occurrence.
+func walkIndexTags(t reflect.Type, prefix string, visiting map[reflect.Type]bool, fn func(path string, directives []string) error) error {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// Embedded structs of unexported types are encoded too.
+		if !sf.IsExported() && !(sf.Anonymous && sf.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		name := resolveBsonTag(sf)
+		if name == "-" {
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		_, tagOpts, _ := strings.Cut(sf.Tag.Get("bson"), ",")
+		if hasTagOption(tagOpts, "inline") {
+			if err := walkIndexTags(ft, prefix, visiting, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		if directives := gmqbTag(sf); len(directives) > 0 {
+			if err := fn(path, directives); err != nil {
+				return err
+			}
+		}
+		// Fields of array elements are indexed through the array's path,
+		// giving a multikey index.
+		for ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array || ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType && !strings.HasPrefix(ft.PkgPath(), "go.mongodb.org") {
+			if err := walkIndexTags(ft, path, visiting, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EnsureIndexes creates the indexes declared by the gmqb struct tags of T
+// (see IndexesFor) and returns their names. Indexes that already exist
+// with the same specification are left as they are, so it is safe to call
+// at every startup.
+//
+// Example:
+//
+//	orders := gmqb.Wrap[Order](db.Collection("orders"))
+//	if _, err := gmqb.EnsureIndexes(ctx, orders); err != nil {
+//	    log.Fatal(err)
+//	}
+func EnsureIndexes[T any](ctx context.Context, coll *Collection[T]) ([]string, error) {
+	models, err := IndexesFor[T]()
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, nil
+	}
+	return coll.CreateIndexes(ctx, models)
+}
@@ -0,0 +1,171 @@
+package gmqb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type indexedAddress struct {
+	City string `bson:"city" gmqb:"index"`
+}
+
+type indexedDoc struct {
+	ID        bson.ObjectID  `bson:"_id,omitempty"`
+	Tenant    string         `bson:"tenant" gmqb:"index=tenant_created,order=1"`
+	CreatedAt time.Time      `bson:"created" gmqb:"createdAt,index=tenant_created,order=2,desc"`
+	Email     string         `bson:"email" gmqb:"unique"`
+	Nick      string         `bson:"nick" gmqb:"index,sparse,desc"`
+	ExpiresAt time.Time      `bson:"expires" gmqb:"ttl=86400"`
+	Address   indexedAddress `bson:"address"`
+	Ignored   string         `bson:"-" gmqb:"index"`
+}
+
+func TestIndexesFor(t *testing.T) {
+	models, err := IndexesFor[indexedDoc]()
+	require.NoError(t, err)
+	require.Len(t, models, 5)
+
+	type spec struct {
+		keys bson.D
+		opts options.IndexOptions
+	}
+	specs := make([]spec, len(models))
+	for i, m := range models {
+		mm := m.MongoIndexModel()
+		specs[i] = spec{mm.Keys.(bson.D), resolveOptions[options.IndexOptions](mm.Options)}
+	}
+
+	assert.Equal(t, bson.D{{Key: "email", Value: 1}}, specs[0].keys)
+	assert.True(t, *specs[0].opts.Unique)
+
+	assert.Equal(t, bson.D{{Key: "nick", Value: -1}}, specs[1].keys)
+	assert.True(t, *specs[1].opts.Sparse)
+	assert.Nil(t, specs[1].opts.Unique)
+
+	assert.Equal(t, bson.D{{Key: "expires", Value: 1}}, specs[2].keys)
+	assert.Equal(t, int32(86400), *specs[2].opts.ExpireAfterSeconds)
+
+	assert.Equal(t, bson.D{{Key: "address.city", Value: 1}}, specs[3].keys)
+
+	assert.Equal(t, bson.D{{Key: "tenant", Value: 1}, {Key: "created", Value: -1}}, specs[4].keys)
+	assert.Equal(t, "tenant_created", *specs[4].opts.Name)
+}
+
+func TestIndexesFor_Order(t *testing.T) {
+	type doc struct {
+		B string `bson:"b" gmqb:"index=ab,order=2"`
+		A string `bson:"a" gmqb:"index=ab,order=1"`
+		C string `bson:"c" gmqb:"index=ab,order=2"`
+	}
+	models, err := IndexesFor[doc]()
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}, {Key: "c", Value: 1}}, models[0].MongoIndexModel().Keys)
+}
+
+func TestIndexesFor_Invalid(t *testing.T) {
+	type badOrder struct {
+		A string `gmqb:"index,order=1"`
+	}
+	_, err := IndexesFor[badOrder]()
+	assert.ErrorIs(t, err, ErrInvalidIndexTag)
+
+	type badTTL struct {
+		A time.Time `gmqb:"ttl=soon"`
+	}
+	_, err = IndexesFor[badTTL]()
+	assert.ErrorIs(t, err, ErrInvalidIndexTag)
+
+	type compoundTTL struct {
+		A time.Time `gmqb:"index=ab,ttl=60"`
+		B string    `gmqb:"index=ab"`
+	}
+	_, err = IndexesFor[compoundTTL]()
+	assert.ErrorIs(t, err, ErrInvalidIndexTag)
+
+	models, err := IndexesFor[bson.M]()
+	require.NoError(t, err)
+	assert.Empty(t, models)
+}
+
+type indexedNode struct {
+	Name     string         `bson:"name" gmqb:"index"`
+	Parent   *indexedNode   `bson:"parent"`
+	Children []*indexedNode `bson:"children"`
+}
+
+func TestIndexesFor_Recursive(t *testing.T) {
+	models, err := IndexesFor[indexedNode]()
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, bson.D{{Key: "name", Value: 1}}, models[0].MongoIndexModel().Keys)
+}
+
+func TestIndexesFor_Inline(t *testing.T) {
+	type inner struct {
+		SKU string `bson:"sku" gmqb:"unique"`
+	}
+	type doc struct {
+		Inner inner  `bson:"zzinner,inline"`
+		Name  string `bson:"name"`
+	}
+	models, err := IndexesFor[doc]()
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, bson.D{{Key: "sku", Value: 1}}, models[0].MongoIndexModel().Keys)
+}
+
+func TestIndexesFor_UnknownDirective(t *testing.T) {
+	type typo struct {
+		A string `bson:"a" gmqb:"uniqe"`
+	}
+	_, err := IndexesFor[typo]()
+	require.ErrorIs(t, err, ErrInvalidIndexTag)
+	assert.EqualError(t, err, `gmqb: invalid index tag: a: unknown directive "uniqe"`)
+
+	type indx struct {
+		A string `bson:"a" gmqb:"indx=ab"`
+	}
+	_, err = IndexesFor[indx]()
+	assert.ErrorIs(t, err, ErrInvalidIndexTag)
+
+	// Directives of timestamps and JSON schemas share the tag.
+	type shared struct {
+		Status  string    `bson:"status" gmqb:"index,enum=a|b,required"`
+		Created time.Time `bson:"created" gmqb:"createdAt"`
+	}
+	models, err := IndexesFor[shared]()
+	require.NoError(t, err)
+	assert.Len(t, models, 1)
+}
+
+func TestIndexesFor_ArrayElements(t *testing.T) {
+	type item struct {
+		SKU string `bson:"sku" gmqb:"index"`
+	}
+	type doc struct {
+		Name  string    `bson:"name" gmqb:"index"`
+		Items []item    `bson:"items"`
+		Pairs [2]*item  `bson:"pairs"`
+		Tags  []string  `bson:"tags" gmqb:"index"`
+		Refs  [][]*item `bson:"refs"`
+	}
+	models, err := IndexesFor[doc]()
+	require.NoError(t, err)
+	var keys []bson.D
+	for _, m := range models {
+		keys = append(keys, m.MongoIndexModel().Keys.(bson.D))
+	}
+	assert.Equal(t, []bson.D{
+		{{Key: "name", Value: 1}},
+		{{Key: "items.sku", Value: 1}},
+		{{Key: "pairs.sku", Value: 1}},
+		{{Key: "tags", Value: 1}},
+		{{Key: "refs.sku", Value: 1}},
+	}, keys)
+}
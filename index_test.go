@@ -0,0 +1,121 @@
+package gmqb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// indexOptions resolves the options of m.
+func indexOptions(m IndexModel) options.IndexOptions {
+	return resolveOptions[options.IndexOptions](m.MongoIndexModel().Options)
+}
+
+func TestIndexModel_Immutable(t *testing.T) {
+	base := NewIndex(Asc("email"))
+	unique := base.Unique()
+	named := base.Name("by_email")
+
+	assert.Nil(t, indexOptions(base).Unique)
+	assert.Nil(t, indexOptions(base).Name)
+	assert.True(t, *indexOptions(unique).Unique)
+	assert.Nil(t, indexOptions(unique).Name)
+	assert.Equal(t, "by_email", *indexOptions(named).Name)
+	assert.Nil(t, indexOptions(named).Unique)
+
+	var zero IndexModel
+	assert.True(t, *indexOptions(zero.Sparse()).Sparse)
+}
+
+func TestIndexModel_Options(t *testing.T) {
+	o := indexOptions(NewIndex(Asc("email")).
+		PartialFilter(Eq("deleted", false)).
+		Collation(&options.Collation{Locale: "en", Strength: 2}).
+		Hidden())
+	assert.Equal(t, bson.D{{Key: "deleted", Value: bson.D{{Key: "$eq", Value: false}}}}, o.PartialFilterExpression)
+	assert.Equal(t, "en", o.Collation.Locale)
+	assert.True(t, *o.Hidden)
+
+	m := NewIndex(WildcardKey("")).WildcardProjection(Include("attrs"))
+	assert.Equal(t, bson.D{{Key: "$**", Value: 1}}, m.MongoIndexModel().Keys)
+	assert.Equal(t, bson.D{{Key: "attrs", Value: 1}}, indexOptions(m).WildcardProjection)
+	assert.Equal(t, bson.D{{Key: "attrs.$**", Value: 1}}, WildcardKey("attrs"))
+
+	m = NewIndex(Geo2DSphereKeys("location")).SphereVersion(3)
+	assert.Equal(t, bson.D{{Key: "location", Value: "2dsphere"}}, m.MongoIndexModel().Keys)
+	assert.Equal(t, int32(3), *indexOptions(m).SphereVersion)
+
+	o = indexOptions(NewIndex(Geo2DKey("pos")).GeoBits(32).GeoBounds(0, 1024))
+	assert.Equal(t, int32(32), *o.Bits)
+	assert.Equal(t, 0.0, *o.Min)
+	assert.Equal(t, 1024.0, *o.Max)
+
+	m = NewIndex(append(Asc("tenant"), HashedKey("user")...))
+	assert.Equal(t, bson.D{{Key: "tenant", Value: 1}, {Key: "user", Value: "hashed"}}, m.MongoIndexModel().Keys)
+
+	o = indexOptions(NewIndex(TextKeys("title", "body")).
+		TextWeights(bson.D{{Key: "title", Value: 10}}).
+		DefaultLanguage("french").
+		LanguageOverride("lang"))
+	assert.Equal(t, bson.D{{Key: "title", Value: 10}}, o.Weights)
+	assert.Equal(t, "french", *o.DefaultLanguage)
+	assert.Equal(t, "lang", *o.LanguageOverride)
+
+	assert.Equal(t, bson.D{
+		{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}},
+		{Key: "unique", Value: true},
+		{Key: "name", Value: "events_by_id"},
+	}, ClusteredIndex("events_by_id"))
+	assert.Len(t, ClusteredIndex(""), 2)
+}
+
+func TestIndexModel_InfoText(t *testing.T) {
+	info, err := NewIndex(append(Asc("tenant"), TextKeys("title", "body")...)).
+		TextWeights(bson.D{{Key: "title", Value: 10}}).info()
+	require.NoError(t, err)
+	assert.Equal(t, "tenant_1_title_text_body_text", info.Name)
+	assert.Equal(t, bson.D{{Key: "tenant", Value: 1}, {Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: 1}}, info.Keys)
+	assert.Equal(t, bson.D{{Key: "body", Value: 1}, {Key: "title", Value: int32(10)}}, info.Weights)
+	assert.Equal(t, "english", info.DefaultLanguage)
+	assert.Equal(t, "language", info.LanguageOverride)
+
+	// As listed by the server, with weights in another order.
+	listed := IndexInfo{
+		Name:             info.Name,
+		Keys:             bson.D{{Key: "tenant", Value: int32(1)}, {Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}},
+		Weights:          bson.D{{Key: "title", Value: int32(10)}, {Key: "body", Value: int32(1)}},
+		DefaultLanguage:  "english",
+		LanguageOverride: "language",
+		Version:          2,
+	}
+	diffs, _ := diffIndex(listed, info)
+	assert.Empty(t, diffs)
+}
+
+func TestDiffIndex_Hidden(t *testing.T) {
+	want, err := NewIndex(Asc("a")).Hidden().info()
+	require.NoError(t, err)
+	have := IndexInfo{Name: "a_1", Keys: bson.D{{Key: "a", Value: int32(1)}}, ExpireAfterSeconds: int32Ptr(60)}
+	want.ExpireAfterSeconds = int32Ptr(120)
+
+	diffs, inPlace := diffIndex(have, want)
+	assert.Equal(t, []string{"expireAfterSeconds: 60 -> 120", "hidden: false -> true"}, diffs)
+	assert.True(t, inPlace)
+	assert.Equal(t, bson.D{
+		{Key: "name", Value: "a_1"},
+		{Key: "expireAfterSeconds", Value: int32(120)},
+		{Key: "hidden", Value: true},
+	}, collModIndex(have, want))
+
+	// The server's default sphere version is not a difference.
+	want, _ = NewIndex(Geo2DSphereKeys("loc")).info()
+	have = IndexInfo{Name: "loc_2dsphere", Keys: bson.D{{Key: "loc", Value: "2dsphere"}}, SphereVersion: int32Ptr(3)}
+	diffs, _ = diffIndex(have, want)
+	assert.Empty(t, diffs)
+	want, _ = NewIndex(Geo2DSphereKeys("loc")).SphereVersion(2).info()
+	diffs, _ = diffIndex(have, want)
+	assert.Equal(t, []string{"2dsphereIndexVersion: 3 -> 2"}, diffs)
+}
@@ -5,12 +5,15 @@ import (
 	"log"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	mongooptions "go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
 
 	"github.com/tryvium-travels/memongo"
 
@@ -339,6 +342,52 @@ func TestIntegration_Distinct(t *testing.T) {
 	assert.Len(t, countries, 3)
 }
 
+func TestIntegration_DistinctOf(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+	seedUsers(t, coll)
+	_, err := coll.InsertOne(ctx, &User{Name: "Finn", Age: 41, Country: "us"})
+	require.NoError(t, err)
+
+	countries, err := gmqb.DistinctOf[string](ctx, coll, "country", gmqb.NewFilter())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"US", "UK", "DE", "us"}, countries)
+
+	ages, err := gmqb.DistinctOf[int](ctx, coll, "age", gmqb.Gte("age", 30))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{30, 35, 41}, ages)
+
+	// Case-insensitive collation folds "US" and "us" together.
+	countries, err = gmqb.DistinctOf[string](ctx, coll, "country", gmqb.NewFilter(),
+		gmqb.WithCollationDistinct(&mongooptions.Collation{Locale: "en", Strength: 2}))
+	require.NoError(t, err)
+	assert.Len(t, countries, 3)
+	countries, err = gmqb.DistinctOf[string](ctx, coll, "country", gmqb.NewFilter(),
+		gmqb.WithCollation(&mongooptions.Collation{Locale: "en", Strength: 2}))
+	require.NoError(t, err)
+	assert.Len(t, countries, 3)
+
+	_, err = coll.CreateIndex(ctx, gmqb.NewIndex(gmqb.Asc("country")))
+	require.NoError(t, err)
+	countries, err = gmqb.DistinctOf[string](ctx, coll, "country", gmqb.Eq("active", true),
+		gmqb.WithHintDistinct(gmqb.Asc("country")))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"US", "UK", "DE"}, countries)
+	countries, err = gmqb.DistinctOf[string](ctx, coll, "country", gmqb.Eq("active", true),
+		gmqb.WithHint(gmqb.Asc("country")))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"US", "UK", "DE"}, countries)
+
+	none, err := gmqb.DistinctOf[string](ctx, coll, "country", gmqb.Eq("name", "nobody"))
+	require.NoError(t, err)
+	assert.NotNil(t, none)
+	assert.Empty(t, none)
+
+	// Values that do not decode into V are reported.
+	_, err = gmqb.DistinctOf[int](ctx, coll, "country", gmqb.NewFilter())
+	assert.Error(t, err)
+}
+
 func TestIntegration_Aggregate_GroupBy(t *testing.T) {
 	coll := freshCollection(t)
 	ctx := context.Background()
@@ -445,6 +494,186 @@ func TestIntegration_Aggregate_AddFieldsAndLimit(t *testing.T) {
 	assert.Equal(t, "junior", results[1].AgeGroup, "Bob(25)=junior")
 }
 
+func TestIntegration_AggregateDatabase_Documents(t *testing.T) {
+	ctx := context.Background()
+
+	type Row struct {
+		X int `bson:"x"`
+	}
+
+	pipeline := gmqb.NewPipeline().
+		Documents(bson.D{{Key: "x", Value: 1}}, bson.D{{Key: "x", Value: 2}}, bson.D{{Key: "x", Value: 3}}).
+		Match(gmqb.Gt("x", 1))
+
+	rows, err := gmqb.AggregateDatabase[Row](testDB, ctx, pipeline, gmqb.WithValidation())
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, 2, rows[0].X)
+	assert.Equal(t, 3, rows[1].X)
+
+	// Let from the context's CallOpts is passed to the command.
+	letCtx := gmqb.WithCallOpts(ctx, gmqb.CallOpts{Let: bson.D{{Key: "min", Value: 2}}})
+	rows, err = gmqb.AggregateDatabase[Row](testDB, letCtx, gmqb.NewPipeline().
+		Documents(bson.D{{Key: "x", Value: 1}}, bson.D{{Key: "x", Value: 2}}, bson.D{{Key: "x", Value: 3}}).
+		Match(gmqb.Expr(gmqb.ExprGt("$x", "$$min"))))
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, 3, rows[0].X)
+}
+
+func TestIntegration_Aggregate_IndexStats(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+	seedUsers(t, coll)
+
+	type IndexStat struct {
+		Name string `bson:"name"`
+	}
+
+	stats, err := gmqb.Aggregate[IndexStat](coll, ctx, gmqb.NewPipeline().IndexStats())
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, "_id_", stats[0].Name)
+}
+
+func TestIntegration_Explain(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+	seedUsers(t, coll)
+
+	res, err := coll.ExplainFind(ctx, gmqb.Eq("email", "alice@example.com"), gmqb.ExplainExecutionStats)
+	require.NoError(t, err)
+	assert.True(t, res.HasCollectionScan())
+	assert.False(t, res.UsesIndex(""))
+
+	_, err = coll.CreateIndex(ctx, gmqb.NewIndex(gmqb.Asc("email")))
+	require.NoError(t, err)
+
+	res, err = coll.ExplainFind(ctx, gmqb.Eq("email", "alice@example.com"), gmqb.ExplainExecutionStats)
+	require.NoError(t, err)
+	assert.False(t, res.HasCollectionScan())
+	assert.True(t, res.UsesIndex("email_1"))
+	assert.Equal(t, int64(1), res.NReturned)
+	assert.Equal(t, int64(1), res.DocsExamined)
+
+	res, err = coll.ExplainCount(ctx, gmqb.Eq("email", "alice@example.com"), gmqb.ExplainQueryPlanner)
+	require.NoError(t, err)
+	assert.True(t, res.UsesIndex("email_1"))
+
+	res, err = coll.ExplainAggregate(ctx, gmqb.NewPipeline().
+		Match(gmqb.Eq("email", "alice@example.com")).
+		Group(gmqb.GroupSpec("$country")), gmqb.ExplainQueryPlanner)
+	require.NoError(t, err)
+	assert.True(t, res.UsesIndex("email_1"))
+}
+
+func TestIntegration_FindIter(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+	seedUsers(t, coll)
+
+	var names []string
+	for u, err := range coll.FindIter(ctx, gmqb.Eq("active", true),
+		gmqb.WithSort(gmqb.Asc("age")),
+		gmqb.WithBatchSize(1),
+		gmqb.WithMaxTime(time.Minute),
+	) {
+		require.NoError(t, err)
+		names = append(names, u.Name)
+	}
+	assert.Equal(t, []string{"Bob", "Diana", "Alice"}, names)
+
+	// Breaking early stops after the first document.
+	count := 0
+	for _, err := range coll.FindIter(ctx, gmqb.NewFilter(), gmqb.WithBatchSize(1)) {
+		require.NoError(t, err)
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestIntegration_AggregateCursor(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+	seedUsers(t, coll)
+
+	type CountryCount struct {
+		Country string `bson:"_id"`
+		Count   int    `bson:"count"`
+	}
+
+	cur, err := gmqb.AggregateCursor[CountryCount](coll, ctx,
+		gmqb.NewPipeline().
+			Group(gmqb.GroupSpec("$country", gmqb.GroupAcc("count", gmqb.AccSum(1)))).
+			Sort(gmqb.Asc("_id")),
+		gmqb.WithAllowDiskUse(true),
+		gmqb.WithBatchSize(1),
+	)
+	require.NoError(t, err)
+	defer cur.Close(ctx)
+
+	var results []CountryCount
+	for cur.Next(ctx) {
+		results = append(results, cur.Current())
+	}
+	require.NoError(t, cur.Err())
+	assert.NotEmpty(t, results)
+	for i := 1; i < len(results); i++ {
+		assert.Less(t, results[i-1].Country, results[i].Country)
+	}
+}
+
+func TestIntegration_Paginate(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+	seedUsers(t, coll)
+
+	names := func(users []User) []string {
+		var out []string
+		for _, u := range users {
+			out = append(out, u.Name)
+		}
+		return out
+	}
+	opts := gmqb.PageOpts{Size: 2, SortFields: []gmqb.SortField{gmqb.SortRule("age", -1)}, Secret: []byte("test")}
+
+	page1, err := gmqb.Paginate(ctx, coll, gmqb.NewFilter(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Charlie", "Alice"}, names(page1.Items))
+	assert.True(t, page1.HasNext)
+	assert.False(t, page1.HasPrev)
+
+	opts.After = page1.Next
+	page2, err := gmqb.Paginate(ctx, coll, gmqb.NewFilter(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Diana", "Bob"}, names(page2.Items))
+	assert.True(t, page2.HasPrev)
+
+	opts.After = page2.Next
+	page3, err := gmqb.Paginate(ctx, coll, gmqb.NewFilter(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Eve"}, names(page3.Items))
+	assert.False(t, page3.HasNext)
+
+	opts.After, opts.Before = "", page3.Prev
+	back, err := gmqb.Paginate(ctx, coll, gmqb.NewFilter(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Diana", "Bob"}, names(back.Items))
+
+	offset, err := gmqb.Paginate(ctx, coll, gmqb.Eq("active", true), gmqb.PageOpts{
+		Size:       2,
+		SortFields: []gmqb.SortField{gmqb.SortRule("name", 1)},
+		Mode:       gmqb.OffsetPaging,
+		PageNumber: 2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Diana"}, names(offset.Items))
+	assert.Equal(t, int64(3), offset.Total)
+	assert.True(t, offset.HasPrev)
+	assert.False(t, offset.HasNext)
+}
+
 func TestIntegration_FindOne_NotFound(t *testing.T) {
 	coll := freshCollection(t)
 	ctx := context.Background()
@@ -500,6 +729,10 @@ func TestIntegration_FindOneAndDelete(t *testing.T) {
 	// Delete non-existent with WithReturnDocumentDelete (operation-specific check)
 	_, err = coll.FindOneAndDelete(ctx, gmqb.Eq("name", "NonExistent"), gmqb.WithReturnDocumentDelete(mongooptions.Before))
 	assert.ErrorIs(t, err, mongo.ErrNoDocuments)
+
+	// Delete non-existent with a shared option
+	_, err = coll.FindOneAndDelete(ctx, gmqb.Eq("name", "NonExistent"), gmqb.WithSort(gmqb.Asc("age")))
+	assert.ErrorIs(t, err, mongo.ErrNoDocuments)
 }
 
 func TestIntegration_FindOneAndUpdate(t *testing.T) {
@@ -559,6 +792,16 @@ func TestIntegration_FindOneAndReplace(t *testing.T) {
 	assert.Equal(t, "Diana Replaced 2", after.Name)
 	assert.Equal(t, 100, after.Age)
 
+	// The shared WithReturnDocument applies to replaces too
+	replacement3 := &User{Name: "Diana Replaced 3", Age: 101, Email: "diana-r3@example.com", Country: "DK", Active: true}
+	after, err = coll.FindOneAndReplace(ctx,
+		gmqb.Eq("name", "Diana Replaced 2"),
+		replacement3,
+		gmqb.WithReturnDocument(mongooptions.After),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "Diana Replaced 3", after.Name)
+
 	// Replace non-existent
 	_, err = coll.FindOneAndReplace(ctx,
 		gmqb.Eq("name", "NonExistent"),
@@ -567,6 +810,59 @@ func TestIntegration_FindOneAndReplace(t *testing.T) {
 	assert.ErrorIs(t, err, mongo.ErrNoDocuments)
 }
 
+type Article struct {
+	ID        bson.ObjectID `bson:"_id,omitempty"`
+	Title     string        `bson:"title"`
+	CreatedAt time.Time     `bson:"createdAt" gmqb:"createdAt"`
+	UpdatedAt time.Time     `bson:"updatedAt" gmqb:"updatedAt"`
+}
+
+func TestIntegration_Timestamps(t *testing.T) {
+	ctx := context.Background()
+	mColl := testDB.Collection(t.Name())
+	_ = mColl.Drop(ctx)
+	coll := gmqb.Wrap[Article](mColl)
+
+	a := &Article{Title: "first"}
+	_, err := coll.InsertOne(ctx, a)
+	require.NoError(t, err)
+	require.False(t, a.CreatedAt.IsZero())
+	assert.Equal(t, a.CreatedAt, a.UpdatedAt)
+	created := a.CreatedAt
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = coll.UpdateOne(ctx, gmqb.Eq("title", "first"), gmqb.NewUpdate().Set("title", "edited"))
+	require.NoError(t, err)
+	got, err := coll.FindOne(ctx, gmqb.Eq("title", "edited"))
+	require.NoError(t, err)
+	assert.Equal(t, created, got.CreatedAt)
+	assert.True(t, got.UpdatedAt.After(created))
+
+	// A replacement without createdAt keeps the stored one.
+	_, err = coll.ReplaceOne(ctx, gmqb.Eq("_id", a.ID), &Article{Title: "replaced"})
+	require.NoError(t, err)
+	got, err = coll.FindOne(ctx, gmqb.Eq("_id", a.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "replaced", got.Title)
+	assert.Equal(t, created, got.CreatedAt)
+
+	// Upserts set createdAt on insert only.
+	_, err = coll.UpsertOne(ctx, gmqb.Eq("title", "new"), gmqb.NewUpdate().Set("title", "new"))
+	require.NoError(t, err)
+	got, err = coll.FindOne(ctx, gmqb.Eq("title", "new"))
+	require.NoError(t, err)
+	assert.False(t, got.CreatedAt.IsZero())
+	assert.Equal(t, got.CreatedAt, got.UpdatedAt)
+
+	_, err = coll.BulkWrite(ctx, []gmqb.WriteModel[Article]{
+		gmqb.NewInsertOneModel[Article]().SetDocument(&Article{Title: "bulk"}),
+	})
+	require.NoError(t, err)
+	got, err = coll.FindOne(ctx, gmqb.Eq("title", "bulk"))
+	require.NoError(t, err)
+	assert.False(t, got.CreatedAt.IsZero())
+}
+
 // --- Filter Chaining Tests ---
 
 func TestIntegration_FilterChain_Basic(t *testing.T) {
@@ -748,10 +1044,152 @@ func TestIntegration_IndexManagement(t *testing.T) {
 	indexes, err = coll.ListIndexes(ctx)
 	require.NoError(t, err)
 	for _, idx := range indexes {
-		nameAttr, ok := idx.Lookup("name").StringValueOK()
-		assert.True(t, ok)
-		assert.NotEqual(t, "age_idx", nameAttr)
+		assert.NotEqual(t, "age_idx", idx.Name)
+		if idx.Name == "email_1" {
+			assert.True(t, idx.Unique)
+			assert.Equal(t, bson.D{{Key: "email", Value: int32(1)}}, idx.Keys)
+		}
+	}
+}
+
+func TestIntegration_SyncIndexes(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+
+	_, err := coll.CreateIndexes(ctx, []gmqb.IndexModel{
+		gmqb.NewIndex(gmqb.Asc("legacy")),
+		gmqb.NewIndex(gmqb.Asc("expiresAt")).TTL(60),
+		gmqb.NewIndex(gmqb.Asc("email")),
+	})
+	require.NoError(t, err)
+
+	desired := []gmqb.IndexModel{
+		gmqb.NewIndex(gmqb.Asc("email")).Unique(),
+		gmqb.NewIndex(gmqb.Asc("expiresAt")).TTL(3600),
+		gmqb.NewIndex(gmqb.Desc("age")),
+	}
+	plan, err := coll.SyncIndexes(ctx, desired, gmqb.SyncOpts{DryRun: true, DropUnknown: true})
+	require.NoError(t, err)
+	assert.Equal(t, "drop email_1: unique: false -> true\n"+
+		"drop legacy_1\n"+
+		"modify expiresAt_1: expireAfterSeconds: 60 -> 3600\n"+
+		`create email_1 {"email":1}: unique: false -> true`+"\n"+
+		`create age_-1 {"age":-1}`, plan.String())
+
+	_, err = coll.SyncIndexes(ctx, desired, gmqb.SyncOpts{DropUnknown: true})
+	require.NoError(t, err)
+
+	indexes, err := coll.ListIndexes(ctx)
+	require.NoError(t, err)
+	byName := map[string]gmqb.IndexInfo{}
+	for _, idx := range indexes {
+		byName[idx.Name] = idx
+	}
+	assert.Len(t, byName, 4)
+	assert.True(t, byName["email_1"].Unique)
+	assert.Equal(t, int32(3600), *byName["expiresAt_1"].ExpireAfterSeconds)
+	assert.Contains(t, byName, "age_-1")
+
+	plan, err = coll.SyncIndexes(ctx, desired, gmqb.SyncOpts{DropUnknown: true})
+	require.NoError(t, err)
+	assert.True(t, plan.IsEmpty())
+}
+
+type Session struct {
+	ID        bson.ObjectID `bson:"_id,omitempty"`
+	Tenant    string        `bson:"tenant" gmqb:"index=tenant_user,order=1"`
+	User      string        `bson:"user" gmqb:"index=tenant_user,order=2"`
+	Token     string        `bson:"token" gmqb:"unique"`
+	ExpiresAt time.Time     `bson:"expiresAt" gmqb:"ttl=3600"`
+}
+
+func TestIntegration_EnsureIndexes(t *testing.T) {
+	ctx := context.Background()
+	mColl := testDB.Collection(t.Name())
+	_ = mColl.Drop(ctx)
+	coll := gmqb.Wrap[Session](mColl)
+
+	names, err := gmqb.EnsureIndexes(ctx, coll)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"token_1", "expiresAt_1", "tenant_user"}, names)
+
+	// Running again at the next startup is a no-op.
+	_, err = gmqb.EnsureIndexes(ctx, coll)
+	require.NoError(t, err)
+
+	_, err = coll.InsertOne(ctx, &Session{Token: "t1"})
+	require.NoError(t, err)
+	_, err = coll.InsertOne(ctx, &Session{Token: "t1"})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+}
+
+func TestIntegration_IndexOptions(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+
+	desired := []gmqb.IndexModel{
+		gmqb.NewIndex(gmqb.Asc("email")).Unique().PartialFilter(gmqb.Eq("active", true)),
+		gmqb.NewIndex(gmqb.Asc("name")).Collation(&mongooptions.Collation{Locale: "en", Strength: 2}),
+		gmqb.NewIndex(gmqb.Asc("age")).Hidden(),
+		gmqb.NewIndex(gmqb.TextKeys("name", "bio")).TextWeights(bson.D{{Key: "name", Value: 5}}).DefaultLanguage("none"),
+		gmqb.NewIndex(gmqb.Geo2DSphereKeys("location")),
+		gmqb.NewIndex(gmqb.Geo2DKey("pos")).GeoBits(20),
+		gmqb.NewIndex(gmqb.HashedKey("country")),
+		gmqb.NewIndex(gmqb.WildcardKey("")).WildcardProjection(gmqb.Include("attrs")),
+	}
+	_, err := coll.CreateIndexes(ctx, desired)
+	require.NoError(t, err)
+
+	// Listed indexes match their specification, so there is nothing to do.
+	plan, err := coll.SyncIndexes(ctx, desired, gmqb.SyncOpts{DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, plan.IsEmpty(), plan.String())
+
+	indexes, err := coll.ListIndexes(ctx)
+	require.NoError(t, err)
+	for _, idx := range indexes {
+		if idx.Name == "age_1" {
+			assert.True(t, idx.Hidden)
+		}
 	}
+
+	// Unhiding is applied in place.
+	desired[2] = gmqb.NewIndex(gmqb.Asc("age"))
+	plan, err = coll.SyncIndexes(ctx, desired, gmqb.SyncOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, "modify age_1: hidden: true -> false", plan.String())
+
+	// A clustered collection.
+	require.NoError(t, testDB.CreateCollection(ctx, t.Name()+"_clustered",
+		mongooptions.CreateCollection().SetClusteredIndex(gmqb.ClusteredIndex("by_id"))))
+}
+
+func TestIntegration_ApplyValidator(t *testing.T) {
+	ctx := context.Background()
+	mColl := testDB.Collection(t.Name())
+	_ = mColl.Drop(ctx)
+	coll := gmqb.Wrap[User](mColl)
+
+	schema, err := gmqb.JSONSchemaFor[User](gmqb.JSONSchemaOpts{})
+	require.NoError(t, err)
+
+	// The collection does not exist yet, so it is created with the validator.
+	require.NoError(t, gmqb.ApplyValidator(ctx, coll, schema, gmqb.ValidationStrict, gmqb.ValidationError))
+	_, err = coll.InsertOne(ctx, &User{Name: "Alice", Age: 30, Email: "a@example.com"})
+	require.NoError(t, err)
+
+	_, err = mColl.InsertOne(ctx, bson.D{{Key: "name", Value: 42}})
+	require.Error(t, err, "documents that do not match the struct are rejected")
+
+	// Existing collections are updated with collMod.
+	require.NoError(t, gmqb.ApplyValidator(ctx, coll, schema, gmqb.ValidationModerate, gmqb.ValidationWarn))
+	_, err = mColl.InsertOne(ctx, bson.D{{Key: "name", Value: 42}})
+	require.NoError(t, err)
+
+	// The same schema finds the documents that do not match.
+	invalid, err := coll.CountDocuments(ctx, gmqb.Nor(gmqb.JsonSchema(schema)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), invalid)
 }
 
 func TestIntegration_ReplaceOne(t *testing.T) {
@@ -788,4 +1226,51 @@ func TestIntegration_CountDocuments_WithOptions(t *testing.T) {
 	count, err = coll.CountDocuments(ctx, gmqb.Eq("active", true), gmqb.WithSkipCount(1))
 	require.NoError(t, err)
 	assert.Equal(t, int64(2), count) // 3 total active, skip 1 = 2
+
+	// The shared WithLimit and WithSkip apply to counts too
+	count, err = coll.CountDocuments(ctx, gmqb.Eq("active", true), gmqb.WithSkip(1), gmqb.WithLimit(1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestIntegration_CallOpts(t *testing.T) {
+	coll := freshCollection(t).With(gmqb.WithWriteConcern(writeconcern.Majority()))
+	seedUsers(t, coll)
+
+	ctx := gmqb.WithCallOpts(context.Background(), gmqb.CallOpts{
+		ReadPreference: readpref.Primary(),
+		MaxTime:        5 * time.Second,
+		Comment:        "call-opts-test",
+		Let:            bson.D{{Key: "minAge", Value: 30}},
+	})
+	users, err := coll.Find(ctx, gmqb.Expr(gmqb.ExprGte("$age", "$$minAge")))
+	require.NoError(t, err)
+	assert.Len(t, users, 2) // Alice (30), Charlie (35)
+
+	count, err := coll.CountDocuments(ctx, gmqb.Eq("active", true))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestIntegration_FindOne_CollationAndHint(t *testing.T) {
+	coll := freshCollection(t)
+	ctx := context.Background()
+	seedUsers(t, coll)
+	_, err := coll.Unwrap().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: mongooptions.Index().SetName("name_ci").SetCollation(&mongooptions.Collation{Locale: "en", Strength: 2}),
+	})
+	require.NoError(t, err)
+
+	ci := &mongooptions.Collation{Locale: "en", Strength: 2}
+	user, err := coll.FindOne(ctx, gmqb.Eq("name", "ALICE"), gmqb.WithCollation(ci), gmqb.WithHint("name_ci"))
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", user.Name)
+
+	count, err := coll.CountDocuments(ctx, gmqb.Eq("name", "bob"), gmqb.WithCollation(ci), gmqb.WithComment("ci-count"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	_, err = coll.FindOne(ctx, gmqb.Eq("name", "Alice"), gmqb.WithLimit(1))
+	assert.ErrorIs(t, err, gmqb.ErrInvalidOption)
 }
@@ -0,0 +1,157 @@
+package gmqb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Operation names a Collection operation passed through the interceptor chain.
+type Operation string
+
+const (
+	OpFind              Operation = "find"
+	OpFindOne           Operation = "findOne"
+	OpInsertOne         Operation = "insertOne"
+	OpInsertMany        Operation = "insertMany"
+	OpBulkWrite         Operation = "bulkWrite"
+	OpUpdateOne         Operation = "updateOne"
+	OpUpdateMany        Operation = "updateMany"
+	OpReplaceOne        Operation = "replaceOne"
+	OpDeleteOne         Operation = "deleteOne"
+	OpDeleteMany        Operation = "deleteMany"
+	OpFindOneAndDelete  Operation = "findOneAndDelete"
+	OpFindOneAndUpdate  Operation = "findOneAndUpdate"
+	OpFindOneAndReplace Operation = "findOneAndReplace"
+	OpCountDocuments    Operation = "countDocuments"
+	OpDistinct          Operation = "distinct"
+	OpAggregate         Operation = "aggregate"
+	OpPaginate          Operation = "paginate"
+	OpWatch             Operation = "watch"
+	OpCreateIndexes     Operation = "createIndexes"
+	OpDropIndex         Operation = "dropIndex"
+	OpListIndexes       Operation = "listIndexes"
+	OpCollMod           Operation = "collMod"
+)
+
+// OpInfo describes one Collection operation. Interceptors may replace Filter,
+// Update, Pipeline, Document or Options before calling next; the operation
+// runs with whatever values next receives.
+type OpInfo struct {
+	// Operation is the name of the operation.
+	Operation Operation
+	// Database and Collection identify the target namespace.
+	Database   string
+	Collection string
+	// Filter is the query filter, if the operation takes one.
+	Filter Filter
+	// Update is the update document for update operations.
+	Update UpdateDoc
+	// Pipeline is the aggregation pipeline for OpAggregate.
+	Pipeline Pipeline
+	// Field is the field name for OpDistinct.
+	Field string
+	// Document is the operation's payload: the *T or []T being written, the
+	// []WriteModel[T] of a bulk write, the []IndexModel being created, the
+	// name of the index being dropped or the bson.D of collMod options.
+	Document any
+	// Options holds the option values passed to the method, typed as the
+	// method declares them, e.g. []Opt or PageOpts.
+	Options any
+	// Result is set once the operation completes to the value the method
+	// returns, e.g. []T, *T, int64, *mongo.UpdateResult or *Cursor[T]. An
+	// interceptor that does not call next may set it to short-circuit the
+	// operation.
+	Result any
+	// Cache reports whether a CachedCollection read was served from the
+	// cache. It is empty for operations the cache does not handle.
+	Cache CacheResult
+}
+
+// CacheResult reports how a CachedCollection read was served.
+type CacheResult string
+
+const (
+	// CacheHit means the result was read from the cache.
+	CacheHit CacheResult = "hit"
+	// CacheMiss means the result was read from MongoDB and then cached.
+	CacheMiss CacheResult = "miss"
+)
+
+// Invoker runs an operation, or the rest of the interceptor chain.
+type Invoker func(ctx context.Context, op *OpInfo) error
+
+// Interceptor wraps every operation of a Collection. It receives the
+// operation and the next step of the chain, and must call next to run it.
+// Interceptors are the place for logging, metrics, tracing, policy checks,
+// tenancy filters and retries.
+//
+// Example (tenancy):
+//
+//	tenancy := func(ctx context.Context, op *gmqb.OpInfo, next gmqb.Invoker) error {
+//	    if op.Operation != gmqb.OpInsertOne && op.Operation != gmqb.OpInsertMany {
+//	        op.Filter = op.Filter.Eq("tenantId", tenantFrom(ctx))
+//	    }
+//	    return next(ctx, op)
+//	}
+//	coll := gmqb.Wrap[Order](db.Collection("orders"), gmqb.WithInterceptors(tenancy))
+type Interceptor func(ctx context.Context, op *OpInfo, next Invoker) error
+
+// CollectionOpt configures a Collection created by Wrap or WrapWithCache.
+type CollectionOpt func(*collectionConfig)
+
+// collectionConfig holds the settings applied by CollectionOpt values.
+type collectionConfig struct {
+	interceptors []Interceptor
+	clone        []options.Lister[options.CollectionOptions]
+}
+
+// WithInterceptors adds interceptors to a Collection. The first interceptor
+// is the outermost: it runs first and sees the final result last.
+//
+// Example:
+//
+//	coll := gmqb.Wrap[User](db.Collection("users"),
+//	    gmqb.WithInterceptors(logging, metrics),
+//	)
+func WithInterceptors(interceptors ...Interceptor) CollectionOpt {
+	return func(c *collectionConfig) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// chainInterceptors composes interceptors around final.
+func chainInterceptors(interceptors []Interceptor, final Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		ic, next := interceptors[i], final
+		final = func(ctx context.Context, op *OpInfo) error {
+			return ic(ctx, op, next)
+		}
+	}
+	return final
+}
+
+// intercept runs call for op through the collection's interceptors and
+// returns the operation's result. The call is bounded by the MaxTime of the
+// context's CallOpts.
+func intercept[R any, T any](c *Collection[T], ctx context.Context, op *OpInfo, call func(ctx context.Context, op *OpInfo) (R, error)) (R, error) {
+	op.Database = c.coll.Database().Name()
+	op.Collection = c.coll.Name()
+	final := func(ctx context.Context, op *OpInfo) error {
+		ctx, cancel := withMaxTime(ctx)
+		defer cancel()
+		r, err := call(ctx, op)
+		op.Result = r
+		return err
+	}
+	err := chainInterceptors(c.interceptors, final)(ctx, op)
+	r, _ := op.Result.(R)
+	return r, err
+}
+
+// optsOf returns op.Options as []O, or nil if an interceptor replaced them
+// with another type.
+func optsOf[O any](op *OpInfo) []O {
+	opts, _ := op.Options.([]O)
+	return opts
+}
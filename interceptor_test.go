@@ -0,0 +1,127 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// offlineCollection returns a mongo.Collection whose client never connects;
+// the tests below short-circuit before any command is sent.
+func offlineCollection(t *testing.T) *mongo.Collection {
+	t.Helper()
+	client, err := mongo.Connect(options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+	return client.Database("shop").Collection("orders")
+}
+
+// stub is an innermost interceptor that records the operation and returns
+// result instead of calling the server.
+func stub(seen *[]OpInfo, result any) Interceptor {
+	return func(_ context.Context, op *OpInfo, _ Invoker) error {
+		*seen = append(*seen, *op)
+		op.Result = result
+		return nil
+	}
+}
+
+func TestInterceptors_OrderAndRewrite(t *testing.T) {
+	var trace []string
+	tag := func(name string) Interceptor {
+		return func(ctx context.Context, op *OpInfo, next Invoker) error {
+			trace = append(trace, name+">")
+			err := next(ctx, op)
+			trace = append(trace, "<"+name)
+			return err
+		}
+	}
+	tenancy := func(ctx context.Context, op *OpInfo, next Invoker) error {
+		op.Filter = op.Filter.Eq("tenant", "acme")
+		return next(ctx, op)
+	}
+	var seen []OpInfo
+	coll := Wrap[bson.M](offlineCollection(t), WithInterceptors(tag("a"), tag("b")), WithInterceptors(tenancy, stub(&seen, []bson.M{{"n": 1}})))
+
+	got, err := coll.Find(context.Background(), Eq("status", "open"), WithLimit(5))
+	require.NoError(t, err)
+	assert.Equal(t, []bson.M{{"n": 1}}, got)
+	assert.Equal(t, []string{"a>", "b>", "<b", "<a"}, trace)
+
+	require.Len(t, seen, 1)
+	op := seen[0]
+	assert.Equal(t, OpFind, op.Operation)
+	assert.Equal(t, "shop", op.Database)
+	assert.Equal(t, "orders", op.Collection)
+	assert.Equal(t, `{"status":{"$eq":"open"},"tenant":{"$eq":"acme"}}`, op.Filter.CompactJSON())
+	assert.Len(t, op.Options, 1)
+}
+
+func TestInterceptors_Operations(t *testing.T) {
+	ctx := context.Background()
+	var seen []OpInfo
+	coll := Wrap[bson.M](offlineCollection(t), WithInterceptors(stub(&seen, nil)))
+
+	doc := bson.M{"a": 1}
+	_, _ = coll.InsertOne(ctx, &doc)
+	_, _ = coll.UpdateOne(ctx, Eq("a", 1), NewUpdate().Set("b", 2), WithUpsert(true))
+	_, _ = coll.DeleteMany(ctx, Eq("a", 1))
+	_, _ = coll.CountDocuments(ctx, NewFilter())
+	_, _ = Aggregate[bson.M](coll, ctx, NewPipeline().Limit(1))
+	_ = coll.DropIndex(ctx, "a_1")
+
+	ops := make([]Operation, len(seen))
+	for i, op := range seen {
+		ops[i] = op.Operation
+	}
+	assert.Equal(t, []Operation{OpInsertOne, OpUpdateOne, OpDeleteMany, OpCountDocuments, OpAggregate, OpDropIndex}, ops)
+	assert.Same(t, &doc, seen[0].Document)
+	assert.Equal(t, `{"$set":{"b":2}}`, seen[1].Update.(Updater).CompactJSON())
+	assert.Equal(t, "a_1", seen[5].Document)
+
+	// Validation errors are returned before the chain runs.
+	seen = nil
+	_, err := coll.DeleteMany(ctx, NewFilter())
+	assert.ErrorIs(t, err, ErrEmptyFilter)
+	assert.Empty(t, seen)
+}
+
+func TestInterceptors_Reject(t *testing.T) {
+	ctx := context.Background()
+	denied := errors.New("denied")
+	deny := func(context.Context, *OpInfo, Invoker) error { return denied }
+	coll := Wrap[bson.M](offlineCollection(t), WithInterceptors(deny))
+
+	_, err := coll.FindOne(ctx, Eq("a", 1))
+	assert.Same(t, denied, err)
+
+	res := coll.Distinct(ctx, "a", NewFilter())
+	assert.ErrorContains(t, res.Err(), "denied")
+	_, err = DistinctOf[string](ctx, coll, "a", NewFilter())
+	assert.Same(t, denied, err)
+
+	for _, err := range coll.Watch(ctx, WatchOpts{}) {
+		assert.ErrorIs(t, err, denied)
+	}
+}
+
+func TestInterceptors_CachedCollection(t *testing.T) {
+	ctx := context.Background()
+	var seen []OpInfo
+	coll := WrapWithCache[bson.M](offlineCollection(t), nil, 0, WithInterceptors(stub(&seen, int64(7))))
+
+	n, err := coll.CountDocuments(ctx, Eq("a", 1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), n)
+	_, _ = coll.DeleteOne(ctx, Eq("a", 1))
+
+	require.Len(t, seen, 2)
+	assert.Equal(t, OpCountDocuments, seen[0].Operation)
+	assert.Equal(t, OpDeleteOne, seen[1].Operation)
+}
@@ -0,0 +1,432 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// JSONSchemaOpts configures JSONSchemaFor.
+type JSONSchemaOpts struct {
+	// Strict sets additionalProperties to false on every object generated
+	// from a struct, so documents with fields the struct does not declare
+	// are rejected. The top-level object always allows _id. A struct with
+	// an inline map instead limits such fields to the map's value schema.
+	Strict bool
+	// Title and Description are set on the top-level schema.
+	Title       string
+	Description string
+}
+
+var (
+	objectIDType   = reflect.TypeOf(bson.ObjectID{})
+	decimal128Type = reflect.TypeOf(bson.Decimal128{})
+	binaryType     = reflect.TypeOf(bson.Binary{})
+	regexType      = reflect.TypeOf(bson.Regex{})
+	timestampType  = reflect.TypeOf(bson.Timestamp{})
+	rawType        = reflect.TypeOf(bson.Raw{})
+	bsonDType      = reflect.TypeOf(bson.D{})
+	bsonAType      = reflect.TypeOf(bson.A{})
+	byteSliceType  = reflect.TypeOf([]byte{})
+)
+
+// JSONSchemaFor generates a $jsonSchema document describing how the BSON
+// encoding of T looks, for use as a collection validator or with the
+// JsonSchema filter.
+//
+// Each field gets the bsonType its Go type encodes to. Nested structs become
+// nested objects and slices become arrays with an item schema. A field is
+// required unless it is a pointer, slice or map, or its bson tag has
+// omitempty. Pointers, slices and maps also accept null. The gmqb tag refines
+// a field with comma-separated directives:
+//
+//   - required: require the field even though it may be omitted.
+//   - optional: do not require the field.
+//   - enum=A|B|C: restrict the field to the listed values, parsed as the
+//     field's type.
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/query/jsonSchema/
+//
+// Example:
+//
+//	type Order struct {
+//	    ID     bson.ObjectID `bson:"_id,omitempty"`
+//	    Status string        `bson:"status" gmqb:"enum=pending|paid|shipped"`
+//	    Items  []Item        `bson:"items"`
+//	    Note   *string       `bson:"note"`
+//	}
+//	schema, err := gmqb.JSONSchemaFor[Order](gmqb.JSONSchemaOpts{Strict: true})
+//	// {"bsonType":"object","required":["status"],"properties":{
+//	//   "_id":{"bsonType":"objectId"},
+//	//   "status":{"bsonType":"string","enum":["pending","paid","shipped"]},
+//	//   "items":{"bsonType":["array","null"],"items":{...}},
+//	//   "note":{"bsonType":["string","null"]}},"additionalProperties":false}
+//	invalid, err := orders.Find(ctx, gmqb.Nor(gmqb.JsonSchema(schema)))
+func JSONSchemaFor[T any](opts JSONSchemaOpts) (bson.D, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: JSONSchemaFor needs a struct type, got %s", ErrInvalidField, t)
+	}
+	g := schemaGenerator{strict: opts.Strict, visiting: map[reflect.Type]bool{}}
+	schema, err := g.object(t, true)
+	if err != nil {
+		return nil, err
+	}
+	var head bson.D
+	if opts.Title != "" {
+		head = append(head, bson.E{Key: "title", Value: opts.Title})
+	}
+	if opts.Description != "" {
+		head = append(head, bson.E{Key: "description", Value: opts.Description})
+	}
+	return append(head, schema...), nil
+}
+
+// schemaGenerator builds the $jsonSchema of a Go type.
+type schemaGenerator struct {
+	strict bool
+	// visiting holds the structs being generated, to stop at recursive types.
+	visiting map[reflect.Type]bool
+}
+
+// object returns the schema of a struct type.
+func (g schemaGenerator) object(t reflect.Type, top bool) (bson.D, error) {
+	if g.visiting[t] {
+		// A recursive type is described by its outermost occurrence only.
+		return bson.D{{Key: "bsonType", Value: "object"}}, nil
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	var required bson.A
+	var props, rest bson.D
+	if err := g.fields(t, &props, &required, &rest); err != nil {
+		return nil, err
+	}
+	if top && g.strict {
+		if _, ok := lookupKey(props, "_id"); !ok {
+			props = append(bson.D{{Key: "_id", Value: bson.D{}}}, props...)
+		}
+	}
+
+	schema := bson.D{{Key: "bsonType", Value: "object"}}
+	if len(required) > 0 {
+		schema = append(schema, bson.E{Key: "required", Value: required})
+	}
+	schema = append(schema, bson.E{Key: "properties", Value: props})
+	if g.strict {
+		// An inline map holds the fields the struct does not declare, so
+		// they are allowed as far as the map's values allow them.
+		switch {
+		case rest == nil:
+			schema = append(schema, bson.E{Key: "additionalProperties", Value: false})
+		case len(rest) > 0:
+			schema = append(schema, bson.E{Key: "additionalProperties", Value: rest})
+		}
+	}
+	return schema, nil
+}
+
+// fields adds the properties and required field names of struct t,
+// including those of inlined structs. If t inlines a map, rest is set to the
+// schema of the map's values, which is empty but not nil for any value.
+func (g schemaGenerator) fields(t reflect.Type, props *bson.D, required *bson.A, rest *bson.D) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// Like the driver, keep embedded structs of unexported types.
+		if !sf.IsExported() && !(sf.Anonymous && sf.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		name := resolveBsonTag(sf)
+		if name == "-" {
+			continue
+		}
+		_, tagOpts, _ := strings.Cut(sf.Tag.Get("bson"), ",")
+		if hasTagOption(tagOpts, "inline") {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			switch ft.Kind() {
+			case reflect.Struct:
+				if err := g.fields(ft, props, required, rest); err != nil {
+					return err
+				}
+			case reflect.Map:
+				values, err := g.value(ft.Elem())
+				if err != nil {
+					return fmt.Errorf("%s.%s: %w", t.Name(), sf.Name, err)
+				}
+				*rest = append(bson.D{}, values...)
+			}
+			continue
+		}
+
+		schema, err := g.value(sf.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), sf.Name, err)
+		}
+		isRequired := !hasTagOption(tagOpts, "omitempty") && !nullable(sf.Type)
+		for _, d := range gmqbTag(sf) {
+			key, value, _ := strings.Cut(d, "=")
+			switch key {
+			case "required":
+				isRequired = true
+			case "optional":
+				isRequired = false
+			case "enum":
+				enum, err := enumValues(sf.Type, value)
+				if err != nil {
+					return fmt.Errorf("%w: %s.%s: %v", ErrInvalidField, t.Name(), sf.Name, err)
+				}
+				schema = append(schema, bson.E{Key: "enum", Value: enum})
+			}
+		}
+		if isRequired {
+			*required = append(*required, name)
+		}
+		*props = append(*props, bson.E{Key: name, Value: schema})
+	}
+	return nil
+}
+
+// value returns the schema of a field of type t.
+func (g schemaGenerator) value(t reflect.Type) (bson.D, error) {
+	if t.Kind() == reflect.Pointer {
+		schema, err := g.value(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return withNull(schema), nil
+	}
+
+	switch t {
+	case timeType, dateTimeType:
+		return bsonType("date"), nil
+	case objectIDType:
+		return bsonType("objectId"), nil
+	case decimal128Type:
+		return bsonType("decimal"), nil
+	case binaryType:
+		return bsonType("binData"), nil
+	case regexType:
+		return bsonType("regex"), nil
+	case timestampType:
+		return bsonType("timestamp"), nil
+	case byteSliceType:
+		return withNull(bsonType("binData")), nil
+	case bsonDType, rawType:
+		return withNull(bsonType("object")), nil
+	case bsonAType:
+		return withNull(bsonType("array")), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return bsonType("string"), nil
+	case reflect.Bool:
+		return bsonType("bool"), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return bsonType("int"), nil
+	case reflect.Int:
+		// int is encoded as an int32 when it fits and an int64 otherwise.
+		return bson.D{{Key: "bsonType", Value: bson.A{"int", "long"}}}, nil
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return bsonType("long"), nil
+	case reflect.Float32, reflect.Float64:
+		return bsonType("double"), nil
+	case reflect.Struct:
+		return g.object(t, false)
+	case reflect.Slice, reflect.Array:
+		items, err := g.value(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		schema := bson.D{{Key: "bsonType", Value: "array"}}
+		if len(items) > 0 {
+			schema = append(schema, bson.E{Key: "items", Value: items})
+		}
+		if t.Kind() == reflect.Slice {
+			return withNull(schema), nil
+		}
+		return schema, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: map keys of type %s cannot be described", ErrInvalidField, t.Key())
+		}
+		values, err := g.value(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		schema := bson.D{{Key: "bsonType", Value: "object"}}
+		if len(values) > 0 {
+			schema = append(schema, bson.E{Key: "additionalProperties", Value: values})
+		}
+		return withNull(schema), nil
+	case reflect.Interface:
+		return bson.D{}, nil
+	}
+	return nil, fmt.Errorf("%w: type %s has no BSON representation", ErrInvalidField, t)
+}
+
+// nullable reports whether a Go type encodes its zero value as null.
+func nullable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// bsonType returns a schema with a single bsonType.
+func bsonType(name string) bson.D {
+	return bson.D{{Key: "bsonType", Value: name}}
+}
+
+// withNull extends the bsonType of schema to accept null.
+func withNull(schema bson.D) bson.D {
+	out := make(bson.D, len(schema))
+	copy(out, schema)
+	for i, e := range out {
+		if e.Key != "bsonType" {
+			continue
+		}
+		switch v := e.Value.(type) {
+		case string:
+			if v != "null" {
+				out[i].Value = bson.A{v, "null"}
+			}
+		case bson.A:
+			if !slices.Contains(v, any("null")) {
+				out[i].Value = append(append(bson.A{}, v...), "null")
+			}
+		}
+	}
+	return out
+}
+
+// hasTagOption reports whether a comma-separated list of bson tag options
+// contains opt.
+func hasTagOption(opts, opt string) bool {
+	return slices.Contains(strings.Split(opts, ","), opt)
+}
+
+// enumValues parses the |-separated values of an enum directive as values
+// of the field's type.
+func enumValues(t reflect.Type, list string) (bson.A, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if list == "" {
+		return nil, errors.New("enum has no values")
+	}
+	var out bson.A
+	for _, s := range strings.Split(list, "|") {
+		var (
+			v   any
+			err error
+		)
+		switch t.Kind() {
+		case reflect.String:
+			v = s
+		case reflect.Bool:
+			v, err = strconv.ParseBool(s)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v, err = strconv.ParseInt(s, 10, t.Bits())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v, err = strconv.ParseUint(s, 10, t.Bits())
+		case reflect.Float32, reflect.Float64:
+			v, err = strconv.ParseFloat(s, t.Bits())
+		default:
+			return nil, fmt.Errorf("enum is not supported on %s fields", t)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("enum value %q: %w", s, err)
+		}
+		// Convert to the field's type so each value encodes as it would.
+		out = append(out, reflect.ValueOf(v).Convert(t).Interface())
+	}
+	return out, nil
+}
+
+// ValidationLevel controls which writes a collection validator checks.
+//
+// See: https://www.mongodb.com/docs/manual/core/schema-validation/specify-validation-level/
+type ValidationLevel string
+
+const (
+	// ValidationOff disables validation.
+	ValidationOff ValidationLevel = "off"
+	// ValidationStrict validates every insert and update. It is the
+	// server's default.
+	ValidationStrict ValidationLevel = "strict"
+	// ValidationModerate validates inserts and updates of documents that
+	// are already valid.
+	ValidationModerate ValidationLevel = "moderate"
+)
+
+// ValidationAction controls what happens to a write that fails validation.
+//
+// See: https://www.mongodb.com/docs/manual/core/schema-validation/handle-invalid-documents/
+type ValidationAction string
+
+const (
+	// ValidationError rejects the write. It is the server's default.
+	ValidationError ValidationAction = "error"
+	// ValidationWarn allows the write and logs a warning.
+	ValidationWarn ValidationAction = "warn"
+)
+
+// ApplyValidator sets schema as the $jsonSchema validator of coll with
+// collMod, creating the collection if it does not exist. An empty level or
+// action keeps the server's default.
+//
+// MongoDB equivalent:
+//
+//	{ collMod: <collection>, validator: { $jsonSchema: schema },
+//	  validationLevel: level, validationAction: action }
+//
+// See: https://www.mongodb.com/docs/manual/core/schema-validation/
+//
+// Example:
+//
+//	schema, err := gmqb.JSONSchemaFor[Order](gmqb.JSONSchemaOpts{})
+//	err = gmqb.ApplyValidator(ctx, orders, schema, gmqb.ValidationStrict, gmqb.ValidationError)
+func ApplyValidator[T any](ctx context.Context, coll *Collection[T], schema bson.D, level ValidationLevel, action ValidationAction) error {
+	validator := JsonSchema(schema).BsonD()
+	cmd := bson.D{{Key: "validator", Value: validator}}
+	if level != "" {
+		cmd = append(cmd, bson.E{Key: "validationLevel", Value: string(level)})
+	}
+	if action != "" {
+		cmd = append(cmd, bson.E{Key: "validationAction", Value: string(action)})
+	}
+	err := coll.collMod(ctx, cmd)
+	var ce mongo.CommandError
+	if !errors.As(err, &ce) || ce.Code != namespaceNotFoundCode {
+		return err
+	}
+
+	create := options.CreateCollection().SetValidator(validator)
+	if level != "" {
+		create.SetValidationLevel(string(level))
+	}
+	if action != "" {
+		create.SetValidationAction(string(action))
+	}
+	return coll.coll.Database().CreateCollection(ctx, coll.coll.Name(), create)
+}
+
+// namespaceNotFoundCode is the server error code for a missing collection.
+const namespaceNotFoundCode = 26
@@ -0,0 +1,127 @@
+package gmqb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type schemaStatus string
+
+type schemaItem struct {
+	SKU string `bson:"sku"`
+	Qty int32  `bson:"qty"`
+}
+
+type schemaAudit struct {
+	By string `bson:"by"`
+}
+
+type schemaOrder struct {
+	ID          bson.ObjectID     `bson:"_id,omitempty"`
+	Status      schemaStatus      `bson:"status" gmqb:"enum=pending|paid"`
+	Priority    int               `bson:"priority" gmqb:"enum=1|2|3"`
+	Total       float64           `bson:"total"`
+	Paid        bool              `bson:"paid"`
+	Items       []schemaItem      `bson:"items"`
+	Tags        []string          `bson:"tags" gmqb:"required"`
+	Note        *string           `bson:"note"`
+	Meta        map[string]string `bson:"meta,omitempty"`
+	At          time.Time         `bson:"at" gmqb:"optional"`
+	Any         interface{}       `bson:"any"`
+	Ignored     string            `bson:"-"`
+	internal    string
+	schemaAudit `bson:",inline"`
+}
+
+func TestJSONSchemaFor(t *testing.T) {
+	schema, err := JSONSchemaFor[schemaOrder](JSONSchemaOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, `{"bsonType":"object",`+
+		`"required":["status","priority","total","paid","tags","by"],`+
+		`"properties":{`+
+		`"_id":{"bsonType":"objectId"},`+
+		`"status":{"bsonType":"string","enum":["pending","paid"]},`+
+		`"priority":{"bsonType":["int","long"],"enum":[1,2,3]},`+
+		`"total":{"bsonType":"double"},`+
+		`"paid":{"bsonType":"bool"},`+
+		`"items":{"bsonType":["array","null"],"items":{"bsonType":"object","required":["sku","qty"],"properties":{"sku":{"bsonType":"string"},"qty":{"bsonType":"int"}}}},`+
+		`"tags":{"bsonType":["array","null"],"items":{"bsonType":"string"}},`+
+		`"note":{"bsonType":["string","null"]},`+
+		`"meta":{"bsonType":["object","null"],"additionalProperties":{"bsonType":"string"}},`+
+		`"at":{"bsonType":"date"},`+
+		`"any":{},`+
+		`"by":{"bsonType":"string"}}}`,
+		toCompactJSON(schema))
+
+	// The schema is usable as a filter.
+	assert.Equal(t, "$jsonSchema", JsonSchema(schema).BsonD()[0].Key)
+}
+
+func TestJSONSchemaFor_Strict(t *testing.T) {
+	type inner struct {
+		A string `bson:"a"`
+	}
+	type doc struct {
+		Inner inner `bson:"inner"`
+	}
+	schema, err := JSONSchemaFor[doc](JSONSchemaOpts{Strict: true, Title: "doc"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"title":"doc","bsonType":"object","required":["inner"],"properties":{`+
+		`"_id":{},`+
+		`"inner":{"bsonType":"object","required":["a"],"properties":{"a":{"bsonType":"string"}},"additionalProperties":false}},`+
+		`"additionalProperties":false}`,
+		toCompactJSON(schema))
+}
+
+func TestJSONSchemaFor_StrictInlineMap(t *testing.T) {
+	type counts struct {
+		Name  string           `bson:"name"`
+		Extra map[string]int64 `bson:",inline"`
+	}
+	schema, err := JSONSchemaFor[counts](JSONSchemaOpts{Strict: true})
+	require.NoError(t, err)
+	assert.Equal(t, `{"bsonType":"object","required":["name"],"properties":{`+
+		`"_id":{},"name":{"bsonType":"string"}},`+
+		`"additionalProperties":{"bsonType":"long"}}`,
+		toCompactJSON(schema))
+
+	type anyExtra struct {
+		Name  string         `bson:"name"`
+		Extra map[string]any `bson:",inline"`
+	}
+	schema, err = JSONSchemaFor[anyExtra](JSONSchemaOpts{Strict: true})
+	require.NoError(t, err)
+	assert.NotContains(t, toCompactJSON(schema), "additionalProperties")
+}
+
+type schemaNode struct {
+	Name     string       `bson:"name"`
+	Children []schemaNode `bson:"children,omitempty"`
+}
+
+func TestJSONSchemaFor_Recursive(t *testing.T) {
+	schema, err := JSONSchemaFor[schemaNode](JSONSchemaOpts{})
+	require.NoError(t, err)
+	assert.Contains(t, toCompactJSON(schema), `"children":{"bsonType":["array","null"],"items":{"bsonType":"object"}}`)
+}
+
+func TestJSONSchemaFor_Invalid(t *testing.T) {
+	_, err := JSONSchemaFor[int](JSONSchemaOpts{})
+	assert.ErrorIs(t, err, ErrInvalidField)
+
+	type badEnum struct {
+		N int8 `gmqb:"enum=1|300"`
+	}
+	_, err = JSONSchemaFor[badEnum](JSONSchemaOpts{})
+	assert.ErrorIs(t, err, ErrInvalidField)
+
+	type badType struct {
+		C chan int
+	}
+	_, err = JSONSchemaFor[badType](JSONSchemaOpts{})
+	assert.ErrorIs(t, err, ErrInvalidField)
+}
@@ -0,0 +1,578 @@
+package gmqb
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MigrationFunc applies or reverts one migration against db.
+type MigrationFunc func(ctx context.Context, db *mongo.Database) error
+
+// Migration is one versioned schema change.
+type Migration struct {
+	// Version orders migrations. It must be positive and unique; a
+	// timestamp such as 20240315120000 works well.
+	Version int64
+	// Description is recorded alongside the applied version.
+	Description string
+	// Up applies the migration.
+	Up MigrationFunc
+	// Down reverts it. It may be nil for migrations that cannot be
+	// reverted, which makes Migrator.Down fail before changing anything.
+	Down MigrationFunc
+}
+
+// MigrateOpts configures a Migrator.
+type MigrateOpts struct {
+	// Collection stores the applied versions and the lock. Default is
+	// "gmqb_migrations".
+	Collection string
+	// LockTTL is how long the lock survives without being renewed, so a
+	// crashed instance does not block migrations forever. The lock is
+	// renewed while migrations run. Default is 1 minute.
+	LockTTL time.Duration
+	// LockWait is how long Up and Down wait for another instance to release
+	// the lock before failing with ErrMigrationLocked. Default is 5 minutes;
+	// a negative value fails immediately.
+	LockWait time.Duration
+	// DryRun makes Up and Down return the migrations they would run
+	// without taking the lock or running them.
+	DryRun bool
+}
+
+// DefaultMigrateOpts provides sensible defaults for a Migrator.
+var DefaultMigrateOpts = MigrateOpts{
+	Collection: "gmqb_migrations",
+	LockTTL:    time.Minute,
+	LockWait:   5 * time.Minute,
+}
+
+// migrationLockID is the _id of the lock document,
+// { _id: "lock", owner: <holder>, expiresAt: <time> }. Applied versions use
+// their numeric version as _id.
+const migrationLockID = "lock"
+
+// migrationDoc records an applied migration.
+type migrationDoc struct {
+	Version     int64     `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version     int64
+	Description string
+	Applied     bool
+	// AppliedAt is the time the migration was applied, if it was.
+	AppliedAt time.Time
+	// Unknown is set for versions recorded as applied that the Migrator
+	// has no definition for, e.g. after a rollback of the service.
+	Unknown bool
+}
+
+// Migrator applies versioned migrations to a database. The versions it has
+// applied are stored in a collection, and a lock document in the same
+// collection ensures that only one instance migrates at a time, so every
+// replica of a service can run it at startup.
+//
+// Example:
+//
+//	m, err := gmqb.NewMigrator(db, []gmqb.Migration{
+//	    {
+//	        Version:     1,
+//	        Description: "rename users.fname",
+//	        Up:          gmqb.RenameFieldStep("users", "fname", "first_name"),
+//	        Down:        gmqb.RenameFieldStep("users", "first_name", "fname"),
+//	    },
+//	    {
+//	        Version: 2,
+//	        Up:      gmqb.CreateIndexesStep("users", gmqb.NewIndex(gmqb.Asc("email")).Unique()),
+//	    },
+//	}, gmqb.DefaultMigrateOpts)
+//	applied, err := m.Up(ctx)
+type Migrator struct {
+	db         *mongo.Database
+	coll       *mongo.Collection
+	migrations []Migration
+	opts       MigrateOpts
+	owner      string
+}
+
+// NewMigrator creates a Migrator for migrations against db. Zero fields of
+// opts take their value from DefaultMigrateOpts. It returns an error
+// wrapping ErrInvalidMigration if two migrations share a version, a version
+// is not positive or a migration has no Up function.
+func NewMigrator(db *mongo.Database, migrations []Migration, opts MigrateOpts) (*Migrator, error) {
+	if opts.Collection == "" {
+		opts.Collection = DefaultMigrateOpts.Collection
+	}
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = DefaultMigrateOpts.LockTTL
+	}
+	if opts.LockWait == 0 {
+		opts.LockWait = DefaultMigrateOpts.LockWait
+	}
+
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	for i, mig := range sorted {
+		switch {
+		case mig.Version <= 0:
+			return nil, fmt.Errorf("%w: version %d is not positive", ErrInvalidMigration, mig.Version)
+		case i > 0 && sorted[i-1].Version == mig.Version:
+			return nil, fmt.Errorf("%w: version %d is defined twice", ErrInvalidMigration, mig.Version)
+		case mig.Up == nil:
+			return nil, fmt.Errorf("%w: version %d has no Up function", ErrInvalidMigration, mig.Version)
+		}
+	}
+
+	return &Migrator{
+		db:         db,
+		coll:       db.Collection(opts.Collection),
+		migrations: sorted,
+		opts:       opts,
+		owner:      migrationOwner(),
+	}, nil
+}
+
+// migrationOwner identifies this process as the holder of the lock.
+func migrationOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Status lists every migration with whether it has been applied, in version
+// order.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := MigrationStatus{Version: mig.Version, Description: mig.Description}
+		if doc, ok := applied[mig.Version]; ok {
+			st.Applied, st.AppliedAt = true, doc.AppliedAt
+			delete(applied, mig.Version)
+		}
+		out = append(out, st)
+	}
+	for _, doc := range applied {
+		out = append(out, MigrationStatus{
+			Version: doc.Version, Description: doc.Description,
+			Applied: true, AppliedAt: doc.AppliedAt, Unknown: true,
+		})
+	}
+	slices.SortFunc(out, func(a, b MigrationStatus) int { return cmp.Compare(a.Version, b.Version) })
+	return out, nil
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied. It stops at the first failure, leaving the migrations applied
+// before it recorded.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.run(ctx, func(applied map[int64]migrationDoc) ([]Migration, error) {
+		var pending []Migration
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok {
+				pending = append(pending, mig)
+			}
+		}
+		return pending, nil
+	}, m.up)
+}
+
+// Down reverts every applied migration with a version above target, newest
+// first, and returns the ones it reverted. Down(ctx, 0) reverts them all.
+func (m *Migrator) Down(ctx context.Context, target int64) ([]Migration, error) {
+	return m.run(ctx, func(applied map[int64]migrationDoc) ([]Migration, error) {
+		var revert []Migration
+		for _, mig := range slices.Backward(m.migrations) {
+			if _, ok := applied[mig.Version]; !ok || mig.Version <= target {
+				continue
+			}
+			if mig.Down == nil {
+				return nil, fmt.Errorf("%w: version %d has no Down function", ErrInvalidMigration, mig.Version)
+			}
+			revert = append(revert, mig)
+		}
+		return revert, nil
+	}, m.down)
+}
+
+// run selects migrations under the lock and applies step to each.
+func (m *Migrator) run(ctx context.Context, selectFn func(map[int64]migrationDoc) ([]Migration, error), step func(context.Context, Migration) error) ([]Migration, error) {
+	if m.opts.DryRun {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return selectFn(applied)
+	}
+
+	ctx, release, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Read the applied versions under the lock, as the previous holder may
+	// have applied some while this instance waited.
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectFn(applied)
+	if err != nil {
+		return nil, err
+	}
+	for i, mig := range selected {
+		if lost := context.Cause(ctx); errors.Is(lost, ErrMigrationLocked) {
+			return selected[:i], lost
+		}
+		if err := step(ctx, mig); err != nil {
+			if lost := context.Cause(ctx); errors.Is(lost, ErrMigrationLocked) {
+				err = lost
+			}
+			return selected[:i], fmt.Errorf("gmqb migrate: version %d: %w", mig.Version, err)
+		}
+	}
+	return selected, nil
+}
+
+// up applies mig and records it.
+func (m *Migrator) up(ctx context.Context, mig Migration) error {
+	if err := mig.Up(ctx, m.db); err != nil {
+		return err
+	}
+	_, err := m.coll.InsertOne(ctx, migrationDoc{Version: mig.Version, Description: mig.Description, AppliedAt: time.Now()})
+	return err
+}
+
+// down reverts mig and removes its record.
+func (m *Migrator) down(ctx context.Context, mig Migration) error {
+	if err := mig.Down(ctx, m.db); err != nil {
+		return err
+	}
+	_, err := m.coll.DeleteOne(ctx, bson.D{{Key: "_id", Value: mig.Version}})
+	return err
+}
+
+// applied returns the recorded migrations by version.
+func (m *Migrator) applied(ctx context.Context) (map[int64]migrationDoc, error) {
+	cur, err := m.coll.Find(ctx, Type("_id", "number").BsonD())
+	if err != nil {
+		return nil, err
+	}
+	var docs []migrationDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make(map[int64]migrationDoc, len(docs))
+	for _, doc := range docs {
+		out[doc.Version] = doc
+	}
+	return out, nil
+}
+
+// lock acquires the migration lock, waiting up to LockWait for another
+// holder, and keeps it renewed until the returned release is called. If a
+// renewal fails the lock may pass to another instance, so the returned
+// context is then cancelled with a cause wrapping ErrMigrationLocked.
+func (m *Migrator) lock(ctx context.Context) (context.Context, func(), error) {
+	deadline := time.Now().Add(m.opts.LockWait)
+	for {
+		ok, err := m.tryLock(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil, ErrMigrationLocked
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(min(time.Second, m.opts.LockTTL/4)):
+		}
+	}
+
+	held, lost := context.WithCancelCause(ctx)
+	renewCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(m.opts.LockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+				ok, err := m.tryLock(renewCtx)
+				switch {
+				case renewCtx.Err() != nil:
+					return
+				case err != nil:
+					lost(fmt.Errorf("%w: renewing the lock: %v", ErrMigrationLocked, err))
+					return
+				case !ok:
+					lost(fmt.Errorf("%w: the lock was taken over while migrating", ErrMigrationLocked))
+					return
+				}
+			}
+		}
+	}()
+
+	return held, func() {
+		cancel()
+		<-done
+		lost(nil)
+		_, _ = m.coll.DeleteOne(context.WithoutCancel(ctx), bson.D{{Key: "_id", Value: migrationLockID}, {Key: "owner", Value: m.owner}})
+	}, nil
+}
+
+// tryLock takes or renews the lock if it is free, expired or already held
+// by this Migrator, and reports whether it did.
+func (m *Migrator) tryLock(ctx context.Context) (bool, error) {
+	now := time.Now()
+	filter := And(
+		Eq("_id", migrationLockID),
+		Or(Eq("owner", m.owner), Lt("expiresAt", now)),
+	)
+	update := NewUpdate().
+		Set("owner", m.owner).
+		Set("expiresAt", now.Add(m.opts.LockTTL))
+	_, err := m.coll.UpdateOne(ctx, filter.BsonD(), update.updatePayload(), options.UpdateOne().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// The lock document exists and is held by another instance.
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Steps combines migration steps into one that runs them in order.
+//
+// Example:
+//
+//	Up: gmqb.Steps(
+//	    gmqb.RenameFieldStep("users", "fname", "first_name"),
+//	    gmqb.CreateIndexesStep("users", gmqb.NewIndex(gmqb.Asc("first_name"))),
+//	)
+func Steps(steps ...MigrationFunc) MigrationFunc {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, step := range steps {
+			if err := step(ctx, db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// RenameFieldStep renames a field in every document of a collection that
+// has it.
+//
+// MongoDB equivalent:
+//
+//	db.<collection>.updateMany({ <from>: { $exists: true } }, { $rename: { <from>: <to> } })
+//
+// Example:
+//
+//	gmqb.RenameFieldStep("users", "fname", "first_name")
+func RenameFieldStep(collection, from, to string) MigrationFunc {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).UpdateMany(ctx,
+			Exists(from, true).BsonD(),
+			NewUpdate().Rename(from, to).updatePayload())
+		return err
+	}
+}
+
+// BackfillStep applies update to every document of a collection matching
+// filter. An empty filter matches every document. Updates built with
+// Pipeline can compute the new value from existing fields.
+//
+// Example:
+//
+//	gmqb.BackfillStep("users", gmqb.Exists("fullName", false),
+//	    gmqb.NewPipeline().SetFields(bson.D{{Key: "fullName",
+//	        Value: gmqb.ExprConcat("$first_name", " ", "$last_name")}}))
+func BackfillStep(collection string, filter Filter, update UpdateDoc) MigrationFunc {
+	return func(ctx context.Context, db *mongo.Database) error {
+		if update == nil || update.IsEmpty() {
+			return fmt.Errorf("%w: BackfillStep requires a non-empty update", ErrEmptyUpdate)
+		}
+		_, err := db.Collection(collection).UpdateMany(ctx, filter.BsonD(), update.updatePayload())
+		return err
+	}
+}
+
+// CreateIndexesStep creates indexes on a collection.
+//
+// Example:
+//
+//	gmqb.CreateIndexesStep("orders",
+//	    gmqb.NewIndex(gmqb.Asc("tenant", "createdAt")),
+//	    gmqb.NewIndex(gmqb.Asc("email")).Unique())
+func CreateIndexesStep(collection string, models ...IndexModel) MigrationFunc {
+	return func(ctx context.Context, db *mongo.Database) error {
+		mongoModels := make([]mongo.IndexModel, len(models))
+		for i, m := range models {
+			mongoModels[i] = m.MongoIndexModel()
+		}
+		_, err := db.Collection(collection).Indexes().CreateMany(ctx, mongoModels)
+		return err
+	}
+}
+
+// DropIndexesStep drops indexes from a collection by name. Indexes that do
+// not exist are ignored, so the step can revert a CreateIndexesStep that
+// only partly succeeded.
+func DropIndexesStep(collection string, names ...string) MigrationFunc {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, name := range names {
+			err := db.Collection(collection).Indexes().DropOne(ctx, name)
+			var ce mongo.CommandError
+			if err != nil && !(errors.As(err, &ce) && ce.Code == indexNotFoundCode) {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// indexNotFoundCode is the server error code for dropping a missing index.
+const indexNotFoundCode = 27
+
+// TransformStep decodes every document of a collection matching filter as
+// a T, passes it to fn and, when fn reports a change, writes back the
+// top-level fields whose encoding changed with $set and $unset. Fields T
+// does not declare are left as they are.
+//
+// Documents are read in _id order in batches of batchSize (default 500),
+// each batch starting after the last _id of the previous one, so a document
+// whose rewrite moves it within the scan is not transformed twice. The _id
+// values should therefore share one BSON type, as a range query only
+// matches values of the type it compares with.
+//
+// Example:
+//
+//	gmqb.TransformStep("users", gmqb.Exists("email", true), 200,
+//	    func(u *User) (bool, error) {
+//	        lower := strings.ToLower(u.Email)
+//	        changed := lower != u.Email
+//	        u.Email = lower
+//	        return changed, nil
+//	    })
+func TransformStep[T any](collection string, filter Filter, batchSize int, fn func(doc *T) (bool, error)) MigrationFunc {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return func(ctx context.Context, db *mongo.Database) error {
+		coll := db.Collection(collection)
+		opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(batchSize))
+		var last *bson.RawValue
+		for {
+			page := filter
+			if last != nil {
+				page = And(filter, Gt("_id", *last))
+			}
+			cur, err := coll.Find(ctx, page.BsonD(), opts)
+			if err != nil {
+				return err
+			}
+			var docs []bson.Raw
+			if err := cur.All(ctx, &docs); err != nil {
+				return err
+			}
+
+			var batch []mongo.WriteModel
+			for _, raw := range docs {
+				id, err := raw.LookupErr("_id")
+				if err != nil {
+					return fmt.Errorf("gmqb migrate: document without _id: %w", err)
+				}
+				last = &id
+				update, err := transformDoc(raw, fn)
+				if err != nil {
+					return err
+				}
+				if !update.IsEmpty() {
+					batch = append(batch, mongo.NewUpdateOneModel().
+						SetFilter(bson.D{{Key: "_id", Value: id}}).
+						SetUpdate(update.updatePayload()))
+				}
+			}
+			if len(batch) > 0 {
+				if _, err := coll.BulkWrite(ctx, batch); err != nil {
+					return err
+				}
+			}
+			if len(docs) < batchSize {
+				return nil
+			}
+		}
+	}
+}
+
+// transformDoc decodes raw as a T, applies fn and returns the update of the
+// top-level fields whose encoding fn changed. It is empty when fn reports
+// no change.
+func transformDoc[T any](raw bson.Raw, fn func(doc *T) (bool, error)) (Updater, error) {
+	doc := new(T)
+	if err := bson.Unmarshal(raw, doc); err != nil {
+		return Updater{}, err
+	}
+	// Compare against the re-encoded original rather than raw, so fields T
+	// does not declare are never unset.
+	before, err := bson.Marshal(doc)
+	if err != nil {
+		return Updater{}, err
+	}
+	changed, err := fn(doc)
+	if err != nil || !changed {
+		return Updater{}, err
+	}
+	after, err := bson.Marshal(doc)
+	if err != nil {
+		return Updater{}, err
+	}
+
+	update := NewUpdate()
+	elems, err := bson.Raw(after).Elements()
+	if err != nil {
+		return Updater{}, err
+	}
+	for _, e := range elems {
+		if old, err := bson.Raw(before).LookupErr(e.Key()); err != nil || !old.Equal(e.Value()) {
+			update = update.Set(e.Key(), e.Value())
+		}
+	}
+	elems, err = bson.Raw(before).Elements()
+	if err != nil {
+		return Updater{}, err
+	}
+	for _, e := range elems {
+		if _, err := bson.Raw(after).LookupErr(e.Key()); err != nil {
+			update = update.Unset(e.Key())
+		}
+	}
+	return update, nil
+}
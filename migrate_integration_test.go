@@ -0,0 +1,191 @@
+package gmqb_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/squall-chua/gmqb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestMigrator_Integration_UpDown(t *testing.T) {
+	db, _ := startStandalone(t)
+	ctx := context.Background()
+	users := db.Collection("users")
+	_, err := users.InsertMany(ctx, []any{
+		bson.D{{Key: "fname", Value: "Ann"}, {Key: "email", Value: "ANN@X.IO"}},
+		bson.D{{Key: "fname", Value: "Bob"}, {Key: "email", Value: "bob@x.io"}},
+	})
+	require.NoError(t, err)
+
+	type user struct {
+		ID    bson.ObjectID `bson:"_id"`
+		First string        `bson:"first_name"`
+		Email string        `bson:"email"`
+	}
+	migrations := []gmqb.Migration{
+		{
+			Version:     1,
+			Description: "rename fname",
+			Up:          gmqb.RenameFieldStep("users", "fname", "first_name"),
+			Down:        gmqb.RenameFieldStep("users", "first_name", "fname"),
+		},
+		{
+			Version:     2,
+			Description: "backfill and index",
+			Up: gmqb.Steps(
+				gmqb.BackfillStep("users", gmqb.Exists("greeting", false),
+					gmqb.NewPipeline().SetFields(bson.D{{Key: "greeting", Value: gmqb.ExprConcat("Hi ", "$first_name")}})),
+				gmqb.CreateIndexesStep("users", gmqb.NewIndex(gmqb.Asc("email")).Unique()),
+			),
+			Down: gmqb.DropIndexesStep("users", "email_1"),
+		},
+		{
+			Version:     3,
+			Description: "lowercase emails",
+			Up: gmqb.TransformStep("users", gmqb.Filter{}, 1, func(u *user) (bool, error) {
+				lower := strings.ToLower(u.Email)
+				changed := lower != u.Email
+				u.Email = lower
+				return changed, nil
+			}),
+		},
+	}
+
+	dry, err := gmqb.NewMigrator(db, migrations, gmqb.MigrateOpts{DryRun: true})
+	require.NoError(t, err)
+	pending, err := dry.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 3)
+
+	m, err := gmqb.NewMigrator(db, migrations, gmqb.DefaultMigrateOpts)
+	require.NoError(t, err)
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, 3)
+
+	var got []user
+	cur, err := users.Find(ctx, bson.D{})
+	require.NoError(t, err)
+	require.NoError(t, cur.All(ctx, &got))
+	assert.Equal(t, "Ann", got[0].First)
+	assert.Equal(t, "ann@x.io", got[0].Email)
+	n, err := users.CountDocuments(ctx, bson.D{{Key: "greeting", Value: bson.D{{Key: "$in", Value: bson.A{"Hi Ann", "Hi Bob"}}}}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n, "TransformStep keeps fields the struct does not declare")
+
+	// Nothing is pending on the next run.
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 3)
+	assert.True(t, status[2].Applied)
+
+	// Version 3 has no Down, so reverting past it fails before any change.
+	_, err = m.Down(ctx, 0)
+	assert.ErrorIs(t, err, gmqb.ErrInvalidMigration)
+
+	reverted, err := m.Down(ctx, 3)
+	require.NoError(t, err)
+	assert.Empty(t, reverted)
+
+	migrations[2].Down = func(context.Context, *mongo.Database) error { return nil }
+	m, err = gmqb.NewMigrator(db, migrations, gmqb.DefaultMigrateOpts)
+	require.NoError(t, err)
+	reverted, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 2)
+	assert.Equal(t, int64(3), reverted[0].Version)
+	status, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, status[0].Applied)
+	assert.False(t, status[1].Applied)
+}
+
+func TestMigrator_Integration_Lock(t *testing.T) {
+	db, _ := startStandalone(t)
+	ctx := context.Background()
+
+	var running, overlaps, runs int32
+	slow := func(context.Context, *mongo.Database) error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(300 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}
+	migrations := []gmqb.Migration{{Version: 1, Up: slow}}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := gmqb.NewMigrator(db, migrations, gmqb.MigrateOpts{LockTTL: 2 * time.Second})
+			if err == nil {
+				_, err = m.Up(ctx)
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), runs, "the migration runs once across instances")
+	assert.Zero(t, overlaps)
+
+	// An instance that cannot wait for the lock gives up.
+	_, err := db.Collection("gmqb_migrations").InsertOne(ctx, bson.D{
+		{Key: "_id", Value: "lock"}, {Key: "owner", Value: "other"}, {Key: "expiresAt", Value: time.Now().Add(time.Hour)},
+	})
+	require.NoError(t, err)
+	m, err := gmqb.NewMigrator(db, []gmqb.Migration{{Version: 2, Up: slow}}, gmqb.MigrateOpts{LockWait: -1})
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	assert.True(t, errors.Is(err, gmqb.ErrMigrationLocked))
+}
+
+func TestMigrator_Integration_LockLost(t *testing.T) {
+	db, _ := startStandalone(t)
+	ctx := context.Background()
+
+	// The step hands the lock to another instance and waits to be stopped.
+	var second bool
+	migrations := []gmqb.Migration{
+		{Version: 1, Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("gmqb_migrations").UpdateOne(ctx,
+				bson.D{{Key: "_id", Value: "lock"}},
+				bson.D{{Key: "$set", Value: bson.D{{Key: "owner", Value: "other"}, {Key: "expiresAt", Value: time.Now().Add(time.Hour)}}}})
+			if err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		{Version: 2, Up: func(context.Context, *mongo.Database) error {
+			second = true
+			return nil
+		}},
+	}
+	m, err := gmqb.NewMigrator(db, migrations, gmqb.MigrateOpts{LockTTL: 300 * time.Millisecond})
+	require.NoError(t, err)
+	applied, err := m.Up(ctx)
+	assert.True(t, errors.Is(err, gmqb.ErrMigrationLocked))
+	assert.Empty(t, applied)
+	assert.False(t, second)
+}
@@ -0,0 +1,80 @@
+package gmqb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestNewMigrator(t *testing.T) {
+	db := offlineCollection(t).Database()
+	noop := func(context.Context, *mongo.Database) error { return nil }
+
+	m, err := NewMigrator(db, []Migration{
+		{Version: 3, Up: noop},
+		{Version: 1, Up: noop},
+		{Version: 2, Up: noop},
+	}, MigrateOpts{LockTTL: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, []int64{m.migrations[0].Version, m.migrations[1].Version, m.migrations[2].Version})
+	assert.Equal(t, "gmqb_migrations", m.coll.Name())
+	assert.Equal(t, time.Second, m.opts.LockTTL)
+	assert.Equal(t, DefaultMigrateOpts.LockWait, m.opts.LockWait)
+
+	for name, migrations := range map[string][]Migration{
+		"duplicate": {{Version: 1, Up: noop}, {Version: 1, Up: noop}},
+		"zero":      {{Version: 0, Up: noop}},
+		"no up":     {{Version: 1}},
+	} {
+		_, err := NewMigrator(db, migrations, MigrateOpts{})
+		assert.ErrorIs(t, err, ErrInvalidMigration, name)
+	}
+}
+
+func TestMigrationSteps(t *testing.T) {
+	var ran []int
+	step := func(i int) MigrationFunc {
+		return func(context.Context, *mongo.Database) error {
+			ran = append(ran, i)
+			return nil
+		}
+	}
+	require.NoError(t, Steps(step(1), step(2))(context.Background(), nil))
+	assert.Equal(t, []int{1, 2}, ran)
+
+	err := BackfillStep("users", Filter{}, NewUpdate())(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrEmptyUpdate)
+}
+
+func TestTransformDoc(t *testing.T) {
+	type user struct {
+		ID    int    `bson:"_id"`
+		Email string `bson:"email"`
+		Nick  string `bson:"nick,omitempty"`
+	}
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: 1},
+		{Key: "email", Value: "ANN@X.IO"},
+		{Key: "nick", Value: "ann"},
+		{Key: "legacy", Value: true},
+	})
+	require.NoError(t, err)
+
+	update, err := transformDoc(raw, func(u *user) (bool, error) {
+		u.Email = strings.ToLower(u.Email)
+		u.Nick = ""
+		return true, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"$set":{"email":"ann@x.io"},"$unset":{"nick":""}}`, update.CompactJSON())
+
+	update, err = transformDoc(raw, func(*user) (bool, error) { return false, nil })
+	require.NoError(t, err)
+	assert.True(t, update.IsEmpty())
+}
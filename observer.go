@@ -0,0 +1,58 @@
+package gmqb
+
+import "context"
+
+// MessageKind distinguishes queue messages from pub/sub events.
+type MessageKind string
+
+const (
+	// MessageQueue marks a message sent through a Queue.
+	MessageQueue MessageKind = "queue"
+	// MessagePubSub marks an event sent through a TailablePubSub.
+	MessagePubSub MessageKind = "pubsub"
+)
+
+// MessageInfo describes a message passing through a MessageObserver.
+type MessageInfo struct {
+	// Kind is the messaging primitive carrying the message.
+	Kind MessageKind
+	// Destination is the queue name or topic.
+	Destination string
+	// ID is the hex message ID. It is empty for pub/sub events.
+	ID string
+	// Group is the consumer group handling a fan-out queue message.
+	Group string
+	// Headers is stored with the message. Entries added by Send are
+	// returned to Receive, which is how trace context travels from the
+	// producer to the consumer.
+	Headers map[string]string
+}
+
+// MessageObserver is notified when queue messages and pub/sub events are
+// sent and received. Both methods return the context to continue with and
+// a function that is called once with the outcome. Set it on QueueOpts or
+// CappedOpts; nil disables observation.
+type MessageObserver interface {
+	// Send is called before a message is stored.
+	Send(ctx context.Context, m *MessageInfo) (context.Context, func(error))
+	// Receive is called before a message is handled, with the Headers
+	// stored by Send. For a Queue the returned context is passed to the
+	// handler.
+	Receive(ctx context.Context, m *MessageInfo) (context.Context, func(error))
+}
+
+// observeSend runs o.Send if o is set.
+func observeSend(ctx context.Context, o MessageObserver, m *MessageInfo) (context.Context, func(error)) {
+	if o == nil {
+		return ctx, func(error) {}
+	}
+	return o.Send(ctx, m)
+}
+
+// observeReceive runs o.Receive if o is set.
+func observeReceive(ctx context.Context, o MessageObserver, m *MessageInfo) (context.Context, func(error)) {
+	if o == nil {
+		return ctx, func(error) {}
+	}
+	return o.Receive(ctx, m)
+}
@@ -1,343 +1,522 @@
 package gmqb
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
-// --- Find Options ---
+// --- Options ---
+
+// Opt is a functional option for a Collection operation. The same options
+// work with every method, e.g. WithHint applies to finds, counts, updates and
+// deletes alike. Passing an option a method does not support, such as
+// WithLimit to FindOne, fails the call with an error wrapping
+// ErrInvalidOption.
+//
+// Example:
+//
+//	users, err := coll.Find(ctx, filter,
+//	    gmqb.WithSort(gmqb.Desc("createdAt")),
+//	    gmqb.WithHint("createdAt_-1"),
+//	    gmqb.WithMaxTime(2*time.Second),
+//	)
+type Opt func(*opConfig)
+
+// optKind identifies an option, so that options a method does not support
+// can be reported.
+type optKind uint
+
+const (
+	optSort optKind = 1 << iota
+	optProjection
+	optSkip
+	optLimit
+	optHint
+	optCollation
+	optComment
+	optMaxTime
+	optUpsert
+	optReturnDocument
+	optOrdered
+	optBatchSize
+	optAllowDiskUse
+	optValidation
+)
+
+// optNames holds the constructor name of each option for error messages.
+var optNames = []struct {
+	kind optKind
+	name string
+}{
+	{optSort, "WithSort"},
+	{optProjection, "WithProjection"},
+	{optSkip, "WithSkip"},
+	{optLimit, "WithLimit"},
+	{optHint, "WithHint"},
+	{optCollation, "WithCollation"},
+	{optComment, "WithComment"},
+	{optMaxTime, "WithMaxTime"},
+	{optUpsert, "WithUpsert"},
+	{optReturnDocument, "WithReturnDocument"},
+	{optOrdered, "WithOrdered"},
+	{optBatchSize, "WithBatchSize"},
+	{optAllowDiskUse, "WithAllowDiskUse"},
+	{optValidation, "WithValidation"},
+}
+
+const (
+	// optsRead are the options of every read or write selecting documents.
+	optsRead = optHint | optCollation | optComment | optMaxTime
+	// optsFindAndModify are the options of FindOneAndDelete.
+	optsFindAndModify = optsRead | optSort | optProjection
+)
+
+// opOptions lists the options each operation supports.
+var opOptions = map[Operation]optKind{
+	OpFind:              optsRead | optSort | optProjection | optSkip | optLimit | optBatchSize | optAllowDiskUse,
+	OpFindOne:           optsRead | optSort | optProjection | optSkip,
+	OpInsertOne:         optComment | optMaxTime,
+	OpInsertMany:        optComment | optMaxTime | optOrdered,
+	OpBulkWrite:         optComment | optMaxTime | optOrdered,
+	OpUpdateOne:         optsRead | optSort | optUpsert,
+	OpUpdateMany:        optsRead | optUpsert,
+	OpReplaceOne:        optsRead | optSort | optUpsert,
+	OpDeleteOne:         optsRead,
+	OpDeleteMany:        optsRead,
+	OpFindOneAndDelete:  optsFindAndModify,
+	OpFindOneAndUpdate:  optsFindAndModify | optUpsert | optReturnDocument,
+	OpFindOneAndReplace: optsFindAndModify | optUpsert | optReturnDocument,
+	OpCountDocuments:    optsRead | optSkip | optLimit,
+	OpDistinct:          optsRead,
+	OpAggregate:         optsRead | optBatchSize | optAllowDiskUse | optValidation,
+}
+
+// opConfig holds the settings applied by Opt values.
+type opConfig struct {
+	set            optKind
+	sort           interface{}
+	projection     interface{}
+	hint           interface{}
+	comment        interface{}
+	collation      *options.Collation
+	skip           *int64
+	limit          *int64
+	upsert         *bool
+	returnDocument *options.ReturnDocument
+	ordered        *bool
+	batchSize      *int32
+	allowDiskUse   *bool
+	maxTime        time.Duration
+	validate       bool
+}
+
+// buildOpts applies opts to an opConfig, failing with ErrInvalidOption if
+// any of them does not apply to op.
+func buildOpts(op Operation, opts []Opt) (opConfig, error) {
+	var c opConfig
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c)
+		}
+	}
+	if extra := c.set &^ opOptions[op]; extra != 0 {
+		for _, n := range optNames {
+			if extra&n.kind != 0 {
+				return c, fmt.Errorf("%w: %s does not apply to %s", ErrInvalidOption, n.name, op)
+			}
+		}
+	}
+	return c, nil
+}
+
+// deadline returns the absolute deadline implied by maxTime, or the zero time.
+func (c opConfig) deadline() time.Time {
+	if c.maxTime <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(c.maxTime)
+}
+
+// withDeadline bounds ctx by deadline unless it is zero.
+func withDeadline(ctx context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	if deadline.IsZero() {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// driverOptions returns the settings of c as driver options of type O. Only
+// options that apply to the operation pass buildOpts, so every setting has a
+// matching field in O.
+func driverOptions[O any](c opConfig) options.Lister[O] {
+	return optLister[O]{cfg: c}
+}
 
-// FindOpt is a functional option for configuring find operations.
-type FindOpt func(*options.FindOptionsBuilder)
+// optLister applies an opConfig to whichever driver options struct O is.
+type optLister[O any] struct{ cfg opConfig }
 
-// WithSort sets the sort specification for a find operation.
+func (l optLister[O]) List() []func(*O) error {
+	if l.cfg.set == 0 {
+		return nil
+	}
+	return []func(*O) error{func(o *O) error {
+		v := reflect.ValueOf(o).Elem()
+		setOptField(v, "Sort", l.cfg.sort)
+		setOptField(v, "Projection", l.cfg.projection)
+		setOptField(v, "Hint", l.cfg.hint)
+		setOptField(v, "Comment", l.cfg.comment)
+		setOptField(v, "Collation", l.cfg.collation)
+		setOptField(v, "Skip", l.cfg.skip)
+		setOptField(v, "Limit", l.cfg.limit)
+		setOptField(v, "Upsert", l.cfg.upsert)
+		setOptField(v, "ReturnDocument", l.cfg.returnDocument)
+		setOptField(v, "Ordered", l.cfg.ordered)
+		setOptField(v, "BatchSize", l.cfg.batchSize)
+		setOptField(v, "AllowDiskUse", l.cfg.allowDiskUse)
+		return nil
+	}}
+}
+
+// WithSort sets the order in which documents are returned, or, for
+// UpdateOne, ReplaceOne and the FindOneAnd methods, which document is
+// modified.
 //
 // Example:
 //
 //	coll.Find(ctx, filter, gmqb.WithSort(gmqb.Desc("createdAt")))
-func WithSort(sort interface{}) FindOpt {
-	return func(o *options.FindOptionsBuilder) {
-		o.SetSort(sort)
+func WithSort(sort interface{}) Opt {
+	return func(c *opConfig) {
+		c.set |= optSort
+		c.sort = sort
 	}
 }
 
-// WithProjection sets the projection for a find operation.
+// WithProjection sets the fields returned by a find or FindOneAnd method.
 //
 // Example:
 //
 //	coll.Find(ctx, filter, gmqb.WithProjection(gmqb.Include("name", "email")))
-func WithProjection(projection interface{}) FindOpt {
-	return func(o *options.FindOptionsBuilder) {
-		o.SetProjection(projection)
+func WithProjection(projection interface{}) Opt {
+	return func(c *opConfig) {
+		c.set |= optProjection
+		c.projection = projection
 	}
 }
 
-// WithSkip sets the number of documents to skip.
+// WithSkip sets the number of documents to skip in a find or count.
 //
 // Example:
 //
 //	coll.Find(ctx, filter, gmqb.WithSkip(20))
-func WithSkip(n int64) FindOpt {
-	return func(o *options.FindOptionsBuilder) {
-		o.SetSkip(n)
+func WithSkip(n int64) Opt {
+	return func(c *opConfig) {
+		c.set |= optSkip
+		c.skip = &n
 	}
 }
 
-// WithLimit sets the maximum number of documents to return.
+// WithLimit sets the maximum number of documents a Find returns or a
+// CountDocuments counts.
 //
 // Example:
 //
 //	coll.Find(ctx, filter, gmqb.WithLimit(10))
-func WithLimit(n int64) FindOpt {
-	return func(o *options.FindOptionsBuilder) {
-		o.SetLimit(n)
+func WithLimit(n int64) Opt {
+	return func(c *opConfig) {
+		c.set |= optLimit
+		c.limit = &n
 	}
 }
 
-// buildFindOpts applies functional options to a FindOptionsBuilder.
-func buildFindOpts(opts []FindOpt) *options.FindOptionsBuilder {
-	o := options.Find()
-	for _, opt := range opts {
-		opt(o)
+// WithHint sets the index the server uses, by name or key specification.
+//
+// Example:
+//
+//	coll.CountDocuments(ctx, filter, gmqb.WithHint("status_1"))
+func WithHint(hint interface{}) Opt {
+	return func(c *opConfig) {
+		c.set |= optHint
+		c.hint = hint
 	}
-	return o
 }
 
-// --- Update Options ---
-
-// UpdateOpt is a functional option for configuring update operations.
-type UpdateOpt func(*options.UpdateOneOptionsBuilder)
-
-// WithUpsert sets the upsert flag. When true, creates a new document if no match.
+// WithCollation sets the collation used to compare strings.
 //
 // Example:
 //
-//	coll.UpdateOne(ctx, filter, update, gmqb.WithUpsert(true))
-func WithUpsert(upsert bool) UpdateOpt {
-	return func(o *options.UpdateOneOptionsBuilder) {
-		o.SetUpsert(upsert)
+//	coll.Find(ctx, gmqb.Eq("name", "alice"),
+//	    gmqb.WithCollation(&options.Collation{Locale: "en", Strength: 2}))
+func WithCollation(collation *options.Collation) Opt {
+	return func(c *opConfig) {
+		c.set |= optCollation
+		c.collation = collation
 	}
 }
 
-// WithCollation sets the collation for an update operation.
-func WithCollation(collation *options.Collation) UpdateOpt {
-	return func(o *options.UpdateOneOptionsBuilder) {
-		o.SetCollation(collation)
+// WithComment attaches a comment to the command, shown by the profiler,
+// currentOp and the server logs.
+//
+// Example:
+//
+//	coll.UpdateMany(ctx, filter, update, gmqb.WithComment("nightly-cleanup"))
+func WithComment(comment interface{}) Opt {
+	return func(c *opConfig) {
+		c.set |= optComment
+		c.comment = comment
 	}
 }
 
-// WithHint sets the hint for an update operation.
-func WithHint(hint interface{}) UpdateOpt {
-	return func(o *options.UpdateOneOptionsBuilder) {
-		o.SetHint(hint)
+// WithMaxTime bounds the total time spent on an operation. For FindCursor,
+// FindIter, AggregateCursor and AggregateIter it spans the initial query and
+// every subsequent batch. The remaining time is sent to the server as
+// maxTimeMS on each round trip.
+//
+// Example:
+//
+//	coll.FindIter(ctx, filter, gmqb.WithMaxTime(10*time.Minute))
+func WithMaxTime(d time.Duration) Opt {
+	return func(c *opConfig) {
+		c.set |= optMaxTime
+		c.maxTime = d
 	}
 }
 
-// buildUpdateOneOpts applies functional options to an UpdateOneOptionsBuilder.
-func buildUpdateOneOpts(opts []UpdateOpt) *options.UpdateOneOptionsBuilder {
-	o := options.UpdateOne()
-	for _, opt := range opts {
-		opt(o)
+// WithUpsert sets the upsert flag. When true, an update or replacement that
+// matches no document inserts one.
+//
+// Example:
+//
+//	coll.UpdateOne(ctx, filter, update, gmqb.WithUpsert(true))
+func WithUpsert(upsert bool) Opt {
+	return func(c *opConfig) {
+		c.set |= optUpsert
+		c.upsert = &upsert
 	}
-	return o
 }
 
-// --- Replace Options ---
-
-// ReplaceOpt is a functional option for configuring replace operations.
-type ReplaceOpt func(*options.ReplaceOptionsBuilder)
-
-// WithUpsertReplace sets the upsert flag for replace operations.
-func WithUpsertReplace(upsert bool) ReplaceOpt {
-	return func(o *options.ReplaceOptionsBuilder) {
-		o.SetUpsert(upsert)
+// WithReturnDocument specifies whether FindOneAndUpdate and FindOneAndReplace
+// return the original or the modified document.
+//
+// Example:
+//
+//	coll.FindOneAndUpdate(ctx, filter, update, gmqb.WithReturnDocument(options.After))
+func WithReturnDocument(rd options.ReturnDocument) Opt {
+	return func(c *opConfig) {
+		c.set |= optReturnDocument
+		c.returnDocument = &rd
 	}
 }
 
-// buildReplaceOpts applies functional options to a ReplaceOptionsBuilder.
-func buildReplaceOpts(opts []ReplaceOpt) *options.ReplaceOptionsBuilder {
-	o := options.Replace()
-	for _, opt := range opts {
-		opt(o)
+// WithOrdered sets the ordered flag of InsertMany and BulkWrite. When true,
+// operations are executed serially and stop at the first error.
+//
+// Example:
+//
+//	coll.BulkWrite(ctx, models, gmqb.WithOrdered(false))
+func WithOrdered(ordered bool) Opt {
+	return func(c *opConfig) {
+		c.set |= optOrdered
+		c.ordered = &ordered
 	}
-	return o
 }
 
-// UpdateManyOpt is a functional option for configuring UpdateMany operations.
-type UpdateManyOpt func(*options.UpdateManyOptionsBuilder)
-
-// WithUpsertMany sets the upsert flag for UpdateMany operations.
-func WithUpsertMany(upsert bool) UpdateManyOpt {
-	return func(o *options.UpdateManyOptionsBuilder) {
-		o.SetUpsert(upsert)
+// WithBatchSize sets the number of documents the server returns per batch.
+//
+// Example:
+//
+//	coll.FindIter(ctx, filter, gmqb.WithBatchSize(1000))
+func WithBatchSize(n int32) Opt {
+	return func(c *opConfig) {
+		c.set |= optBatchSize
+		c.batchSize = &n
 	}
 }
 
-// buildUpdateManyOpts applies functional options to an UpdateManyOptionsBuilder.
-func buildUpdateManyOpts(opts []UpdateManyOpt) *options.UpdateManyOptionsBuilder {
-	o := options.UpdateMany()
-	for _, opt := range opts {
-		opt(o)
+// WithAllowDiskUse allows the server to write temporary files when a blocking
+// sort or group stage exceeds its memory limit.
+//
+// Example:
+//
+//	gmqb.AggregateIter[Row](coll, ctx, pipeline, gmqb.WithAllowDiskUse(true))
+func WithAllowDiskUse(allow bool) Opt {
+	return func(c *opConfig) {
+		c.set |= optAllowDiskUse
+		c.allowDiskUse = &allow
 	}
-	return o
 }
 
-// --- Bulk Write Options ---
-
-// BulkWriteOpt is a functional option for configuring bulk write operations.
-type BulkWriteOpt func(*options.BulkWriteOptionsBuilder)
-
-// WithOrdered sets the ordered flag. When true, operations are executed serially.
+// WithValidation makes an aggregation run Pipeline.Validate before sending the
+// pipeline to the server, returning the validation error instead of executing.
 //
 // Example:
 //
-//	coll.BulkWrite(ctx, models, gmqb.WithOrdered(false))
-func WithOrdered(ordered bool) BulkWriteOpt {
-	return func(o *options.BulkWriteOptionsBuilder) {
-		o.SetOrdered(ordered)
+//	results, err := gmqb.Aggregate[Stats](coll, ctx, pipeline, gmqb.WithValidation())
+func WithValidation() Opt {
+	return func(c *opConfig) {
+		c.set |= optValidation
+		c.validate = true
 	}
 }
 
-// buildBulkWriteOpts applies functional options to a BulkWriteOptionsBuilder.
-func buildBulkWriteOpts(opts []BulkWriteOpt) *options.BulkWriteOptionsBuilder {
-	o := options.BulkWrite()
-	for _, opt := range opts {
-		opt(o)
-	}
-	return o
-}
+// --- Deprecated option names ---
 
-// --- FindOneAndDelete Options ---
+// FindOpt configures find operations.
+//
+// Deprecated: Use Opt.
+type FindOpt = Opt
 
-// FindOneAndDeleteOpt is a functional option for configuring FindOneAndDelete operations.
-type FindOneAndDeleteOpt func(*options.FindOneAndDeleteOptionsBuilder)
+// UpdateOpt configures UpdateOne.
+//
+// Deprecated: Use Opt.
+type UpdateOpt = Opt
 
-// WithSortFindAndDelete sets the sort specification for FindOneAndDelete.
-func WithSortFindAndDelete(sort interface{}) FindOneAndDeleteOpt {
-	return func(o *options.FindOneAndDeleteOptionsBuilder) {
-		o.SetSort(sort)
-	}
-}
+// ReplaceOpt configures ReplaceOne.
+//
+// Deprecated: Use Opt.
+type ReplaceOpt = Opt
 
-// WithProjectionFindAndDelete sets the projection for FindOneAndDelete.
-func WithProjectionFindAndDelete(projection interface{}) FindOneAndDeleteOpt {
-	return func(o *options.FindOneAndDeleteOptionsBuilder) {
-		o.SetProjection(projection)
-	}
-}
+// UpdateManyOpt configures UpdateMany.
+//
+// Deprecated: Use Opt.
+type UpdateManyOpt = Opt
 
-// WithHintFindAndDelete sets the hint for FindOneAndDelete.
-func WithHintFindAndDelete(hint interface{}) FindOneAndDeleteOpt {
-	return func(o *options.FindOneAndDeleteOptionsBuilder) {
-		o.SetHint(hint)
-	}
-}
+// BulkWriteOpt configures BulkWrite.
+//
+// Deprecated: Use Opt.
+type BulkWriteOpt = Opt
 
-// WithReturnDocumentDelete is a no-op for FindOneAndDelete as it always returns
-// the document before deletion. Provided for API parity.
-func WithReturnDocumentDelete(rd options.ReturnDocument) FindOneAndDeleteOpt {
-	return func(o *options.FindOneAndDeleteOptionsBuilder) {}
-}
+// FindOneAndDeleteOpt configures FindOneAndDelete.
+//
+// Deprecated: Use Opt.
+type FindOneAndDeleteOpt = Opt
 
-// buildFindOneAndDeleteOpts applies functional options to a FindOneAndDeleteOptionsBuilder.
-func buildFindOneAndDeleteOpts(opts []FindOneAndDeleteOpt) *options.FindOneAndDeleteOptionsBuilder {
-	o := options.FindOneAndDelete()
-	for _, opt := range opts {
-		opt(o)
-	}
-	return o
-}
+// FindOneAndUpdateOpt configures FindOneAndUpdate.
+//
+// Deprecated: Use Opt.
+type FindOneAndUpdateOpt = Opt
 
-// --- FindOneAndUpdate Options ---
+// FindOneAndReplaceOpt configures FindOneAndReplace.
+//
+// Deprecated: Use Opt.
+type FindOneAndReplaceOpt = Opt
 
-// FindOneAndUpdateOpt is a functional option for configuring FindOneAndUpdate operations.
-type FindOneAndUpdateOpt func(*options.FindOneAndUpdateOptionsBuilder)
+// CountOpt configures CountDocuments.
+//
+// Deprecated: Use Opt.
+type CountOpt = Opt
 
-// WithSortFindAndUpdate sets the sort specification for FindOneAndUpdate.
-func WithSortFindAndUpdate(sort interface{}) FindOneAndUpdateOpt {
-	return func(o *options.FindOneAndUpdateOptionsBuilder) {
-		o.SetSort(sort)
-	}
-}
+// DistinctOpt configures DistinctOf.
+//
+// Deprecated: Use Opt.
+type DistinctOpt = Opt
 
-// WithProjectionFindAndUpdate sets the projection for FindOneAndUpdate.
-func WithProjectionFindAndUpdate(projection interface{}) FindOneAndUpdateOpt {
-	return func(o *options.FindOneAndUpdateOptionsBuilder) {
-		o.SetProjection(projection)
-	}
-}
+// AggregateOpt configures aggregations.
+//
+// Deprecated: Use Opt.
+type AggregateOpt = Opt
 
-// WithHintFindAndUpdate sets the hint for FindOneAndUpdate.
-func WithHintFindAndUpdate(hint interface{}) FindOneAndUpdateOpt {
-	return func(o *options.FindOneAndUpdateOptionsBuilder) {
-		o.SetHint(hint)
-	}
-}
+// CursorOpt configures FindCursor, FindIter, AggregateCursor and AggregateIter.
+//
+// Deprecated: Use Opt.
+type CursorOpt = Opt
 
-// WithReturnDocument specifies whether to return the original or the updated document.
+// WithUpsertReplace sets the upsert flag for ReplaceOne.
 //
-// Example:
+// Deprecated: Use WithUpsert.
+func WithUpsertReplace(upsert bool) Opt { return WithUpsert(upsert) }
+
+// WithUpsertMany sets the upsert flag for UpdateMany.
 //
-//	coll.FindOneAndUpdate(ctx, filter, update, gmqb.WithReturnDocument(options.After))
-func WithReturnDocument(rd options.ReturnDocument) FindOneAndUpdateOpt {
-	return func(o *options.FindOneAndUpdateOptionsBuilder) {
-		o.SetReturnDocument(rd)
-	}
-}
+// Deprecated: Use WithUpsert.
+func WithUpsertMany(upsert bool) Opt { return WithUpsert(upsert) }
 
-// WithUpsertFindAndUpdate sets the upsert flag for FindOneAndUpdate.
-func WithUpsertFindAndUpdate(upsert bool) FindOneAndUpdateOpt {
-	return func(o *options.FindOneAndUpdateOptionsBuilder) {
-		o.SetUpsert(upsert)
-	}
-}
+// WithSortFindAndDelete sets the sort specification for FindOneAndDelete.
+//
+// Deprecated: Use WithSort.
+func WithSortFindAndDelete(sort interface{}) Opt { return WithSort(sort) }
 
-// buildFindOneAndUpdateOpts applies functional options to a FindOneAndUpdateOptionsBuilder.
-func buildFindOneAndUpdateOpts(opts []FindOneAndUpdateOpt) *options.FindOneAndUpdateOptionsBuilder {
-	o := options.FindOneAndUpdate()
-	for _, opt := range opts {
-		opt(o)
-	}
-	return o
-}
+// WithProjectionFindAndDelete sets the projection for FindOneAndDelete.
+//
+// Deprecated: Use WithProjection.
+func WithProjectionFindAndDelete(projection interface{}) Opt { return WithProjection(projection) }
 
-// --- FindOneAndReplace Options ---
+// WithHintFindAndDelete sets the hint for FindOneAndDelete.
+//
+// Deprecated: Use WithHint.
+func WithHintFindAndDelete(hint interface{}) Opt { return WithHint(hint) }
 
-// FindOneAndReplaceOpt is a functional option for configuring FindOneAndReplace operations.
-type FindOneAndReplaceOpt func(*options.FindOneAndReplaceOptionsBuilder)
+// WithReturnDocumentDelete does nothing: FindOneAndDelete always returns the
+// document as it was before deletion.
+//
+// Deprecated: Omit it.
+func WithReturnDocumentDelete(rd options.ReturnDocument) Opt { return func(*opConfig) {} }
 
-// WithSortFindAndReplace sets the sort specification for FindOneAndReplace.
-func WithSortFindAndReplace(sort interface{}) FindOneAndReplaceOpt {
-	return func(o *options.FindOneAndReplaceOptionsBuilder) {
-		o.SetSort(sort)
-	}
-}
+// WithSortFindAndUpdate sets the sort specification for FindOneAndUpdate.
+//
+// Deprecated: Use WithSort.
+func WithSortFindAndUpdate(sort interface{}) Opt { return WithSort(sort) }
 
-// WithProjectionFindAndReplace sets the projection for FindOneAndReplace.
-func WithProjectionFindAndReplace(projection interface{}) FindOneAndReplaceOpt {
-	return func(o *options.FindOneAndReplaceOptionsBuilder) {
-		o.SetProjection(projection)
-	}
-}
+// WithProjectionFindAndUpdate sets the projection for FindOneAndUpdate.
+//
+// Deprecated: Use WithProjection.
+func WithProjectionFindAndUpdate(projection interface{}) Opt { return WithProjection(projection) }
 
-// WithHintFindAndReplace sets the hint for FindOneAndReplace.
-func WithHintFindAndReplace(hint interface{}) FindOneAndReplaceOpt {
-	return func(o *options.FindOneAndReplaceOptionsBuilder) {
-		o.SetHint(hint)
-	}
-}
+// WithHintFindAndUpdate sets the hint for FindOneAndUpdate.
+//
+// Deprecated: Use WithHint.
+func WithHintFindAndUpdate(hint interface{}) Opt { return WithHint(hint) }
 
-// WithReturnDocumentReplace specifies whether to return the original or the replaced document.
+// WithUpsertFindAndUpdate sets the upsert flag for FindOneAndUpdate.
 //
-// Example:
+// Deprecated: Use WithUpsert.
+func WithUpsertFindAndUpdate(upsert bool) Opt { return WithUpsert(upsert) }
+
+// WithSortFindAndReplace sets the sort specification for FindOneAndReplace.
 //
-//	coll.FindOneAndReplace(ctx, filter, replace, gmqb.WithReturnDocumentReplace(options.After))
-func WithReturnDocumentReplace(rd options.ReturnDocument) FindOneAndReplaceOpt {
-	return func(o *options.FindOneAndReplaceOptionsBuilder) {
-		o.SetReturnDocument(rd)
-	}
-}
+// Deprecated: Use WithSort.
+func WithSortFindAndReplace(sort interface{}) Opt { return WithSort(sort) }
 
-// WithUpsertFindAndReplace sets the upsert flag for FindOneAndReplace.
-func WithUpsertFindAndReplace(upsert bool) FindOneAndReplaceOpt {
-	return func(o *options.FindOneAndReplaceOptionsBuilder) {
-		o.SetUpsert(upsert)
-	}
-}
+// WithProjectionFindAndReplace sets the projection for FindOneAndReplace.
+//
+// Deprecated: Use WithProjection.
+func WithProjectionFindAndReplace(projection interface{}) Opt { return WithProjection(projection) }
 
-// buildFindOneAndReplaceOpts applies functional options to a FindOneAndReplaceOptionsBuilder.
-func buildFindOneAndReplaceOpts(opts []FindOneAndReplaceOpt) *options.FindOneAndReplaceOptionsBuilder {
-	o := options.FindOneAndReplace()
-	for _, opt := range opts {
-		opt(o)
-	}
-	return o
-}
+// WithHintFindAndReplace sets the hint for FindOneAndReplace.
+//
+// Deprecated: Use WithHint.
+func WithHintFindAndReplace(hint interface{}) Opt { return WithHint(hint) }
 
-// --- Count Options ---
+// WithReturnDocumentReplace specifies whether FindOneAndReplace returns the
+// original or the replaced document.
+//
+// Deprecated: Use WithReturnDocument.
+func WithReturnDocumentReplace(rd options.ReturnDocument) Opt { return WithReturnDocument(rd) }
 
-// CountOpt is a functional option for configuring count operations.
-type CountOpt func(*options.CountOptionsBuilder)
+// WithUpsertFindAndReplace sets the upsert flag for FindOneAndReplace.
+//
+// Deprecated: Use WithUpsert.
+func WithUpsertFindAndReplace(upsert bool) Opt { return WithUpsert(upsert) }
 
 // WithLimitCount sets the maximum number of documents to count.
-func WithLimitCount(n int64) CountOpt {
-	return func(o *options.CountOptionsBuilder) {
-		o.SetLimit(n)
-	}
-}
+//
+// Deprecated: Use WithLimit.
+func WithLimitCount(n int64) Opt { return WithLimit(n) }
 
 // WithSkipCount sets the number of documents to skip before counting.
-func WithSkipCount(n int64) CountOpt {
-	return func(o *options.CountOptionsBuilder) {
-		o.SetSkip(n)
-	}
-}
+//
+// Deprecated: Use WithSkip.
+func WithSkipCount(n int64) Opt { return WithSkip(n) }
 
-// buildCountOpts applies functional options to a CountOptionsBuilder.
-func buildCountOpts(opts []CountOpt) *options.CountOptionsBuilder {
-	o := options.Count()
-	for _, opt := range opts {
-		opt(o)
-	}
-	return o
-}
+// WithCollationDistinct sets the collation for a distinct operation.
+//
+// Deprecated: Use WithCollation.
+func WithCollationDistinct(collation *options.Collation) Opt { return WithCollation(collation) }
 
+// WithHintDistinct sets the index hint for a distinct operation.
+//
+// Deprecated: Use WithHint.
+func WithHintDistinct(hint interface{}) Opt { return WithHint(hint) }
@@ -1,17 +1,147 @@
 package gmqb
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
-func TestOptions_UpdateMany(t *testing.T) {
-	opts := buildUpdateManyOpts([]UpdateManyOpt{WithUpsertMany(true)})
-	assert.NotNil(t, opts)
+func TestOptions_AnyOperation(t *testing.T) {
+	en := &options.Collation{Locale: "en", Strength: 2}
+	cfg, err := buildOpts(OpFindOne, []Opt{WithSort(Asc("age")), WithHint("age_1"), WithCollation(en), WithComment("c"), WithSkip(2)})
+	require.NoError(t, err)
+
+	fo := resolveOptions[options.FindOneOptions](driverOptions[options.FindOneOptions](cfg))
+	assert.Equal(t, Asc("age"), fo.Sort)
+	assert.Equal(t, "age_1", fo.Hint)
+	assert.Same(t, en, fo.Collation)
+	assert.Equal(t, "c", fo.Comment)
+	assert.Equal(t, int64(2), *fo.Skip)
+
+	cfg, err = buildOpts(OpDeleteMany, []Opt{WithHint("age_1"), WithCollation(en)})
+	require.NoError(t, err)
+	do := resolveOptions[options.DeleteManyOptions](driverOptions[options.DeleteManyOptions](cfg))
+	assert.Equal(t, "age_1", do.Hint)
+	assert.Same(t, en, do.Collation)
+
+	cfg, err = buildOpts(OpCountDocuments, []Opt{WithLimit(5), WithSkip(1)})
+	require.NoError(t, err)
+	co := resolveOptions[options.CountOptions](driverOptions[options.CountOptions](cfg))
+	assert.Equal(t, int64(5), *co.Limit)
+	assert.Equal(t, int64(1), *co.Skip)
+
+	cfg, err = buildOpts(OpFindOneAndUpdate, []Opt{WithUpsert(true), WithReturnDocument(options.After), WithProjection(Include("name"))})
+	require.NoError(t, err)
+	uo := resolveOptions[options.FindOneAndUpdateOptions](driverOptions[options.FindOneAndUpdateOptions](cfg))
+	assert.True(t, *uo.Upsert)
+	assert.Equal(t, options.After, *uo.ReturnDocument)
+	assert.Equal(t, Include("name"), uo.Projection)
+
+	cfg, err = buildOpts(OpAggregate, []Opt{WithBatchSize(100), WithAllowDiskUse(true), WithMaxTime(time.Minute), WithValidation()})
+	require.NoError(t, err)
+	ao := resolveOptions[options.AggregateOptions](driverOptions[options.AggregateOptions](cfg))
+	assert.Equal(t, int32(100), *ao.BatchSize)
+	assert.True(t, *ao.AllowDiskUse)
+	assert.True(t, cfg.validate)
+	assert.False(t, cfg.deadline().IsZero())
+
+	assert.Empty(t, driverOptions[options.FindOptions](opConfig{}).List())
+}
+
+func TestOptions_NotApplicable(t *testing.T) {
+	tests := []struct {
+		op   Operation
+		opts []Opt
+		name string
+	}{
+		{OpFindOne, []Opt{WithLimit(5)}, "WithLimit"},
+		{OpInsertOne, []Opt{WithComment("c"), WithHint("a_1")}, "WithHint"},
+		{OpUpdateMany, []Opt{WithSort(Asc("a"))}, "WithSort"},
+		{OpFindOneAndDelete, []Opt{WithUpsert(true)}, "WithUpsert"},
+		{OpFind, []Opt{WithValidation()}, "WithValidation"},
+		{OpDistinct, []Opt{WithProjection(Include("a"))}, "WithProjection"},
+		{OpBulkWrite, []Opt{WithReturnDocument(options.After)}, "WithReturnDocument"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.op)+"/"+tt.name, func(t *testing.T) {
+			_, err := buildOpts(tt.op, tt.opts)
+			assert.ErrorIs(t, err, ErrInvalidOption)
+			assert.EqualError(t, err, "gmqb: invalid option: "+tt.name+" does not apply to "+string(tt.op))
+		})
+	}
 }
 
-func TestOptions_BulkWrite(t *testing.T) {
-	opts := buildBulkWriteOpts([]BulkWriteOpt{WithOrdered(false)})
-	assert.NotNil(t, opts)
+func TestOptions_Rejected(t *testing.T) {
+	// Options are checked before the server is contacted.
+	coll := Wrap[bson.M](offlineCollection(t))
+	ctx := context.Background()
+
+	_, err := coll.FindOne(ctx, NewFilter(), WithLimit(1))
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	_, err = coll.InsertOne(ctx, &bson.M{"a": 1}, WithUpsert(true))
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	_, err = coll.DeleteMany(ctx, Eq("a", 1), WithProjection(Include("a")))
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	_, err = Aggregate[bson.M](coll, ctx, NewPipeline().Match(Eq("a", 1)), WithSort(Asc("a")))
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	_, err = coll.ExplainCount(ctx, NewFilter(), ExplainQueryPlanner, WithSort(Asc("a")))
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorContains(t, coll.Distinct(ctx, "a", NewFilter(), WithLimit(1)).Err(), "WithLimit does not apply to distinct")
+}
+
+func TestOptions_Deprecated(t *testing.T) {
+	cfg, err := buildOpts(OpFindOneAndReplace, []Opt{
+		WithSortFindAndReplace(Asc("a")),
+		WithProjectionFindAndReplace(Include("a")),
+		WithHintFindAndReplace("a_1"),
+		WithUpsertFindAndReplace(true),
+		WithReturnDocumentReplace(options.After),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, optSort|optProjection|optHint|optUpsert|optReturnDocument, cfg.set)
+
+	cfg, err = buildOpts(OpCountDocuments, []Opt{WithLimitCount(3), WithSkipCount(1)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *cfg.limit)
+	assert.Equal(t, int64(1), *cfg.skip)
+
+	// FindOneAndDelete always returns the deleted document.
+	cfg, err = buildOpts(OpFindOneAndDelete, []Opt{WithReturnDocumentDelete(options.After)})
+	require.NoError(t, err)
+	assert.Zero(t, cfg.set)
+}
+
+func TestOptions_CacheKeys(t *testing.T) {
+	key := func(op Operation, opts ...Opt) string {
+		cfg, err := buildOpts(op, opts)
+		require.NoError(t, err)
+		k, err := driverOptsKey[options.FindOptions](context.Background(), cfg)
+		require.NoError(t, err)
+		return k
+	}
+	assert.NotEqual(t, key(OpFind, WithSort(Asc("age")), WithLimit(1)), key(OpFind, WithSort(Asc("age"))))
+	assert.NotEqual(t, key(OpDistinct, WithHint("country_1")), key(OpDistinct))
+
+	// The CallOpts of the context take part, overridden by the call's options.
+	ctxKey := func(ctx context.Context, opts ...Opt) string {
+		cfg, err := buildOpts(OpAggregate, opts)
+		require.NoError(t, err)
+		k, err := driverOptsKey[options.AggregateOptions](ctx, cfg)
+		require.NoError(t, err)
+		return k
+	}
+	ctx := context.Background()
+	letA := WithCallOpts(ctx, CallOpts{Let: bson.D{{Key: "x", Value: 1}}})
+	letB := WithCallOpts(ctx, CallOpts{Let: bson.D{{Key: "x", Value: 2}}})
+	assert.NotEqual(t, ctxKey(letA), ctxKey(letB))
+	assert.NotEqual(t, ctxKey(ctx), ctxKey(letA))
+	allow := true
+	disk := WithCallOpts(ctx, CallOpts{AllowDiskUse: &allow})
+	assert.NotEqual(t, ctxKey(ctx), ctxKey(disk))
+	assert.Equal(t, ctxKey(ctx, WithAllowDiskUse(false)), ctxKey(disk, WithAllowDiskUse(false)))
 }
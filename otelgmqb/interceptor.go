@@ -0,0 +1,118 @@
+package otelgmqb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/squall-chua/gmqb"
+)
+
+// Attribute keys specific to gmqb.
+const (
+	// CacheResultKey records whether a CachedCollection read was a "hit" or
+	// a "miss".
+	CacheResultKey = attribute.Key("gmqb.cache.result")
+	// UpdateKey records the shape of an update document or pipeline.
+	UpdateKey = attribute.Key("gmqb.update")
+)
+
+// Interceptor returns a gmqb.Interceptor that wraps every operation in a
+// client span and records its duration in the db.client.operation.duration
+// histogram. Reads served by a CachedCollection are tagged with their cache
+// result and counted in gmqb.cache.requests.
+//
+// Spans follow the OpenTelemetry database conventions: they are named
+// "<operation> <collection>" and db.query.text holds the filter or pipeline
+// shape. Place the interceptor first so its span covers the others.
+//
+// Example:
+//
+//	coll := gmqb.WrapWithCache[User](db.Collection("users"), cache, time.Minute,
+//	    gmqb.WithInterceptors(otelgmqb.Interceptor(otelgmqb.WithTracerProvider(tp))),
+//	)
+func Interceptor(opts ...Option) gmqb.Interceptor {
+	cfg := newConfig(opts)
+	tracer := cfg.tracer()
+	meter := cfg.meter()
+
+	duration, err := meter.Float64Histogram("db.client.operation.duration",
+		metric.WithDescription("Duration of database client operations."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10))
+	if err != nil {
+		otel.Handle(err)
+	}
+	cacheRequests, err := meter.Int64Counter("gmqb.cache.requests",
+		metric.WithDescription("CachedCollection reads by cache result."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return func(ctx context.Context, op *gmqb.OpInfo, next gmqb.Invoker) error {
+		attrs := []attribute.KeyValue{
+			semconv.DBSystemNameMongoDB,
+			semconv.DBNamespace(op.Database),
+			semconv.DBCollectionName(op.Collection),
+			semconv.DBOperationName(string(op.Operation)),
+		}
+		ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", op.Operation, op.Collection),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...),
+			trace.WithAttributes(cfg.queryAttributes(op)...))
+		defer span.End()
+
+		start := time.Now()
+		err := next(ctx, op)
+		elapsed := time.Since(start).Seconds()
+
+		if op.Cache != "" {
+			span.SetAttributes(CacheResultKey.String(string(op.Cache)))
+			cacheRequests.Add(ctx, 1, metric.WithAttributes(
+				semconv.DBNamespace(op.Database),
+				semconv.DBCollectionName(op.Collection),
+				CacheResultKey.String(string(op.Cache))))
+		}
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			attrs = append(attrs, semconv.ErrorTypeKey.String(fmt.Sprintf("%T", err)))
+		}
+		duration.Record(ctx, elapsed, metric.WithAttributes(attrs...))
+		return err
+	}
+}
+
+// queryAttributes describes the operation's filter or pipeline and its
+// update document.
+func (c config) queryAttributes(op *gmqb.OpInfo) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	switch {
+	case !op.Pipeline.IsEmpty():
+		attrs = append(attrs, semconv.DBQueryText(c.render(op.Pipeline)))
+	case !op.Filter.IsEmpty():
+		attrs = append(attrs, semconv.DBQueryText(c.render(op.Filter)))
+	}
+	if op.Update != nil && !op.Update.IsEmpty() {
+		attrs = append(attrs, UpdateKey.String(c.render(op.Update)))
+	}
+	return attrs
+}
+
+// render returns v as compact JSON, or its shape unless raw queries are on.
+func (c config) render(v any) string {
+	if s, ok := v.(interface{ CompactJSON() string }); ok && c.rawQueries {
+		return s.CompactJSON()
+	}
+	return gmqb.QueryShape(v)
+}
@@ -0,0 +1,99 @@
+package otelgmqb
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/squall-chua/gmqb"
+)
+
+// messagingSystem is the messaging.system value of gmqb spans.
+const messagingSystem = "gmqb"
+
+// messageObserver implements gmqb.MessageObserver with spans.
+type messageObserver struct {
+	cfg    config
+	tracer trace.Tracer
+}
+
+// MessageObserver returns a gmqb.MessageObserver that creates a producer
+// span for every message enqueued or published and a consumer span for every
+// message handled or delivered. The producer's trace context is stored in
+// the message headers, and each consumer span links to it.
+//
+// Example:
+//
+//	q, err := gmqb.NewQueue[Job](db, "jobs", gmqb.QueueOpts{
+//	    Observer: otelgmqb.MessageObserver(),
+//	})
+//	bus, err := gmqb.NewTailablePubSub[Event](db, "events", gmqb.CappedOpts{
+//	    SizeBytes: 1 << 20,
+//	    Observer:  otelgmqb.MessageObserver(),
+//	})
+func MessageObserver(opts ...Option) gmqb.MessageObserver {
+	cfg := newConfig(opts)
+	return &messageObserver{cfg: cfg, tracer: cfg.tracer()}
+}
+
+// Send starts a producer span and injects its context into m.Headers.
+func (o *messageObserver) Send(ctx context.Context, m *gmqb.MessageInfo) (context.Context, func(error)) {
+	ctx, span := o.tracer.Start(ctx, "send "+m.Destination,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messageAttributes(m, semconv.MessagingOperationTypeSend)...))
+	if m.Headers != nil {
+		o.cfg.propagator.Inject(ctx, propagation.MapCarrier(m.Headers))
+	}
+	return ctx, endSpan(span)
+}
+
+// Receive starts a consumer span linked to the producer span recorded in
+// m.Headers. Queue messages are processed by the handler; pub/sub events are
+// received onto the subscription channel.
+func (o *messageObserver) Receive(ctx context.Context, m *gmqb.MessageInfo) (context.Context, func(error)) {
+	opType, name := semconv.MessagingOperationTypeProcess, "process "
+	if m.Kind == gmqb.MessagePubSub {
+		opType, name = semconv.MessagingOperationTypeReceive, "receive "
+	}
+	startOpts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messageAttributes(m, opType)...),
+	}
+	producer := o.cfg.propagator.Extract(context.Background(), propagation.MapCarrier(m.Headers))
+	if link := trace.LinkFromContext(producer); link.SpanContext.IsValid() {
+		startOpts = append(startOpts, trace.WithLinks(link))
+	}
+	ctx, span := o.tracer.Start(ctx, name+m.Destination, startOpts...)
+	return ctx, endSpan(span)
+}
+
+// messageAttributes describes m following the messaging conventions.
+func messageAttributes(m *gmqb.MessageInfo, opType attribute.KeyValue) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemKey.String(messagingSystem),
+		semconv.MessagingDestinationName(m.Destination),
+		opType,
+	}
+	if m.ID != "" {
+		attrs = append(attrs, semconv.MessagingMessageID(m.ID))
+	}
+	if m.Group != "" {
+		attrs = append(attrs, semconv.MessagingConsumerGroupName(m.Group))
+	}
+	return attrs
+}
+
+// endSpan returns a function that records err on span and ends it.
+func endSpan(span trace.Span) func(error) {
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,98 @@
+// Package otelgmqb instruments gmqb with OpenTelemetry traces and metrics.
+//
+// Instrumentation is opt-in: Interceptor traces and times Collection and
+// CachedCollection operations, and MessageObserver traces Queue and
+// TailablePubSub messages, propagating trace context through the stored
+// documents so consumer spans link to the producer span.
+//
+// Spans record the query shape rendered by gmqb.QueryShape, never the values
+// in a filter or pipeline, unless WithRawQueries is set.
+//
+// Example:
+//
+//	users := gmqb.Wrap[User](db.Collection("users"),
+//	    gmqb.WithInterceptors(otelgmqb.Interceptor()),
+//	)
+//	q, err := gmqb.NewQueue[Job](db, "jobs", gmqb.QueueOpts{
+//	    Observer: otelgmqb.MessageObserver(),
+//	})
+package otelgmqb
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer and meter of this package.
+const instrumentationName = "github.com/squall-chua/gmqb/otelgmqb"
+
+// Option configures Interceptor and MessageObserver.
+type Option func(*config)
+
+// config holds the settings applied by Option values.
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+	rawQueries     bool
+}
+
+// newConfig applies opts over the global providers and the W3C trace
+// context propagator.
+func newConfig(opts []Option) config {
+	cfg := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+		propagator:     propagation.TraceContext{},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// tracer returns the package tracer from the configured provider.
+func (c config) tracer() trace.Tracer {
+	return c.tracerProvider.Tracer(instrumentationName, trace.WithSchemaURL(semconv.SchemaURL))
+}
+
+// meter returns the package meter from the configured provider.
+func (c config) meter() metric.Meter {
+	return c.meterProvider.Meter(instrumentationName, metric.WithSchemaURL(semconv.SchemaURL))
+}
+
+// WithTracerProvider sets the TracerProvider used to create spans. The
+// default is the global provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the MeterProvider used to record metrics. The
+// default is the global provider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithPropagator sets the propagator that writes trace context into message
+// headers and reads it back. The default is W3C trace context.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// WithRawQueries records filters, updates and pipelines with their literal
+// values instead of their shape. Only enable it where span data may hold
+// the values users query by.
+func WithRawQueries() Option {
+	return func(c *config) {
+		c.rawQueries = true
+	}
+}
@@ -0,0 +1,186 @@
+package otelgmqb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/squall-chua/gmqb"
+	"github.com/squall-chua/gmqb/otelgmqb"
+)
+
+// offlineCollection returns a collection whose client never connects; the
+// stub interceptor below answers every operation instead of the server.
+func offlineCollection(t *testing.T) *mongo.Collection {
+	t.Helper()
+	client, err := mongo.Connect(options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+	return client.Database("shop").Collection("orders")
+}
+
+// stub answers an operation with result, marking it as a cache read if
+// cache is set.
+func stub(result any, cache gmqb.CacheResult, err error) gmqb.Interceptor {
+	return func(_ context.Context, op *gmqb.OpInfo, _ gmqb.Invoker) error {
+		op.Result = result
+		op.Cache = cache
+		return err
+	}
+}
+
+func newTracerProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exp := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)), exp
+}
+
+func attrMap(attrs []attribute.KeyValue) map[attribute.Key]string {
+	m := make(map[attribute.Key]string, len(attrs))
+	for _, kv := range attrs {
+		m[kv.Key] = kv.Value.Emit()
+	}
+	return m
+}
+
+func TestInterceptor_Spans(t *testing.T) {
+	ctx := context.Background()
+	tp, exp := newTracerProvider()
+	coll := gmqb.Wrap[bson.M](offlineCollection(t), gmqb.WithInterceptors(
+		otelgmqb.Interceptor(otelgmqb.WithTracerProvider(tp)),
+		stub([]bson.M{}, "", nil),
+	))
+
+	_, err := coll.Find(ctx, gmqb.And(gmqb.Eq("status", "paid"), gmqb.In("sku", "a", "b")))
+	require.NoError(t, err)
+	_, err = coll.UpdateOne(ctx, gmqb.Eq("_id", 7), gmqb.NewUpdate().Set("status", "shipped"))
+	require.NoError(t, err)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "find orders", spans[0].Name)
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind)
+	attrs := attrMap(spans[0].Attributes)
+	assert.Equal(t, "mongodb", attrs["db.system.name"])
+	assert.Equal(t, "shop", attrs["db.namespace"])
+	assert.Equal(t, "orders", attrs["db.collection.name"])
+	assert.Equal(t, "find", attrs["db.operation.name"])
+	assert.Equal(t, `{"$and":[{"status":{"$eq":"?"}},{"sku":{"$in":["?"]}}]}`, attrs["db.query.text"])
+
+	attrs = attrMap(spans[1].Attributes)
+	assert.Equal(t, `{"_id":{"$eq":"?"}}`, attrs["db.query.text"])
+	assert.Equal(t, `{"$set":{"status":"?"}}`, attrs["gmqb.update"])
+}
+
+func TestInterceptor_RawQueriesAndErrors(t *testing.T) {
+	ctx := context.Background()
+	tp, exp := newTracerProvider()
+	boom := errors.New("boom")
+	coll := gmqb.Wrap[bson.M](offlineCollection(t), gmqb.WithInterceptors(
+		otelgmqb.Interceptor(otelgmqb.WithTracerProvider(tp), otelgmqb.WithRawQueries()),
+		stub(nil, "", boom),
+	))
+
+	_, err := gmqb.Aggregate[bson.M](coll, ctx, gmqb.NewPipeline().Match(gmqb.Eq("status", "paid")))
+	require.ErrorIs(t, err, boom)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, `[{"$match":{"status":{"$eq":"paid"}}}]`, attrMap(spans[0].Attributes)["db.query.text"])
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	require.Len(t, spans[0].Events, 1)
+}
+
+func TestInterceptor_CacheMetrics(t *testing.T) {
+	ctx := context.Background()
+	tp, exp := newTracerProvider()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	var result gmqb.CacheResult
+	inner := func(_ context.Context, op *gmqb.OpInfo, _ gmqb.Invoker) error {
+		op.Result = int64(1)
+		op.Cache = result
+		return nil
+	}
+	coll := gmqb.Wrap[bson.M](offlineCollection(t), gmqb.WithInterceptors(
+		otelgmqb.Interceptor(otelgmqb.WithTracerProvider(tp), otelgmqb.WithMeterProvider(mp)),
+		inner,
+	))
+	for _, r := range []gmqb.CacheResult{gmqb.CacheMiss, gmqb.CacheHit, gmqb.CacheHit} {
+		result = r
+		_, err := coll.CountDocuments(ctx, gmqb.Eq("a", 1))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "miss", attrMap(exp.GetSpans()[0].Attributes)["gmqb.cache.result"])
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	counts := map[string]int64{}
+	var histCount uint64
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			assert.Equal(t, "gmqb.cache.requests", m.Name)
+			for _, dp := range data.DataPoints {
+				v, _ := dp.Attributes.Value("gmqb.cache.result")
+				counts[v.AsString()] = dp.Value
+			}
+		case metricdata.Histogram[float64]:
+			assert.Equal(t, "db.client.operation.duration", m.Name)
+			for _, dp := range data.DataPoints {
+				histCount += dp.Count
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{"hit": 2, "miss": 1}, counts)
+	assert.Equal(t, uint64(3), histCount)
+}
+
+func TestMessageObserver_LinksConsumerToProducer(t *testing.T) {
+	tp, exp := newTracerProvider()
+	obs := otelgmqb.MessageObserver(otelgmqb.WithTracerProvider(tp))
+
+	sent := &gmqb.MessageInfo{Kind: gmqb.MessageQueue, Destination: "jobs", ID: "65a1", Headers: map[string]string{}}
+	_, done := obs.Send(context.Background(), sent)
+	done(nil)
+	require.Contains(t, sent.Headers, "traceparent")
+
+	handled := &gmqb.MessageInfo{Kind: gmqb.MessageQueue, Destination: "jobs", ID: "65a1", Group: "billing", Headers: sent.Headers}
+	ctx, done := obs.Receive(context.Background(), handled)
+	assert.True(t, trace.SpanContextFromContext(ctx).IsValid())
+	done(errors.New("handler failed"))
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 2)
+	producer, consumer := spans[0], spans[1]
+	assert.Equal(t, "send jobs", producer.Name)
+	assert.Equal(t, trace.SpanKindProducer, producer.SpanKind)
+	assert.Equal(t, "process jobs", consumer.Name)
+	assert.Equal(t, trace.SpanKindConsumer, consumer.SpanKind)
+	assert.Equal(t, codes.Error, consumer.Status.Code)
+	assert.Equal(t, "billing", attrMap(consumer.Attributes)["messaging.consumer.group.name"])
+	require.Len(t, consumer.Links, 1)
+	assert.Equal(t, producer.SpanContext.SpanID(), consumer.Links[0].SpanContext.SpanID())
+	assert.Equal(t, producer.SpanContext.TraceID(), consumer.Links[0].SpanContext.TraceID())
+
+	// Messages stored before instrumentation carry no headers and no link.
+	_, done = obs.Receive(context.Background(), &gmqb.MessageInfo{Kind: gmqb.MessagePubSub, Destination: "events"})
+	done(nil)
+	last := exp.GetSpans()[2]
+	assert.Equal(t, "receive events", last.Name)
+	assert.Empty(t, last.Links)
+}
@@ -0,0 +1,433 @@
+package gmqb
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// PageMode selects how Paginate pages through results.
+type PageMode int
+
+const (
+	// KeysetPaging seeks past the last document of the previous page using a
+	// range filter on the sort fields. It is stable under concurrent inserts
+	// and costs the same for every page when the sort fields are indexed.
+	KeysetPaging PageMode = iota
+
+	// OffsetPaging skips (PageNumber-1)*Size documents and returns the total
+	// match count, using a single $facet aggregation.
+	OffsetPaging
+)
+
+// PageOpts configures Paginate.
+type PageOpts struct {
+	// Size is the maximum number of items per page. Required.
+	Size int64
+
+	// SortFields is the page order. An ascending _id tiebreaker is appended
+	// unless _id is already present, so the order is always total.
+	SortFields []SortField
+
+	// After is a Page.Next token: return the page following it (keyset mode).
+	After string
+
+	// Before is a Page.Prev token: return the page preceding it (keyset mode).
+	Before string
+
+	// Mode selects keyset (default) or offset paging.
+	Mode PageMode
+
+	// PageNumber is the 1-based page to return in offset mode. Zero means 1.
+	PageNumber int64
+
+	// Secret signs page tokens so that clients cannot forge or alter them.
+	// When empty, a random key generated at process start is used; tokens are
+	// then only valid within the same process. Set Secret when tokens must
+	// survive restarts or be shared between instances.
+	Secret []byte
+}
+
+// Page is one page of results returned by Paginate.
+type Page[T any] struct {
+	// Items holds the documents of this page in sort order. An empty page
+	// has no tokens and reports neither HasNext nor HasPrev.
+	Items []T
+
+	// Next is an opaque token for the following page, or "" if there is none.
+	// Pass it as PageOpts.After. Always "" in offset mode.
+	Next string
+
+	// Prev is an opaque token for the preceding page, or "" if there is none.
+	// Pass it as PageOpts.Before. Always "" in offset mode.
+	Prev string
+
+	// HasNext reports whether more documents follow this page.
+	HasNext bool
+
+	// HasPrev reports whether documents precede this page.
+	HasPrev bool
+
+	// Total is the number of documents matching the filter. Only set in
+	// offset mode.
+	Total int64
+}
+
+// itemCount returns the number of documents on the page.
+func (p *Page[T]) itemCount() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.Items)
+}
+
+// Paginate returns one page of documents matching filter.
+//
+// In keyset mode (the default) the page boundary is carried in opaque,
+// HMAC-signed tokens: Page.Next continues forward via PageOpts.After and
+// Page.Prev goes back via PageOpts.Before. For a sort on (a desc, b asc) with
+// the _id tiebreaker, the next page is selected with
+//
+//	{ $or: [
+//	    { a: { $lt: a0 } },
+//	    { a: a0, b: { $gt: b0 } },
+//	    { a: a0, b: b0, _id: { $gt: id0 } },
+//	] }
+//
+// where a0, b0, id0 are the values of the last document on the current page.
+// Ties on the sort fields are therefore never skipped or repeated. Range
+// operators compare within a BSON type only, so sort fields should be present
+// and of a consistent type in every document. Tokens are bound to the sort
+// order; a token issued for a different order returns ErrInvalidPageToken.
+//
+// In offset mode the page and total count are fetched in one round-trip:
+//
+//	[ { $match: filter }, { $sort: sort },
+//	  { $facet: { items: [ { $skip: n }, { $limit: size } ], total: [ { $count: "n" } ] } } ]
+//
+// See: https://www.mongodb.com/docs/manual/reference/method/cursor.skip/#using-range-queries
+//
+// Example:
+//
+//	page, err := gmqb.Paginate(ctx, coll, gmqb.Eq("status", "active"), gmqb.PageOpts{
+//	    Size:       20,
+//	    SortFields: []gmqb.SortField{gmqb.SortRule("createdAt", -1)},
+//	    After:      req.Cursor,
+//	    Secret:     cfg.PageSecret,
+//	})
+//	// respond with page.Items and page.Next
+func Paginate[T any](ctx context.Context, coll *Collection[T], filter Filter, opts PageOpts) (*Page[T], error) {
+	// Reject bad options and tokens before the interceptors run.
+	if _, err := resolvePage(opts); err != nil {
+		return nil, err
+	}
+	return intercept(coll, ctx, &OpInfo{Operation: OpPaginate, Filter: filter, Options: opts}, func(ctx context.Context, op *OpInfo) (*Page[T], error) {
+		opts, _ := op.Options.(PageOpts)
+		pos, err := resolvePage(opts)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Mode == OffsetPaging {
+			return paginateOffset(ctx, coll, op.Filter, pos.keys, opts)
+		}
+		return paginateKeyset(ctx, coll, op.Filter, pos, opts)
+	})
+}
+
+// pagePosition is the validated sort and, in keyset mode, the seek position
+// decoded from the page token.
+type pagePosition struct {
+	keys     []SortField
+	values   []interface{} // nil on the first page
+	backward bool
+	secret   []byte
+}
+
+// resolvePage validates opts and decodes its page token.
+func resolvePage(opts PageOpts) (pagePosition, error) {
+	var pos pagePosition
+	if opts.Size <= 0 {
+		return pos, fmt.Errorf("gmqb paginate: Size must be positive")
+	}
+	keys, err := pageSortKeys(opts.SortFields)
+	if err != nil {
+		return pos, err
+	}
+	pos.keys = keys
+	if opts.Mode == OffsetPaging {
+		if opts.After != "" || opts.Before != "" {
+			return pos, fmt.Errorf("gmqb paginate: After and Before are not supported in offset mode")
+		}
+		return pos, nil
+	}
+	if opts.After != "" && opts.Before != "" {
+		return pos, fmt.Errorf("gmqb paginate: After and Before are mutually exclusive")
+	}
+	pos.secret = pageSecret(opts.Secret)
+	pos.backward = opts.Before != ""
+	if token := opts.After + opts.Before; token != "" {
+		pos.values, err = decodePageToken(token, pos.secret, keys, pos.backward)
+		if err != nil {
+			return pos, err
+		}
+	}
+	return pos, nil
+}
+
+// pageSortKeys validates the sort fields and appends the _id tiebreaker.
+func pageSortKeys(fields []SortField) ([]SortField, error) {
+	keys := make([]SortField, 0, len(fields)+1)
+	hasID := false
+	for _, f := range fields {
+		if f.Field == "" || (f.Order != 1 && f.Order != -1) {
+			return nil, fmt.Errorf("gmqb paginate: invalid sort field %q with order %d", f.Field, f.Order)
+		}
+		hasID = hasID || f.Field == "_id"
+		keys = append(keys, f)
+	}
+	if !hasID {
+		keys = append(keys, SortField{Field: "_id", Order: 1})
+	}
+	return keys, nil
+}
+
+// paginateKeyset implements KeysetPaging.
+func paginateKeyset[T any](ctx context.Context, coll *Collection[T], filter Filter, pos pagePosition, opts PageOpts) (*Page[T], error) {
+	keys, secret, backward := pos.keys, pos.secret, pos.backward
+	query := filter.BsonD()
+	if pos.values != nil {
+		seek := seekFilter(keys, pos.values, backward)
+		if len(query) > 0 {
+			query = bson.D{{Key: "$and", Value: bson.A{query, seek}}}
+		} else {
+			query = seek
+		}
+	}
+
+	sort := SortSpec(keys...)
+	if backward {
+		sort = SortSpec(reverseSort(keys)...)
+	}
+	cur, err := coll.collFor(ctx).Find(ctx, query, callOptions[options.FindOptions](ctx), options.Find().SetSort(sort).SetLimit(opts.Size+1))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var items []T
+	var bounds [][]interface{}
+	for cur.Next(ctx) {
+		var item T
+		if err := cur.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		bounds = append(bounds, sortValuesOf(cur.Current, keys))
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	more := int64(len(items)) > opts.Size
+	if more {
+		items, bounds = items[:opts.Size], bounds[:opts.Size]
+	}
+	if backward {
+		reverseInPlace(items)
+		reverseInPlace(bounds)
+	}
+	page := &Page[T]{Items: items}
+	if backward {
+		page.HasPrev, page.HasNext = more, true
+	} else {
+		page.HasNext, page.HasPrev = more, opts.After != ""
+	}
+	if len(items) == 0 {
+		// Paged past either end of the results: there is no boundary
+		// document to issue tokens from.
+		page.HasNext, page.HasPrev = false, false
+		return page, nil
+	}
+	if page.HasNext {
+		page.Next = encodePageToken(secret, keys, bounds[len(bounds)-1], false)
+	}
+	if page.HasPrev {
+		page.Prev = encodePageToken(secret, keys, bounds[0], true)
+	}
+	return page, nil
+}
+
+// paginateOffset implements OffsetPaging with a single $facet aggregation.
+func paginateOffset[T any](ctx context.Context, coll *Collection[T], filter Filter, keys []SortField, opts PageOpts) (*Page[T], error) {
+	pageNo := max(opts.PageNumber, 1)
+	skip := (pageNo - 1) * opts.Size
+	p := NewPipeline().
+		Match(filter).
+		Sort(SortSpec(keys...)).
+		Facet(map[string]Pipeline{
+			"items": NewPipeline().Skip(skip).Limit(opts.Size),
+			"total": NewPipeline().Count("n"),
+		})
+	cur, err := coll.collFor(ctx).Aggregate(ctx, p.BsonD(), callOptions[options.AggregateOptions](ctx))
+	if err != nil {
+		return nil, err
+	}
+	var res []struct {
+		Items []T `bson:"items"`
+		Total []struct {
+			N int64 `bson:"n"`
+		} `bson:"total"`
+	}
+	if err := cur.All(ctx, &res); err != nil {
+		return nil, err
+	}
+	page := &Page[T]{HasPrev: pageNo > 1}
+	if len(res) > 0 {
+		page.Items = res[0].Items
+		if len(res[0].Total) > 0 {
+			page.Total = res[0].Total[0].N
+		}
+	}
+	page.HasNext = skip+int64(len(page.Items)) < page.Total
+	return page, nil
+}
+
+// seekFilter builds the keyset range filter selecting documents strictly after
+// (or, backward, strictly before) the boundary values in sort order.
+func seekFilter(keys []SortField, values []interface{}, backward bool) bson.D {
+	branches := make(bson.A, 0, len(keys))
+	for i, k := range keys {
+		branch := make(bson.D, 0, i+1)
+		for j := 0; j < i; j++ {
+			branch = append(branch, bson.E{Key: keys[j].Field, Value: values[j]})
+		}
+		op := "$gt"
+		if (k.Order == -1) != backward {
+			op = "$lt"
+		}
+		branch = append(branch, bson.E{Key: k.Field, Value: bson.D{{Key: op, Value: values[i]}}})
+		branches = append(branches, branch)
+	}
+	if len(branches) == 1 {
+		return branches[0].(bson.D)
+	}
+	return bson.D{{Key: "$or", Value: branches}}
+}
+
+// reverseSort inverts every sort direction.
+func reverseSort(keys []SortField) []SortField {
+	out := make([]SortField, len(keys))
+	for i, k := range keys {
+		out[i] = SortField{Field: k.Field, Order: -k.Order}
+	}
+	return out
+}
+
+func reverseInPlace[E any](s []E) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// sortValuesOf extracts the sort field values of a raw document. Absent
+// fields are recorded as null.
+func sortValuesOf(raw bson.Raw, keys []SortField) []interface{} {
+	out := make([]interface{}, len(keys))
+	for i, k := range keys {
+		v, err := raw.LookupErr(strings.Split(k.Field, ".")...)
+		if err != nil {
+			continue
+		}
+		out[i] = v
+	}
+	return out
+}
+
+// --- Page tokens ---
+
+// processPageSecret signs tokens when PageOpts.Secret is empty.
+var processPageSecret = func() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("gmqb: generating page token key: %v", err))
+	}
+	return b
+}()
+
+func pageSecret(secret []byte) []byte {
+	if len(secret) == 0 {
+		return processPageSecret
+	}
+	return secret
+}
+
+// sortFingerprint identifies a sort order so tokens cannot be replayed
+// against a different one.
+func sortFingerprint(keys []SortField) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.Field + ":" + strconv.Itoa(k.Order)
+	}
+	return strings.Join(parts, ",")
+}
+
+// pageToken is the signed payload of a page token.
+type pageToken struct {
+	Sort     string `bson:"s"`
+	Backward bool   `bson:"b"`
+	Values   bson.A `bson:"k"`
+}
+
+// encodePageToken returns base64url(BSON payload) + "." + base64url(HMAC-SHA256).
+func encodePageToken(secret []byte, keys []SortField, values []interface{}, backward bool) string {
+	payload, err := bson.Marshal(pageToken{Sort: sortFingerprint(keys), Backward: backward, Values: values})
+	if err != nil {
+		// Values were read from a BSON document, so they always marshal.
+		panic(fmt.Sprintf("gmqb: encoding page token: %v", err))
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+// decodePageToken verifies a token and returns its boundary values.
+func decodePageToken(token string, secret []byte, keys []SortField, backward bool) ([]interface{}, error) {
+	enc := base64.RawURLEncoding
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidPageToken)
+	}
+	payload, err1 := enc.DecodeString(body)
+	got, err2 := enc.DecodeString(sig)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidPageToken)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidPageToken)
+	}
+	var t pageToken
+	if err := bson.Unmarshal(payload, &t); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPageToken, err)
+	}
+	if t.Sort != sortFingerprint(keys) || len(t.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: issued for a different sort order", ErrInvalidPageToken)
+	}
+	if t.Backward != backward {
+		if backward {
+			return nil, fmt.Errorf("%w: a Next token cannot be used as Before", ErrInvalidPageToken)
+		}
+		return nil, fmt.Errorf("%w: a Prev token cannot be used as After", ErrInvalidPageToken)
+	}
+	return t.Values, nil
+}
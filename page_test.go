@@ -0,0 +1,140 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestSeekFilter(t *testing.T) {
+	keys, err := pageSortKeys([]SortField{SortRule("a", -1), SortRule("b", 1)})
+	require.NoError(t, err)
+	assert.Equal(t, []SortField{{"a", -1}, {"b", 1}, {"_id", 1}}, keys)
+
+	f := Filter{d: seekFilter(keys, []interface{}{5, "x", 9}, false)}
+	assert.Equal(t,
+		`{"$or":[{"a":{"$lt":5}},{"a":5,"b":{"$gt":"x"}},{"a":5,"b":"x","_id":{"$gt":9}}]}`,
+		f.CompactJSON())
+
+	f = Filter{d: seekFilter(keys, []interface{}{5, "x", 9}, true)}
+	assert.Equal(t,
+		`{"$or":[{"a":{"$gt":5}},{"a":5,"b":{"$lt":"x"}},{"a":5,"b":"x","_id":{"$lt":9}}]}`,
+		f.CompactJSON())
+
+	keys, err = pageSortKeys([]SortField{SortRule("_id", -1)})
+	require.NoError(t, err)
+	assert.Equal(t, `{"_id":{"$lt":3}}`, Filter{d: seekFilter(keys, []interface{}{3}, false)}.CompactJSON())
+
+	_, err = pageSortKeys([]SortField{SortRule("a", 2)})
+	assert.Error(t, err)
+}
+
+func TestPageToken(t *testing.T) {
+	keys := []SortField{{"createdAt", -1}, {"_id", 1}}
+	id := bson.NewObjectID()
+	secret := []byte("s3cret")
+	values := []interface{}{bson.DateTime(1700000000000), id}
+
+	token := encodePageToken(secret, keys, values, false)
+	got, err := decodePageToken(token, secret, keys, false)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{bson.DateTime(1700000000000), id}, got)
+
+	// Tampering with the payload or using another key is detected.
+	tampered := []byte(token)
+	tampered[3] ^= 1
+	for name, tc := range map[string]struct {
+		token  string
+		secret []byte
+		keys   []SortField
+		back   bool
+	}{
+		"tampered":     {string(tampered), secret, keys, false},
+		"wrong secret": {token, []byte("other"), keys, false},
+		"other sort":   {token, secret, []SortField{{"createdAt", 1}, {"_id", 1}}, false},
+		"direction":    {token, secret, keys, true},
+		"malformed":    {"not-a-token", secret, keys, false},
+	} {
+		_, err := decodePageToken(tc.token, tc.secret, tc.keys, tc.back)
+		assert.True(t, errors.Is(err, ErrInvalidPageToken), name)
+	}
+
+	// Without a Secret, tokens use the per-process key.
+	token = encodePageToken(pageSecret(nil), keys, values, true)
+	_, err = decodePageToken(token, pageSecret(nil), keys, true)
+	assert.NoError(t, err)
+}
+
+// TestSeekFilter_Walk pages through documents with heavy ties in both
+// directions using the in-memory engine and checks every document is visited
+// exactly once, in order.
+func TestSeekFilter_Walk(t *testing.T) {
+	var docs []bson.D
+	for i := 0; i < 23; i++ {
+		docs = append(docs, bson.D{{Key: "_id", Value: i}, {Key: "score", Value: i % 3}, {Key: "name", Value: string(rune('a' + i%2))}})
+	}
+	keys, err := pageSortKeys([]SortField{SortRule("score", -1), SortRule("name", 1)})
+	require.NoError(t, err)
+	const size = 4
+
+	fetch := func(values []interface{}, backward bool) []bson.D {
+		sort := keys
+		if backward {
+			sort = reverseSort(keys)
+		}
+		p := NewPipeline()
+		if values != nil {
+			p = p.Match(Filter{d: seekFilter(keys, values, backward)})
+		}
+		out, err := RunPipeline(docs, p.Sort(SortSpec(sort...)).Limit(size))
+		require.NoError(t, err)
+		if backward {
+			reverseInPlace(out)
+		}
+		return out
+	}
+	bound := func(d bson.D) []interface{} {
+		raw, err := bson.Marshal(d)
+		require.NoError(t, err)
+		return sortValuesOf(raw, keys)
+	}
+
+	all, err := RunPipeline(docs, NewPipeline().Sort(SortSpec(keys...)))
+	require.NoError(t, err)
+
+	var forward []bson.D
+	var pages [][]bson.D
+	for page := fetch(nil, false); len(page) > 0; page = fetch(bound(page[len(page)-1]), false) {
+		forward = append(forward, page...)
+		pages = append(pages, page)
+	}
+	assert.Equal(t, all, forward)
+
+	var backward []bson.D
+	for page := pages[len(pages)-1]; len(page) > 0; page = fetch(bound(page[0]), true) {
+		backward = append(append([]bson.D{}, page...), backward...)
+	}
+	assert.Equal(t, all, backward)
+}
+
+func TestPaginate_Validation(t *testing.T) {
+	ctx := context.Background()
+	_, err := Paginate[bson.D](ctx, nil, NewFilter(), PageOpts{})
+	assert.ErrorContains(t, err, "Size must be positive")
+
+	_, err = Paginate[bson.D](ctx, nil, NewFilter(), PageOpts{Size: 1, SortFields: []SortField{{"a", 0}}})
+	assert.ErrorContains(t, err, "invalid sort field")
+
+	_, err = Paginate[bson.D](ctx, nil, NewFilter(), PageOpts{Size: 1, After: "x", Before: "y"})
+	assert.ErrorContains(t, err, "mutually exclusive")
+
+	_, err = Paginate[bson.D](ctx, nil, NewFilter(), PageOpts{Size: 1, Mode: OffsetPaging, After: "x"})
+	assert.ErrorContains(t, err, "offset mode")
+
+	_, err = Paginate[bson.D](ctx, nil, NewFilter(), PageOpts{Size: 1, After: "garbage"})
+	assert.True(t, errors.Is(err, ErrInvalidPageToken))
+}
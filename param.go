@@ -0,0 +1,476 @@
+package gmqb
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Parameter is a named placeholder for a value in a Filter, Updater or Pipeline.
+// Builders containing parameters are templates: they must be bound to concrete
+// values with Bind before they are sent to the server. An unbound Parameter
+// fails to marshal with ErrUnboundParam.
+type Parameter struct {
+	name string
+	list bool
+}
+
+// Param returns a placeholder that can be used anywhere a value is accepted.
+//
+// Example:
+//
+//	adults := gmqb.And(
+//	    gmqb.Gte("age", gmqb.Param("minAge")),
+//	    gmqb.In("country", gmqb.Param("country")),
+//	)
+//	f, err := adults.Bind(map[string]any{"minAge": 18, "country": "US"})
+//	// {"$and": [{"age": {"$gte": 18}}, {"country": {"$in": ["US"]}}]}
+func Param(name string) Parameter {
+	return Parameter{name: name}
+}
+
+// ParamList returns a placeholder for a list of values. Inside an array, such
+// as the values of In, Nin or All, the bound slice is spliced into the array
+// instead of being nested as a single element.
+//
+// Example:
+//
+//	tmpl := gmqb.In("status", gmqb.ParamList("statuses"))
+//	f, err := tmpl.Bind(map[string]any{"statuses": []string{"active", "pending"}})
+//	// {"status": {"$in": ["active", "pending"]}}
+func ParamList(name string) Parameter {
+	return Parameter{name: name, list: true}
+}
+
+// Name returns the parameter name.
+func (p Parameter) Name() string {
+	return p.name
+}
+
+// MarshalBSONValue implements bson.ValueMarshaler. It always fails, so a
+// template that was never bound cannot reach the server by accident.
+func (p Parameter) MarshalBSONValue() (byte, []byte, error) {
+	return 0, nil, fmt.Errorf("%w: %q", ErrUnboundParam, p.name)
+}
+
+// Params returns the sorted, de-duplicated names of all parameters in the filter.
+func (f Filter) Params() []string {
+	return paramNames(f.d)
+}
+
+// Bind returns a copy of the filter with every Param replaced by its value from
+// params. Missing parameters are reported together in an error wrapping
+// ErrUnboundParam; extra entries in params are ignored.
+//
+// Example:
+//
+//	tmpl := gmqb.Gte("age", gmqb.Param("minAge"))
+//	f, err := tmpl.Bind(map[string]any{"minAge": 21})
+func (f Filter) Bind(params map[string]any) (Filter, error) {
+	d, err := bindDoc(f.d, params)
+	if err != nil {
+		return Filter{}, err
+	}
+	return Filter{d: d}, nil
+}
+
+// Params returns the sorted, de-duplicated names of all parameters in the update.
+func (u Updater) Params() []string {
+	return paramNames(u.ops)
+}
+
+// Bind returns a copy of the update with every Param replaced by its value from
+// params. Missing parameters are reported in an error wrapping ErrUnboundParam.
+//
+// Example:
+//
+//	tmpl := gmqb.NewUpdate().Set("status", gmqb.Param("status")).Inc("version", 1)
+//	u, err := tmpl.Bind(map[string]any{"status": "archived"})
+func (u Updater) Bind(params map[string]any) (Updater, error) {
+	d, err := bindDoc(u.ops, params)
+	if err != nil {
+		return Updater{}, err
+	}
+	return Updater{ops: d}, nil
+}
+
+// Params returns the sorted, de-duplicated names of all parameters in the pipeline.
+func (p Pipeline) Params() []string {
+	return paramNames(p.stages)
+}
+
+// Bind returns a copy of the pipeline with every Param replaced by its value
+// from params, including inside $facet, $lookup and $unionWith sub-pipelines.
+// Missing parameters are reported in an error wrapping ErrUnboundParam.
+//
+// Example:
+//
+//	tmpl := gmqb.NewPipeline().
+//	    Match(gmqb.Eq("tenant", gmqb.Param("tenant"))).
+//	    Limit(100)
+//	p, err := tmpl.Bind(map[string]any{"tenant": "acme"})
+func (p Pipeline) Bind(params map[string]any) (Pipeline, error) {
+	v, err := bindParams(p.stages, params)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	return Pipeline{stages: v.([]bson.D)}, nil
+}
+
+// --- Templates ---
+
+// boundTemplate records which template a builder was bound from, so that
+// CachedCollection can key it without re-serializing the whole document.
+type boundTemplate struct {
+	key string
+}
+
+// FilterTemplate is a parameterized Filter prepared for repeated binding. Its
+// identity is computed once, so filters bound from it are cached by
+// CachedCollection under the template identity plus the parameter values.
+//
+// Example:
+//
+//	var byTenant = gmqb.NewFilterTemplate(gmqb.NewFilter().
+//	    Eq("tenant", gmqb.Param("tenant")).
+//	    Gte("createdAt", gmqb.Param("since")))
+//
+//	f, err := byTenant.Bind(map[string]any{"tenant": "acme", "since": since})
+//	users, err := cachedColl.Find(ctx, f)
+type FilterTemplate struct {
+	filter Filter
+	id     string
+	params []string
+}
+
+// NewFilterTemplate prepares f for repeated binding.
+func NewFilterTemplate(f Filter) FilterTemplate {
+	return FilterTemplate{
+		filter: f,
+		id:     templateID("filter", renderParams(f.d)),
+		params: f.Params(),
+	}
+}
+
+// Params returns the sorted names of the template's parameters.
+func (t FilterTemplate) Params() []string {
+	return t.params
+}
+
+// Filter returns the unbound template filter.
+func (t FilterTemplate) Filter() Filter {
+	return t.filter
+}
+
+// Bind returns the template filter with params substituted. See Filter.Bind.
+func (t FilterTemplate) Bind(params map[string]any) (Filter, error) {
+	f, err := t.filter.Bind(params)
+	if err != nil {
+		return Filter{}, err
+	}
+	f.bound = &boundTemplate{key: boundKey(t.id, t.params, params)}
+	return f, nil
+}
+
+// PipelineTemplate is a parameterized Pipeline prepared for repeated binding.
+// Pipelines bound from it are cached by CachedAggregate under the template
+// identity plus the parameter values.
+//
+// Example:
+//
+//	var topByCountry = gmqb.NewPipelineTemplate(gmqb.NewPipeline().
+//	    Match(gmqb.Eq("country", gmqb.Param("country"))).
+//	    Sort(gmqb.Desc("score")).
+//	    Limit(10))
+//
+//	p, err := topByCountry.Bind(map[string]any{"country": "DE"})
+type PipelineTemplate struct {
+	pipeline Pipeline
+	id       string
+	params   []string
+}
+
+// NewPipelineTemplate prepares p for repeated binding.
+func NewPipelineTemplate(p Pipeline) PipelineTemplate {
+	return PipelineTemplate{
+		pipeline: p,
+		id:       templateID("pipeline", bson.D{{Key: "pipeline", Value: renderParams(p.stages)}}),
+		params:   p.Params(),
+	}
+}
+
+// Params returns the sorted names of the template's parameters.
+func (t PipelineTemplate) Params() []string {
+	return t.params
+}
+
+// Pipeline returns the unbound template pipeline.
+func (t PipelineTemplate) Pipeline() Pipeline {
+	return t.pipeline
+}
+
+// Bind returns the template pipeline with params substituted. See Pipeline.Bind.
+func (t PipelineTemplate) Bind(params map[string]any) (Pipeline, error) {
+	p, err := t.pipeline.Bind(params)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	p.bound = &boundTemplate{key: boundKey(t.id, t.params, params)}
+	return p, nil
+}
+
+// templateID hashes the placeholder-rendered form of a template.
+func templateID(kind string, rendered interface{}) string {
+	raw, err := bson.MarshalExtJSON(rendered, true, false)
+	if err != nil {
+		// Values that cannot be serialized still get a usable identity; the
+		// template simply never shares cache entries with another template.
+		raw = []byte(fmt.Sprintf("%p", &rendered))
+	}
+	sum := sha256.Sum256(append([]byte(kind+":"), raw...))
+	return fmt.Sprintf("%x", sum)
+}
+
+// boundKey combines a template ID with the values of its parameters.
+func boundKey(id string, names []string, params map[string]any) string {
+	vals := make(bson.D, 0, len(names))
+	for _, n := range names {
+		vals = append(vals, bson.E{Key: n, Value: builderValue(params[n])})
+	}
+	raw, err := bson.MarshalExtJSON(vals, true, false)
+	if err != nil {
+		return ""
+	}
+	return id + ":" + string(raw)
+}
+
+// --- Substitution ---
+
+// bindDoc binds the parameters in a document.
+func bindDoc(d bson.D, params map[string]any) (bson.D, error) {
+	v, err := bindParams(d, params)
+	if err != nil {
+		return nil, err
+	}
+	out, _ := v.(bson.D)
+	return out, nil
+}
+
+// bindParams replaces every Parameter in v with its value, returning an error
+// listing all parameters missing from params.
+func bindParams(v interface{}, params map[string]any) (interface{}, error) {
+	var missing []string
+	out := substituteParams(v, func(p Parameter) interface{} {
+		val, ok := params[p.name]
+		if !ok {
+			missing = append(missing, p.name)
+			return p
+		}
+		return builderValue(val)
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnboundParam, strings.Join(dedupeSorted(missing), ", "))
+	}
+	return out, nil
+}
+
+// renderParams replaces every Parameter with a {"$param": name} document
+// ({"$paramList": name} for ParamList), giving templates a printable and
+// hashable form.
+func renderParams(v interface{}) interface{} {
+	return substituteParams(v, func(p Parameter) interface{} {
+		if p.list {
+			return bson.D{{Key: "$paramList", Value: p.name}}
+		}
+		return bson.D{{Key: "$param", Value: p.name}}
+	})
+}
+
+// paramNames returns the sorted, de-duplicated names of all parameters in v.
+func paramNames(v interface{}) []string {
+	var names []string
+	substituteParams(v, func(p Parameter) interface{} {
+		names = append(names, p.name)
+		return p
+	})
+	return dedupeSorted(names)
+}
+
+// substituteParams walks documents, arrays and sub-pipelines, returning a copy
+// of v with every Parameter replaced by fn(p). Containers without parameters
+// are returned as-is.
+func substituteParams(v interface{}, fn func(Parameter) interface{}) interface{} {
+	switch x := v.(type) {
+	case Parameter:
+		return fn(x)
+	case bson.D:
+		var out bson.D
+		for i, e := range x {
+			nv := substituteParams(e.Value, fn)
+			if out == nil && !sameValue(nv, e.Value) {
+				out = make(bson.D, len(x))
+				copy(out, x)
+			}
+			if out != nil {
+				out[i] = bson.E{Key: e.Key, Value: nv}
+			}
+		}
+		if out == nil {
+			return x
+		}
+		return out
+	case bson.M:
+		var out bson.M
+		for k, val := range x {
+			nv := substituteParams(val, fn)
+			if out == nil && !sameValue(nv, val) {
+				out = make(bson.M, len(x))
+				for k2, v2 := range x {
+					out[k2] = v2
+				}
+			}
+			if out != nil {
+				out[k] = nv
+			}
+		}
+		if out == nil {
+			return x
+		}
+		return out
+	case map[string]interface{}:
+		return substituteParams(bson.M(x), fn)
+	case bson.A:
+		return bson.A(substituteSlice(x, fn))
+	case []interface{}:
+		return substituteSlice(x, fn)
+	case []bson.D:
+		var out []bson.D
+		for i, d := range x {
+			nd := substituteParams(d, fn).(bson.D)
+			if out == nil && !sameValue(nd, d) {
+				out = make([]bson.D, len(x))
+				copy(out, x)
+			}
+			if out != nil {
+				out[i] = nd
+			}
+		}
+		if out == nil {
+			return x
+		}
+		return out
+	case Filter:
+		return Filter{d: substituteParams(x.d, fn).(bson.D)}
+	case Pipeline:
+		return Pipeline{stages: substituteParams(x.stages, fn).([]bson.D)}
+	}
+	return v
+}
+
+// substituteSlice applies substituteParams to every element of a slice.
+// ParamList elements bound to a slice are spliced in place.
+func substituteSlice(x []interface{}, fn func(Parameter) interface{}) []interface{} {
+	var out []interface{}
+	for i, item := range x {
+		nv := substituteParams(item, fn)
+		if out == nil && !sameValue(nv, item) {
+			out = make([]interface{}, i, len(x))
+			copy(out, x[:i])
+		}
+		if out == nil {
+			continue
+		}
+		if p, ok := item.(Parameter); ok && p.list {
+			if elems, ok := sliceElems(nv); ok {
+				out = append(out, elems...)
+				continue
+			}
+		}
+		out = append(out, nv)
+	}
+	if out == nil {
+		return x
+	}
+	return out
+}
+
+// sliceElems returns the elements of any slice or array value except []byte
+// and documents.
+func sliceElems(v interface{}) ([]interface{}, bool) {
+	switch v.(type) {
+	case []byte, bson.D:
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out, true
+}
+
+// sameValue reports whether substitution left the original value orig
+// unchanged. Containers are compared by identity of their backing storage.
+func sameValue(repl, orig interface{}) bool {
+	switch o := orig.(type) {
+	case Parameter:
+		r, ok := repl.(Parameter)
+		return ok && r == o
+	case bson.D:
+		r, ok := repl.(bson.D)
+		return ok && sameBacking(r, o)
+	case bson.A:
+		r, ok := repl.(bson.A)
+		return ok && sameBacking(r, o)
+	case []interface{}:
+		r, ok := repl.([]interface{})
+		return ok && sameBacking(r, o)
+	case []bson.D:
+		r, ok := repl.([]bson.D)
+		return ok && sameBacking(r, o)
+	case bson.M, map[string]interface{}, Filter, Pipeline:
+		// Maps and builders are always rebuilt when walked.
+		return false
+	}
+	return true
+}
+
+// sameBacking reports whether two slices share the same backing array.
+func sameBacking[E any](a, b []E) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// builderValue converts gmqb builders passed as parameter values to their BSON form.
+func builderValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case Filter:
+		return x.d
+	case Updater:
+		return x.ops
+	case Pipeline:
+		return x.stages
+	}
+	return v
+}
+
+// dedupeSorted sorts names and removes duplicates.
+func dedupeSorted(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	out := names[:1]
+	for _, n := range names[1:] {
+		if n != out[len(out)-1] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,190 @@
+package gmqb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestFilter_Bind(t *testing.T) {
+	tmpl := And(
+		Gte("age", Param("minAge")),
+		In("country", Param("country"), "XX"),
+		ElemMatch("tags", Eq("name", Param("tag"))),
+	)
+	assert.Equal(t, []string{"country", "minAge", "tag"}, tmpl.Params())
+
+	f, err := tmpl.Bind(map[string]any{"minAge": 18, "country": "US", "tag": "go", "unused": 1})
+	require.NoError(t, err)
+	assert.Empty(t, f.Params())
+	assert.Equal(t,
+		`{"$and":[{"age":{"$gte":18}},{"country":{"$in":["US","XX"]}},{"tags":{"$elemMatch":{"name":{"$eq":"go"}}}}]}`,
+		f.CompactJSON())
+
+	// The template is unchanged and can be bound again.
+	f2, err := tmpl.Bind(map[string]any{"minAge": 65, "country": "DE", "tag": "rust"})
+	require.NoError(t, err)
+	assert.Contains(t, f2.CompactJSON(), `{"$gte":65}`)
+	assert.Equal(t, []string{"country", "minAge", "tag"}, tmpl.Params())
+	assert.Contains(t, f.CompactJSON(), `{"$gte":18}`)
+}
+
+func TestFilter_Bind_Unbound(t *testing.T) {
+	tmpl := NewFilter().Eq("a", Param("a")).Eq("b", Param("b")).Eq("c", Param("a"))
+	_, err := tmpl.Bind(map[string]any{"c": 1})
+	require.ErrorIs(t, err, ErrUnboundParam)
+	assert.EqualError(t, err, "gmqb: unbound parameter: a, b")
+}
+
+func TestParam_MarshalFails(t *testing.T) {
+	_, err := bson.Marshal(Eq("a", Param("a")).BsonD())
+	assert.ErrorIs(t, err, ErrUnboundParam)
+
+	// Debug output renders placeholders instead of failing.
+	assert.Equal(t, `{"a":{"$eq":{"$param":"a"}}}`, Eq("a", Param("a")).CompactJSON())
+	assert.Equal(t, `[{"$match":{"a":{"$in":[{"$paramList":"xs"}]}}}]`,
+		NewPipeline().Match(In("a", ParamList("xs"))).CompactJSON())
+}
+
+func TestParamList(t *testing.T) {
+	tmpl := In("status", ParamList("statuses"), "archived")
+	f, err := tmpl.Bind(map[string]any{"statuses": []string{"active", "pending"}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"status":{"$in":["active","pending","archived"]}}`, f.CompactJSON())
+
+	// A scalar bound to a list parameter is used as a single element.
+	f, err = tmpl.Bind(map[string]any{"statuses": "active"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"status":{"$in":["active","archived"]}}`, f.CompactJSON())
+
+	// Outside an array a list parameter binds like a plain parameter.
+	f, err = Eq("tags", ParamList("tags")).Bind(map[string]any{"tags": []string{"a"}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"tags":{"$eq":["a"]}}`, f.CompactJSON())
+}
+
+func TestUpdater_Bind(t *testing.T) {
+	tmpl := NewUpdate().Set("status", Param("status")).Inc("version", 1)
+	assert.Equal(t, []string{"status"}, tmpl.Params())
+
+	u, err := tmpl.Bind(map[string]any{"status": "archived"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"$set":{"status":"archived"},"$inc":{"version":1}}`, u.CompactJSON())
+
+	_, err = tmpl.Bind(nil)
+	assert.ErrorIs(t, err, ErrUnboundParam)
+}
+
+func TestPipeline_Bind(t *testing.T) {
+	tmpl := NewPipeline().
+		Match(Eq("tenant", Param("tenant"))).
+		Facet(map[string]Pipeline{
+			"top": NewPipeline().Sort(Desc("score")).Limit(5),
+			"min": NewPipeline().Match(Gte("score", Param("minScore"))),
+		}).
+		RawStage("$set", bson.M{"label": Param("label")})
+	assert.Equal(t, []string{"label", "minScore", "tenant"}, tmpl.Params())
+
+	p, err := tmpl.Bind(map[string]any{"tenant": "acme", "minScore": 10, "label": "x"})
+	require.NoError(t, err)
+	assert.Empty(t, p.Params())
+	js := p.CompactJSON()
+	assert.Contains(t, js, `{"$match":{"tenant":{"$eq":"acme"}}}`)
+	assert.Contains(t, js, `{"$match":{"score":{"$gte":10}}}`)
+	assert.Contains(t, js, `{"$set":{"label":"x"}}`)
+	assert.Equal(t, []string{"label", "minScore", "tenant"}, tmpl.Params())
+
+	_, err = tmpl.Bind(map[string]any{"tenant": "acme"})
+	assert.EqualError(t, err, "gmqb: unbound parameter: label, minScore")
+}
+
+func TestBind_BuilderValues(t *testing.T) {
+	p, err := NewPipeline().RawStage("$match", Param("m")).Bind(map[string]any{"m": Eq("a", 1)})
+	require.NoError(t, err)
+	assert.Equal(t, `[{"$match":{"a":{"$eq":1}}}]`, p.CompactJSON())
+}
+
+func TestBind_NoParamsSharesStorage(t *testing.T) {
+	f := Eq("a", 1).Eq("b", bson.A{1, 2})
+	bound, err := f.Bind(nil)
+	require.NoError(t, err)
+	assert.True(t, sameBacking(f.d, bound.d))
+}
+
+func TestFilterTemplate_CacheKey(t *testing.T) {
+	tmpl := NewFilterTemplate(NewFilter().Eq("tenant", Param("tenant")).Gte("age", Param("age")))
+	assert.Equal(t, []string{"age", "tenant"}, tmpl.Params())
+
+	a, err := tmpl.Bind(map[string]any{"tenant": "acme", "age": 18})
+	require.NoError(t, err)
+	b, err := tmpl.Bind(map[string]any{"age": 18, "tenant": "acme", "extra": true})
+	require.NoError(t, err)
+	c, err := tmpl.Bind(map[string]any{"tenant": "acme", "age": 19})
+	require.NoError(t, err)
+
+	ka, _ := filterKey(a)
+	kb, _ := filterKey(b)
+	kc, _ := filterKey(c)
+	assert.Equal(t, ka, kb)
+	assert.NotEqual(t, ka, kc)
+
+	// A different template with the same parameter values gets a different key.
+	other := NewFilterTemplate(NewFilter().Eq("tenant", Param("tenant")).Gt("age", Param("age")))
+	d, err := other.Bind(map[string]any{"tenant": "acme", "age": 18})
+	require.NoError(t, err)
+	kd, _ := filterKey(d)
+	assert.NotEqual(t, ka, kd)
+
+	// Chaining onto a bound filter falls back to full serialization.
+	chained := a.Eq("x", 1)
+	assert.Nil(t, chained.bound)
+	kx, _ := filterKey(chained)
+	assert.Equal(t, chained.CompactJSON(), kx)
+
+	_, err = tmpl.Bind(map[string]any{"tenant": "acme"})
+	assert.ErrorIs(t, err, ErrUnboundParam)
+}
+
+func TestPipelineTemplate_CacheKey(t *testing.T) {
+	tmpl := NewPipelineTemplate(NewPipeline().Match(Eq("country", Param("country"))).Limit(10))
+	assert.Equal(t, tmpl.Pipeline().Params(), tmpl.Params())
+
+	a, err := tmpl.Bind(map[string]any{"country": "DE"})
+	require.NoError(t, err)
+	b, err := tmpl.Bind(map[string]any{"country": "US"})
+	require.NoError(t, err)
+	ka, _ := pipelineKey(a)
+	kb, _ := pipelineKey(b)
+	assert.NotEqual(t, ka, kb)
+	assert.Equal(t, `[{"$match":{"country":{"$eq":"DE"}}},{"$limit":10}]`, a.CompactJSON())
+
+	listTmpl := NewPipelineTemplate(NewPipeline().Match(In("country", ParamList("country"))).Limit(10))
+	plainTmpl := NewPipelineTemplate(NewPipeline().Match(In("country", Param("country"))).Limit(10))
+	assert.NotEqual(t, listTmpl.id, plainTmpl.id)
+}
+
+func TestTemplate_CacheKey_BuilderParams(t *testing.T) {
+	tmpl := NewPipelineTemplate(NewPipeline().RawStage("$unionWith", bson.D{
+		{Key: "coll", Value: "archive"},
+		{Key: "pipeline", Value: Param("sub")},
+	}))
+	a, err := tmpl.Bind(map[string]any{"sub": NewPipeline().Match(Eq("year", 2023))})
+	require.NoError(t, err)
+	b, err := tmpl.Bind(map[string]any{"sub": NewPipeline().Match(Eq("year", 2024))})
+	require.NoError(t, err)
+	ka, _ := pipelineKey(a)
+	kb, _ := pipelineKey(b)
+	assert.NotEqual(t, ka, kb)
+
+	match := NewPipelineTemplate(NewPipeline().RawStage("$match", Param("m")))
+	ma, err := match.Bind(map[string]any{"m": Eq("status", "open")})
+	require.NoError(t, err)
+	mb, err := match.Bind(map[string]any{"m": Eq("status", "closed")})
+	require.NoError(t, err)
+	ka, _ = pipelineKey(ma)
+	kb, _ = pipelineKey(mb)
+	assert.NotEqual(t, ka, kb)
+	assert.Contains(t, ka, `"open"`)
+}
@@ -18,6 +18,7 @@ import (
 //	    Limit(10)
 type Pipeline struct {
 	stages []bson.D
+	bound  *boundTemplate // set when bound from a PipelineTemplate
 }
 
 // NewPipeline creates an empty aggregation pipeline.
@@ -827,6 +828,321 @@ func (p Pipeline) GeoNear(opts GeoNearOpts) Pipeline {
 	return p.addStage("$geoNear", doc)
 }
 
+// Documents returns literal documents as the pipeline input, without reading a
+// collection. Must be the first stage and is typically used with AggregateDatabase.
+//
+// MongoDB equivalent:
+//
+//	{ $documents: [ { x: 1 }, { x: 2 } ] }
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/documents/
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().Documents(
+//	    bson.D{{"name", "Alice"}, {"age", 30}},
+//	    bson.D{{"name", "Bob"}, {"age", 25}},
+//	)
+func (p Pipeline) Documents(docs ...interface{}) Pipeline {
+	arr := make(bson.A, len(docs))
+	copy(arr, docs)
+	return p.addStage("$documents", arr)
+}
+
+// CollStatsOpts configures the $collStats aggregation stage.
+type CollStatsOpts struct {
+	LatencyStats      bool  // include latency statistics
+	LatencyHistograms bool  // add latency histograms to latencyStats
+	StorageStats      bool  // include storage statistics
+	StorageScale      int32 // scale factor for storage sizes (0 = bytes)
+	Count             bool  // include the document count
+	QueryExecStats    bool  // include query execution statistics
+}
+
+// CollStats returns statistics about the collection. Must be the first stage.
+//
+// MongoDB equivalent:
+//
+//	{ $collStats: { latencyStats: { histograms: true }, storageStats: { scale: 1024 }, count: {}, queryExecStats: {} } }
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/collStats/
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().CollStats(gmqb.CollStatsOpts{
+//	    StorageStats: true,
+//	    StorageScale: 1024,
+//	    Count:        true,
+//	})
+func (p Pipeline) CollStats(opts CollStatsOpts) Pipeline {
+	doc := bson.D{}
+	if opts.LatencyStats || opts.LatencyHistograms {
+		latency := bson.D{}
+		if opts.LatencyHistograms {
+			latency = append(latency, bson.E{Key: "histograms", Value: true})
+		}
+		doc = append(doc, bson.E{Key: "latencyStats", Value: latency})
+	}
+	if opts.StorageStats || opts.StorageScale > 0 {
+		storage := bson.D{}
+		if opts.StorageScale > 0 {
+			storage = append(storage, bson.E{Key: "scale", Value: opts.StorageScale})
+		}
+		doc = append(doc, bson.E{Key: "storageStats", Value: storage})
+	}
+	if opts.Count {
+		doc = append(doc, bson.E{Key: "count", Value: bson.D{}})
+	}
+	if opts.QueryExecStats {
+		doc = append(doc, bson.E{Key: "queryExecStats", Value: bson.D{}})
+	}
+	return p.addStage("$collStats", doc)
+}
+
+// IndexStats returns usage statistics for each index of the collection. Must be
+// the first stage.
+//
+// MongoDB equivalent:
+//
+//	{ $indexStats: { } }
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/indexStats/
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().IndexStats()
+func (p Pipeline) IndexStats() Pipeline {
+	return p.addStage("$indexStats", bson.D{})
+}
+
+// PlanCacheStatsOpts configures the $planCacheStats aggregation stage.
+type PlanCacheStatsOpts struct {
+	AllHosts bool // return plan cache entries from all members of a sharded cluster
+}
+
+// PlanCacheStats returns plan cache information for the collection. Must be the
+// first stage.
+//
+// MongoDB equivalent:
+//
+//	{ $planCacheStats: { allHosts: true } }
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/planCacheStats/
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().PlanCacheStats(gmqb.PlanCacheStatsOpts{})
+func (p Pipeline) PlanCacheStats(opts PlanCacheStatsOpts) Pipeline {
+	doc := bson.D{}
+	if opts.AllHosts {
+		doc = append(doc, bson.E{Key: "allHosts", Value: true})
+	}
+	return p.addStage("$planCacheStats", doc)
+}
+
+// CurrentOpOpts configures the $currentOp aggregation stage.
+type CurrentOpOpts struct {
+	AllUsers        bool  // report operations of all users, not only the current one
+	IdleConnections bool  // include idle connections
+	IdleCursors     bool  // include idle cursors
+	IdleSessions    *bool // include idle sessions (server default: true)
+	LocalOps        bool  // on mongos, report operations running on the mongos itself
+	TargetAllNodes  bool  // on sharded clusters, report operations on all nodes
+}
+
+// CurrentOp returns information on active operations. Must be the first stage
+// and runs against the admin database (see AggregateDatabase).
+//
+// MongoDB equivalent:
+//
+//	{ $currentOp: { allUsers: true, idleConnections: false, ... } }
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/currentOp/
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().
+//	    CurrentOp(gmqb.CurrentOpOpts{AllUsers: true}).
+//	    Match(gmqb.Gte("secs_running", 10))
+func (p Pipeline) CurrentOp(opts CurrentOpOpts) Pipeline {
+	doc := bson.D{}
+	if opts.AllUsers {
+		doc = append(doc, bson.E{Key: "allUsers", Value: true})
+	}
+	if opts.IdleConnections {
+		doc = append(doc, bson.E{Key: "idleConnections", Value: true})
+	}
+	if opts.IdleCursors {
+		doc = append(doc, bson.E{Key: "idleCursors", Value: true})
+	}
+	if opts.IdleSessions != nil {
+		doc = append(doc, bson.E{Key: "idleSessions", Value: *opts.IdleSessions})
+	}
+	if opts.LocalOps {
+		doc = append(doc, bson.E{Key: "localOps", Value: true})
+	}
+	if opts.TargetAllNodes {
+		doc = append(doc, bson.E{Key: "targetAllNodes", Value: true})
+	}
+	return p.addStage("$currentOp", doc)
+}
+
+// SessionUser identifies a user whose sessions $listSessions should return.
+type SessionUser struct {
+	User string
+	DB   string
+}
+
+// ListSessionsOpts configures the $listSessions aggregation stage.
+type ListSessionsOpts struct {
+	Users    []SessionUser // sessions of these users only
+	AllUsers bool          // sessions of all users
+}
+
+// ListSessions lists sessions stored in the system.sessions collection. Must be
+// the first stage and runs against the config.system.sessions collection.
+//
+// MongoDB equivalent:
+//
+//	{ $listSessions: { users: [ { user: "u", db: "d" } ] } }
+//	{ $listSessions: { allUsers: true } }
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/listSessions/
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().ListSessions(gmqb.ListSessionsOpts{AllUsers: true})
+func (p Pipeline) ListSessions(opts ListSessionsOpts) Pipeline {
+	doc := bson.D{}
+	if len(opts.Users) > 0 {
+		users := make(bson.A, len(opts.Users))
+		for i, u := range opts.Users {
+			users[i] = bson.D{{Key: "user", Value: u.User}, {Key: "db", Value: u.DB}}
+		}
+		doc = append(doc, bson.E{Key: "users", Value: users})
+	}
+	if opts.AllUsers {
+		doc = append(doc, bson.E{Key: "allUsers", Value: true})
+	}
+	return p.addStage("$listSessions", doc)
+}
+
+// ListSearchIndexesOpts configures the $listSearchIndexes aggregation stage.
+// Set at most one of ID and Name; leave both empty to list every search index.
+type ListSearchIndexesOpts struct {
+	ID   string // search index ID
+	Name string // search index name
+}
+
+// ListSearchIndexes returns information about Atlas Search and Vector Search
+// indexes on the collection. Must be the first stage.
+//
+// MongoDB equivalent:
+//
+//	{ $listSearchIndexes: { name: "default" } }
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/listSearchIndexes/
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().ListSearchIndexes(gmqb.ListSearchIndexesOpts{Name: "default"})
+func (p Pipeline) ListSearchIndexes(opts ListSearchIndexesOpts) Pipeline {
+	doc := bson.D{}
+	if opts.ID != "" {
+		doc = append(doc, bson.E{Key: "id", Value: opts.ID})
+	}
+	if opts.Name != "" {
+		doc = append(doc, bson.E{Key: "name", Value: opts.Name})
+	}
+	return p.addStage("$listSearchIndexes", doc)
+}
+
+// ChangeStreamOpts configures the $changeStream aggregation stage.
+type ChangeStreamOpts struct {
+	FullDocument             string          // "default", "updateLookup", "whenAvailable" or "required"
+	FullDocumentBeforeChange string          // "off", "whenAvailable" or "required"
+	ResumeAfter              interface{}     // resume token
+	StartAfter               interface{}     // resume token
+	StartAtOperationTime     *bson.Timestamp // cluster time to start from
+	AllChangesForCluster     bool            // watch every database (admin database only)
+	ShowExpandedEvents       bool            // include DDL events such as createIndexes
+}
+
+// ChangeStream opens a change stream cursor on the collection. Must be the
+// first stage. Prefer mongo.Collection.Watch for long-lived consumers; this
+// builder is useful when composing change stream pipelines by hand.
+//
+// MongoDB equivalent:
+//
+//	{ $changeStream: { fullDocument: "updateLookup", startAtOperationTime: <ts> } }
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/changeStream/
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().
+//	    ChangeStream(gmqb.ChangeStreamOpts{FullDocument: "updateLookup"}).
+//	    Match(gmqb.In("operationType", "insert", "update"))
+func (p Pipeline) ChangeStream(opts ChangeStreamOpts) Pipeline {
+	doc := bson.D{}
+	if opts.AllChangesForCluster {
+		doc = append(doc, bson.E{Key: "allChangesForCluster", Value: true})
+	}
+	if opts.FullDocument != "" {
+		doc = append(doc, bson.E{Key: "fullDocument", Value: opts.FullDocument})
+	}
+	if opts.FullDocumentBeforeChange != "" {
+		doc = append(doc, bson.E{Key: "fullDocumentBeforeChange", Value: opts.FullDocumentBeforeChange})
+	}
+	if opts.ResumeAfter != nil {
+		doc = append(doc, bson.E{Key: "resumeAfter", Value: opts.ResumeAfter})
+	}
+	if opts.StartAfter != nil {
+		doc = append(doc, bson.E{Key: "startAfter", Value: opts.StartAfter})
+	}
+	if opts.StartAtOperationTime != nil {
+		doc = append(doc, bson.E{Key: "startAtOperationTime", Value: *opts.StartAtOperationTime})
+	}
+	if opts.ShowExpandedEvents {
+		doc = append(doc, bson.E{Key: "showExpandedEvents", Value: true})
+	}
+	return p.addStage("$changeStream", doc)
+}
+
+// ChangeStreamSplitLargeEvent splits change events larger than 16 MB into
+// fragments. Must be the last stage of a change stream pipeline.
+//
+// MongoDB equivalent:
+//
+//	{ $changeStreamSplitLargeEvent: { } }
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/changeStreamSplitLargeEvent/
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().
+//	    ChangeStream(gmqb.ChangeStreamOpts{FullDocument: "required"}).
+//	    ChangeStreamSplitLargeEvent()
+func (p Pipeline) ChangeStreamSplitLargeEvent() Pipeline {
+	return p.addStage("$changeStreamSplitLargeEvent", bson.D{})
+}
+
+// ShardedDataDistribution returns the data distribution of sharded collections.
+// Must be the first stage and runs against the admin database (see AggregateDatabase).
+//
+// MongoDB equivalent:
+//
+//	{ $shardedDataDistribution: { } }
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/shardedDataDistribution/
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().ShardedDataDistribution()
+func (p Pipeline) ShardedDataDistribution() Pipeline {
+	return p.addStage("$shardedDataDistribution", bson.D{})
+}
+
 // Fill populates null and missing field values within documents.
 //
 // MongoDB equivalent:
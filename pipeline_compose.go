@@ -0,0 +1,253 @@
+package gmqb
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// StageName is the operator of an aggregation stage, such as "$match".
+type StageName string
+
+// Aggregation stage names.
+const (
+	StageAddFields                   StageName = "$addFields"
+	StageBucket                      StageName = "$bucket"
+	StageBucketAuto                  StageName = "$bucketAuto"
+	StageChangeStream                StageName = "$changeStream"
+	StageChangeStreamSplitLargeEvent StageName = "$changeStreamSplitLargeEvent"
+	StageCollStats                   StageName = "$collStats"
+	StageCount                       StageName = "$count"
+	StageCurrentOp                   StageName = "$currentOp"
+	StageDensify                     StageName = "$densify"
+	StageDocuments                   StageName = "$documents"
+	StageFacet                       StageName = "$facet"
+	StageFill                        StageName = "$fill"
+	StageGeoNear                     StageName = "$geoNear"
+	StageGraphLookup                 StageName = "$graphLookup"
+	StageGroup                       StageName = "$group"
+	StageIndexStats                  StageName = "$indexStats"
+	StageLimit                       StageName = "$limit"
+	StageListLocalSessions           StageName = "$listLocalSessions"
+	StageListSearchIndexes           StageName = "$listSearchIndexes"
+	StageListSessions                StageName = "$listSessions"
+	StageLookup                      StageName = "$lookup"
+	StageMatch                       StageName = "$match"
+	StageMerge                       StageName = "$merge"
+	StageOut                         StageName = "$out"
+	StagePlanCacheStats              StageName = "$planCacheStats"
+	StageProject                     StageName = "$project"
+	StageRedact                      StageName = "$redact"
+	StageReplaceRoot                 StageName = "$replaceRoot"
+	StageReplaceWith                 StageName = "$replaceWith"
+	StageSample                      StageName = "$sample"
+	StageSearch                      StageName = "$search"
+	StageSearchMeta                  StageName = "$searchMeta"
+	StageSet                         StageName = "$set"
+	StageSetWindowFields             StageName = "$setWindowFields"
+	StageShardedDataDistribution     StageName = "$shardedDataDistribution"
+	StageSkip                        StageName = "$skip"
+	StageSort                        StageName = "$sort"
+	StageSortByCount                 StageName = "$sortByCount"
+	StageUnionWith                   StageName = "$unionWith"
+	StageUnset                       StageName = "$unset"
+	StageUnwind                      StageName = "$unwind"
+	StageVectorSearch                StageName = "$vectorSearch"
+)
+
+// Stage is a single aggregation stage: its operator and specification.
+type Stage struct {
+	Name StageName
+	Spec interface{}
+}
+
+// NewStage creates a Stage for use with Pipeline.Insert and Pipeline.Replace.
+//
+// Example:
+//
+//	tenant := gmqb.NewStage(gmqb.StageMatch, gmqb.Eq("tenant", "acme").BsonD())
+func NewStage(name StageName, spec interface{}) Stage {
+	return Stage{Name: name, Spec: spec}
+}
+
+// BsonD returns the stage as a single-key document.
+func (s Stage) BsonD() bson.D {
+	return bson.D{{Key: string(s.Name), Value: s.Spec}}
+}
+
+// stageOf converts a stored stage document to a Stage. The spec is deep
+// copied, so changing it does not affect the pipeline it came from.
+func stageOf(d bson.D) Stage {
+	if len(d) == 0 {
+		return Stage{}
+	}
+	return Stage{Name: StageName(d[0].Key), Spec: copyValue(d[0].Value)}
+}
+
+// copyValue deep copies the documents and arrays in v. Other values are
+// returned as they are.
+func copyValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case bson.D:
+		out := make(bson.D, len(x))
+		for i, e := range x {
+			out[i] = bson.E{Key: e.Key, Value: copyValue(e.Value)}
+		}
+		return out
+	case bson.M:
+		out := make(bson.M, len(x))
+		for k, val := range x {
+			out[k] = copyValue(val)
+		}
+		return out
+	case map[string]interface{}:
+		return map[string]interface{}(copyValue(bson.M(x)).(bson.M))
+	case bson.A:
+		return bson.A(copyValue([]interface{}(x)).([]interface{}))
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, val := range x {
+			out[i] = copyValue(val)
+		}
+		return out
+	case []bson.D:
+		out := make([]bson.D, len(x))
+		for i, d := range x {
+			out[i] = copyValue(d).(bson.D)
+		}
+		return out
+	}
+	return v
+}
+
+// Len returns the number of stages in the pipeline.
+func (p Pipeline) Len() int {
+	return len(p.stages)
+}
+
+// Stages returns the pipeline's stages in order. The stages are deep copies;
+// modifying them does not affect the pipeline.
+//
+// Example:
+//
+//	for _, s := range p.Stages() {
+//	    fmt.Println(s.Name)
+//	}
+func (p Pipeline) Stages() []Stage {
+	out := make([]Stage, len(p.stages))
+	for i, d := range p.stages {
+		out[i] = stageOf(d)
+	}
+	return out
+}
+
+// StageAt returns a deep copy of the stage at index i, or false if i is out
+// of range.
+//
+// Example:
+//
+//	if s, ok := p.StageAt(0); ok && s.Name == gmqb.StageMatch {
+//	    // ...
+//	}
+func (p Pipeline) StageAt(i int) (Stage, bool) {
+	if i < 0 || i >= len(p.stages) {
+		return Stage{}, false
+	}
+	return stageOf(p.stages[i]), true
+}
+
+// Find returns the indices of all stages with the given operator.
+//
+// Example:
+//
+//	idx := p.Find(gmqb.StageLookup) // e.g. [1, 3]
+func (p Pipeline) Find(name StageName) []int {
+	var out []int
+	for i, d := range p.stages {
+		if len(d) > 0 && StageName(d[0].Key) == name {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// Insert returns a new Pipeline with the stages inserted before index i.
+// An index equal to Len appends. It panics if i is out of range.
+//
+// Example:
+//
+//	// Prepend a tenant filter to a shared fragment.
+//	p = p.Insert(0, gmqb.NewStage(gmqb.StageMatch, gmqb.Eq("tenant", t).BsonD()))
+//
+//	// Splice another pipeline's stages in after the first stage.
+//	p = p.Insert(1, lookupBlock.Stages()...)
+func (p Pipeline) Insert(i int, stages ...Stage) Pipeline {
+	p.checkIndex("Insert", i, len(p.stages))
+	out := make([]bson.D, 0, len(p.stages)+len(stages))
+	out = append(out, p.stages[:i]...)
+	for _, s := range stages {
+		out = append(out, s.BsonD())
+	}
+	out = append(out, p.stages[i:]...)
+	return Pipeline{stages: out}
+}
+
+// Remove returns a new Pipeline without the stage at index i. It panics if i
+// is out of range.
+//
+// Example:
+//
+//	// Drop pagination from a shared pipeline when counting.
+//	for _, i := range slices.Backward(p.Find(gmqb.StageLimit)) {
+//	    p = p.Remove(i)
+//	}
+func (p Pipeline) Remove(i int) Pipeline {
+	p.checkIndex("Remove", i, len(p.stages)-1)
+	out := make([]bson.D, 0, len(p.stages)-1)
+	out = append(out, p.stages[:i]...)
+	out = append(out, p.stages[i+1:]...)
+	return Pipeline{stages: out}
+}
+
+// Replace returns a new Pipeline with the stage at index i replaced. It panics
+// if i is out of range.
+//
+// Example:
+//
+//	if idx := p.Find(gmqb.StageLimit); len(idx) > 0 {
+//	    p = p.Replace(idx[0], gmqb.NewStage(gmqb.StageLimit, 50))
+//	}
+func (p Pipeline) Replace(i int, stage Stage) Pipeline {
+	p.checkIndex("Replace", i, len(p.stages)-1)
+	out := make([]bson.D, len(p.stages))
+	copy(out, p.stages)
+	out[i] = stage.BsonD()
+	return Pipeline{stages: out}
+}
+
+// Concat returns a new Pipeline with the stages of others appended in order.
+//
+// Example:
+//
+//	tenantScope := gmqb.NewPipeline().Match(gmqb.Eq("tenant", t))
+//	withOwner := gmqb.NewPipeline().Lookup(gmqb.LookupOpts{From: "users", LocalField: "ownerId", ForeignField: "_id", As: "owner"})
+//	p := tenantScope.Concat(withOwner, reportStages)
+func (p Pipeline) Concat(others ...Pipeline) Pipeline {
+	n := len(p.stages)
+	for _, o := range others {
+		n += len(o.stages)
+	}
+	out := make([]bson.D, 0, n)
+	out = append(out, p.stages...)
+	for _, o := range others {
+		out = append(out, o.stages...)
+	}
+	return Pipeline{stages: out}
+}
+
+// checkIndex panics with a descriptive message when i is outside [0, max].
+func (p Pipeline) checkIndex(op string, i, max int) {
+	if i < 0 || i > max {
+		panic(fmt.Sprintf("gmqb: Pipeline.%s: index %d out of range [0, %d]", op, i, max))
+	}
+}
@@ -0,0 +1,118 @@
+package gmqb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func stageNames(p Pipeline) []StageName {
+	var out []StageName
+	for _, s := range p.Stages() {
+		out = append(out, s.Name)
+	}
+	return out
+}
+
+func TestPipeline_Stages(t *testing.T) {
+	p := NewPipeline().Match(Eq("a", 1)).Sort(Asc("a")).Limit(5)
+	assert.Equal(t, 3, p.Len())
+	assert.Equal(t, []StageName{StageMatch, StageSort, StageLimit}, stageNames(p))
+
+	s, ok := p.StageAt(2)
+	require.True(t, ok)
+	assert.Equal(t, StageLimit, s.Name)
+	assert.Equal(t, int64(5), s.Spec)
+	assert.Equal(t, bson.D{{Key: "$limit", Value: int64(5)}}, s.BsonD())
+
+	_, ok = p.StageAt(3)
+	assert.False(t, ok)
+	_, ok = p.StageAt(-1)
+	assert.False(t, ok)
+
+	// Modifying the returned slice does not affect the pipeline.
+	stages := p.Stages()
+	stages[0] = NewStage(StageSkip, 1)
+	assert.Equal(t, []StageName{StageMatch, StageSort, StageLimit}, stageNames(p))
+}
+
+func TestPipeline_Stages_SpecIsCopy(t *testing.T) {
+	p := NewPipeline().
+		Match(In("a", 1, 2)).
+		RawStage("$set", bson.M{"tags": bson.A{"x"}})
+	want := p.CompactJSON()
+
+	stages := p.Stages()
+	match := stages[0].Spec.(bson.D)
+	match[0].Key = "hacked"
+	match[0].Value.(bson.D)[0].Value.(bson.A)[0] = 99
+	stages[1].Spec.(bson.M)["tags"].(bson.A)[0] = "y"
+
+	s, ok := p.StageAt(0)
+	require.True(t, ok)
+	s.Spec.(bson.D)[0].Key = "hacked"
+
+	assert.Equal(t, want, p.CompactJSON())
+}
+
+func TestPipeline_Find(t *testing.T) {
+	p := NewPipeline().
+		Match(Eq("a", 1)).
+		Lookup(LookupOpts{From: "x", LocalField: "a", ForeignField: "b", As: "x"}).
+		Match(Eq("b", 1)).
+		Lookup(LookupOpts{From: "y", LocalField: "a", ForeignField: "b", As: "y"})
+	assert.Equal(t, []int{0, 2}, p.Find(StageMatch))
+	assert.Equal(t, []int{1, 3}, p.Find("$lookup"))
+	assert.Nil(t, p.Find(StageGroup))
+}
+
+func TestPipeline_Insert(t *testing.T) {
+	base := NewPipeline().Sort(Asc("a")).Limit(5)
+
+	p := base.Insert(0, NewStage(StageMatch, Eq("tenant", "t1").BsonD()))
+	assert.Equal(t, `[{"$match":{"tenant":{"$eq":"t1"}}},{"$sort":{"a":1}},{"$limit":5}]`, p.CompactJSON())
+
+	frag := NewPipeline().Skip(1).Project(Include("a"))
+	p = base.Insert(1, frag.Stages()...)
+	assert.Equal(t, []StageName{StageSort, StageSkip, StageProject, StageLimit}, stageNames(p))
+
+	p = base.Insert(base.Len(), NewStage(StageCount, "n"))
+	assert.Equal(t, []StageName{StageSort, StageLimit, StageCount}, stageNames(p))
+
+	assert.Equal(t, []StageName{StageSort, StageLimit}, stageNames(base))
+	assert.Panics(t, func() { base.Insert(3) })
+	assert.Panics(t, func() { base.Insert(-1) })
+}
+
+func TestPipeline_RemoveReplace(t *testing.T) {
+	base := NewPipeline().Match(Eq("a", 1)).Skip(10).Limit(5)
+
+	p := base.Remove(1)
+	assert.Equal(t, []StageName{StageMatch, StageLimit}, stageNames(p))
+
+	p = base.Replace(2, NewStage(StageLimit, 50))
+	assert.Equal(t, `[{"$match":{"a":{"$eq":1}}},{"$skip":10},{"$limit":50}]`, p.CompactJSON())
+
+	assert.Equal(t, `[{"$match":{"a":{"$eq":1}}},{"$skip":10},{"$limit":5}]`, base.CompactJSON())
+	assert.Panics(t, func() { base.Remove(3) })
+	assert.Panics(t, func() { base.Replace(-1, NewStage(StageLimit, 1)) })
+	assert.Panics(t, func() { NewPipeline().Remove(0) })
+}
+
+func TestPipeline_Concat(t *testing.T) {
+	a := NewPipeline().Match(Eq("tenant", "t1"))
+	b := NewPipeline().Lookup(LookupOpts{From: "users", LocalField: "ownerId", ForeignField: "_id", As: "owner"})
+	c := NewPipeline().Limit(10)
+
+	p := a.Concat(b, c)
+	assert.Equal(t, []StageName{StageMatch, StageLookup, StageLimit}, stageNames(p))
+	assert.Equal(t, 1, a.Len())
+
+	// Appending to the result never writes into a's backing array.
+	a2 := a.Concat()
+	_ = a2.Limit(1)
+	_ = a.Skip(1)
+	assert.Equal(t, []StageName{StageMatch}, stageNames(a2))
+}
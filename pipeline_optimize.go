@@ -0,0 +1,510 @@
+package gmqb
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// OptimizeChange describes a single rewrite applied by Pipeline.Optimize.
+type OptimizeChange struct {
+	// Rule identifies the rewrite, e.g. "merge-match" or "fold-limit".
+	Rule string
+	// Description is a human-readable summary of the rewrite.
+	Description string
+}
+
+// OptimizeReport lists the rewrites applied by Pipeline.Optimize, in order.
+type OptimizeReport struct {
+	Changes []OptimizeChange
+}
+
+// Changed reports whether Optimize rewrote the pipeline.
+func (r OptimizeReport) Changed() bool {
+	return len(r.Changes) > 0
+}
+
+// String renders the report with one rewrite per line.
+func (r OptimizeReport) String() string {
+	lines := make([]string, len(r.Changes))
+	for i, c := range r.Changes {
+		lines[i] = c.Rule + ": " + c.Description
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Optimization rule names reported in OptimizeChange.Rule.
+const (
+	RuleMergeMatch        = "merge-match"
+	RulePushMatch         = "push-match"
+	RuleCoalesceSortLimit = "coalesce-sort-limit"
+	RuleMergeProjection   = "merge-projection"
+	RuleFoldSkip          = "fold-skip"
+	RuleFoldLimit         = "fold-limit"
+)
+
+// Optimize applies safe, semantics-preserving rewrites to the pipeline and
+// returns the rewritten pipeline together with a report of what changed.
+// The original pipeline is not modified.
+//
+// Rewrites applied until no further change is possible:
+//   - consecutive $match stages are merged into one
+//   - a $match on fields untouched by a preceding $project, $addFields, $set,
+//     $unset or $unwind is moved ahead of that stage
+//   - a $limit separated from a preceding $sort by per-document stages is moved
+//     next to the $sort so the server can coalesce them into a top-k sort
+//   - adjacent $unset / exclusion $project stages, and adjacent pure inclusion
+//     $project stages, are merged
+//   - $skip + $skip is folded into one $skip and $limit + $limit into one $limit
+//
+// Stages the optimizer cannot analyse (for example a $match using $expr or
+// $where) are left in place. Sub-pipelines of $facet, $lookup and $unionWith are
+// not rewritten.
+//
+// Example:
+//
+//	p, report := gmqb.NewPipeline().
+//	    AddFields(gmqb.AddFieldsSpec(gmqb.AddField("total", gmqb.ExprAdd("$a", "$b")))).
+//	    Match(gmqb.Eq("status", "active")).
+//	    Skip(10).Skip(10).
+//	    Optimize()
+//	// p:      [{$match: {status: "active"}}, {$addFields: {...}}, {$skip: 20}]
+//	// report: push-match, fold-skip
+func (p Pipeline) Optimize() (Pipeline, OptimizeReport) {
+	stages := make([]bson.D, len(p.stages))
+	copy(stages, p.stages)
+
+	var report OptimizeReport
+	record := func(rule, format string, args ...interface{}) {
+		report.Changes = append(report.Changes, OptimizeChange{Rule: rule, Description: fmt.Sprintf(format, args...)})
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i := 0; i+1 < len(stages); i++ {
+			a, b := stages[i], stages[i+1]
+			if len(a) != 1 || len(b) != 1 {
+				continue
+			}
+			opA, opB := a[0].Key, b[0].Key
+
+			// merge-match
+			if opA == "$match" && opB == "$match" {
+				if merged, ok := mergeMatch(a[0].Value, b[0].Value); ok {
+					stages = replaceStages(stages, i, 2, bson.D{{Key: "$match", Value: merged}})
+					record(RuleMergeMatch, "merged $match stages %d and %d", i, i+1)
+					changed = true
+					continue
+				}
+			}
+
+			// push-match
+			if opB == "$match" && isReshapeStage(opA) {
+				if fields, ok := matchFields(b[0].Value); ok && stagePreservesFields(a, fields) {
+					stages[i], stages[i+1] = b, a
+					record(RulePushMatch, "moved $match at stage %d ahead of %s", i+1, opA)
+					changed = true
+					continue
+				}
+			}
+
+			// coalesce-sort-limit
+			if opB == "$limit" && isOneToOneStage(opA) && precededBySort(stages, i) {
+				stages[i], stages[i+1] = b, a
+				record(RuleCoalesceSortLimit, "moved $limit at stage %d ahead of %s towards $sort", i+1, opA)
+				changed = true
+				continue
+			}
+
+			// fold-skip / fold-limit
+			if opA == opB && (opA == "$skip" || opA == "$limit") {
+				x, okA := toInt64(a[0].Value)
+				y, okB := toInt64(b[0].Value)
+				if okA && okB {
+					if opA == "$skip" {
+						stages = replaceStages(stages, i, 2, bson.D{{Key: "$skip", Value: x + y}})
+						record(RuleFoldSkip, "folded $skip %d + $skip %d into $skip %d", x, y, x+y)
+					} else {
+						n := min(x, y)
+						stages = replaceStages(stages, i, 2, bson.D{{Key: "$limit", Value: n}})
+						record(RuleFoldLimit, "folded $limit %d + $limit %d into $limit %d", x, y, n)
+					}
+					changed = true
+					continue
+				}
+			}
+
+			// merge-projection
+			if (opA == "$project" || opA == "$unset") && (opB == "$project" || opB == "$unset") {
+				if merged, ok := mergeProjections(a, b); ok {
+					stages = replaceStages(stages, i, 2, merged)
+					record(RuleMergeProjection, "merged %s and %s at stages %d and %d", opA, opB, i, i+1)
+					changed = true
+					continue
+				}
+			}
+		}
+	}
+
+	return Pipeline{stages: stages}, report
+}
+
+// replaceStages returns a new slice in which n stages starting at i are replaced by repl.
+func replaceStages(stages []bson.D, i, n int, repl ...bson.D) []bson.D {
+	out := make([]bson.D, 0, len(stages)-n+len(repl))
+	out = append(out, stages[:i]...)
+	out = append(out, repl...)
+	return append(out, stages[i+n:]...)
+}
+
+// isReshapeStage reports whether a $match may be moved ahead of op when the
+// matched fields are untouched by it.
+func isReshapeStage(op string) bool {
+	switch op {
+	case "$project", "$addFields", "$set", "$unset", "$unwind":
+		return true
+	}
+	return false
+}
+
+// isOneToOneStage reports whether op emits exactly one document per input
+// document without reordering, so a following $limit commutes with it.
+func isOneToOneStage(op string) bool {
+	switch op {
+	case "$project", "$addFields", "$set", "$unset", "$replaceRoot", "$replaceWith":
+		return true
+	}
+	return false
+}
+
+// precededBySort reports whether a $sort precedes stage i with only one-to-one
+// stages in between.
+func precededBySort(stages []bson.D, i int) bool {
+	for j := i; j >= 0; j-- {
+		if len(stages[j]) != 1 {
+			return false
+		}
+		op := stages[j][0].Key
+		if op == "$sort" {
+			return true
+		}
+		if !isOneToOneStage(op) {
+			return false
+		}
+	}
+	return false
+}
+
+// mergeMatch combines two $match documents. Disjoint top-level keys are
+// concatenated; otherwise both documents are wrapped in $and.
+func mergeMatch(a, b interface{}) (bson.D, bool) {
+	da, okA := a.(bson.D)
+	db, okB := b.(bson.D)
+	if !okA || !okB {
+		return nil, false
+	}
+	keys := make(map[string]bool, len(da))
+	for _, e := range da {
+		keys[e.Key] = true
+	}
+	for _, e := range db {
+		if keys[e.Key] {
+			return bson.D{{Key: "$and", Value: bson.A{da, db}}}, true
+		}
+	}
+	merged := make(bson.D, 0, len(da)+len(db))
+	merged = append(merged, da...)
+	return append(merged, db...), true
+}
+
+// matchFields returns the field paths referenced by a $match document. It
+// returns false when the filter uses operators whose field references cannot
+// be determined statically ($expr, $where, $text, ...).
+func matchFields(v interface{}) ([]string, bool) {
+	d, ok := v.(bson.D)
+	if !ok {
+		return nil, false
+	}
+	var fields []string
+	for _, e := range d {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			arr, ok := subPipeline(e.Value)
+			if !ok {
+				return nil, false
+			}
+			for _, sub := range arr {
+				f, ok := matchFields(sub)
+				if !ok {
+					return nil, false
+				}
+				fields = append(fields, f...)
+			}
+		case "$comment":
+		default:
+			if strings.HasPrefix(e.Key, "$") {
+				return nil, false
+			}
+			fields = append(fields, e.Key)
+		}
+	}
+	return fields, true
+}
+
+// pathsConflict reports whether two dotted field paths overlap, i.e. one is
+// equal to or nested under the other.
+func pathsConflict(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// pathCovered reports whether field is equal to or nested under path.
+func pathCovered(field, path string) bool {
+	return field == path || strings.HasPrefix(field, path+".")
+}
+
+// stagePreservesFields reports whether every field passes through stage
+// unchanged, so a $match on those fields can run before it.
+func stagePreservesFields(stage bson.D, fields []string) bool {
+	op, val := stage[0].Key, stage[0].Value
+	var touched []string
+	switch op {
+	case "$addFields", "$set":
+		for _, e := range documentElems(val) {
+			touched = append(touched, e.Key)
+		}
+	case "$unset":
+		names, ok := unsetFields(val)
+		if !ok {
+			return false
+		}
+		touched = names
+	case "$unwind":
+		if path, ok := val.(string); ok {
+			touched = []string{strings.TrimPrefix(path, "$")}
+			break
+		}
+		path, _ := lookupKey(val, "path")
+		s, ok := path.(string)
+		if !ok {
+			return false
+		}
+		touched = []string{strings.TrimPrefix(s, "$")}
+		if idx, ok := lookupKey(val, "includeArrayIndex"); ok {
+			if s, ok := idx.(string); ok {
+				touched = append(touched, s)
+			}
+		}
+	case "$project":
+		spec, ok := val.(bson.D)
+		if !ok {
+			return false
+		}
+		return projectionPreserves(spec, fields)
+	default:
+		return false
+	}
+	for _, f := range fields {
+		for _, t := range touched {
+			if pathsConflict(f, t) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// projectionFlag classifies a $project value as include (1), exclude (0) or
+// computed (-1).
+func projectionFlag(v interface{}) int {
+	switch b := v.(type) {
+	case bool:
+		if b {
+			return 1
+		}
+		return 0
+	}
+	if n, ok := toInt64(v); ok {
+		if n == 0 {
+			return 0
+		}
+		return 1
+	}
+	return -1
+}
+
+// isExclusionProjection reports whether every entry of spec excludes a field.
+func isExclusionProjection(spec bson.D) bool {
+	for _, e := range spec {
+		if projectionFlag(e.Value) != 0 {
+			return false
+		}
+	}
+	return len(spec) > 0
+}
+
+// isInclusionProjection reports whether spec only includes fields, optionally
+// excluding _id.
+func isInclusionProjection(spec bson.D) bool {
+	included := false
+	for _, e := range spec {
+		switch projectionFlag(e.Value) {
+		case 1:
+			included = true
+		case 0:
+			if e.Key != "_id" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return included
+}
+
+// projectionPreserves reports whether every field survives spec unchanged.
+func projectionPreserves(spec bson.D, fields []string) bool {
+	if isExclusionProjection(spec) {
+		for _, f := range fields {
+			for _, e := range spec {
+				if pathsConflict(f, e.Key) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	for _, f := range fields {
+		covered := strings.Split(f, ".")[0] == "_id"
+		for _, e := range spec {
+			switch projectionFlag(e.Value) {
+			case 1:
+				if pathCovered(f, e.Key) {
+					covered = true
+				}
+			default:
+				if pathsConflict(f, e.Key) {
+					return false
+				}
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// unsetFields returns the field names removed by a $unset stage value.
+func unsetFields(v interface{}) ([]string, bool) {
+	switch u := v.(type) {
+	case string:
+		return []string{u}, true
+	case []string:
+		return u, true
+	case bson.A:
+		out := make([]string, 0, len(u))
+		for _, item := range u {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, s)
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// exclusionFields returns the fields removed by a $unset or exclusion $project stage.
+func exclusionFields(stage bson.D) ([]string, bool) {
+	switch stage[0].Key {
+	case "$unset":
+		return unsetFields(stage[0].Value)
+	case "$project":
+		spec, ok := stage[0].Value.(bson.D)
+		if !ok || !isExclusionProjection(spec) {
+			return nil, false
+		}
+		out := make([]string, len(spec))
+		for i, e := range spec {
+			out[i] = e.Key
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// mergeProjections merges two adjacent $project/$unset stages when both are
+// exclusions, or both are pure inclusion projections.
+func mergeProjections(a, b bson.D) (bson.D, bool) {
+	if ea, ok := exclusionFields(a); ok {
+		eb, ok := exclusionFields(b)
+		if !ok {
+			return nil, false
+		}
+		seen := make(map[string]bool, len(ea)+len(eb))
+		var fields []string
+		for _, f := range append(append([]string{}, ea...), eb...) {
+			if !seen[f] {
+				seen[f] = true
+				fields = append(fields, f)
+			}
+		}
+		if a[0].Key == "$project" {
+			return bson.D{{Key: "$project", Value: Exclude(fields...)}}, true
+		}
+		if len(fields) == 1 {
+			return bson.D{{Key: "$unset", Value: fields[0]}}, true
+		}
+		return bson.D{{Key: "$unset", Value: fields}}, true
+	}
+
+	if a[0].Key != "$project" || b[0].Key != "$project" {
+		return nil, false
+	}
+	sa, okA := a[0].Value.(bson.D)
+	sb, okB := b[0].Value.(bson.D)
+	if !okA || !okB || !isInclusionProjection(sa) || !isInclusionProjection(sb) {
+		return nil, false
+	}
+
+	excludeID := false
+	for _, e := range append(append(bson.D{}, sa...), sb...) {
+		if e.Key == "_id" && projectionFlag(e.Value) == 0 {
+			excludeID = true
+		}
+	}
+	// A field survives both stages when it is included by both; the narrower
+	// of two overlapping paths is what remains.
+	var merged bson.D
+	seen := make(map[string]bool)
+	for _, e := range sb {
+		if e.Key == "_id" || projectionFlag(e.Value) != 1 {
+			continue
+		}
+		for _, inc := range sa {
+			if inc.Key == "_id" || projectionFlag(inc.Value) != 1 {
+				continue
+			}
+			path := ""
+			switch {
+			case pathCovered(e.Key, inc.Key):
+				path = e.Key
+			case pathCovered(inc.Key, e.Key):
+				path = inc.Key
+			}
+			if path != "" && !seen[path] {
+				seen[path] = true
+				merged = append(merged, bson.E{Key: path, Value: 1})
+			}
+		}
+	}
+	if len(merged) == 0 {
+		return nil, false
+	}
+	if excludeID {
+		merged = append(merged, bson.E{Key: "_id", Value: 0})
+	}
+	return bson.D{{Key: "$project", Value: merged}}, true
+}
@@ -0,0 +1,145 @@
+package gmqb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func rules(r OptimizeReport) []string {
+	out := make([]string, len(r.Changes))
+	for i, c := range r.Changes {
+		out[i] = c.Rule
+	}
+	return out
+}
+
+func TestOptimize_NoChange(t *testing.T) {
+	p := NewPipeline().Match(Eq("a", 1)).Sort(Asc("a")).Limit(5)
+	opt, report := p.Optimize()
+	assert.False(t, report.Changed())
+	assert.Equal(t, p.CompactJSON(), opt.CompactJSON())
+}
+
+func TestOptimize_MergeMatch(t *testing.T) {
+	opt, report := NewPipeline().Match(Eq("a", 1)).Match(Gt("b", 2)).Optimize()
+	assert.Equal(t, []string{RuleMergeMatch}, rules(report))
+	assert.Equal(t, `[{"$match":{"a":{"$eq":1},"b":{"$gt":2}}}]`, opt.CompactJSON())
+
+	opt, _ = NewPipeline().Match(Gt("a", 1)).Match(Lt("a", 5)).Optimize()
+	assert.Equal(t, `[{"$match":{"$and":[{"a":{"$gt":1}},{"a":{"$lt":5}}]}}]`, opt.CompactJSON())
+}
+
+func TestOptimize_PushMatch(t *testing.T) {
+	p := NewPipeline().
+		Match(Eq("tenant", "t1")).
+		AddFields(AddFieldsSpec(AddField("total", ExprAdd("$a", "$b")))).
+		Match(Eq("status", "active"))
+	opt, report := p.Optimize()
+	assert.Equal(t, []string{RulePushMatch, RuleMergeMatch}, rules(report))
+	stages := opt.BsonD()
+	require.Len(t, stages, 2)
+	assert.Equal(t, "$match", stages[0][0].Key)
+	assert.Len(t, stages[0][0].Value, 2)
+	assert.Equal(t, "$addFields", stages[1][0].Key)
+}
+
+func TestOptimize_PushMatch_Blocked(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Pipeline
+	}{
+		{"ComputedField", NewPipeline().AddFields(AddFieldsSpec(AddField("total", 1))).Match(Eq("total", 1))},
+		{"NestedComputed", NewPipeline().SetFields(AddFieldsSpec(AddField("a.b", 1))).Match(Eq("a", 1))},
+		{"Unwound", NewPipeline().Unwind("$tags").Match(Eq("tags", "x"))},
+		{"UnwindIndex", NewPipeline().UnwindWithOpts(UnwindOpts{Path: "$tags", IncludeArrayIndex: "i"}).Match(Eq("i", 0))},
+		{"ProjectedAway", NewPipeline().Project(Include("name")).Match(Eq("age", 1))},
+		{"Excluded", NewPipeline().Project(Exclude("age")).Match(Eq("age", 1))},
+		{"Unset", NewPipeline().Unset("age").Match(Eq("age", 1))},
+		{"Expr", NewPipeline().Project(Include("a")).Match(Expr(bson.D{{Key: "$gt", Value: bson.A{"$a", 1}}}))},
+		{"Group", NewPipeline().Group(GroupSpec("$a")).Match(Eq("a", 1))},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, report := tc.p.Optimize()
+			assert.NotContains(t, rules(report), RulePushMatch)
+		})
+	}
+}
+
+func TestOptimize_PushMatch_Allowed(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Pipeline
+	}{
+		{"Included", NewPipeline().Project(Include("name", "age")).Match(Gt("age", 1))},
+		{"IncludedParent", NewPipeline().Project(Include("address")).Match(Eq("address.city", "x"))},
+		{"ImplicitID", NewPipeline().Project(Include("name")).Match(Eq("_id", 1))},
+		{"NotExcluded", NewPipeline().Project(Exclude("password")).Match(Eq("age", 1))},
+		{"Unwind", NewPipeline().Unwind("$tags").Match(Eq("age", 1))},
+		{"UnsetOther", NewPipeline().Unset("a", "b").Match(Or(Eq("c", 1), Eq("d", 2)))},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opt, report := tc.p.Optimize()
+			assert.Equal(t, []string{RulePushMatch}, rules(report))
+			assert.Equal(t, "$match", opt.BsonD()[0][0].Key)
+		})
+	}
+}
+
+func TestOptimize_CoalesceSortLimit(t *testing.T) {
+	p := NewPipeline().Sort(Desc("score")).Project(Include("score")).SetFields(AddFieldsSpec(AddField("x", 1))).Limit(10)
+	opt, report := p.Optimize()
+	assert.Equal(t, []string{RuleCoalesceSortLimit, RuleCoalesceSortLimit}, rules(report))
+	ops := []string{}
+	for _, s := range opt.BsonD() {
+		ops = append(ops, s[0].Key)
+	}
+	assert.Equal(t, []string{"$sort", "$limit", "$project", "$set"}, ops)
+
+	// No $sort before the one-to-one stages: the $limit stays put.
+	_, report = NewPipeline().Group(GroupSpec("$a")).Project(Include("a")).Limit(5).Optimize()
+	assert.False(t, report.Changed())
+}
+
+func TestOptimize_FoldSkipLimit(t *testing.T) {
+	opt, report := NewPipeline().Skip(10).Skip(5).Limit(20).Limit(7).Optimize()
+	assert.Equal(t, []string{RuleFoldSkip, RuleFoldLimit}, rules(report))
+	assert.Equal(t, `[{"$skip":15},{"$limit":7}]`, opt.CompactJSON())
+}
+
+func TestOptimize_MergeProjection(t *testing.T) {
+	opt, report := NewPipeline().Unset("a").Unset("b", "c").Optimize()
+	assert.Equal(t, []string{RuleMergeProjection}, rules(report))
+	assert.Equal(t, `[{"$unset":["a","b","c"]}]`, opt.CompactJSON())
+
+	opt, _ = NewPipeline().Project(Exclude("a")).Unset("b").Optimize()
+	assert.Equal(t, `[{"$project":{"a":0,"b":0}}]`, opt.CompactJSON())
+
+	opt, _ = NewPipeline().
+		Project(bson.D{{Key: "name", Value: 1}, {Key: "address", Value: 1}, {Key: "_id", Value: 0}}).
+		Project(Include("name", "address.city", "missing")).
+		Optimize()
+	assert.Equal(t, `[{"$project":{"name":1,"address.city":1,"_id":0}}]`, opt.CompactJSON())
+
+	// Computed projections and inclusion-after-exclusion are not merged.
+	_, report = NewPipeline().Project(bson.D{{Key: "x", Value: "$y"}}).Project(Include("x")).Optimize()
+	assert.False(t, report.Changed())
+	_, report = NewPipeline().Unset("a").Project(Include("b")).Optimize()
+	assert.False(t, report.Changed())
+}
+
+func TestOptimize_Immutable(t *testing.T) {
+	p := NewPipeline().Skip(1).Skip(2)
+	before := p.CompactJSON()
+	_, _ = p.Optimize()
+	assert.Equal(t, before, p.CompactJSON())
+}
+
+func TestOptimizeReport_String(t *testing.T) {
+	_, report := NewPipeline().Skip(1).Skip(2).Optimize()
+	assert.Equal(t, "fold-skip: folded $skip 1 + $skip 2 into $skip 3", report.String())
+}
@@ -174,3 +174,80 @@ func ExampleNewPipeline() {
 		Limit(10)
 	_ = p.BsonD()
 }
+
+func assertPipelineJSON(t *testing.T, p Pipeline, expected string) {
+	t.Helper()
+	assert.JSONEq(t, expected, p.CompactJSON())
+}
+
+func TestPipeline_Documents(t *testing.T) {
+	p := NewPipeline().Documents(bson.D{{Key: "x", Value: 1}}, bson.D{{Key: "x", Value: 2}})
+	assertPipelineJSON(t, p, `[{"$documents":[{"x":1},{"x":2}]}]`)
+}
+
+func TestPipeline_CollStats(t *testing.T) {
+	assertPipelineJSON(t, NewPipeline().CollStats(CollStatsOpts{}), `[{"$collStats":{}}]`)
+	p := NewPipeline().CollStats(CollStatsOpts{
+		LatencyHistograms: true,
+		StorageScale:      1024,
+		Count:             true,
+		QueryExecStats:    true,
+	})
+	assertPipelineJSON(t, p, `[{"$collStats":{"latencyStats":{"histograms":true},"storageStats":{"scale":1024},"count":{},"queryExecStats":{}}}]`)
+	p = NewPipeline().CollStats(CollStatsOpts{LatencyStats: true, StorageStats: true})
+	assertPipelineJSON(t, p, `[{"$collStats":{"latencyStats":{},"storageStats":{}}}]`)
+}
+
+func TestPipeline_IndexStats(t *testing.T) {
+	assertPipelineJSON(t, NewPipeline().IndexStats(), `[{"$indexStats":{}}]`)
+}
+
+func TestPipeline_PlanCacheStats(t *testing.T) {
+	assertPipelineJSON(t, NewPipeline().PlanCacheStats(PlanCacheStatsOpts{}), `[{"$planCacheStats":{}}]`)
+	assertPipelineJSON(t, NewPipeline().PlanCacheStats(PlanCacheStatsOpts{AllHosts: true}), `[{"$planCacheStats":{"allHosts":true}}]`)
+}
+
+func TestPipeline_CurrentOp(t *testing.T) {
+	idle := false
+	p := NewPipeline().CurrentOp(CurrentOpOpts{
+		AllUsers:        true,
+		IdleConnections: true,
+		IdleCursors:     true,
+		IdleSessions:    &idle,
+		LocalOps:        true,
+		TargetAllNodes:  true,
+	})
+	assertPipelineJSON(t, p, `[{"$currentOp":{"allUsers":true,"idleConnections":true,"idleCursors":true,"idleSessions":false,"localOps":true,"targetAllNodes":true}}]`)
+	assertPipelineJSON(t, NewPipeline().CurrentOp(CurrentOpOpts{}), `[{"$currentOp":{}}]`)
+}
+
+func TestPipeline_ListSessions(t *testing.T) {
+	p := NewPipeline().ListSessions(ListSessionsOpts{Users: []SessionUser{{User: "app", DB: "admin"}}})
+	assertPipelineJSON(t, p, `[{"$listSessions":{"users":[{"user":"app","db":"admin"}]}}]`)
+	assertPipelineJSON(t, NewPipeline().ListSessions(ListSessionsOpts{AllUsers: true}), `[{"$listSessions":{"allUsers":true}}]`)
+}
+
+func TestPipeline_ListSearchIndexes(t *testing.T) {
+	assertPipelineJSON(t, NewPipeline().ListSearchIndexes(ListSearchIndexesOpts{}), `[{"$listSearchIndexes":{}}]`)
+	assertPipelineJSON(t, NewPipeline().ListSearchIndexes(ListSearchIndexesOpts{Name: "default"}), `[{"$listSearchIndexes":{"name":"default"}}]`)
+	assertPipelineJSON(t, NewPipeline().ListSearchIndexes(ListSearchIndexesOpts{ID: "abc"}), `[{"$listSearchIndexes":{"id":"abc"}}]`)
+}
+
+func TestPipeline_ChangeStream(t *testing.T) {
+	p := NewPipeline().ChangeStream(ChangeStreamOpts{
+		AllChangesForCluster:     true,
+		FullDocument:             "updateLookup",
+		FullDocumentBeforeChange: "whenAvailable",
+		ResumeAfter:              bson.D{{Key: "_data", Value: "token"}},
+		StartAtOperationTime:     &bson.Timestamp{T: 1, I: 2},
+		ShowExpandedEvents:       true,
+	})
+	assertPipelineJSON(t, p, `[{"$changeStream":{"allChangesForCluster":true,"fullDocument":"updateLookup","fullDocumentBeforeChange":"whenAvailable","resumeAfter":{"_data":"token"},"startAtOperationTime":{"$timestamp":{"t":1,"i":2}},"showExpandedEvents":true}}]`)
+	p = NewPipeline().ChangeStream(ChangeStreamOpts{StartAfter: bson.D{{Key: "_data", Value: "t"}}}).ChangeStreamSplitLargeEvent()
+	assertPipelineJSON(t, p, `[{"$changeStream":{"startAfter":{"_data":"t"}}},{"$changeStreamSplitLargeEvent":{}}]`)
+	assert.NoError(t, p.Validate())
+}
+
+func TestPipeline_ShardedDataDistribution(t *testing.T) {
+	assertPipelineJSON(t, NewPipeline().ShardedDataDistribution(), `[{"$shardedDataDistribution":{}}]`)
+}
@@ -0,0 +1,274 @@
+package gmqb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// firstOnlyStages lists stages that MongoDB only accepts as the first stage of a pipeline.
+var firstOnlyStages = map[string]bool{
+	"$changeStream":            true,
+	"$collStats":               true,
+	"$currentOp":               true,
+	"$documents":               true,
+	"$geoNear":                 true,
+	"$indexStats":              true,
+	"$listLocalSessions":       true,
+	"$listSearchIndexes":       true,
+	"$listSessions":            true,
+	"$planCacheStats":          true,
+	"$search":                  true,
+	"$searchMeta":              true,
+	"$shardedDataDistribution": true,
+	"$vectorSearch":            true,
+}
+
+// lastOnlyStages lists stages that MongoDB only accepts as the last stage of a pipeline.
+var lastOnlyStages = map[string]bool{
+	"$out":                         true,
+	"$merge":                       true,
+	"$changeStreamSplitLargeEvent": true,
+}
+
+// facetForbiddenStages lists stages that cannot appear inside a $facet sub-pipeline.
+//
+// See: https://www.mongodb.com/docs/manual/reference/operator/aggregation/facet/#behavior
+var facetForbiddenStages = map[string]bool{
+	"$collStats":      true,
+	"$facet":          true,
+	"$geoNear":        true,
+	"$indexStats":     true,
+	"$out":            true,
+	"$merge":          true,
+	"$planCacheStats": true,
+	"$search":         true,
+	"$searchMeta":     true,
+	"$vectorSearch":   true,
+}
+
+// Validate performs static checks on the pipeline structure and reports mistakes
+// that the server would otherwise reject at execution time. It is most useful for
+// pipelines assembled from reusable fragments or containing RawStage entries.
+//
+// The following rules are checked:
+//   - each stage is a single-key document whose key is a "$" operator
+//   - $out and $merge appear only as the last stage, and $out at most once
+//   - $geoNear, $collStats, $documents, $changeStream and other source stages appear only first
+//   - $facet sub-pipelines contain no forbidden stages ($out, $merge, $facet, $geoNear, ...)
+//   - $unionWith and $lookup sub-pipelines contain no $out or $merge
+//   - $limit is positive, $skip is non-negative, and $sample size is non-negative
+//
+// All violations are returned joined together; each wraps ErrInvalidPipeline and names
+// the stage index and operator. Violations inside sub-pipelines are reported with their
+// path, e.g. "stage 1 ($facet) > facet \"byAge\" > stage 0 ($out)".
+//
+// Example:
+//
+//	p := gmqb.NewPipeline().Out("archive").Match(gmqb.Eq("a", 1))
+//	if err := p.Validate(); err != nil {
+//	    // gmqb: invalid pipeline: stage 0 ($out): must be the last stage
+//	}
+func (p Pipeline) Validate() error {
+	return errors.Join(validateStages(p.stages, "", pipelineRoot)...)
+}
+
+// pipelineContext identifies where a (sub-)pipeline is embedded, which decides
+// which positional rules apply.
+type pipelineContext int
+
+const (
+	pipelineRoot pipelineContext = iota
+	pipelineFacet
+	pipelineUnionWith
+	pipelineLookup
+)
+
+// validateStages checks a list of stages and returns every violation found.
+// prefix is prepended to the stage location in error messages.
+func validateStages(stages []bson.D, prefix string, ctx pipelineContext) []error {
+	var errs []error
+	outCount := 0
+
+	for i, stage := range stages {
+		loc := fmt.Sprintf("%sstage %d", prefix, i)
+		if len(stage) != 1 {
+			errs = append(errs, fmt.Errorf("%w: %s: stage document must have exactly one operator, got %d", ErrInvalidPipeline, loc, len(stage)))
+			continue
+		}
+		op := stage[0].Key
+		val := stage[0].Value
+		loc = fmt.Sprintf("%s (%s)", loc, op)
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Errorf("%w: %s: %s", ErrInvalidPipeline, loc, fmt.Sprintf(format, args...)))
+		}
+
+		if !strings.HasPrefix(op, "$") {
+			fail("stage operator must start with '$'")
+			continue
+		}
+
+		switch ctx {
+		case pipelineFacet:
+			if facetForbiddenStages[op] {
+				fail("not allowed inside a $facet sub-pipeline")
+				continue
+			}
+		case pipelineUnionWith, pipelineLookup:
+			if op == "$out" || op == "$merge" {
+				fail("not allowed inside a sub-pipeline")
+				continue
+			}
+		}
+
+		if firstOnlyStages[op] && i != 0 {
+			fail("must be the first stage")
+		}
+		if lastOnlyStages[op] && i != len(stages)-1 {
+			fail("must be the last stage")
+		}
+		if op == "$out" {
+			outCount++
+			if outCount == 2 {
+				fail("pipeline contains more than one $out stage")
+			}
+		}
+
+		switch op {
+		case "$limit":
+			if n, ok := toInt64(val); !ok || n <= 0 {
+				fail("limit must be a positive integer, got %v", val)
+			}
+		case "$skip":
+			if n, ok := toInt64(val); !ok || n < 0 {
+				fail("skip must be a non-negative integer, got %v", val)
+			}
+		case "$sample":
+			size, found := lookupKey(val, "size")
+			if n, ok := toInt64(size); !found || !ok || n < 0 {
+				fail("sample size must be a non-negative integer, got %v", size)
+			}
+		case "$facet":
+			for _, e := range documentElems(val) {
+				sub, ok := subPipeline(e.Value)
+				if !ok {
+					fail("facet %q must be an array of stages", e.Key)
+					continue
+				}
+				errs = append(errs, validateStages(sub, fmt.Sprintf("%s > facet %q > ", loc, e.Key), pipelineFacet)...)
+			}
+		case "$unionWith":
+			if v, found := lookupKey(val, "pipeline"); found {
+				sub, ok := subPipeline(v)
+				if !ok {
+					fail("pipeline must be an array of stages")
+					continue
+				}
+				errs = append(errs, validateStages(sub, loc+" > ", pipelineUnionWith)...)
+			}
+		case "$lookup":
+			if v, found := lookupKey(val, "pipeline"); found {
+				sub, ok := subPipeline(v)
+				if !ok {
+					fail("pipeline must be an array of stages")
+					continue
+				}
+				errs = append(errs, validateStages(sub, loc+" > ", pipelineLookup)...)
+			}
+		}
+	}
+	return errs
+}
+
+// toInt64 converts any Go numeric value to int64. Fractional floats are rejected.
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	case float32:
+		if float32(int64(n)) == n {
+			return int64(n), true
+		}
+	case float64:
+		if float64(int64(n)) == n {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+// documentElems returns the elements of a document value given as bson.D or bson.M.
+func documentElems(v interface{}) bson.D {
+	switch d := v.(type) {
+	case bson.D:
+		return d
+	case bson.M:
+		out := make(bson.D, 0, len(d))
+		for k, val := range d {
+			out = append(out, bson.E{Key: k, Value: val})
+		}
+		return out
+	case map[string]interface{}:
+		return documentElems(bson.M(d))
+	}
+	return nil
+}
+
+// lookupKey returns the value stored under key in a bson.D or bson.M document.
+func lookupKey(v interface{}, key string) (interface{}, bool) {
+	for _, e := range documentElems(v) {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// subPipeline converts a sub-pipeline value ([]bson.D, bson.A, Pipeline) to []bson.D.
+func subPipeline(v interface{}) ([]bson.D, bool) {
+	switch s := v.(type) {
+	case []bson.D:
+		return s, true
+	case Pipeline:
+		return s.stages, true
+	case bson.A:
+		return docSlice(s)
+	case []interface{}:
+		return docSlice(s)
+	}
+	return nil, false
+}
+
+// docSlice converts a slice of document values to []bson.D.
+func docSlice(items []interface{}) ([]bson.D, bool) {
+	out := make([]bson.D, 0, len(items))
+	for _, item := range items {
+		switch item.(type) {
+		case bson.D, bson.M, map[string]interface{}:
+			out = append(out, documentElems(item))
+		default:
+			return nil, false
+		}
+	}
+	return out, true
+}
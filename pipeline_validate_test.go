@@ -0,0 +1,77 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestPipeline_Validate_Valid(t *testing.T) {
+	archived := NewPipeline().Match(Eq("a", 1))
+	p := NewPipeline().
+		GeoNear(GeoNearOpts{Near: Point(0, 0), DistanceField: "dist", Spherical: true}).
+		Match(Eq("active", true)).
+		Facet(map[string]Pipeline{
+			"top":   NewPipeline().Sort(Desc("score")).Limit(5),
+			"total": NewPipeline().Count("n"),
+		}).
+		UnionWith("archive", &archived).
+		Skip(0).
+		Sample(3).
+		Merge(MergeOpts{Into: "out"})
+	assert.NoError(t, p.Validate())
+	assert.NoError(t, NewPipeline().Validate())
+}
+
+func TestPipeline_Validate_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       Pipeline
+		wantMsg string
+	}{
+		{"OutNotLast", NewPipeline().Out("a").Match(Eq("x", 1)), "stage 0 ($out): must be the last stage"},
+		{"MergeNotLast", NewPipeline().Merge(MergeOpts{Into: "a"}).Limit(1), "stage 0 ($merge): must be the last stage"},
+		{"MultipleOut", NewPipeline().Out("a").Out("b"), "stage 1 ($out): pipeline contains more than one $out stage"},
+		{"GeoNearNotFirst", NewPipeline().Match(Eq("x", 1)).GeoNear(GeoNearOpts{Near: Point(0, 0), DistanceField: "d"}), "stage 1 ($geoNear): must be the first stage"},
+		{"CollStatsNotFirst", NewPipeline().Limit(1).RawStage("$collStats", bson.D{}), "stage 1 ($collStats): must be the first stage"},
+		{"DocumentsNotFirst", NewPipeline().Limit(1).RawStage("$documents", bson.A{}), "stage 1 ($documents): must be the first stage"},
+		{"ChangeStreamNotFirst", NewPipeline().Limit(1).RawStage("$changeStream", bson.D{}), "stage 1 ($changeStream): must be the first stage"},
+		{"FacetForbidden", NewPipeline().Facet(map[string]Pipeline{"x": NewPipeline().Out("a")}), `stage 0 ($facet) > facet "x" > stage 0 ($out): not allowed inside a $facet sub-pipeline`},
+		{"FacetNested", NewPipeline().Facet(map[string]Pipeline{"x": NewPipeline().Facet(map[string]Pipeline{})}), `stage 0 ($facet) > facet "x" > stage 0 ($facet): not allowed`},
+		{"LimitZero", NewPipeline().Limit(0), "stage 0 ($limit): limit must be a positive integer"},
+		{"LimitNotNumber", NewPipeline().RawStage("$limit", "10"), "stage 0 ($limit): limit must be a positive integer"},
+		{"NegativeSkip", NewPipeline().Skip(-1), "stage 0 ($skip): skip must be a non-negative integer"},
+		{"NegativeSample", NewPipeline().Sample(-1), "stage 0 ($sample): sample size must be a non-negative integer"},
+		{"UnionWithOut", NewPipeline().RawStage("$unionWith", bson.D{{Key: "coll", Value: "a"}, {Key: "pipeline", Value: bson.A{bson.D{{Key: "$out", Value: "b"}}}}}), "stage 0 ($unionWith) > stage 0 ($out): not allowed inside a sub-pipeline"},
+		{"LookupMerge", NewPipeline().LookupPipeline(LookupPipelineOpts{From: "a", Pipeline: NewPipeline().Merge(MergeOpts{Into: "b"}), As: "x"}), "stage 0 ($lookup) > stage 0 ($merge): not allowed inside a sub-pipeline"},
+		{"RawMissingDollar", NewPipeline().RawStage("match", bson.D{}), "stage 0 (match): stage operator must start with '$'"},
+		{"RawMultiKey", Pipeline{stages: []bson.D{{{Key: "$match", Value: bson.D{}}, {Key: "$limit", Value: 1}}}}, "stage 0: stage document must have exactly one operator, got 2"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.p.Validate()
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidPipeline))
+			assert.Contains(t, err.Error(), tc.wantMsg)
+		})
+	}
+}
+
+func TestPipeline_Validate_ReportsAllViolations(t *testing.T) {
+	err := NewPipeline().Out("a").Limit(0).Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "stage 0 ($out)")
+	assert.Contains(t, err.Error(), "stage 1 ($limit)")
+}
+
+func TestAggregate_WithValidation(t *testing.T) {
+	// Validation fails before the collection is touched, so a nil mongo.Collection is safe.
+	coll := Wrap[bson.M](nil)
+	_, err := Aggregate[bson.M](coll, context.Background(), NewPipeline().Out("a").Limit(1), WithValidation())
+	assert.ErrorIs(t, err, ErrInvalidPipeline)
+}
@@ -14,6 +14,9 @@ import (
 type CappedOpts struct {
 	SizeBytes int64 // ring-buffer byte limit (required; MongoDB min is 4096)
 	MaxDocs   int64 // optional document count cap (0 = no limit)
+
+	// Observer, if set, is notified of every event published and delivered.
+	Observer MessageObserver
 }
 
 // DefaultCappedOpts is a sensible default: 5 MB ring buffer, no doc limit.
@@ -23,8 +26,9 @@ var DefaultCappedOpts = CappedOpts{SizeBytes: 5 * 1024 * 1024}
 // and tailable cursors. It is suitable for standalone MongoDB deployments where
 // change streams are not available.
 type TailablePubSub[T any] struct {
-	coll *mongo.Collection
-	opts CappedOpts
+	coll  *mongo.Collection
+	topic string
+	opts  CappedOpts
 }
 
 // NewTailablePubSub creates a TailablePubSub that uses a capped collection named topic.
@@ -58,20 +62,29 @@ func NewTailablePubSub[T any](db *mongo.Database, topic string, opts CappedOpts)
 	}
 
 	return &TailablePubSub[T]{
-		coll: db.Collection(topic),
-		opts: opts,
+		coll:  db.Collection(topic),
+		topic: topic,
+		opts:  opts,
 	}, nil
 }
 
 // envelope wraps the user payload so we can extend metadata later without
 // breaking the BSON layout of existing documents.
 type envelope[T any] struct {
-	Payload T `bson:"p"`
+	Payload T                 `bson:"p"`
+	Headers map[string]string `bson:"h,omitempty"`
 }
 
 // Publish inserts a typed document into the capped collection.
 func (ps *TailablePubSub[T]) Publish(ctx context.Context, event T) error {
-	_, err := ps.coll.InsertOne(ctx, envelope[T]{Payload: event})
+	m := MessageInfo{Kind: MessagePubSub, Destination: ps.topic, Headers: map[string]string{}}
+	ctx, done := observeSend(ctx, ps.opts.Observer, &m)
+	env := envelope[T]{Payload: event}
+	if len(m.Headers) > 0 {
+		env.Headers = m.Headers
+	}
+	_, err := ps.coll.InsertOne(ctx, env)
+	done(err)
 	return err
 }
 
@@ -143,9 +156,16 @@ func (ps *TailablePubSub[T]) tailOnce(ctx context.Context, ch chan<- T) {
 			continue
 		}
 
+		_, done := observeReceive(ctx, ps.opts.Observer, &MessageInfo{
+			Kind:        MessagePubSub,
+			Destination: ps.topic,
+			Headers:     env.Headers,
+		})
 		select {
 		case ch <- env.Payload:
+			done(nil)
 		case <-ctx.Done():
+			done(ctx.Err())
 			return
 		}
 	}
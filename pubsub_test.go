@@ -233,3 +233,30 @@ func ExampleTailablePubSub() {
 	// 3. Publish an event.
 	_ = bus.Publish(ctx, UserEvent{UserID: "123", Type: "signup"})
 }
+
+func TestTailablePubSub_ObserverHeaders(t *testing.T) {
+	db, _ := startStandalone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	obs := &recordingObserver{}
+	opts := gmqb.DefaultCappedOpts
+	opts.Observer = obs
+	bus, err := gmqb.NewTailablePubSub[MyEvent](db, "observed_topic", opts)
+	require.NoError(t, err)
+
+	ch, stop := bus.Subscribe(ctx)
+	defer stop()
+	require.NoError(t, bus.Publish(ctx, MyEvent{ID: 1}))
+
+	select {
+	case ev := <-ch:
+		assert.Equal(t, 1, ev.ID)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for event")
+	}
+	received := obs.Received()
+	require.Len(t, received, 1)
+	assert.Equal(t, gmqb.MessagePubSub, received[0].Kind)
+	assert.Equal(t, "producer:observed_topic", received[0].Headers["origin"])
+}
@@ -2,6 +2,7 @@ package gmqb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -77,6 +78,31 @@ func (q *Queue[T]) Enqueue(ctx context.Context, payload T, opts ...EnqueueOpt) (
 		CreatedAt:   time.Now(),
 	}
 
+	m := MessageInfo{Kind: MessageQueue, Destination: q.name, ID: cfg.ID.Hex(), Headers: map[string]string{}}
+	ctx, done := observeSend(ctx, q.opts.Observer, &m)
+	if len(m.Headers) > 0 {
+		doc.Headers = m.Headers
+	}
+	id, err := q.insert(ctx, &doc, cfg)
+	done(err)
+	return id, err
+}
+
+// insert stores doc and wakes in-process workers.
+func (q *Queue[T]) insert(ctx context.Context, doc *queueDoc[T], cfg EnqueueConfig) (bson.ObjectID, error) {
+	// A duplicate key error aborts a transaction, so inside one an idempotent
+	// enqueue checks for the message up front instead of swallowing the error.
+	if cfg.Idempotent && InTransaction(ctx) {
+		err := q.coll.FindOne(ctx, bson.D{{Key: "_id", Value: cfg.ID}},
+			options.FindOne().SetProjection(bson.D{{Key: "_id", Value: 1}})).Err()
+		if err == nil {
+			return cfg.ID, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return bson.NilObjectID, fmt.Errorf("gmqb queue: enqueue: %w", err)
+		}
+	}
+
 	_, err := q.coll.InsertOne(ctx, doc)
 	if err != nil {
 		if cfg.Idempotent && mongo.IsDuplicateKeyError(err) {
@@ -85,11 +111,14 @@ func (q *Queue[T]) Enqueue(ctx context.Context, payload T, opts ...EnqueueOpt) (
 		return bson.NilObjectID, fmt.Errorf("gmqb queue: enqueue: %w", err)
 	}
 
-	// Trigger wake channel for in-process workers
-	select {
-	case q.wake <- struct{}{}:
-	default:
-	}
+	// Trigger wake channel for in-process workers; inside a transaction the
+	// message only becomes visible once it commits.
+	afterCommit(ctx, func(context.Context) {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	})
 
 	return doc.ID, nil
 }
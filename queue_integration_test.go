@@ -2,6 +2,7 @@ package gmqb_test
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -154,3 +155,57 @@ func TestQueue_Integration_ExactlyOnce(t *testing.T) {
 	time.Sleep(500 * time.Millisecond)
 	assert.Equal(t, int32(1), atomic.LoadInt32(&processed))
 }
+
+// recordingObserver stamps a header on every message it sees sent and
+// records the messages it sees received.
+type recordingObserver struct {
+	mu       sync.Mutex
+	received []gmqb.MessageInfo
+}
+
+func (o *recordingObserver) Send(ctx context.Context, m *gmqb.MessageInfo) (context.Context, func(error)) {
+	m.Headers["origin"] = "producer:" + m.Destination
+	return ctx, func(error) {}
+}
+
+func (o *recordingObserver) Receive(ctx context.Context, m *gmqb.MessageInfo) (context.Context, func(error)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.received = append(o.received, *m)
+	return ctx, func(error) {}
+}
+
+func (o *recordingObserver) Received() []gmqb.MessageInfo {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]gmqb.MessageInfo(nil), o.received...)
+}
+
+func TestQueue_Integration_ObserverHeaders(t *testing.T) {
+	db, _ := startStandalone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	type Job struct {
+		ID int `bson:"id"`
+	}
+
+	obs := &recordingObserver{}
+	opts := gmqb.DefaultQueueOpts
+	opts.Observer = obs
+	q, _ := gmqb.NewQueue[Job](db, "observer_test", opts)
+	_ = q.EnsureIndexes(ctx)
+
+	w := gmqb.NewWorker(q, func(ctx context.Context, payload Job) error { return nil }, gmqb.WithConsumerGroup("audit"))
+	go w.Run(ctx)
+
+	id, err := q.Enqueue(ctx, Job{ID: 1})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(obs.Received()) == 1 }, 5*time.Second, 100*time.Millisecond)
+	m := obs.Received()[0]
+	assert.Equal(t, gmqb.MessageQueue, m.Kind)
+	assert.Equal(t, id.Hex(), m.ID)
+	assert.Equal(t, "audit", m.Group)
+	assert.Equal(t, map[string]string{"origin": "producer:observer_test"}, m.Headers)
+}
@@ -12,15 +12,16 @@ import (
 
 // queueDoc is the internal wrapper for messages stored in MongoDB.
 type queueDoc[T any] struct {
-	ID          bson.ObjectID `bson:"_id,omitempty"`
-	Payload     T             `bson:"p"`
-	Status      string        `bson:"s"` // pending | processing | done | dead
-	Attempts    int           `bson:"a"`
-	MaxAttempts int           `bson:"ma"`
-	ClaimedAt   *time.Time    `bson:"ca,omitempty"` // visibility timeout anchor
-	ClaimedBy   string        `bson:"cb,omitempty"` // worker instance ID
-	CreatedAt   time.Time     `bson:"t"`
-	FailedGroup string        `bson:"fg,omitempty"` // populated only in DLQ for fan-out messages
+	ID          bson.ObjectID     `bson:"_id,omitempty"`
+	Payload     T                 `bson:"p"`
+	Status      string            `bson:"s"` // pending | processing | done | dead
+	Attempts    int               `bson:"a"`
+	MaxAttempts int               `bson:"ma"`
+	ClaimedAt   *time.Time        `bson:"ca,omitempty"` // visibility timeout anchor
+	ClaimedBy   string            `bson:"cb,omitempty"` // worker instance ID
+	CreatedAt   time.Time         `bson:"t"`
+	FailedGroup string            `bson:"fg,omitempty"` // populated only in DLQ for fan-out messages
+	Headers     map[string]string `bson:"h,omitempty"`  // MessageObserver metadata, e.g. trace context
 }
 
 const (
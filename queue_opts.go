@@ -51,6 +51,8 @@ type QueueOpts struct {
 	// RetentionTTL is the retention period for messages in the primary queue.
 	// This is especially important for Fan-Out mode. Default is 7 days.
 	RetentionTTL time.Duration
+	// Observer, if set, is notified of every message enqueued and handled.
+	Observer MessageObserver
 }
 
 // DefaultQueueOpts provides sensible defaults for a Queue.
@@ -184,7 +184,15 @@ func (w *Worker[T]) process(ctx context.Context, doc *queueDoc[T]) {
 		}
 	}
 
-	err := w.handler(ctx, doc.Payload)
+	hctx, done := observeReceive(ctx, w.q.opts.Observer, &MessageInfo{
+		Kind:        MessageQueue,
+		Destination: w.q.name,
+		ID:          doc.ID.Hex(),
+		Group:       w.config.ConsumerGroup,
+		Headers:     doc.Headers,
+	})
+	err := w.handler(hctx, doc.Payload)
+	done(err)
 
 	if w.config.Delivery == AtMostOnce {
 		return // Already done via claimOne targetStatus
@@ -0,0 +1,280 @@
+package gmqb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"regexp"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// shapePlaceholder stands in for every literal value in a query shape.
+const shapePlaceholder = "?"
+
+// fieldPathPattern matches aggregation field paths and variables such as
+// "$total", "$items.sku" and "$$ROOT", which Redact keeps verbatim inside
+// aggregation expressions. Elsewhere such a string is a literal.
+var fieldPathPattern = regexp.MustCompile(`^\$\$?[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// RedactMode selects how Redact hides literal values.
+type RedactMode int
+
+const (
+	// RedactPlaceholder replaces every literal with "?" and collapses an
+	// array of literals to ["?"].
+	RedactPlaceholder RedactMode = iota
+	// RedactHash replaces every literal with "sha256:" and the first 12 hex
+	// digits of its digest, so equal values can be correlated across
+	// entries without being revealed.
+	RedactHash
+)
+
+// RedactOpts configures Redact.
+type RedactOpts struct {
+	// Mode selects how literal values are hidden.
+	Mode RedactMode
+	// Allow lists the dotted field paths whose values are shown as is, e.g.
+	// "status" or "items.sku". Operators do not count towards a path, so
+	// "items.sku" also covers {items: {$elemMatch: {sku: ...}}}.
+	Allow []string
+}
+
+// Redact renders a Filter, Updater, Pipeline or BSON document as compact
+// JSON with literal values hidden. Field names, operators and the field
+// paths of aggregation expressions such as "$total" are kept, so the output
+// stays readable. Expressions are the bodies of pipeline stages other than
+// $match and the operands of $expr; in filters, $match and updates a string
+// like "$total" is a literal and is hidden.
+//
+// Example:
+//
+//	gmqb.Redact(gmqb.And(gmqb.Eq("status", "paid"), gmqb.Eq("email", "a@b.c")),
+//	    gmqb.RedactOpts{Allow: []string{"status"}})
+//	// {"$and":[{"status":{"$eq":"paid"}},{"email":{"$eq":"?"}}]}
+func Redact(v any, opts RedactOpts) string {
+	r := redactor{mode: opts.Mode}
+	if len(opts.Allow) > 0 {
+		r.allow = make(map[string]bool, len(opts.Allow))
+		for _, p := range opts.Allow {
+			r.allow[p] = true
+		}
+	}
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case Pipeline:
+		return pipelineToCompactJSON(r.stages(x.stages))
+	case []bson.D:
+		return pipelineToCompactJSON(r.stages(x))
+	case Serializable:
+		return toCompactJSON(r.value(x.BsonD(), "", false).(bson.D))
+	case UpdateDoc:
+		return Redact(x.updatePayload(), opts)
+	case bson.D:
+		return toCompactJSON(r.value(x, "", false).(bson.D))
+	case bson.M:
+		return toCompactJSON(r.value(sortedDoc(x), "", false).(bson.D))
+	}
+	return r.literal(v)
+}
+
+// QueryShape renders v like Redact with default options: every literal
+// becomes "?", so queries that differ only in their values share one shape.
+// It is meant for labelling logs, metrics and traces.
+//
+// Example:
+//
+//	gmqb.QueryShape(gmqb.And(gmqb.Eq("status", "paid"), gmqb.In("sku", "a", "b")))
+//	// {"$and":[{"status":{"$eq":"?"}},{"sku":{"$in":["?"]}}]}
+func QueryShape(v any) string {
+	return Redact(v, RedactOpts{})
+}
+
+// redactor walks a document, hiding literals outside the allowed paths.
+type redactor struct {
+	mode  RedactMode
+	allow map[string]bool
+}
+
+// stages redacts every stage of a pipeline.
+func (r redactor) stages(stages []bson.D) []bson.D {
+	out := make([]bson.D, len(stages))
+	for i, s := range stages {
+		out[i] = make(bson.D, len(s))
+		for j, e := range s {
+			out[i][j] = bson.E{Key: e.Key, Value: r.stageBody(e.Key, e.Value)}
+		}
+	}
+	return out
+}
+
+// stageBody redacts the body of the stage named name. A $match body is a
+// query; the bodies of other stages are expressions, apart from the nested
+// pipelines and filters a few of them take.
+func (r redactor) stageBody(name string, body any) any {
+	if name == "$match" {
+		return r.value(body, "", false)
+	}
+	doc, ok := docValue(body)
+	if !ok {
+		return r.value(body, "", true)
+	}
+	out := make(bson.D, len(doc))
+	for i, e := range doc {
+		var v any
+		switch {
+		case name == "$facet":
+			v = r.pipeline(e.Value)
+		case (name == "$lookup" || name == "$unionWith") && e.Key == "pipeline":
+			v = r.pipeline(e.Value)
+		case name == "$graphLookup" && e.Key == "restrictSearchWithMatch",
+			name == "$geoNear" && e.Key == "query":
+			v = r.value(e.Value, "", false)
+		default:
+			v = r.value(e.Value, childPath("", e.Key), true)
+		}
+		out[i] = bson.E{Key: e.Key, Value: v}
+	}
+	return out
+}
+
+// pipeline redacts a nested pipeline given as a Pipeline or an array of
+// stage documents.
+func (r redactor) pipeline(v any) any {
+	switch x := v.(type) {
+	case Pipeline:
+		return bson.A(stagesToA(r.stages(x.stages)))
+	case []bson.D:
+		return bson.A(stagesToA(r.stages(x)))
+	case bson.A:
+		return r.pipeline([]interface{}(x))
+	case []interface{}:
+		stages := make([]bson.D, len(x))
+		for i, item := range x {
+			doc, ok := docValue(item)
+			if !ok {
+				return r.value(v, "", true)
+			}
+			stages[i] = doc
+		}
+		return bson.A(stagesToA(r.stages(stages)))
+	}
+	return r.value(v, "", true)
+}
+
+// value returns a copy of v, found at field path, with literals hidden.
+// Documents are returned as bson.D, with map keys in sorted order. expr
+// reports whether v is part of an aggregation expression, where field
+// paths are kept.
+func (r redactor) value(v any, path string, expr bool) any {
+	if r.allow[path] {
+		return v
+	}
+	switch x := v.(type) {
+	case bson.D:
+		out := make(bson.D, len(x))
+		for i, e := range x {
+			childExpr := expr
+			switch e.Key {
+			case "$expr":
+				childExpr = true
+			case "$literal":
+				childExpr = false
+			}
+			out[i] = bson.E{Key: e.Key, Value: r.value(e.Value, childPath(path, e.Key), childExpr)}
+		}
+		return out
+	case bson.M:
+		return r.value(sortedDoc(x), path, expr)
+	case map[string]interface{}:
+		return r.value(sortedDoc(x), path, expr)
+	case Filter:
+		return r.value(x.d, path, expr)
+	case Pipeline, []bson.D:
+		return r.pipeline(x)
+	case bson.A:
+		return r.slice(x, path, expr)
+	case []interface{}:
+		return r.slice(x, path, expr)
+	case string:
+		if expr && fieldPathPattern.MatchString(x) {
+			return x
+		}
+	}
+	return r.literal(v)
+}
+
+// slice redacts every element of an array. In placeholder mode an array
+// made only of literals collapses to a single placeholder.
+func (r redactor) slice(x []interface{}, path string, expr bool) bson.A {
+	out := make(bson.A, len(x))
+	literals := true
+	for i, item := range x {
+		out[i] = r.value(item, path, expr)
+		if s, ok := out[i].(string); !ok || s != shapePlaceholder {
+			literals = false
+		}
+	}
+	if literals && len(out) > 0 {
+		return bson.A{shapePlaceholder}
+	}
+	return out
+}
+
+// literal hides a single value according to the redaction mode.
+func (r redactor) literal(v any) string {
+	if r.mode != RedactHash {
+		return shapePlaceholder
+	}
+	raw, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: v}}, true, false)
+	if err != nil {
+		return shapePlaceholder
+	}
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// childPath extends a dotted field path with key. Operator keys do not
+// name a field and leave the path unchanged.
+func childPath(path, key string) string {
+	if len(key) > 0 && key[0] == '$' {
+		return path
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// stagesToA converts pipeline stages to an array value.
+func stagesToA(stages []bson.D) []interface{} {
+	out := make([]interface{}, len(stages))
+	for i, s := range stages {
+		out[i] = s
+	}
+	return out
+}
+
+// docValue returns v as a bson.D if it is a document.
+func docValue(v any) (bson.D, bool) {
+	switch x := v.(type) {
+	case bson.D:
+		return x, true
+	case bson.M:
+		return sortedDoc(x), true
+	case map[string]interface{}:
+		return sortedDoc(x), true
+	}
+	return nil, false
+}
+
+// sortedDoc converts a map document to a bson.D ordered by key.
+func sortedDoc(m map[string]interface{}) bson.D {
+	out := make(bson.D, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		out = append(out, bson.E{Key: k, Value: m[k]})
+	}
+	return out
+}
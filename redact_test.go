@@ -0,0 +1,95 @@
+package gmqb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestQueryShape(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"nil", nil, ""},
+		{"filter", And(Eq("status", "paid"), In("sku", "a", "b", "c"), Gt("total", 100)),
+			`{"$and":[{"status":{"$eq":"?"}},{"sku":{"$in":["?"]}},{"total":{"$gt":"?"}}]}`},
+		{"same shape for other values", And(Eq("status", "new"), In("sku", "z"), Gt("total", 1)),
+			`{"$and":[{"status":{"$eq":"?"}},{"sku":{"$in":["?"]}},{"total":{"$gt":"?"}}]}`},
+		{"literal that looks like money", Eq("note", "$5 off"), `{"note":{"$eq":"?"}}`},
+		{"update", NewUpdate().Set("status", "paid").Inc("n", 1), `{"$set":{"status":"?"},"$inc":{"n":"?"}}`},
+		{"pipeline keeps field paths", NewPipeline().
+			Match(Eq("country", "US")).
+			Group(GroupSpec("$city", GroupAcc("total", AccSum("$amount")))).
+			Limit(10),
+			`[{"$match":{"country":{"$eq":"?"}}},{"$group":{"_id":"$city","total":{"$sum":"$amount"}}},{"$limit":"?"}]`},
+		{"map document", bson.M{"b": 2, "a": bson.A{bson.M{"x": 1}, "$y"}}, `{"a":[{"x":"?"},"?"],"b":"?"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, QueryShape(tt.in))
+		})
+	}
+}
+
+func TestQueryShape_FieldPathLiterals(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"$eq operand", Eq("token", "$s3cr3t"), `{"token":{"$eq":"?"}}`},
+		{"$ne operand", Ne("token", "$s3cr3t"), `{"token":{"$ne":"?"}}`},
+		{"$in operands", In("token", "$a", "$b"), `{"token":{"$in":["?"]}}`},
+		{"$nin operands", Nin("token", "$a"), `{"token":{"$nin":["?"]}}`},
+		{"plain field value", bson.D{{Key: "token", Value: "$s3cr3t"}}, `{"token":"?"}`},
+		{"update value", NewUpdate().Set("token", "$s3cr3t"), `{"$set":{"token":"?"}}`},
+		{"$match value", []bson.D{{{Key: "$match", Value: bson.D{{Key: "token", Value: "$s3cr3t"}}}}},
+			`[{"$match":{"token":"?"}}]`},
+		{"$expr keeps paths", Expr(ExprGt("$total", "$limit")),
+			`{"$expr":{"$gt":["$total","$limit"]}}`},
+		{"$literal inside $expr", Expr(bson.D{{Key: "$eq", Value: bson.A{"$token", bson.D{{Key: "$literal", Value: "$s3cr3t"}}}}}),
+			`{"$expr":{"$eq":["$token",{"$literal":"?"}]}}`},
+		{"stage keeps paths", NewPipeline().RawStage("$set", bson.D{{Key: "total", Value: "$amount"}}),
+			`[{"$set":{"total":"$amount"}}]`},
+		{"nested $match", NewPipeline().RawStage("$lookup", bson.D{
+			{Key: "from", Value: "orders"},
+			{Key: "let", Value: bson.D{{Key: "uid", Value: "$_id"}}},
+			{Key: "pipeline", Value: NewPipeline().Match(Eq("token", "$s3cr3t")).Project(bson.D{{Key: "t", Value: "$token"}})},
+			{Key: "as", Value: "orders"},
+		}),
+			`[{"$lookup":{"from":"?","let":{"uid":"$_id"},"pipeline":[{"$match":{"token":{"$eq":"?"}}},{"$project":{"t":"$token"}}],"as":"?"}}]`},
+		{"$facet", NewPipeline().Facet(map[string]Pipeline{"a": NewPipeline().Match(Eq("x", "$y")).Count("n")}),
+			`[{"$facet":{"a":[{"$match":{"x":{"$eq":"?"}}},{"$count":"?"}]}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, QueryShape(tt.in))
+		})
+	}
+}
+
+func TestRedact_AllowAndHash(t *testing.T) {
+	filter := And(
+		Eq("status", "paid"),
+		Eq("email", "a@example.com"),
+		ElemMatch("items", Eq("sku", "x1").Gt("qty", 2)),
+	)
+	assert.Equal(t,
+		`{"$and":[{"status":{"$eq":"paid"}},{"email":{"$eq":"?"}},{"items":{"$elemMatch":{"sku":{"$eq":"x1"},"qty":{"$gt":"?"}}}}]}`,
+		Redact(filter, RedactOpts{Allow: []string{"status", "items.sku"}}))
+
+	hashed := Redact(In("email", "a@example.com", "b@example.com"), RedactOpts{Mode: RedactHash})
+	assert.Regexp(t, `^\{"email":\{"\$in":\["sha256:[0-9a-f]{12}","sha256:[0-9a-f]{12}"\]\}\}$`, hashed)
+	assert.NotContains(t, hashed, "example.com")
+
+	// Equal values hash alike, so entries can be correlated.
+	assert.Equal(t,
+		Redact(Eq("email", "a@example.com"), RedactOpts{Mode: RedactHash}),
+		Redact(Eq("email", "a@example.com"), RedactOpts{Mode: RedactHash}))
+	assert.NotEqual(t,
+		Redact(Eq("email", "a@example.com"), RedactOpts{Mode: RedactHash}),
+		Redact(Eq("email", "b@example.com"), RedactOpts{Mode: RedactHash}))
+}
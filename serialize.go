@@ -22,9 +22,19 @@ type Serializable interface {
 	CompactJSON() string
 }
 
+// marshalDebugJSON marshals d to relaxed Extended JSON. Templates containing
+// unbound parameters render each one as {"$param": name}.
+func marshalDebugJSON(d bson.D) ([]byte, error) {
+	raw, err := bson.MarshalExtJSON(d, false, false)
+	if err != nil && len(paramNames(d)) > 0 {
+		return bson.MarshalExtJSON(renderParams(d), false, false)
+	}
+	return raw, err
+}
+
 // toJSON converts a bson.D to a pretty-printed JSON string.
 func toJSON(d bson.D) string {
-	raw, err := bson.MarshalExtJSON(d, false, false)
+	raw, err := marshalDebugJSON(d)
 	if err != nil {
 		return "{}"
 	}
@@ -37,7 +47,7 @@ func toJSON(d bson.D) string {
 
 // toCompactJSON converts a bson.D to a compact JSON string.
 func toCompactJSON(d bson.D) string {
-	raw, err := bson.MarshalExtJSON(d, false, false)
+	raw, err := marshalDebugJSON(d)
 	if err != nil {
 		return "{}"
 	}
@@ -48,7 +58,7 @@ func toCompactJSON(d bson.D) string {
 func pipelineToJSON(stages []bson.D) string {
 	result := make([]json.RawMessage, 0, len(stages))
 	for _, stage := range stages {
-		raw, err := bson.MarshalExtJSON(stage, false, false)
+		raw, err := marshalDebugJSON(stage)
 		if err != nil {
 			continue
 		}
@@ -65,7 +75,7 @@ func pipelineToJSON(stages []bson.D) string {
 func pipelineToCompactJSON(stages []bson.D) string {
 	result := make([]json.RawMessage, 0, len(stages))
 	for _, stage := range stages {
-		raw, err := bson.MarshalExtJSON(stage, false, false)
+		raw, err := marshalDebugJSON(stage)
 		if err != nil {
 			continue
 		}
@@ -0,0 +1,139 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// SlowQueryOpts configures SlowQueryLogger.
+type SlowQueryOpts struct {
+	// Threshold is the duration an operation must reach to be logged. Zero
+	// logs every operation.
+	Threshold time.Duration
+	// Level is the level of each entry. Default is slog.LevelWarn.
+	Level slog.Leveler
+	// Redact controls how literal values in the logged filter, update and
+	// pipeline are hidden.
+	Redact RedactOpts
+}
+
+// SlowQueryLogger returns an Interceptor that logs every operation taking at
+// least opts.Threshold to logger, or to slog.Default() if logger is nil.
+// Each entry records the namespace, operation, duration, result count where
+// one is known, and the filter, update or pipeline rendered by Redact.
+//
+// Example:
+//
+//	orders := gmqb.Wrap[Order](db.Collection("orders"), gmqb.WithInterceptors(
+//	    gmqb.SlowQueryLogger(logger, gmqb.SlowQueryOpts{
+//	        Threshold: 100 * time.Millisecond,
+//	        Redact:    gmqb.RedactOpts{Allow: []string{"status"}},
+//	    }),
+//	))
+//	// level=WARN msg="gmqb slow query" db=shop collection=orders operation=find
+//	//   duration=312ms count=48 filter={"status":{"$eq":"paid"},"email":{"$eq":"?"}}
+func SlowQueryLogger(logger *slog.Logger, opts SlowQueryOpts) Interceptor {
+	level := opts.Level
+	if level == nil {
+		level = slog.LevelWarn
+	}
+	return func(ctx context.Context, op *OpInfo, next Invoker) error {
+		start := time.Now()
+		err := next(ctx, op)
+		elapsed := time.Since(start)
+		if elapsed < opts.Threshold {
+			return err
+		}
+
+		l := logger
+		if l == nil {
+			l = slog.Default()
+		}
+		if !l.Enabled(ctx, level.Level()) {
+			return err
+		}
+
+		attrs := []slog.Attr{
+			slog.String("db", op.Database),
+			slog.String("collection", op.Collection),
+			slog.String("operation", string(op.Operation)),
+			slog.Duration("duration", elapsed),
+		}
+		if n, ok := resultCount(op); ok && err == nil {
+			attrs = append(attrs, slog.Int64("count", n))
+		}
+		switch {
+		case !op.Pipeline.IsEmpty():
+			attrs = append(attrs, slog.String("pipeline", Redact(op.Pipeline, opts.Redact)))
+		case !op.Filter.IsEmpty():
+			attrs = append(attrs, slog.String("filter", Redact(op.Filter, opts.Redact)))
+		}
+		if op.Update != nil && !op.Update.IsEmpty() {
+			attrs = append(attrs, slog.String("update", Redact(op.Update, opts.Redact)))
+		}
+		if op.Cache != "" {
+			attrs = append(attrs, slog.String("cache", string(op.Cache)))
+		}
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		l.LogAttrs(ctx, level.Level(), "gmqb slow query", attrs...)
+		return err
+	}
+}
+
+// itemCounter is implemented by results that hold a page of documents.
+type itemCounter interface {
+	itemCount() int
+}
+
+// resultCount returns the number of documents an operation returned or
+// affected, or false if its result carries no count, as for cursors.
+func resultCount(op *OpInfo) (int64, bool) {
+	switch r := op.Result.(type) {
+	case int64:
+		return r, true
+	case itemCounter:
+		return int64(r.itemCount()), true
+	case *mongo.InsertOneResult:
+		return 1, r != nil
+	case *mongo.InsertManyResult:
+		if r == nil {
+			return 0, false
+		}
+		return int64(len(r.InsertedIDs)), true
+	case *mongo.UpdateResult:
+		if r == nil {
+			return 0, false
+		}
+		return r.MatchedCount + r.UpsertedCount, true
+	case *mongo.DeleteResult:
+		if r == nil {
+			return 0, false
+		}
+		return r.DeletedCount, true
+	case *mongo.BulkWriteResult:
+		if r == nil {
+			return 0, false
+		}
+		return r.InsertedCount + r.MatchedCount + r.UpsertedCount + r.DeletedCount, true
+	}
+
+	v := reflect.ValueOf(op.Result)
+	switch op.Operation {
+	case OpFindOne, OpFindOneAndDelete, OpFindOneAndUpdate, OpFindOneAndReplace:
+		if v.Kind() == reflect.Pointer && !v.IsNil() {
+			return 1, true
+		}
+		return 0, true
+	}
+	if v.Kind() == reflect.Slice {
+		return int64(v.Len()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package gmqb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// slowStub answers an operation with result after sleeping for d.
+func slowStub(d time.Duration, result any) Interceptor {
+	return func(_ context.Context, op *OpInfo, _ Invoker) error {
+		time.Sleep(d)
+		op.Result = result
+		return nil
+	}
+}
+
+func decodeEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var e map[string]any
+		require.NoError(t, dec.Decode(&e))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestSlowQueryLogger(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	slow := SlowQueryLogger(logger, SlowQueryOpts{
+		Threshold: 20 * time.Millisecond,
+		Redact:    RedactOpts{Allow: []string{"status"}},
+	})
+
+	fast := Wrap[bson.M](offlineCollection(t), WithInterceptors(slow, slowStub(0, []bson.M{{}})))
+	_, err := fast.Find(ctx, Eq("status", "paid"))
+	require.NoError(t, err)
+	assert.Zero(t, buf.Len(), "fast operations are not logged")
+
+	coll := Wrap[bson.M](offlineCollection(t), WithInterceptors(slow, slowStub(25*time.Millisecond, []bson.M{{}, {}})))
+	_, err = coll.Find(ctx, Eq("status", "paid").Eq("email", "a@example.com"))
+	require.NoError(t, err)
+
+	updates := Wrap[bson.M](offlineCollection(t), WithInterceptors(slow, slowStub(25*time.Millisecond, &mongo.UpdateResult{MatchedCount: 3})))
+	_, err = updates.UpdateMany(ctx, Eq("status", "new"), NewUpdate().Set("status", "paid"))
+	require.NoError(t, err)
+
+	_, err = Aggregate[bson.M](coll, ctx, NewPipeline().Match(Eq("total", 5)))
+	require.NoError(t, err)
+
+	entries := decodeEntries(t, &buf)
+	require.Len(t, entries, 3)
+
+	e := entries[0]
+	assert.Equal(t, "WARN", e["level"])
+	assert.Equal(t, "gmqb slow query", e["msg"])
+	assert.Equal(t, "shop", e["db"])
+	assert.Equal(t, "orders", e["collection"])
+	assert.Equal(t, "find", e["operation"])
+	assert.EqualValues(t, 2, e["count"])
+	assert.GreaterOrEqual(t, e["duration"], float64(20*time.Millisecond))
+	assert.Equal(t, `{"status":{"$eq":"paid"},"email":{"$eq":"?"}}`, e["filter"])
+
+	assert.EqualValues(t, 3, entries[1]["count"])
+	assert.Equal(t, `{"$set":{"status":"paid"}}`, entries[1]["update"])
+
+	assert.Equal(t, "aggregate", entries[2]["operation"])
+	assert.Equal(t, `[{"$match":{"total":{"$eq":"?"}}}]`, entries[2]["pipeline"])
+}
+
+func TestResultCount(t *testing.T) {
+	n, ok := resultCount(&OpInfo{Operation: OpCountDocuments, Result: int64(9)})
+	assert.True(t, ok)
+	assert.Equal(t, int64(9), n)
+
+	n, ok = resultCount(&OpInfo{Operation: OpPaginate, Result: &Page[bson.M]{Items: []bson.M{{}, {}}}})
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), n)
+
+	n, ok = resultCount(&OpInfo{Operation: OpFindOne, Result: (*bson.M)(nil)})
+	assert.True(t, ok)
+	assert.Zero(t, n)
+
+	_, ok = resultCount(&OpInfo{Operation: OpFind, Result: &Cursor[bson.M]{}})
+	assert.False(t, ok)
+}
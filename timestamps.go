@@ -0,0 +1,248 @@
+package gmqb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Tag values that mark the timestamp fields Collection maintains.
+const (
+	tagCreatedAt = "createdAt"
+	tagUpdatedAt = "updatedAt"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	timePtrType  = reflect.TypeOf((*time.Time)(nil))
+	dateTimeType = reflect.TypeOf(bson.DateTime(0))
+)
+
+// timestampCache stores the resolved timestamp fields of each struct type.
+var timestampCache sync.Map // map[reflect.Type]timestampResult
+
+// timestampResult is a cached timestampsOf outcome.
+type timestampResult struct {
+	ts  *timestampFields
+	err error
+}
+
+// timestampField is a top-level struct field holding a time.Time,
+// *time.Time or bson.DateTime.
+type timestampField struct {
+	index int
+	name  string
+}
+
+// timestampFields locates the fields of a struct type tagged
+// gmqb:"createdAt" and gmqb:"updatedAt". Either may be nil.
+type timestampFields struct {
+	typ     reflect.Type
+	created *timestampField
+	updated *timestampField
+}
+
+// timestampsOf returns the timestamp fields of T, or nil if T is not a
+// struct or has none.
+func timestampsOf[T any]() (*timestampFields, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if cached, ok := timestampCache.Load(t); ok {
+		r := cached.(timestampResult)
+		return r.ts, r.err
+	}
+	ts, err := buildTimestampFields(t)
+	timestampCache.Store(t, timestampResult{ts, err})
+	return ts, err
+}
+
+// buildTimestampFields inspects the gmqb tags of t's top-level fields.
+func buildTimestampFields(t reflect.Type) (*timestampFields, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	ts := timestampFields{typ: t}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		for _, directive := range gmqbTag(sf) {
+			var slot **timestampField
+			switch directive {
+			case tagCreatedAt:
+				slot = &ts.created
+			case tagUpdatedAt:
+				slot = &ts.updated
+			default:
+				continue
+			}
+			if sf.Type != timeType && sf.Type != timePtrType && sf.Type != dateTimeType {
+				return nil, fmt.Errorf("%w: %s.%s is tagged %s but is a %s, not a time.Time, *time.Time or bson.DateTime",
+					ErrInvalidField, t.Name(), sf.Name, directive, sf.Type)
+			}
+			if *slot != nil {
+				return nil, fmt.Errorf("%w: %s has more than one %s field", ErrInvalidField, t.Name(), directive)
+			}
+			*slot = &timestampField{index: i, name: resolveBsonTag(sf)}
+		}
+	}
+	if ts.created == nil && ts.updated == nil {
+		return nil, nil
+	}
+	return &ts, nil
+}
+
+// gmqbTag returns the comma-separated directives of a field's gmqb tag.
+func gmqbTag(sf reflect.StructField) []string {
+	tag := sf.Tag.Get("gmqb")
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
+
+// timestampNow returns the current time at the millisecond precision
+// MongoDB stores, so stamped structs match what is read back.
+func timestampNow() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// isZero reports whether the timestamp field of v is unset.
+func (f *timestampField) isZero(v reflect.Value) bool {
+	return v.Field(f.index).IsZero()
+}
+
+// set stores now in the timestamp field of v.
+func (f *timestampField) set(v reflect.Value, now time.Time) {
+	fv := v.Field(f.index)
+	switch fv.Type() {
+	case timeType:
+		fv.Set(reflect.ValueOf(now))
+	case timePtrType:
+		fv.Set(reflect.ValueOf(&now))
+	case dateTimeType:
+		fv.Set(reflect.ValueOf(bson.NewDateTimeFromTime(now)))
+	}
+}
+
+// matches reports whether v is a non-nil pointer to the struct type ts
+// describes. Interceptors may swap the document for another type.
+func (ts *timestampFields) matches(v reflect.Value) bool {
+	return ts != nil && v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Type() == ts.typ
+}
+
+// stampInsert sets the unset timestamp fields of the struct v points to.
+func (ts *timestampFields) stampInsert(v reflect.Value, now time.Time) {
+	if !ts.matches(v) {
+		return
+	}
+	v = v.Elem()
+	for _, f := range []*timestampField{ts.created, ts.updated} {
+		if f != nil && f.isZero(v) {
+			f.set(v, now)
+		}
+	}
+}
+
+// stampUpdate adds a $set of updatedAt to update and, for upserts, a
+// $setOnInsert of createdAt. Fields the update already writes are left
+// alone. A pipeline update gets a leading $set stage instead, which its own
+// stages may still override.
+func (ts *timestampFields) stampUpdate(update UpdateDoc, upsert bool, now time.Time) UpdateDoc {
+	if ts == nil {
+		return update
+	}
+	switch u := update.(type) {
+	case Updater:
+		if ts.updated != nil && !u.touches(ts.updated.name) {
+			u = u.Set(ts.updated.name, now)
+		}
+		if upsert && ts.created != nil && !u.touches(ts.created.name) {
+			u = u.SetOnInsert(ts.created.name, now)
+		}
+		return u
+	case Pipeline:
+		var set bson.D
+		if ts.updated != nil {
+			set = append(set, bson.E{Key: ts.updated.name, Value: now})
+		}
+		if upsert && ts.created != nil {
+			set = append(set, bson.E{Key: ts.created.name, Value: bson.D{{Key: "$ifNull", Value: bson.A{"$" + ts.created.name, now}}}})
+		}
+		if len(set) == 0 {
+			return u
+		}
+		stages := make([]bson.D, 0, len(u.stages)+1)
+		stages = append(stages, bson.D{{Key: "$set", Value: set}})
+		return Pipeline{stages: append(stages, u.stages...)}
+	}
+	return update
+}
+
+// touches reports whether any operator of u writes field, a path inside it
+// or a path containing it.
+func (u Updater) touches(field string) bool {
+	for _, op := range u.ops {
+		fields, _ := op.Value.(bson.D)
+		for _, e := range fields {
+			if e.Key == field || strings.HasPrefix(e.Key, field+".") || strings.HasPrefix(field, e.Key+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stampReplace sets updatedAt on the struct doc points to and returns the
+// value to send. A replacement would drop the stored createdAt, so when
+// doc's createdAt is unset the replacement becomes an update pipeline that
+// keeps it, falling back to now for an upsert. The bool reports whether
+// the pipeline was returned.
+func (ts *timestampFields) stampReplace(doc any, now time.Time) (any, bool, error) {
+	v := reflect.ValueOf(doc)
+	if !ts.matches(v) {
+		return doc, false, nil
+	}
+	if ts.updated != nil {
+		ts.updated.set(v.Elem(), now)
+	}
+	if ts.created == nil || !ts.created.isZero(v.Elem()) {
+		return doc, false, nil
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	var fields bson.D
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return nil, false, err
+	}
+	name := ts.created.name
+	return bson.A{bson.D{{Key: "$replaceWith", Value: bson.D{{Key: "$mergeObjects", Value: bson.A{
+		bson.D{{Key: "$literal", Value: fields}},
+		bson.D{{Key: name, Value: bson.D{{Key: "$ifNull", Value: bson.A{"$" + name, now}}}}},
+	}}}}}}, true, nil
+}
+
+// resolveOptions applies the setters of an options builder to a fresh
+// options struct.
+func resolveOptions[O any](b interface{ List() []func(*O) error }) O {
+	var o O
+	for _, fn := range b.List() {
+		_ = fn(&o)
+	}
+	return o
+}
+
+// isUpsert reports whether an options struct has Upsert set to true.
+func isUpsert(upsert *bool) bool {
+	return upsert != nil && *upsert
+}
+
+// timestampedModel is implemented by the write models that maintain
+// createdAt and updatedAt fields in a BulkWrite.
+type timestampedModel interface {
+	timestampedWriteModel(ts *timestampFields, now time.Time) (mongo.WriteModel, error)
+}
@@ -0,0 +1,128 @@
+package gmqb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type stampedDoc struct {
+	ID        bson.ObjectID `bson:"_id,omitempty"`
+	Name      string        `bson:"name"`
+	CreatedAt time.Time     `bson:"created_at" gmqb:"createdAt"`
+	UpdatedAt *time.Time    `bson:"updated_at,omitempty" gmqb:"updatedAt"`
+}
+
+func TestTimestampsOf(t *testing.T) {
+	ts, err := timestampsOf[stampedDoc]()
+	require.NoError(t, err)
+	assert.Equal(t, "created_at", ts.created.name)
+	assert.Equal(t, "updated_at", ts.updated.name)
+
+	ts, err = timestampsOf[bson.M]()
+	require.NoError(t, err)
+	assert.Nil(t, ts)
+
+	type badType struct {
+		CreatedAt string `gmqb:"createdAt"`
+	}
+	_, err = timestampsOf[badType]()
+	assert.ErrorIs(t, err, ErrInvalidField)
+}
+
+func TestTimestamps_StampInsert(t *testing.T) {
+	ts, _ := timestampsOf[stampedDoc]()
+	now := timestampNow()
+	earlier := now.Add(-time.Hour)
+
+	doc := stampedDoc{Name: "a"}
+	ts.stampInsert(reflect.ValueOf(&doc), now)
+	assert.Equal(t, now, doc.CreatedAt)
+	require.NotNil(t, doc.UpdatedAt)
+	assert.Equal(t, now, *doc.UpdatedAt)
+
+	// Values set by the caller are kept.
+	doc = stampedDoc{CreatedAt: earlier}
+	ts.stampInsert(reflect.ValueOf(&doc), now)
+	assert.Equal(t, earlier, doc.CreatedAt)
+
+	// A document of another type is left alone.
+	other := struct{ CreatedAt time.Time }{}
+	ts.stampInsert(reflect.ValueOf(&other), now)
+	assert.True(t, other.CreatedAt.IsZero())
+}
+
+func TestTimestamps_StampUpdate(t *testing.T) {
+	ts, _ := timestampsOf[stampedDoc]()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	u := ts.stampUpdate(NewUpdate().Set("name", "b"), false, now).(Updater)
+	assert.Equal(t, `{"$set":{"name":"b","updated_at":{"$date":"2024-01-02T03:04:05Z"}}}`, u.CompactJSON())
+
+	u = ts.stampUpdate(NewUpdate().Set("name", "b"), true, now).(Updater)
+	assert.Equal(t,
+		`{"$set":{"name":"b","updated_at":{"$date":"2024-01-02T03:04:05Z"}},"$setOnInsert":{"created_at":{"$date":"2024-01-02T03:04:05Z"}}}`,
+		u.CompactJSON())
+
+	// Fields the caller writes are not overridden, which would also conflict.
+	u = ts.stampUpdate(NewUpdate().Unset("updated_at").SetOnInsert("created_at", 1), true, now).(Updater)
+	assert.Equal(t, `{"$unset":{"updated_at":""},"$setOnInsert":{"created_at":1}}`, u.CompactJSON())
+
+	p := ts.stampUpdate(NewPipeline().SetFields(bson.D{{Key: "name", Value: "b"}}), true, now).(Pipeline)
+	assert.Equal(t,
+		`[{"$set":{"updated_at":{"$date":"2024-01-02T03:04:05Z"},"created_at":{"$ifNull":["$created_at",{"$date":"2024-01-02T03:04:05Z"}]}}},{"$set":{"name":"b"}}]`,
+		p.CompactJSON())
+
+	var none *timestampFields
+	assert.Equal(t, NewUpdate().Set("a", 1), none.stampUpdate(NewUpdate().Set("a", 1), true, now))
+}
+
+func TestTimestamps_StampReplace(t *testing.T) {
+	ts, _ := timestampsOf[stampedDoc]()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// A replacement that carries createdAt is sent as is.
+	doc := &stampedDoc{Name: "a", CreatedAt: now.Add(-time.Hour)}
+	out, asUpdate, err := ts.stampReplace(doc, now)
+	require.NoError(t, err)
+	assert.False(t, asUpdate)
+	assert.Same(t, doc, out)
+	assert.Equal(t, now, *doc.UpdatedAt)
+
+	// Otherwise it becomes a pipeline that keeps the stored createdAt.
+	doc = &stampedDoc{Name: "a"}
+	out, asUpdate, err = ts.stampReplace(doc, now)
+	require.NoError(t, err)
+	assert.True(t, asUpdate)
+	b, err := bson.MarshalExtJSON(bson.D{{Key: "u", Value: out}}, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"$replaceWith":{"$mergeObjects":[{"$literal":{"name":"a",`)
+	assert.Contains(t, string(b), `{"created_at":{"$ifNull":["$created_at",{"$date":"2024-01-02T03:04:05Z"}]}}`)
+}
+
+func TestTimestamps_WriteModels(t *testing.T) {
+	ts, _ := timestampsOf[stampedDoc]()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	doc := &stampedDoc{Name: "a"}
+	_, err := NewInsertOneModel[stampedDoc]().SetDocument(doc).timestampedWriteModel(ts, now)
+	require.NoError(t, err)
+	assert.Equal(t, now, doc.CreatedAt)
+
+	um := NewUpdateOneModel[stampedDoc]().SetFilter(Eq("name", "a")).SetUpdate(NewUpdate().Set("name", "b")).SetUpsert(true)
+	wm, err := um.timestampedWriteModel(ts, now)
+	require.NoError(t, err)
+	stamped := wm.(*mongo.UpdateOneModel).Update.(bson.D)
+	assert.Len(t, stamped, 2, "$set and $setOnInsert")
+	assert.Len(t, um.model.Update.(bson.D), 1, "the model itself is not modified")
+
+	rm := NewReplaceOneModel[stampedDoc]().SetFilter(Eq("name", "a")).SetReplacement(&stampedDoc{Name: "b"})
+	wm, err = rm.timestampedWriteModel(ts, now)
+	require.NoError(t, err)
+	assert.IsType(t, &mongo.UpdateOneModel{}, wm)
+}
@@ -0,0 +1,224 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Error labels attached by the server (or the driver) to transaction errors
+// that are safe to retry.
+const (
+	labelTransientTransaction = "TransientTransactionError"
+	labelUnknownCommitResult  = "UnknownTransactionCommitResult"
+)
+
+// TxOpts configures WithTransaction.
+type TxOpts struct {
+	// MaxRetries bounds how many times the transaction is retried after a
+	// TransientTransactionError, and separately how many times the commit is
+	// retried after an UnknownTransactionCommitResult. Default is 5.
+	MaxRetries int
+	// MinBackoff is the delay before the first retry. Each further retry
+	// doubles it, with jitter, up to MaxBackoff. Default is 10ms.
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Default is 1s.
+	MaxBackoff time.Duration
+	// Transaction sets the read concern, write concern and read preference of
+	// the transaction. Nil uses the client and session defaults.
+	Transaction *options.TransactionOptionsBuilder
+}
+
+// DefaultTxOpts provides sensible defaults for WithTransaction.
+var DefaultTxOpts = TxOpts{
+	MaxRetries: 5,
+	MinBackoff: 10 * time.Millisecond,
+	MaxBackoff: time.Second,
+}
+
+// txSession is the subset of *mongo.Session used to drive a transaction.
+type txSession interface {
+	StartTransaction(opts ...options.Lister[options.TransactionOptions]) error
+	AbortTransaction(ctx context.Context) error
+	CommitTransaction(ctx context.Context) error
+}
+
+// txState is carried in the context handed to a WithTransaction callback.
+// It collects side effects that must only happen once the transaction has
+// committed, such as populating a CachedCollection.
+type txState struct {
+	mu          sync.Mutex
+	afterCommit []func(context.Context)
+}
+
+type txStateKey struct{}
+
+// InTransaction reports whether ctx belongs to a WithTransaction callback.
+func InTransaction(ctx context.Context) bool {
+	return txFromContext(ctx) != nil
+}
+
+func txFromContext(ctx context.Context) *txState {
+	tx, _ := ctx.Value(txStateKey{}).(*txState)
+	return tx
+}
+
+// afterCommit schedules fn to run once the transaction carried by ctx commits.
+// Outside a transaction fn runs immediately. Scheduled functions are dropped
+// when the attempt is aborted.
+func afterCommit(ctx context.Context, fn func(context.Context)) {
+	tx := txFromContext(ctx)
+	if tx == nil {
+		fn(ctx)
+		return
+	}
+	tx.mu.Lock()
+	tx.afterCommit = append(tx.afterCommit, fn)
+	tx.mu.Unlock()
+}
+
+// WithTransaction runs fn inside a multi-document transaction on a new session
+// of client and commits it when fn returns nil. Returning an error aborts the
+// transaction and the error is returned unchanged.
+//
+// The context passed to fn carries the session, so every Collection[T],
+// Queue[T] and CachedCollection[T] call made with it joins the transaction.
+// Calls made with any other context run outside the transaction. Inside fn:
+//   - CachedCollection reads bypass the cache so they observe the
+//     transaction's own writes, and the results are only stored in the cache
+//     after a successful commit;
+//   - Queue.Enqueue only wakes in-process workers after commit.
+//
+// The whole transaction is retried when an operation or the commit fails with
+// a TransientTransactionError, and the commit alone is retried on an
+// UnknownTransactionCommitResult, each up to MaxRetries times with bounded
+// exponential backoff. fn must therefore be safe to run more than once.
+// Calling WithTransaction with a context that is already inside a transaction
+// runs fn as part of the outer transaction.
+//
+// Transactions require a replica set or sharded cluster.
+//
+// MongoDB equivalent:
+//
+//	session.withTransaction(async () => { ... })
+//
+// See: https://www.mongodb.com/docs/manual/core/transactions/
+//
+// Example:
+//
+//	err := gmqb.WithTransaction(ctx, client, func(ctx context.Context) error {
+//	    if _, err := accounts.UpdateOne(ctx, gmqb.Eq("_id", from), gmqb.NewUpdate().Inc("balance", -amount)); err != nil {
+//	        return err
+//	    }
+//	    _, err := accounts.UpdateOne(ctx, gmqb.Eq("_id", to), gmqb.NewUpdate().Inc("balance", amount))
+//	    return err
+//	}, gmqb.DefaultTxOpts)
+func WithTransaction(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error, opts TxOpts) error {
+	if InTransaction(ctx) {
+		return fn(ctx)
+	}
+	sess, err := client.StartSession()
+	if err != nil {
+		return fmt.Errorf("gmqb tx: start session: %w", err)
+	}
+	defer sess.EndSession(context.WithoutCancel(ctx))
+
+	return runTransaction(ctx, mongo.NewSessionContext(ctx, sess), sess, fn, opts)
+}
+
+// runTransaction drives the start/commit/abort/retry loop. Callbacks run with
+// sessCtx; after-commit hooks run with ctx.
+func runTransaction(ctx, sessCtx context.Context, sess txSession, fn func(ctx context.Context) error, opts TxOpts) error {
+	opts = opts.withDefaults()
+	var txOpts []options.Lister[options.TransactionOptions]
+	if opts.Transaction != nil {
+		txOpts = append(txOpts, opts.Transaction)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := sess.StartTransaction(txOpts...); err != nil {
+			return fmt.Errorf("gmqb tx: start transaction: %w", err)
+		}
+		tx := &txState{}
+		err := fn(context.WithValue(sessCtx, txStateKey{}, tx))
+		if err == nil {
+			err = commitTransaction(sessCtx, sess, opts)
+			if err == nil {
+				for _, hook := range tx.afterCommit {
+					hook(ctx)
+				}
+				return nil
+			}
+		} else {
+			_ = sess.AbortTransaction(context.WithoutCancel(sessCtx))
+		}
+
+		if !hasErrorLabel(err, labelTransientTransaction) || attempt >= opts.MaxRetries {
+			return err
+		}
+		if werr := opts.wait(ctx, attempt); werr != nil {
+			return err
+		}
+	}
+}
+
+// commitTransaction commits, retrying while the outcome is unknown.
+func commitTransaction(ctx context.Context, sess txSession, opts TxOpts) error {
+	for attempt := 0; ; attempt++ {
+		err := sess.CommitTransaction(ctx)
+		if err == nil {
+			return nil
+		}
+		if !hasErrorLabel(err, labelUnknownCommitResult) || attempt >= opts.MaxRetries {
+			return fmt.Errorf("gmqb tx: commit: %w", err)
+		}
+		if werr := opts.wait(ctx, attempt); werr != nil {
+			return fmt.Errorf("gmqb tx: commit: %w", err)
+		}
+	}
+}
+
+func (o TxOpts) withDefaults() TxOpts {
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = DefaultTxOpts.MaxRetries
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = DefaultTxOpts.MinBackoff
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = max(DefaultTxOpts.MaxBackoff, o.MinBackoff)
+	}
+	return o
+}
+
+// backoff returns the jittered delay before retry number attempt (0-based).
+func (o TxOpts) backoff(attempt int) time.Duration {
+	d := o.MaxBackoff
+	if attempt < 30 {
+		d = min(o.MinBackoff<<attempt, o.MaxBackoff)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// wait sleeps for the backoff of attempt or until ctx is done.
+func (o TxOpts) wait(ctx context.Context, attempt int) error {
+	t := time.NewTimer(o.backoff(attempt))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func hasErrorLabel(err error, label string) bool {
+	var le mongo.LabeledError
+	return errors.As(err, &le) && le.HasErrorLabel(label)
+}
@@ -0,0 +1,73 @@
+package gmqb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/squall-chua/gmqb"
+)
+
+func TestWithTransaction_CommitAndAbort(t *testing.T) {
+	_, mColl := startReplicaSet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	client := mColl.Database().Client()
+	coll := gmqb.Wrap[User](mColl)
+	_, err := coll.InsertOne(ctx, &User{Name: "Alice", Age: 30})
+	require.NoError(t, err)
+
+	boom := errors.New("boom")
+	err = gmqb.WithTransaction(ctx, client, func(ctx context.Context) error {
+		if _, err := coll.InsertOne(ctx, &User{Name: "Bob"}); err != nil {
+			return err
+		}
+		return boom
+	}, gmqb.DefaultTxOpts)
+	assert.Same(t, boom, err)
+	n, err := coll.CountDocuments(ctx, gmqb.Eq("name", "Bob"))
+	require.NoError(t, err)
+	assert.Zero(t, n, "aborted insert must not be visible")
+
+	err = gmqb.WithTransaction(ctx, client, func(ctx context.Context) error {
+		assert.True(t, gmqb.InTransaction(ctx))
+		_, err := coll.UpdateOne(ctx, gmqb.Eq("name", "Alice"), gmqb.NewUpdate().Inc("age", 1))
+		return err
+	}, gmqb.DefaultTxOpts)
+	require.NoError(t, err)
+	alice, err := coll.FindOne(ctx, gmqb.Eq("name", "Alice"))
+	require.NoError(t, err)
+	assert.Equal(t, 31, alice.Age)
+}
+
+func TestWithTransaction_CacheWritesDeferred(t *testing.T) {
+	_, mColl := startReplicaSet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	client := mColl.Database().Client()
+	coll := gmqb.WrapWithCache[User](mColl, newInvalidatorCache(), time.Minute)
+	_, err := coll.InsertOne(ctx, &User{Name: "Alice", Age: 30})
+	require.NoError(t, err)
+
+	// A read inside an aborted transaction sees its own write but never
+	// reaches the cache.
+	_ = gmqb.WithTransaction(ctx, client, func(ctx context.Context) error {
+		if _, err := coll.InsertOne(ctx, &User{Name: "Bob"}); err != nil {
+			return err
+		}
+		n, err := coll.CountDocuments(ctx, gmqb.NewFilter())
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+		return errors.New("rollback")
+	}, gmqb.DefaultTxOpts)
+
+	n, err := coll.CountDocuments(ctx, gmqb.NewFilter())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
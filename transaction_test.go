@@ -0,0 +1,135 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// fakeSession records transaction calls and fails commits with queued errors.
+type fakeSession struct {
+	starts, aborts, commits int
+	commitErrs              []error
+}
+
+func (s *fakeSession) StartTransaction(...options.Lister[options.TransactionOptions]) error {
+	s.starts++
+	return nil
+}
+
+func (s *fakeSession) AbortTransaction(context.Context) error {
+	s.aborts++
+	return nil
+}
+
+func (s *fakeSession) CommitTransaction(context.Context) error {
+	s.commits++
+	if len(s.commitErrs) == 0 {
+		return nil
+	}
+	err := s.commitErrs[0]
+	s.commitErrs = s.commitErrs[1:]
+	return err
+}
+
+func labelled(label string) error {
+	return mongo.CommandError{Code: 112, Message: "conflict", Labels: []string{label}}
+}
+
+var fastTx = TxOpts{MaxRetries: 3, MinBackoff: time.Microsecond, MaxBackoff: time.Microsecond}
+
+func TestRunTransaction_RetriesTransientErrors(t *testing.T) {
+	ctx := context.Background()
+	sess := &fakeSession{}
+	var committed []int
+	calls := 0
+	err := runTransaction(ctx, ctx, sess, func(ctx context.Context) error {
+		calls++
+		n := calls
+		afterCommit(ctx, func(context.Context) { committed = append(committed, n) })
+		if calls < 3 {
+			return labelled(labelTransientTransaction)
+		}
+		return nil
+	}, fastTx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sess.starts)
+	assert.Equal(t, 2, sess.aborts)
+	assert.Equal(t, 1, sess.commits)
+	// Only the hooks of the committed attempt run.
+	assert.Equal(t, []int{3}, committed)
+}
+
+func TestRunTransaction_GivesUp(t *testing.T) {
+	ctx := context.Background()
+	sess := &fakeSession{}
+	err := runTransaction(ctx, ctx, sess, func(context.Context) error {
+		return labelled(labelTransientTransaction)
+	}, fastTx)
+	assert.True(t, hasErrorLabel(err, labelTransientTransaction))
+	assert.Equal(t, 4, sess.starts)
+
+	// Other callback errors abort without retrying and are returned as-is.
+	sess = &fakeSession{}
+	boom := errors.New("boom")
+	err = runTransaction(ctx, ctx, sess, func(context.Context) error { return boom }, fastTx)
+	assert.Same(t, boom, err)
+	assert.Equal(t, 1, sess.starts)
+	assert.Equal(t, 1, sess.aborts)
+}
+
+func TestRunTransaction_CommitRetries(t *testing.T) {
+	ctx := context.Background()
+
+	// An unknown commit result retries the commit only.
+	sess := &fakeSession{commitErrs: []error{labelled(labelUnknownCommitResult), labelled(labelUnknownCommitResult)}}
+	hooks := 0
+	err := runTransaction(ctx, ctx, sess, func(ctx context.Context) error {
+		afterCommit(ctx, func(context.Context) { hooks++ })
+		return nil
+	}, fastTx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sess.starts)
+	assert.Equal(t, 3, sess.commits)
+	assert.Equal(t, 1, hooks)
+
+	// A transient commit error reruns the whole transaction.
+	sess = &fakeSession{commitErrs: []error{labelled(labelTransientTransaction)}}
+	calls := 0
+	err = runTransaction(ctx, ctx, sess, func(context.Context) error { calls++; return nil }, fastTx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// A permanent commit failure is reported and no hooks run.
+	sess = &fakeSession{commitErrs: []error{errors.New("write concern")}}
+	hooks = 0
+	err = runTransaction(ctx, ctx, sess, func(ctx context.Context) error {
+		afterCommit(ctx, func(context.Context) { hooks++ })
+		return nil
+	}, fastTx)
+	assert.ErrorContains(t, err, "gmqb tx: commit")
+	assert.Zero(t, hooks)
+}
+
+func TestAfterCommit_OutsideTransaction(t *testing.T) {
+	ran := false
+	afterCommit(context.Background(), func(context.Context) { ran = true })
+	assert.True(t, ran)
+	assert.False(t, InTransaction(context.Background()))
+}
+
+func TestTxOpts_Backoff(t *testing.T) {
+	o := TxOpts{MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}.withDefaults()
+	for attempt := 0; attempt < 100; attempt++ {
+		d := o.backoff(attempt)
+		assert.LessOrEqual(t, d, 50*time.Millisecond)
+		assert.GreaterOrEqual(t, d, 5*time.Millisecond)
+	}
+	assert.Equal(t, DefaultTxOpts.MaxRetries, TxOpts{}.withDefaults().MaxRetries)
+}
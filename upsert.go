@@ -0,0 +1,234 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// UpsertOpts configures UpsertManyByKey.
+type UpsertOpts struct {
+	// OnInsertOnly lists fields written only when a document is inserted,
+	// through $setOnInsert, e.g. a first-seen date. Like the key fields they
+	// are Go field paths or BSON field names.
+	OnInsertOnly []string
+	// Chunk configures the batching of the bulk write. The zero value uses
+	// DefaultChunkOpts.
+	Chunk ChunkOpts
+}
+
+// UpsertResult reports the outcome of UpsertManyByKey. Indexes refer to
+// positions in the docs slice.
+type UpsertResult struct {
+	// Inserted counts the documents that matched no existing document.
+	Inserted int64
+	// Updated counts the existing documents that were changed.
+	Updated int64
+	// Unchanged counts the existing documents that already held the values.
+	Unchanged int64
+	// UpsertedIDs maps the index of each inserted document to its _id.
+	UpsertedIDs map[int]any
+	// Failed maps the index of each document that was not written to its
+	// error.
+	Failed map[int]error
+}
+
+// UpsertManyByKey writes docs by natural key: each document updates the
+// stored document whose keyFields hold the same values, or is inserted when
+// there is none. Key fields and opts.OnInsertOnly fields are Go field paths,
+// as accepted by Field, or BSON field names.
+//
+// Every document becomes an upserting UpdateOneModel whose filter matches
+// the key fields' values, whose $set writes the remaining fields and whose
+// $setOnInsert writes the insert-only fields and any _id. The models are
+// written unordered with BulkWriteChunked, so keys should be unique within
+// docs and backed by a unique index. Timestamp fields are maintained as by
+// UpdateOne, so with an updatedAt field every matched document counts as
+// updated.
+//
+// When any document failed, the returned error wraps ErrPartialWrite and
+// the result is still returned.
+//
+// MongoDB equivalent (per document):
+//
+//	{ updateOne: { filter: { sku: "A1" }, update: { $set: { ... }, $setOnInsert: { ... } }, upsert: true } }
+//
+// Example:
+//
+//	res, err := coll.UpsertManyByKey(ctx, products, []string{"SKU"}, gmqb.UpsertOpts{
+//	    OnInsertOnly: []string{"FirstSeen"},
+//	})
+//	fmt.Println(res.Inserted, res.Updated, res.Unchanged)
+func (c *Collection[T]) UpsertManyByKey(ctx context.Context, docs []T, keyFields []string, opts UpsertOpts) (*UpsertResult, error) {
+	if len(keyFields) == 0 {
+		return nil, fmt.Errorf("%w: UpsertManyByKey requires at least one key field", ErrInvalidField)
+	}
+	keys, err := resolvePaths[T](keyFields)
+	if err != nil {
+		return nil, err
+	}
+	insertOnly, err := resolvePaths[T](opts.OnInsertOnly)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := timestampsOf[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	skip := map[string]bool{"_id": true}
+	for _, p := range slices.Concat(keys, insertOnly) {
+		skip[p] = true
+	}
+	if ts != nil {
+		// Left to stampUpdate, which sets them to the time of the write.
+		for _, f := range []*timestampField{ts.created, ts.updated} {
+			if f != nil {
+				skip[f.name] = true
+			}
+		}
+	}
+
+	res := &UpsertResult{UpsertedIDs: map[int]any{}, Failed: map[int]error{}}
+	models := make([]WriteModel[T], 0, len(docs))
+	positions := make([]int, 0, len(docs))
+	for i := range docs {
+		m, err := upsertModel[T](docs[i], keys, insertOnly, skip)
+		if err != nil {
+			res.Failed[i] = err
+			continue
+		}
+		models = append(models, m)
+		positions = append(positions, i)
+	}
+
+	cres, err := c.BulkWriteChunked(ctx, models, opts.Chunk)
+	if err != nil && !errors.Is(err, ErrPartialWrite) {
+		return nil, err
+	}
+	res.Inserted = cres.UpsertedCount
+	res.Updated = cres.ModifiedCount
+	res.Unchanged = cres.MatchedCount - cres.ModifiedCount
+	for j, id := range cres.UpsertedIDs {
+		res.UpsertedIDs[positions[j]] = id
+	}
+	for j, ferr := range cres.Failed {
+		res.Failed[positions[j]] = ferr
+	}
+	if len(res.Failed) > 0 {
+		return res, fmt.Errorf("%w: %d of %d documents failed", ErrPartialWrite, len(res.Failed), len(docs))
+	}
+	return res, nil
+}
+
+// upsertModel builds the upserting update of doc by its key paths.
+func upsertModel[T any](doc T, keys, insertOnly []string, skip map[string]bool) (WriteModel[T], error) {
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("gmqb: encode document: %w", err)
+	}
+	raw := bson.Raw(b)
+	var fields bson.D
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("gmqb: decode document: %w", err)
+	}
+
+	filter := NewFilter()
+	var firstKey bson.RawValue
+	for i, k := range keys {
+		v, err := raw.LookupErr(strings.Split(k, ".")...)
+		if err != nil {
+			return nil, fmt.Errorf("%w: document has no value for key field %q", ErrInvalidField, k)
+		}
+		if i == 0 {
+			firstKey = v
+		}
+		filter = filter.Eq(k, v)
+	}
+
+	update := NewUpdate()
+	for _, e := range setFields(nil, "", fields, skip) {
+		update = update.Set(e.Key, e.Value)
+	}
+	for _, p := range insertOnly {
+		if v, err := raw.LookupErr(strings.Split(p, ".")...); err == nil {
+			update = update.SetOnInsert(p, v)
+		}
+	}
+	if id, err := raw.LookupErr("_id"); err == nil && !slices.Contains(keys, "_id") && !slices.Contains(insertOnly, "_id") {
+		update = update.SetOnInsert("_id", id)
+	}
+	if update.IsEmpty() {
+		// A document holding only its keys still needs an operator.
+		update = update.SetOnInsert(keys[0], firstKey)
+	}
+	return NewUpdateOneModel[T]().SetFilter(filter).SetUpdate(update).SetUpsert(true), nil
+}
+
+// setFields appends to set the fields of doc under prefix, leaving out the
+// paths in skip. A sub-document holding a skipped path is set field by
+// field so the rest of it is still written.
+func setFields(set bson.D, prefix string, doc bson.D, skip map[string]bool) bson.D {
+	for _, e := range doc {
+		path := e.Key
+		if prefix != "" {
+			path = prefix + "." + e.Key
+		}
+		if skip[path] {
+			continue
+		}
+		if sub, ok := e.Value.(bson.D); ok && skipsUnder(skip, path) {
+			set = setFields(set, path, sub, skip)
+			continue
+		}
+		set = append(set, bson.E{Key: path, Value: e.Value})
+	}
+	return set
+}
+
+// skipsUnder reports whether skip holds a path inside path.
+func skipsUnder(skip map[string]bool, path string) bool {
+	for p := range skip {
+		if strings.HasPrefix(p, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePaths resolves Go field paths of T, or BSON field names, to BSON
+// paths. Names are used as given when T is not a struct.
+func resolvePaths[T any](names []string) ([]string, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return names, nil
+	}
+	fields := getOrBuildFieldMap(t)
+	paths := make([]string, len(names))
+	for i, name := range names {
+		if p, ok := fields[name]; ok {
+			paths[i] = p
+			continue
+		}
+		found := false
+		for _, p := range fields {
+			if p == name {
+				found = true
+				break
+			}
+		}
+		if !found && name != "_id" {
+			return nil, fmt.Errorf("%w: field %q does not exist in struct %s", ErrInvalidField, name, t.Name())
+		}
+		paths[i] = name
+	}
+	return paths, nil
+}
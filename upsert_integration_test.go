@@ -0,0 +1,55 @@
+package gmqb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/squall-chua/gmqb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type catalogItem struct {
+	SKU       string  `bson:"sku"`
+	Name      string  `bson:"name"`
+	Price     float64 `bson:"price"`
+	FirstSeen string  `bson:"firstSeen"`
+}
+
+func TestIntegration_UpsertManyByKey(t *testing.T) {
+	ctx := context.Background()
+	raw := testDB.Collection(t.Name())
+	_ = raw.Drop(ctx)
+	coll := gmqb.Wrap[catalogItem](raw)
+	_, err := coll.CreateIndex(ctx, gmqb.NewIndex(gmqb.Asc("sku")).Unique())
+	require.NoError(t, err)
+	opts := gmqb.UpsertOpts{OnInsertOnly: []string{"FirstSeen"}}
+
+	res, err := coll.UpsertManyByKey(ctx, []catalogItem{
+		{SKU: "A", Name: "Lamp", Price: 10, FirstSeen: "mon"},
+		{SKU: "B", Name: "Desk", Price: 99, FirstSeen: "mon"},
+	}, []string{"SKU"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), res.Inserted)
+	assert.Len(t, res.UpsertedIDs, 2)
+
+	res, err = coll.UpsertManyByKey(ctx, []catalogItem{
+		{SKU: "A", Name: "Lamp", Price: 12, FirstSeen: "tue"},
+		{SKU: "B", Name: "Desk", Price: 99, FirstSeen: "tue"},
+		{SKU: "C", Name: "Chair", Price: 45, FirstSeen: "tue"},
+	}, []string{"SKU"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.Inserted)
+	assert.Equal(t, int64(1), res.Updated)
+	assert.Equal(t, int64(1), res.Unchanged)
+	assert.Contains(t, res.UpsertedIDs, 2)
+
+	a, err := coll.FindOne(ctx, gmqb.Eq("sku", "A"))
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, a.Price)
+	assert.Equal(t, "mon", a.FirstSeen, "insert-only fields keep their first value")
+
+	n, err := coll.CountDocuments(ctx, gmqb.NewFilter())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+}
@@ -0,0 +1,94 @@
+package gmqb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type upsertAddress struct {
+	City   string `bson:"city"`
+	Street string `bson:"street"`
+}
+
+type upsertProduct struct {
+	ID        bson.ObjectID `bson:"_id,omitempty"`
+	SKU       string        `bson:"sku"`
+	Name      string        `bson:"name"`
+	Address   upsertAddress `bson:"address"`
+	FirstSeen string        `bson:"firstSeen,omitempty"`
+	UpdatedAt time.Time     `bson:"updatedAt" gmqb:"updatedAt"`
+}
+
+// upsertUpdate returns the filter and update of the model built for doc.
+func upsertUpdate(t *testing.T, doc upsertProduct, keys, insertOnly []string) (string, string) {
+	t.Helper()
+	var seen []OpInfo
+	coll := Wrap[upsertProduct](offlineCollection(t), WithInterceptors(stub(&seen, nil)))
+	_, err := coll.UpsertManyByKey(context.Background(), []upsertProduct{doc}, keys, UpsertOpts{OnInsertOnly: insertOnly})
+	require.NoError(t, err)
+	require.Len(t, seen, 1)
+	models := seen[0].Document.([]WriteModel[upsertProduct])
+	m := models[0].(*UpdateOneModel[upsertProduct])
+	return toCompactJSON(m.model.Filter.(bson.D)), toCompactJSON(m.model.Update.(bson.D))
+}
+
+func TestUpsertManyByKey_Models(t *testing.T) {
+	doc := upsertProduct{SKU: "A1", Name: "Lamp", Address: upsertAddress{City: "Oslo", Street: "Main"}, FirstSeen: "2024-01-01"}
+
+	filter, update := upsertUpdate(t, doc, []string{"SKU"}, []string{"FirstSeen"})
+	assert.Equal(t, `{"sku":{"$eq":"A1"}}`, filter)
+	assert.Equal(t, `{"$set":{"name":"Lamp","address":{"city":"Oslo","street":"Main"}},`+
+		`"$setOnInsert":{"firstSeen":"2024-01-01"}}`, update)
+	assert.NotContains(t, update, "updatedAt", "timestamps are left to the collection")
+
+	// Nested key and insert-only paths split their parent document.
+	filter, update = upsertUpdate(t, doc, []string{"sku", "Address.City"}, []string{"address.street"})
+	assert.Equal(t, `{"sku":{"$eq":"A1"},"address.city":{"$eq":"Oslo"}}`, filter)
+	assert.Equal(t, `{"$set":{"name":"Lamp","firstSeen":"2024-01-01"},"$setOnInsert":{"address.street":"Main"}}`, update)
+
+	// A stored _id is only written on insert.
+	doc.ID = bson.NewObjectID()
+	_, update = upsertUpdate(t, doc, []string{"SKU"}, nil)
+	assert.Contains(t, update, `"$setOnInsert":{"_id":{"$oid":"`+doc.ID.Hex()+`"}}`)
+}
+
+func TestUpsertManyByKey_Invalid(t *testing.T) {
+	ctx := context.Background()
+	var seen []OpInfo
+	coll := Wrap[upsertProduct](offlineCollection(t), WithInterceptors(stub(&seen, nil)))
+
+	_, err := coll.UpsertManyByKey(ctx, nil, nil, UpsertOpts{})
+	assert.ErrorIs(t, err, ErrInvalidField)
+	_, err = coll.UpsertManyByKey(ctx, nil, []string{"Nope"}, UpsertOpts{})
+	assert.ErrorIs(t, err, ErrInvalidField)
+	_, err = coll.UpsertManyByKey(ctx, nil, []string{"SKU"}, UpsertOpts{OnInsertOnly: []string{"nope"}})
+	assert.ErrorIs(t, err, ErrInvalidField)
+
+	// A document without a value for its key fails alone.
+	docs := []bson.M{{"sku": "A1", "n": 1}, {"n": 2}}
+	res, err := Wrap[bson.M](offlineCollection(t), WithInterceptors(stub(&seen, nil))).
+		UpsertManyByKey(ctx, docs, []string{"sku"}, UpsertOpts{})
+	assert.ErrorIs(t, err, ErrPartialWrite)
+	require.Len(t, res.Failed, 1)
+	assert.ErrorIs(t, res.Failed[1], ErrInvalidField)
+	assert.Len(t, seen[len(seen)-1].Document.([]WriteModel[bson.M]), 1)
+}
+
+func TestSetFields(t *testing.T) {
+	doc := bson.D{
+		{Key: "_id", Value: 1},
+		{Key: "a", Value: bson.D{{Key: "b", Value: 1}, {Key: "c", Value: bson.D{{Key: "d", Value: 2}, {Key: "e", Value: 3}}}}},
+		{Key: "f", Value: 4},
+	}
+	skip := map[string]bool{"_id": true, "a.c.d": true}
+	assert.Equal(t, bson.D{
+		{Key: "a.b", Value: 1},
+		{Key: "a.c.e", Value: 3},
+		{Key: "f", Value: 4},
+	}, setFields(nil, "", doc, skip))
+}
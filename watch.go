@@ -0,0 +1,391 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ChangeOp is the operationType of a change event.
+type ChangeOp string
+
+const (
+	// ChangeInsert is emitted when a document is inserted.
+	ChangeInsert ChangeOp = "insert"
+	// ChangeUpdate is emitted when a document is updated in place.
+	ChangeUpdate ChangeOp = "update"
+	// ChangeReplace is emitted when a document is replaced.
+	ChangeReplace ChangeOp = "replace"
+	// ChangeDelete is emitted when a document is deleted.
+	ChangeDelete ChangeOp = "delete"
+	// ChangeDrop is emitted when the collection is dropped.
+	ChangeDrop ChangeOp = "drop"
+	// ChangeRename is emitted when the collection is renamed.
+	ChangeRename ChangeOp = "rename"
+	// ChangeDropDatabase is emitted when the database is dropped.
+	ChangeDropDatabase ChangeOp = "dropDatabase"
+	// ChangeInvalidate is emitted when the stream can no longer continue,
+	// for example after a drop or rename. It is always the last event.
+	ChangeInvalidate ChangeOp = "invalidate"
+)
+
+// ChangeEvent is a typed change stream event for a Collection[T].
+//
+// See: https://www.mongodb.com/docs/manual/reference/change-events/
+type ChangeEvent[T any] struct {
+	// ResumeToken identifies the event; Watch checkpoints it once the event
+	// has been handled.
+	ResumeToken bson.Raw `bson:"_id"`
+	// OperationType is the kind of change.
+	OperationType ChangeOp `bson:"operationType"`
+	// ClusterTime is the oplog time of the change.
+	ClusterTime bson.Timestamp `bson:"clusterTime"`
+	// Namespace is the database and collection the change applies to.
+	Namespace ChangeNamespace `bson:"ns"`
+	// DocumentKey holds the _id (and shard key, if any) of the changed document.
+	DocumentKey bson.D `bson:"documentKey,omitempty"`
+	// FullDocument is the document after the change. It is set for inserts and
+	// replaces, and for updates when WatchOpts.FullDocument requests it.
+	FullDocument *T `bson:"fullDocument,omitempty"`
+	// FullDocumentBeforeChange is the pre-image of the document. It is only set
+	// when WatchOpts.FullDocumentBeforeChange requests it and the collection
+	// has changeStreamPreAndPostImages enabled.
+	FullDocumentBeforeChange *T `bson:"fullDocumentBeforeChange,omitempty"`
+	// UpdateDescription describes the fields changed by an update.
+	UpdateDescription *UpdateDescription `bson:"updateDescription,omitempty"`
+}
+
+// ChangeNamespace is the namespace of a change event.
+type ChangeNamespace struct {
+	DB   string `bson:"db"`
+	Coll string `bson:"coll"`
+}
+
+// UpdateDescription lists the fields modified by an update event.
+type UpdateDescription struct {
+	UpdatedFields   bson.D           `bson:"updatedFields"`
+	RemovedFields   []string         `bson:"removedFields"`
+	TruncatedArrays []TruncatedArray `bson:"truncatedArrays,omitempty"`
+}
+
+// TruncatedArray describes an array shortened by an update.
+type TruncatedArray struct {
+	Field   string `bson:"field"`
+	NewSize int32  `bson:"newSize"`
+}
+
+// WatchOpts configures Collection.Watch.
+type WatchOpts struct {
+	// Filter selects events by their document. Field paths are rewritten onto
+	// fullDocument.*, so events without a full document (deletes, and updates
+	// without FullDocument) never match a non-empty Filter.
+	Filter Filter
+	// Operations restricts the stream to the given operation types. Empty
+	// means all.
+	Operations []ChangeOp
+	// FullDocument controls whether update events carry the current document.
+	// It defaults to options.UpdateLookup when Filter is set.
+	FullDocument options.FullDocument
+	// FullDocumentBeforeChange controls whether events carry the pre-image.
+	FullDocumentBeforeChange options.FullDocument
+	// StartAt starts the stream at the given cluster time instead of now. It
+	// is ignored when a checkpoint exists.
+	StartAt time.Time
+	// Checkpoint persists the resume token of every handled event so the
+	// stream continues where it left off after a restart. Optional.
+	Checkpoint CheckpointStore
+	// CheckpointKey names the checkpoint. Defaults to "watch:<db>.<coll>".
+	CheckpointKey string
+	// BatchSize sets the number of events per server batch.
+	BatchSize int32
+}
+
+// Watch opens a change stream on the collection and returns an iterator over
+// its events. The iterator runs until ctx is cancelled or the loop body
+// breaks.
+//
+// Events are checkpointed once the loop body continues past them, so with a
+// Checkpoint store every event is delivered at least once across restarts;
+// the event being handled when the loop breaks is delivered again.
+// When the stream fails it is reopened from the last handled event with
+// exponential back-off (max 30 s), as ChangeStreamInvalidator does. Errors
+// that retrying cannot fix, such as a filter that cannot be applied to change
+// events, an oplog that no longer covers the resume point or an error returned
+// by an interceptor, are yielded and end the iteration. Decode and checkpoint errors are yielded without ending
+// it. An invalidate event is yielded and then ends the iteration.
+//
+// Change streams require a replica set or sharded cluster.
+//
+// MongoDB equivalent:
+//
+//	db.collection.watch([{ $match: { operationType: { $in: [...] }, "fullDocument.field": ... } }], opts)
+//
+// See: https://www.mongodb.com/docs/manual/changeStreams/
+//
+// Example:
+//
+//	events := coll.Watch(ctx, gmqb.WatchOpts{
+//	    Filter:     gmqb.Eq("status", "paid"),
+//	    Operations: []gmqb.ChangeOp{gmqb.ChangeInsert, gmqb.ChangeUpdate},
+//	    Checkpoint: gmqb.NewMongoCheckpointStore(db.Collection("checkpoints")),
+//	})
+//	for ev, err := range events {
+//	    if err != nil {
+//	        log.Print(err)
+//	        continue
+//	    }
+//	    fmt.Println(ev.OperationType, ev.FullDocument)
+//	}
+func (c *Collection[T]) Watch(ctx context.Context, opts WatchOpts) iter.Seq2[ChangeEvent[T], error] {
+	return func(yield func(ChangeEvent[T], error) bool) {
+		if _, err := watchPipeline(opts); err != nil {
+			yield(ChangeEvent[T]{}, err)
+			return
+		}
+		key := opts.CheckpointKey
+		if key == "" {
+			key = "watch:" + c.coll.Database().Name() + "." + c.coll.Name()
+		}
+
+		var token bson.Raw
+		if opts.Checkpoint != nil {
+			var err error
+			token, err = opts.Checkpoint.LoadCheckpoint(ctx, key)
+			if err != nil && !yield(ChangeEvent[T]{}, fmt.Errorf("gmqb watch: load checkpoint: %w", err)) {
+				return
+			}
+		}
+
+		backoff := time.Second
+		const maxBackoff = 30 * time.Second
+		for ctx.Err() == nil {
+			cs, err := c.openChangeStream(ctx, opts, token)
+			if err == nil {
+				var done bool
+				done, err = watchStream(ctx, cs, yield, func(ev ChangeEvent[T]) error {
+					token = ev.ResumeToken
+					backoff = time.Second
+					if opts.Checkpoint == nil {
+						return nil
+					}
+					return opts.Checkpoint.SaveCheckpoint(context.WithoutCancel(ctx), key, token)
+				})
+				_ = cs.Close(context.WithoutCancel(ctx))
+				if done {
+					return
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && !resumableWatchError(err) {
+				var de *watchDriverError
+				if errors.As(err, &de) {
+					err = de.err
+				}
+				yield(ChangeEvent[T]{}, fmt.Errorf("gmqb watch: %w", err))
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}
+}
+
+// openChangeStream opens a change stream through the interceptor chain,
+// resuming after token if set.
+func (c *Collection[T]) openChangeStream(ctx context.Context, opts WatchOpts, token bson.Raw) (*mongo.ChangeStream, error) {
+	return intercept(c, ctx, &OpInfo{Operation: OpWatch, Filter: opts.Filter, Options: opts}, func(ctx context.Context, op *OpInfo) (*mongo.ChangeStream, error) {
+		opts, _ := op.Options.(WatchOpts)
+		opts.Filter = op.Filter
+		pipeline, err := watchPipeline(opts)
+		if err != nil {
+			return nil, err
+		}
+		if opts.FullDocument == "" && !opts.Filter.IsEmpty() {
+			opts.FullDocument = options.UpdateLookup
+		}
+		cs, err := c.collFor(ctx).Watch(ctx, pipeline, callOptions[options.ChangeStreamOptions](ctx), opts.changeStreamOptions(token))
+		if err != nil {
+			return nil, &watchDriverError{err: err}
+		}
+		return cs, nil
+	})
+}
+
+// watchDriverError marks a failure reported by the driver while opening or
+// reading a change stream, as opposed to one raised by an interceptor.
+type watchDriverError struct{ err error }
+
+func (e *watchDriverError) Error() string { return e.err.Error() }
+func (e *watchDriverError) Unwrap() error { return e.err }
+
+// watchStream yields the events of one change stream. It reports done when
+// iteration must end: the consumer stopped, or the stream was invalidated.
+func watchStream[T any](ctx context.Context, cs *mongo.ChangeStream, yield func(ChangeEvent[T], error) bool, handled func(ChangeEvent[T]) error) (bool, error) {
+	for cs.Next(ctx) {
+		var ev ChangeEvent[T]
+		if err := cs.Decode(&ev); err != nil {
+			// Skip the undecodable event rather than replaying it forever.
+			ev = ChangeEvent[T]{ResumeToken: cs.ResumeToken()}
+			if !yield(ev, fmt.Errorf("gmqb watch: decode event: %w", err)) {
+				return true, nil
+			}
+		} else if !yield(ev, nil) {
+			return true, nil
+		}
+		if err := handled(ev); err != nil && !yield(ChangeEvent[T]{}, fmt.Errorf("gmqb watch: save checkpoint: %w", err)) {
+			return true, nil
+		}
+		if ev.OperationType == ChangeInvalidate {
+			return true, nil
+		}
+	}
+	if err := cs.Err(); err != nil {
+		return false, &watchDriverError{err: err}
+	}
+	return false, nil
+}
+
+// resumableWatchError reports whether reopening the stream may succeed.
+// Errors raised by interceptors or while building the pipeline are final.
+func resumableWatchError(err error) bool {
+	var de *watchDriverError
+	if !errors.As(err, &de) {
+		return false
+	}
+	var se mongo.ServerError
+	if errors.As(err, &se) {
+		// ChangeStreamFatalError, ChangeStreamHistoryLost, InvalidResumeToken
+		return !se.HasErrorCode(280) && !se.HasErrorCode(286) && !se.HasErrorCode(260)
+	}
+	return true
+}
+
+// changeStreamOptions builds the driver options, resuming after token if set.
+func (o WatchOpts) changeStreamOptions(token bson.Raw) *options.ChangeStreamOptionsBuilder {
+	csOpts := options.ChangeStream()
+	if o.FullDocument != "" {
+		csOpts.SetFullDocument(o.FullDocument)
+	}
+	if o.FullDocumentBeforeChange != "" {
+		csOpts.SetFullDocumentBeforeChange(o.FullDocumentBeforeChange)
+	}
+	if o.BatchSize > 0 {
+		csOpts.SetBatchSize(o.BatchSize)
+	}
+	switch {
+	case token != nil:
+		csOpts.SetResumeAfter(token)
+	case !o.StartAt.IsZero():
+		csOpts.SetStartAtOperationTime(&bson.Timestamp{T: uint32(o.StartAt.Unix())})
+	}
+	return csOpts
+}
+
+// watchPipeline builds the $match stage selecting operations and documents.
+func watchPipeline(o WatchOpts) (mongo.Pipeline, error) {
+	var match bson.D
+	if len(o.Operations) > 0 {
+		ops := make(bson.A, len(o.Operations))
+		for i, op := range o.Operations {
+			ops[i] = string(op)
+		}
+		match = append(match, bson.E{Key: "operationType", Value: bson.D{{Key: "$in", Value: ops}}})
+	}
+	if !o.Filter.IsEmpty() {
+		doc, err := changeEventFilter(o.Filter.BsonD())
+		if err != nil {
+			return nil, err
+		}
+		match = append(match, doc...)
+	}
+	if len(match) == 0 {
+		return mongo.Pipeline{}, nil
+	}
+	return mongo.Pipeline{{{Key: "$match", Value: match}}}, nil
+}
+
+// changeEventFilter rewrites a document filter so it applies to the
+// fullDocument of a change event.
+func changeEventFilter(d bson.D) (bson.D, error) {
+	out := make(bson.D, 0, len(d))
+	for _, e := range d {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			arr, ok := e.Value.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("gmqb watch: %s expects an array", e.Key)
+			}
+			clauses := make(bson.A, len(arr))
+			for i, clause := range arr {
+				cd, ok := clause.(bson.D)
+				if !ok {
+					return nil, fmt.Errorf("gmqb watch: %s expects documents, got %T", e.Key, clause)
+				}
+				rewritten, err := changeEventFilter(cd)
+				if err != nil {
+					return nil, err
+				}
+				clauses[i] = rewritten
+			}
+			out = append(out, bson.E{Key: e.Key, Value: clauses})
+		case "$expr":
+			out = append(out, bson.E{Key: e.Key, Value: changeEventExpr(e.Value)})
+		case "$comment":
+			out = append(out, e)
+		default:
+			if strings.HasPrefix(e.Key, "$") {
+				return nil, fmt.Errorf("%w: %s cannot be applied to change events", ErrUnsupportedOperator, e.Key)
+			}
+			out = append(out, bson.E{Key: "fullDocument." + e.Key, Value: e.Value})
+		}
+	}
+	return out, nil
+}
+
+// changeEventExpr rewrites field paths ("$a.b") and $$ROOT/$$CURRENT in an
+// aggregation expression onto the event's fullDocument.
+func changeEventExpr(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string:
+		switch {
+		case x == "$$ROOT" || x == "$$CURRENT":
+			return "$fullDocument"
+		case strings.HasPrefix(x, "$$ROOT.") || strings.HasPrefix(x, "$$CURRENT."):
+			return "$fullDocument" + x[strings.Index(x, "."):]
+		case strings.HasPrefix(x, "$") && !strings.HasPrefix(x, "$$"):
+			return "$fullDocument." + x[1:]
+		}
+		return x
+	case bson.D:
+		out := make(bson.D, len(x))
+		for i, e := range x {
+			if e.Key == "$literal" {
+				out[i] = e
+				continue
+			}
+			out[i] = bson.E{Key: e.Key, Value: changeEventExpr(e.Value)}
+		}
+		return out
+	case bson.A:
+		out := make(bson.A, len(x))
+		for i, item := range x {
+			out[i] = changeEventExpr(item)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+package gmqb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/squall-chua/gmqb"
+)
+
+func TestWatch_FilteredEvents(t *testing.T) {
+	_, mColl := startReplicaSet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	coll := gmqb.Wrap[User](mColl)
+	events := make(chan gmqb.ChangeEvent[User], 10)
+	go func() {
+		for ev, err := range coll.Watch(ctx, gmqb.WatchOpts{
+			Filter:     gmqb.Eq("country", "US"),
+			Operations: []gmqb.ChangeOp{gmqb.ChangeInsert, gmqb.ChangeUpdate},
+		}) {
+			if err == nil {
+				events <- ev
+			}
+		}
+	}()
+	time.Sleep(500 * time.Millisecond) // let the stream open
+
+	_, err := coll.InsertOne(ctx, &User{Name: "Alice", Country: "US"})
+	require.NoError(t, err)
+	_, err = coll.InsertOne(ctx, &User{Name: "Bob", Country: "UK"})
+	require.NoError(t, err)
+	_, err = coll.UpdateOne(ctx, gmqb.Eq("name", "Alice"), gmqb.NewUpdate().Set("age", 31))
+	require.NoError(t, err)
+
+	ev := <-events
+	assert.Equal(t, gmqb.ChangeInsert, ev.OperationType)
+	assert.Equal(t, "Alice", ev.FullDocument.Name)
+
+	ev = <-events
+	assert.Equal(t, gmqb.ChangeUpdate, ev.OperationType)
+	assert.Equal(t, 31, ev.FullDocument.Age)
+	require.NotNil(t, ev.UpdateDescription)
+	assert.Equal(t, "age", ev.UpdateDescription.UpdatedFields[0].Key)
+}
+
+func TestWatch_ResumesFromCheckpoint(t *testing.T) {
+	_, mColl := startReplicaSet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	coll := gmqb.Wrap[User](mColl)
+	store := gmqb.NewMongoCheckpointStore(mColl.Database().Collection("checkpoints"))
+	opts := gmqb.WatchOpts{Operations: []gmqb.ChangeOp{gmqb.ChangeInsert}, Checkpoint: store}
+
+	// Insert before watching; StartAt reaches back to cover it.
+	_, err := coll.InsertOne(ctx, &User{Name: "first"})
+	require.NoError(t, err)
+	opts.StartAt = time.Now().Add(-time.Minute)
+
+	// Handle the first event, then let the stream time out. Breaking out of
+	// the loop instead would leave the event unacknowledged.
+	firstCtx, firstCancel := context.WithTimeout(ctx, 2*time.Second)
+	var seen []string
+	for ev, err := range coll.Watch(firstCtx, opts) {
+		require.NoError(t, err)
+		seen = append(seen, ev.FullDocument.Name)
+	}
+	firstCancel()
+	assert.Equal(t, []string{"first"}, seen)
+
+	_, err = coll.InsertOne(ctx, &User{Name: "second"})
+	require.NoError(t, err)
+
+	// A new stream resumes after the checkpoint rather than from StartAt.
+	for ev, err := range coll.Watch(ctx, opts) {
+		require.NoError(t, err)
+		assert.Equal(t, "second", ev.FullDocument.Name)
+		break
+	}
+}
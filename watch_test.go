@@ -0,0 +1,122 @@
+package gmqb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/lib/v4/cache"
+	gocachestore "github.com/eko/gocache/store/go_cache/v4"
+	gocache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestWatchPipeline(t *testing.T) {
+	p, err := watchPipeline(WatchOpts{})
+	require.NoError(t, err)
+	assert.Empty(t, p)
+
+	p, err = watchPipeline(WatchOpts{
+		Operations: []ChangeOp{ChangeInsert, ChangeUpdate},
+		Filter: And(
+			Eq("status", "paid"),
+			Or(Gt("total", 100), ElemMatch("items", Eq("sku", "x"))),
+			Expr(ExprGt("$total", "$$ROOT.limit")),
+		),
+	})
+	require.NoError(t, err)
+	require.Len(t, p, 1)
+	assert.Equal(t,
+		`{"$match":{"operationType":{"$in":["insert","update"]},"$and":[{"fullDocument.status":{"$eq":"paid"}},{"$or":[{"fullDocument.total":{"$gt":100}},{"fullDocument.items":{"$elemMatch":{"sku":{"$eq":"x"}}}}]},{"$expr":{"$gt":["$fullDocument.total","$fullDocument.limit"]}}]}}`,
+		compactJSON(t, p[0]))
+
+	_, err = watchPipeline(WatchOpts{Filter: Where("this.a > 1")})
+	assert.True(t, errors.Is(err, ErrUnsupportedOperator))
+}
+
+func compactJSON(t *testing.T, d bson.D) string {
+	t.Helper()
+	b, err := bson.MarshalExtJSON(d, false, false)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestWatchOpts_ChangeStreamOptions(t *testing.T) {
+	resolve := func(b *options.ChangeStreamOptionsBuilder) options.ChangeStreamOptions {
+		var o options.ChangeStreamOptions
+		for _, fn := range b.List() {
+			require.NoError(t, fn(&o))
+		}
+		return o
+	}
+
+	start := time.Unix(1700000000, 0)
+	o := resolve(WatchOpts{StartAt: start, FullDocumentBeforeChange: options.WhenAvailable}.changeStreamOptions(nil))
+	require.NotNil(t, o.StartAtOperationTime)
+	assert.Equal(t, uint32(1700000000), o.StartAtOperationTime.T)
+	assert.Equal(t, options.WhenAvailable, *o.FullDocumentBeforeChange)
+
+	// A resume token wins over StartAt.
+	token, err := bson.Marshal(bson.D{{Key: "_data", Value: "82"}})
+	require.NoError(t, err)
+	o = resolve(WatchOpts{StartAt: start}.changeStreamOptions(token))
+	assert.Nil(t, o.StartAtOperationTime)
+	assert.Equal(t, bson.Raw(token), o.ResumeAfter)
+}
+
+func TestChangeEvent_Decode(t *testing.T) {
+	type Order struct {
+		Status string `bson:"status"`
+	}
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: bson.D{{Key: "_data", Value: "8265"}}},
+		{Key: "operationType", Value: "update"},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "shop"}, {Key: "coll", Value: "orders"}}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: 7}}},
+		{Key: "fullDocument", Value: bson.D{{Key: "status", Value: "paid"}}},
+		{Key: "fullDocumentBeforeChange", Value: nil},
+		{Key: "updateDescription", Value: bson.D{
+			{Key: "updatedFields", Value: bson.D{{Key: "status", Value: "paid"}}},
+			{Key: "removedFields", Value: bson.A{"note"}},
+		}},
+	})
+	require.NoError(t, err)
+
+	var ev ChangeEvent[Order]
+	require.NoError(t, bson.Unmarshal(raw, &ev))
+	assert.Equal(t, ChangeUpdate, ev.OperationType)
+	assert.Equal(t, ChangeNamespace{DB: "shop", Coll: "orders"}, ev.Namespace)
+	assert.Equal(t, "paid", ev.FullDocument.Status)
+	assert.Nil(t, ev.FullDocumentBeforeChange)
+	assert.Equal(t, []string{"note"}, ev.UpdateDescription.RemovedFields)
+	assert.NotEmpty(t, ev.ResumeToken)
+}
+
+func TestResumableWatchError(t *testing.T) {
+	assert.True(t, resumableWatchError(&watchDriverError{errors.New("connection reset")}))
+	assert.True(t, resumableWatchError(&watchDriverError{mongo.CommandError{Code: 91}}))
+	assert.False(t, resumableWatchError(&watchDriverError{mongo.CommandError{Code: 286}}))
+	// Errors raised by interceptors are not retried.
+	assert.False(t, resumableWatchError(errors.New("denied")))
+}
+
+func TestCacheCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewCacheCheckpointStore(cache.New[[]byte](gocachestore.NewGoCache(gocache.New(time.Minute, time.Minute))))
+
+	token, err := s.LoadCheckpoint(ctx, "k")
+	require.NoError(t, err)
+	assert.Nil(t, token)
+
+	want, err := bson.Marshal(bson.D{{Key: "_data", Value: "8265"}})
+	require.NoError(t, err)
+	require.NoError(t, s.SaveCheckpoint(ctx, "k", want))
+	token, err = s.LoadCheckpoint(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, bson.Raw(want), token)
+}
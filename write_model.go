@@ -1,6 +1,9 @@
 package gmqb
 
 import (
+	"reflect"
+	"time"
+
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
@@ -9,9 +12,19 @@ type WriteModel[T any] interface {
 	MongoWriteModel() mongo.WriteModel
 }
 
+// mongoWriteModel converts m to a driver write model, stamping its timestamp
+// fields when T declares any.
+func mongoWriteModel[T any](m WriteModel[T], ts *timestampFields, now time.Time) (mongo.WriteModel, error) {
+	if tm, ok := m.(timestampedModel); ok && ts != nil {
+		return tm.timestampedWriteModel(ts, now)
+	}
+	return m.MongoWriteModel(), nil
+}
+
 // InsertOneModel is a type-safe wrapper for mongo.InsertOneModel.
 type InsertOneModel[T any] struct {
 	model *mongo.InsertOneModel
+	doc   *T
 }
 
 // NewInsertOneModel creates a new InsertOneModel.
@@ -24,6 +37,7 @@ func NewInsertOneModel[T any]() *InsertOneModel[T] {
 // SetDocument sets the document to insert.
 func (m *InsertOneModel[T]) SetDocument(doc *T) *InsertOneModel[T] {
 	m.model.SetDocument(doc)
+	m.doc = doc
 	return m
 }
 
@@ -32,9 +46,16 @@ func (m *InsertOneModel[T]) MongoWriteModel() mongo.WriteModel {
 	return m.model
 }
 
+// timestampedWriteModel sets the unset timestamp fields of the document.
+func (m *InsertOneModel[T]) timestampedWriteModel(ts *timestampFields, now time.Time) (mongo.WriteModel, error) {
+	ts.stampInsert(reflect.ValueOf(m.doc), now)
+	return m.model, nil
+}
+
 // ReplaceOneModel is a type-safe wrapper for mongo.ReplaceOneModel.
 type ReplaceOneModel[T any] struct {
-	model *mongo.ReplaceOneModel
+	model       *mongo.ReplaceOneModel
+	replacement *T
 }
 
 // NewReplaceOneModel creates a new ReplaceOneModel.
@@ -53,6 +74,7 @@ func (m *ReplaceOneModel[T]) SetFilter(filter Filter) *ReplaceOneModel[T] {
 // SetReplacement sets the replacement document.
 func (m *ReplaceOneModel[T]) SetReplacement(rep *T) *ReplaceOneModel[T] {
 	m.model.SetReplacement(rep)
+	m.replacement = rep
 	return m
 }
 
@@ -67,9 +89,28 @@ func (m *ReplaceOneModel[T]) MongoWriteModel() mongo.WriteModel {
 	return m.model
 }
 
+// timestampedWriteModel sets updatedAt on the replacement, turning the
+// model into a pipeline update that keeps the stored createdAt if the
+// replacement has none.
+func (m *ReplaceOneModel[T]) timestampedWriteModel(ts *timestampFields, now time.Time) (mongo.WriteModel, error) {
+	replacement, asUpdate, err := ts.stampReplace(m.replacement, now)
+	if err != nil || !asUpdate {
+		return m.model, err
+	}
+	return &mongo.UpdateOneModel{
+		Collation: m.model.Collation,
+		Upsert:    m.model.Upsert,
+		Filter:    m.model.Filter,
+		Update:    replacement,
+		Hint:      m.model.Hint,
+		Sort:      m.model.Sort,
+	}, nil
+}
+
 // UpdateOneModel is a type-safe wrapper for mongo.UpdateOneModel.
 type UpdateOneModel[T any] struct {
-	model *mongo.UpdateOneModel
+	model  *mongo.UpdateOneModel
+	update Updater
 }
 
 // NewUpdateOneModel creates a new UpdateOneModel.
@@ -88,6 +129,7 @@ func (m *UpdateOneModel[T]) SetFilter(filter Filter) *UpdateOneModel[T] {
 // SetUpdate sets the update operations.
 func (m *UpdateOneModel[T]) SetUpdate(update Updater) *UpdateOneModel[T] {
 	m.model.SetUpdate(update.BsonD())
+	m.update = update
 	return m
 }
 
@@ -102,9 +144,18 @@ func (m *UpdateOneModel[T]) MongoWriteModel() mongo.WriteModel {
 	return m.model
 }
 
+// timestampedWriteModel returns a copy of the model whose update also sets
+// the timestamp fields.
+func (m *UpdateOneModel[T]) timestampedWriteModel(ts *timestampFields, now time.Time) (mongo.WriteModel, error) {
+	stamped := *m.model
+	stamped.Update = ts.stampUpdate(m.update, isUpsert(m.model.Upsert), now).updatePayload()
+	return &stamped, nil
+}
+
 // UpdateManyModel is a type-safe wrapper for mongo.UpdateManyModel.
 type UpdateManyModel[T any] struct {
-	model *mongo.UpdateManyModel
+	model  *mongo.UpdateManyModel
+	update Updater
 }
 
 // NewUpdateManyModel creates a new UpdateManyModel.
@@ -123,6 +174,7 @@ func (m *UpdateManyModel[T]) SetFilter(filter Filter) *UpdateManyModel[T] {
 // SetUpdate sets the update operations.
 func (m *UpdateManyModel[T]) SetUpdate(update Updater) *UpdateManyModel[T] {
 	m.model.SetUpdate(update.BsonD())
+	m.update = update
 	return m
 }
 
@@ -137,6 +189,14 @@ func (m *UpdateManyModel[T]) MongoWriteModel() mongo.WriteModel {
 	return m.model
 }
 
+// timestampedWriteModel returns a copy of the model whose update also sets
+// the timestamp fields.
+func (m *UpdateManyModel[T]) timestampedWriteModel(ts *timestampFields, now time.Time) (mongo.WriteModel, error) {
+	stamped := *m.model
+	stamped.Update = ts.stampUpdate(m.update, isUpsert(m.model.Upsert), now).updatePayload()
+	return &stamped, nil
+}
+
 // DeleteOneModel is a type-safe wrapper for mongo.DeleteOneModel.
 type DeleteOneModel[T any] struct {
 	model *mongo.DeleteOneModel